@@ -3,17 +3,27 @@ package main
 import (
 	"context"
 	"database/sql"
+	"flag"
 	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"slices"
 	"syscall"
 	"time"
 	"github.com/ec332/aegis/market/internal/api"
+	"github.com/ec332/aegis/market/internal/health"
+	"github.com/ec332/aegis/market/internal/logging"
+	"github.com/ec332/aegis/market/internal/metrics"
 	"github.com/ec332/aegis/market/internal/middleware"
+	"github.com/ec332/aegis/market/internal/oracle"
+	"github.com/ec332/aegis/market/internal/ratelimit"
 	"github.com/ec332/aegis/market/internal/repository"
 	"github.com/ec332/aegis/market/internal/service"
+	"github.com/ec332/aegis/market/internal/tracing"
+	"github.com/ec332/aegis/market/internal/txservice"
 	"github.com/ec332/aegis/market/pkg/config"
 	"github.com/go-chi/chi/v5"
 	chimiddleware "github.com/go-chi/chi/v5/middleware"
@@ -23,50 +33,178 @@ import (
 )
 
 func main() {
+	configPath := flag.String("config", os.Getenv("CONFIG_FILE"), "path to a YAML config file")
+	flag.Parse()
+
 	// Load configuration
-	cfg, err := config.Load()
+	cfg, err := config.Load(*configPath)
 	if err != nil {
 		log.Fatalf("Failed to load config: %v", err)
 	}
-	log.Println("Configuration loaded")
+	configs := config.NewManager(*configPath, cfg)
+
+	// Initialize logger
+	var logLevel slog.LevelVar
+	level, _ := logging.ParseLevel(cfg.Log.Level)
+	logLevel.Set(level)
+	logger := logging.New(&logLevel)
+	slog.SetDefault(logger)
+	logger.Info("configuration loaded", "file", *configPath)
+
+	// Initialize tracing
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing.Exporter, cfg.Tracing.ServiceName)
+	if err != nil {
+		fatal(logger, "failed to initialize tracing", err)
+	}
+	defer shutdownTracing(context.Background())
+	logger.Info("tracing initialized", "exporter", cfg.Tracing.Exporter)
 
 	// Initialize database
-	db, err := sql.Open("postgres", cfg.DatabaseURL)
+	db, err := sql.Open("postgres", cfg.Database.URL)
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		fatal(logger, "failed to connect to database", err)
 	}
 	defer db.Close()
+	db.SetMaxOpenConns(cfg.Database.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.Database.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.Database.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.Database.ConnMaxIdleTime)
 	// Test database connection
 	if err := db.Ping(); err != nil {
-		log.Fatalf("Failed to ping database: %v", err)
+		fatal(logger, "failed to ping database", err)
 	}
-	log.Println("PostgreSQL connected")
+	logger.Info("PostgreSQL connected")
 
 	// Initialize schema
 	repo := repository.New(db)
 	ctx := context.Background()
 	if err := repo.InitSchema(ctx); err != nil {
-		log.Fatalf("Failed to initialize schema: %v", err)
+		fatal(logger, "failed to initialize schema", err)
 	}
-	log.Println("Database schema initialized")
+	logger.Info("database schema initialized")
+
+	// Register database metrics
+	metrics.RegisterDBStats(db)
+	metrics.RegisterBusinessStats(repo, 5*time.Second)
 
 	// Initialize Redis client
-	redisOpts, err := redis.ParseURL(cfg.RedisURL)
+	redisOpts, err := redis.ParseURL(cfg.Redis.URL)
 	if err != nil {
-		log.Fatalf("Failed to parse Redis URL: %v", err)
+		fatal(logger, "failed to parse Redis URL", err)
 	}
 	redisClient := redis.NewClient(redisOpts)
 	defer redisClient.Close()
+	tracing.InstrumentRedis(redisClient)
 
 	// Test Redis connection
 	if err := redisClient.Ping(ctx).Err(); err != nil {
-		log.Fatalf("Failed to connect to Redis: %v", err)
+		fatal(logger, "failed to connect to Redis", err)
+	}
+	logger.Info("Redis connected")
+
+	// Health checks
+	checker := health.New()
+	checker.AddReadinessCheck("postgres", cfg.Health.CheckTimeout, db.PingContext)
+	checker.AddReadinessCheck("redis", cfg.Health.CheckTimeout, func(ctx context.Context) error {
+		return redisClient.Ping(ctx).Err()
+	})
+	checker.AddReadinessCheck("migrations", cfg.Health.CheckTimeout, func(ctx context.Context) error {
+		version, err := repo.CurrentSchemaVersion(ctx)
+		if err != nil {
+			return err
+		}
+		if version < repository.SchemaVersion() {
+			return fmt.Errorf("schema version %d, expected %d", version, repository.SchemaVersion())
+		}
+		return nil
+	})
+
+	// Register oracle resolvers
+	resolvers := oracle.NewRegistry()
+	resolvers.Register(oracle.ResolverManual, oracle.Manual{})
+	resolvers.Register(oracle.ResolverHTTPJSON, oracle.NewHTTPJSON(cfg.Oracle.HTTPTimeout, cfg.Oracle.MaxResponseBytes,
+		cfg.Oracle.AllowedHosts, cfg.Oracle.AllowPrivate))
+
+	// Record trades with the transaction service when it is configured
+	var transactions *txservice.Client
+	if cfg.Transactions.URL != "" {
+		transactions = txservice.New(txservice.Config{
+			URL:             cfg.Transactions.URL,
+			Timeout:         cfg.Transactions.Timeout,
+			Retries:         cfg.Transactions.Retries,
+			RetryBackoff:    cfg.Transactions.RetryBackoff,
+			BreakerFailures: cfg.Transactions.BreakerFailures,
+			BreakerCooldown: cfg.Transactions.BreakerCooldown,
+		})
 	}
-	log.Println("Redis connected")
 
 	// Initialize service
-	svc := service.New(repo, redisClient)
-	log.Println("Service initialized")
+	svc := service.New(repo, redisClient, logger, service.Config{
+		RequiredApprovals: cfg.Markets.RequiredApprovals,
+		DisputeWindow:     cfg.Markets.DisputeWindow,
+		DisputeBond:       cfg.Markets.DisputeBond,
+		Resolvers:         resolvers,
+		MaxOpenBuyValue:   cfg.Markets.MaxOpenBuyValue,
+		DefaultFeeRate:    cfg.Markets.DefaultFeeRate,
+		DefaultMinFee:     cfg.Markets.DefaultMinFee,
+		FeeSplit: service.FeeSplit{
+			Creator:            cfg.Markets.FeeSplit.Creator,
+			LiquidityProviders: cfg.Markets.FeeSplit.LiquidityProviders,
+		},
+		Limits: service.Limits{
+			MaxPosition:         cfg.Markets.Limits.MaxPosition,
+			MaxTradeShares:      cfg.Markets.Limits.MaxTradeShares,
+			MaxTreasuryExposure: cfg.Markets.Limits.MaxTreasuryExposure,
+		},
+		CircuitBreaker: service.CircuitBreaker{
+			PriceMove: cfg.Markets.CircuitBreaker.PriceMove,
+			Window:    cfg.Markets.CircuitBreaker.Window,
+			Cooldown:  cfg.Markets.CircuitBreaker.Cooldown,
+		},
+		Transactions: transactions,
+	})
+	logger.Info("service initialized", "resolvers", resolvers.Names())
+
+	// Move due markets through resolution in the background
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	schedulerBeat := checker.RegisterWorker("scheduler", 3*cfg.Markets.SchedulerInterval)
+	go svc.RunScheduler(workerCtx, cfg.Markets.SchedulerInterval, schedulerBeat)
+	if transactions != nil {
+		// A pass ends at the first request that still fails after its retries
+		tx := cfg.Transactions
+		maxPass := time.Duration(tx.Retries+1)*tx.Timeout + tx.RetryBackoff<<tx.Retries
+		recorderBeat := checker.RegisterWorker("trade-recorder", 3*tx.RecordInterval+maxPass)
+		go svc.RunTradeRecorder(workerCtx, tx.RecordInterval, recorderBeat)
+		logger.Info("recording trades with the transaction service", "url", tx.URL)
+		if tx.ReconcileInterval > 0 {
+			reconcilerBeat := checker.RegisterWorker("reconciler", 3*tx.ReconcileInterval+maxPass)
+			go svc.RunReconciler(workerCtx, tx.ReconcileInterval, reconcilerBeat)
+		}
+	}
+	if in := cfg.Ingest; in.Stream != "" {
+		ingest := service.Ingest{
+			Stream:           in.Stream,
+			Group:            in.Group,
+			Consumer:         in.Consumer,
+			DeadLetterStream: in.DeadLetterStream,
+			BatchSize:        in.BatchSize,
+			Block:            in.Block,
+			MaxDeliveries:    in.MaxDeliveries,
+			ClaimIdle:        in.ClaimIdle,
+		}
+		if ingest.Consumer == "" {
+			if ingest.Consumer, err = os.Hostname(); err != nil {
+				fatal(logger, "failed to name the ingest consumer; set INGEST_CONSUMER", err)
+			}
+		}
+		if ingest.DeadLetterStream == "" {
+			ingest.DeadLetterStream = in.Stream + ":dead"
+		}
+		ingesterBeat := checker.RegisterWorker("trade-ingester", 3*in.Block+cfg.Health.CheckTimeout)
+		go svc.RunTradeIngester(workerCtx, ingest, ingesterBeat)
+		logger.Info("consuming trade events", "stream", in.Stream, "group", in.Group, "consumer", ingest.Consumer)
+	}
 
 	// Setup router
 	r := chi.NewRouter()
@@ -74,18 +212,23 @@ func main() {
 	// Middleware
 	r.Use(chimiddleware.RequestID)
 	r.Use(chimiddleware.RealIP)
-	r.Use(middleware.Logging)
-	r.Use(middleware.Recovery)
-	r.Use(chimiddleware.Timeout(10 * time.Second))
+	r.Use(middleware.Authenticate(cfg.Auth.ServiceKeys))
+	r.Use(middleware.Tracing)
+	r.Use(middleware.Logging(logger, func() float64 { return configs.Current().Log.SampleRate }))
+	r.Use(middleware.Metrics)
+	r.Use(middleware.Recovery(logger))
 
-	// CORS
+	// CORS (allowed origins are re-read on every request so SIGHUP reloads apply)
 	r.Use(cors.Handler(cors.Options{
-		AllowedOrigins:   []string{"*"},
+		AllowOriginFunc: func(r *http.Request, origin string) bool {
+			origins := configs.Current().CORS.AllowedOrigins
+			return slices.Contains(origins, "*") || slices.Contains(origins, origin)
+		},
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-User-ID", "X-Service-Key"},
-		ExposedHeaders:   []string{"Link"},
-		AllowCredentials: true,
-		MaxAge:           300,
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-User-ID", "X-User-Roles", "X-Service-Key"},
+		ExposedHeaders:   []string{"Link", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "RateLimit-Policy", "Retry-After"},
+		AllowCredentials: cfg.CORS.AllowCredentials,
+		MaxAge:           cfg.CORS.MaxAge,
 	}))
 
 	// Health check
@@ -93,42 +236,146 @@ func main() {
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("OK"))
 	})
+	r.Get("/livez", checker.LivenessHandler())
+	r.Get("/readyz", checker.ReadinessHandler())
 
-	// API routes
-	r.Post("/markets", api.CreateMarket(svc))
-	r.Get("/markets", api.ListMarkets(svc))
-	r.Get("/markets/{marketId}", api.GetMarket(svc))
-	r.Put("/markets/{marketId}", api.UpdateMarket(svc))
-	r.Get("/markets/{marketId}/stream", api.StreamLiquidityUpdates(svc))
+	// Prometheus metrics
+	r.Handle("/metrics", metrics.Handler())
+
+	// Rate limiting
+	limits := ratelimit.NewMiddleware(
+		ratelimit.NewLimiter(redisClient),
+		rateLimitPolicies(cfg.RateLimit),
+		cfg.RateLimit.Enabled,
+		logger,
+	)
+	// Stream slots outlive a crashed replica by at most a few ping intervals
+	streamSlotTTL := 3 * cfg.SSE.PingInterval
+
+	// API routes. All but the event stream, which stays open, end at the request timeout.
+	r.Group(func(r chi.Router) {
+		r.Use(chimiddleware.Timeout(cfg.Server.RequestTimeout))
+
+		r.With(limits.Limit("markets.create")).Post("/markets", api.CreateMarket(svc))
+		r.With(limits.Limit("markets.read")).Get("/markets", api.ListMarkets(svc))
+		r.With(limits.Limit("markets.read")).Get("/markets/facets", api.GetMarketFacets(svc))
+		r.With(limits.Limit("markets.read")).Get("/markets/{marketId}", api.GetMarket(svc))
+		r.With(limits.Limit("markets.update")).Put("/markets/{marketId}", api.UpdateMarket(svc))
+		r.With(limits.Limit("markets.read")).Get("/markets/{marketId}/revisions", api.ListMarketRevisions(svc))
+		r.With(limits.Limit("markets.update")).Post("/markets/{marketId}/submit", api.SubmitMarket(svc))
+		r.With(limits.Limit("markets.update")).Post("/markets/{marketId}/halt", api.HaltMarket(svc))
+		r.With(limits.Limit("markets.update")).Post("/markets/{marketId}/resume", api.ResumeMarket(svc))
+		r.With(limits.Limit("markets.read")).Get("/markets/{marketId}/halts", api.ListMarketHalts(svc))
+		r.With(limits.Limit("markets.update")).Post("/markets/{marketId}/reviews", api.CreateReview(svc))
+		r.With(limits.Limit("markets.read")).Get("/markets/{marketId}/reviews", api.ListMarketReviews(svc))
+		r.With(limits.Limit("markets.read")).Get("/markets/{marketId}/resolution", api.ListResolutionProposals(svc))
+		r.With(limits.Limit("markets.update")).Post("/markets/{marketId}/resolution/proposals", api.ProposeResolution(svc))
+		r.With(limits.Limit("markets.update")).Post("/markets/{marketId}/resolution/disputes", api.DisputeResolution(svc))
+		r.With(limits.Limit("markets.update")).Post("/markets/{marketId}/resolution/arbitration", api.ArbitrateResolution(svc))
+		r.With(limits.Limit("markets.update")).Post("/markets/{marketId}/resolution/finalize", api.FinalizeResolution(svc))
+		r.With(limits.Limit("markets.update")).Post("/markets/{marketId}/options", api.CreateOption(svc))
+		r.With(limits.Limit("markets.update")).Put("/markets/{marketId}/options/order", api.ReorderOptions(svc))
+		r.With(limits.Limit("markets.update")).Put("/markets/{marketId}/options/{optionId}", api.UpdateOption(svc))
+		r.With(limits.Limit("markets.update")).Delete("/markets/{marketId}/options/{optionId}", api.DeleteOption(svc))
+		r.With(limits.Limit("markets.trade")).Post("/markets/{marketId}/orders", api.PlaceOrder(svc))
+		r.With(limits.Limit("markets.read")).Get("/markets/{marketId}/orders", api.ListOrders(svc))
+		r.With(limits.Limit("markets.trade")).Delete("/markets/{marketId}/orders/{orderId}", api.CancelOrder(svc))
+		r.With(limits.Limit("markets.trade")).Post("/markets/{marketId}/sets/mint", api.MintCompleteSet(svc))
+		r.With(limits.Limit("markets.trade")).Post("/markets/{marketId}/sets/redeem", api.RedeemCompleteSet(svc))
+		r.With(limits.Limit("markets.read")).Get("/markets/{marketId}/book", api.GetOrderBook(svc))
+		r.With(limits.Limit("markets.read")).Get("/markets/{marketId}/quote", api.QuoteOrder(svc))
+		r.With(limits.Limit("markets.read")).Get("/markets/{marketId}/fees", api.GetMarketFees(svc))
+		r.With(limits.Limit("markets.read")).Get("/markets/{marketId}/liquidity", api.GetMarketLiquidity(svc))
+		r.With(limits.Limit("markets.trade")).Post("/markets/{marketId}/liquidity/add", api.AddLiquidity(svc))
+		r.With(limits.Limit("markets.trade")).Post("/markets/{marketId}/liquidity/remove", api.RemoveLiquidity(svc))
+
+		r.With(limits.Limit("categories.write")).Post("/categories", api.CreateCategory(svc))
+		r.With(limits.Limit("markets.read")).Get("/categories", api.ListCategories(svc))
+		r.With(limits.Limit("markets.read")).Get("/categories/{categoryId}", api.GetCategory(svc))
+		r.With(limits.Limit("categories.write")).Put("/categories/{categoryId}", api.UpdateCategory(svc))
+		r.With(limits.Limit("categories.write")).Delete("/categories/{categoryId}", api.DeleteCategory(svc))
+
+		r.With(limits.Limit("markets.read")).Get("/moderation/queue", api.GetReviewQueue(svc))
+		r.With(limits.Limit("markets.read")).Get("/risk/exposures", api.GetExposureReport(svc))
+
+		r.With(limits.Limit("markets.read")).Get("/positions", api.ListPositions(svc))
+		r.With(limits.Limit("markets.read")).Get("/positions/liquidity", api.ListLiquidityPositions(svc))
+	})
+
+	r.With(
+		limits.Limit("markets.stream"),
+		limits.Concurrent("sse", cfg.RateLimit.MaxStreamsPerClient, streamSlotTTL),
+	).Get("/markets/{marketId}/stream", api.StreamMarketEvents(svc, func() time.Duration {
+		return configs.Current().SSE.PingInterval
+	}))
 
 	// Start server
-	addr := fmt.Sprintf(":%s", cfg.Port)
+	addr := fmt.Sprintf(":%s", cfg.Server.Port)
 	srv := &http.Server{
 		Addr:         addr,
 		Handler:      r,
-		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 15 * time.Second,
-		IdleTimeout:  60 * time.Second,
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
+		IdleTimeout:  cfg.Server.IdleTimeout,
 	}
 
+	// Reload non-critical settings on SIGHUP
+	configs.OnReload(func(c *config.Config) {
+		level, _ := logging.ParseLevel(c.Log.Level)
+		logLevel.Set(level)
+	})
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			ignored, err := configs.Reload()
+			if err != nil {
+				logger.Error("failed to reload configuration", "error", err)
+				continue
+			}
+			logger.Info("configuration reloaded", "ignored_until_restart", ignored)
+		}
+	}()
+
 	// Graceful shutdown
 	go func() {
-		log.Printf("Market service starting on %s", addr)
+		logger.Info("market service starting", "addr", addr)
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Server error: %v", err)
+			fatal(logger, "server error", err)
 		}
 	}()
 
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
-	log.Println("Shutting down server...")
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	logger.Info("shutting down server")
+
+	// Fail readiness first so load balancers drain traffic before connections close
+	checker.SetDraining()
+	stopWorkers()
+	time.Sleep(cfg.Server.DrainDelay)
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
 	defer cancel()
 
 	if err := srv.Shutdown(ctx); err != nil {
-		log.Fatalf("Server forced to shutdown: %v", err)
+		fatal(logger, "server forced to shutdown", err)
 	}
 
-	log.Println("Server exited")
+	logger.Info("server exited")
+}
+
+// rateLimitPolicies converts configured policies into limiter policies keyed by name
+func rateLimitPolicies(cfg config.RateLimitConfig) map[string]ratelimit.Policy {
+	policies := make(map[string]ratelimit.Policy, len(cfg.Policies))
+	for name, p := range cfg.Policies {
+		policies[name] = ratelimit.Policy{Name: name, Rate: p.Rate, Period: p.Period, Burst: p.Burst}
+	}
+	return policies
+}
+
+// fatal logs err and terminates the process
+func fatal(logger *slog.Logger, msg string, err error) {
+	logger.Error(msg, "error", err)
+	os.Exit(1)
 }
@@ -0,0 +1,121 @@
+// Command oraclestub serves JSON feeds for testing oracle resolvers locally.
+//
+// Feeds are set with PUT /feeds/{name} and read with GET /feeds/{name}, so a market's
+// resolution spec can point at http://localhost:8090/feeds/{name}. Files in -dir named
+// {name}.json are loaded as feeds at startup.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"github.com/go-chi/chi/v5"
+)
+
+const maxFeedBytes = 1 << 20
+
+// feeds holds the served documents by name
+type feeds struct {
+	mu   sync.RWMutex
+	docs map[string][]byte
+}
+
+func main() {
+	addr := flag.String("addr", ":8090", "listen address")
+	dir := flag.String("dir", "", "directory of {name}.json feeds to load at startup")
+	flag.Parse()
+
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	f := &feeds{docs: map[string][]byte{}}
+	if *dir != "" {
+		if err := f.load(*dir); err != nil {
+			logger.Error("failed to load feeds", "dir", *dir, "error", err)
+			os.Exit(1)
+		}
+	}
+
+	r := chi.NewRouter()
+	r.Get("/feeds", f.list)
+	r.Get("/feeds/{name}", f.get)
+	r.Put("/feeds/{name}", f.put)
+	r.Delete("/feeds/{name}", f.delete)
+
+	logger.Info("oracle stub listening", "addr", *addr, "feeds", len(f.docs))
+	if err := http.ListenAndServe(*addr, r); err != nil {
+		logger.Error("server error", "error", err)
+		os.Exit(1)
+	}
+}
+
+// load reads every {name}.json file in dir
+func (f *feeds) load(dir string) error {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		doc, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if !json.Valid(doc) {
+			return fmt.Errorf("%s is not valid JSON", path)
+		}
+		f.docs[strings.TrimSuffix(filepath.Base(path), ".json")] = doc
+	}
+	return nil
+}
+
+func (f *feeds) list(w http.ResponseWriter, r *http.Request) {
+	f.mu.RLock()
+	names := make([]string, 0, len(f.docs))
+	for name := range f.docs {
+		names = append(names, name)
+	}
+	f.mu.RUnlock()
+	sort.Strings(names)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string][]string{"feeds": names})
+}
+
+func (f *feeds) get(w http.ResponseWriter, r *http.Request) {
+	f.mu.RLock()
+	doc, ok := f.docs[chi.URLParam(r, "name")]
+	f.mu.RUnlock()
+	if !ok {
+		http.Error(w, "feed not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(doc)
+}
+
+func (f *feeds) put(w http.ResponseWriter, r *http.Request) {
+	doc, err := io.ReadAll(io.LimitReader(r.Body, maxFeedBytes))
+	if err != nil || !json.Valid(doc) {
+		http.Error(w, "body must be a JSON document", http.StatusBadRequest)
+		return
+	}
+
+	f.mu.Lock()
+	f.docs[chi.URLParam(r, "name")] = doc
+	f.mu.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (f *feeds) delete(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	delete(f.docs, chi.URLParam(r, "name"))
+	f.mu.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
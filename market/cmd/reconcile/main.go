@@ -0,0 +1,107 @@
+// Command reconcile compares markets' pools and positions with the trades the
+// transaction service recorded and prints the discrepancies as JSON.
+//
+// It reads the market service's configuration, so TX_SERVICE_URL must be set.
+// -market limits the check to one market; -repair corrects the discrepancies it
+// can. It exits 1 on failure and 2 when discrepancies are left unrepaired.
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"log/slog"
+	"os"
+	"github.com/ec332/aegis/market/internal/logging"
+	"github.com/ec332/aegis/market/internal/repository"
+	"github.com/ec332/aegis/market/internal/service"
+	"github.com/ec332/aegis/market/internal/txservice"
+	"github.com/ec332/aegis/market/pkg/config"
+	"github.com/ec332/aegis/market/pkg/models"
+	_ "github.com/lib/pq"
+	"github.com/redis/go-redis/v9"
+)
+
+func main() {
+	configPath := flag.String("config", os.Getenv("CONFIG_FILE"), "path to a YAML config file")
+	marketID := flag.String("market", "", "reconcile only this market")
+	repair := flag.Bool("repair", false, "correct pools and positions to the replayed values")
+	flag.Parse()
+
+	// Logs go to stderr so the report alone is on stdout
+	var logLevel slog.LevelVar
+	logger := slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: &logLevel}))
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		fatal(logger, "failed to load config", err)
+	}
+	level, _ := logging.ParseLevel(cfg.Log.Level)
+	logLevel.Set(level)
+	if cfg.Transactions.URL == "" {
+		logger.Error("transaction service not configured; set TX_SERVICE_URL")
+		os.Exit(1)
+	}
+
+	db, err := sql.Open("postgres", cfg.Database.URL)
+	if err != nil {
+		fatal(logger, "failed to connect to database", err)
+	}
+	defer db.Close()
+
+	redisOpts, err := redis.ParseURL(cfg.Redis.URL)
+	if err != nil {
+		fatal(logger, "failed to parse Redis URL", err)
+	}
+	redisClient := redis.NewClient(redisOpts)
+	defer redisClient.Close()
+
+	svc := service.New(repository.New(db), redisClient, logger, service.Config{
+		Transactions: txservice.New(txservice.Config{
+			URL:             cfg.Transactions.URL,
+			Timeout:         cfg.Transactions.Timeout,
+			Retries:         cfg.Transactions.Retries,
+			RetryBackoff:    cfg.Transactions.RetryBackoff,
+			BreakerFailures: cfg.Transactions.BreakerFailures,
+			BreakerCooldown: cfg.Transactions.BreakerCooldown,
+		}),
+	})
+
+	report, err := svc.Reconcile(context.Background(), *marketID, *repair)
+	if err != nil {
+		fatal(logger, "failed to reconcile", err)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		fatal(logger, "failed to write report", err)
+	}
+	if unrepaired(report) > 0 {
+		os.Exit(2)
+	}
+}
+
+// unrepaired counts the discrepancies left in place
+func unrepaired(report *models.ReconciliationReport) int {
+	n := 0
+	for _, m := range report.Markets {
+		for _, p := range m.Pools {
+			if !p.Repaired {
+				n++
+			}
+		}
+		for _, p := range m.Positions {
+			if !p.Repaired {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+func fatal(logger *slog.Logger, msg string, err error) {
+	logger.Error(msg, "error", err)
+	os.Exit(1)
+}
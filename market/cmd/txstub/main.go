@@ -0,0 +1,50 @@
+// Command txstub serves an in-memory stand-in for the transaction service, for
+// testing trade recording locally.
+//
+// It speaks the service's /transactions API on the same port (5555), with numbers
+// and times encoded as strings. -fail-rate answers that fraction of requests with
+// 503 and -latency delays every response, to exercise client retries and the
+// circuit breaker.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"os"
+	"time"
+	"github.com/ec332/aegis/market/internal/txservice/txstub"
+	"github.com/go-chi/chi/v5"
+)
+
+func main() {
+	addr := flag.String("addr", ":5555", "listen address")
+	failRate := flag.Float64("fail-rate", 0, "fraction of requests answered with 503")
+	latency := flag.Duration("latency", 0, "delay before every response")
+	flag.Parse()
+
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+	r := chi.NewRouter()
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(*latency)
+			if rand.Float64() < *failRate {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusServiceUnavailable)
+				json.NewEncoder(w).Encode(map[string]string{"error": "injected failure"})
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+	txstub.New().Routes(r)
+
+	logger.Info("transaction stub listening", "addr", *addr, "fail_rate", *failRate, "latency", *latency)
+	if err := http.ListenAndServe(*addr, r); err != nil {
+		logger.Error("server error", "error", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"github.com/ec332/aegis/market/internal/service"
+	"github.com/ec332/aegis/market/pkg/models"
+	"github.com/go-chi/chi/v5"
+)
+
+// CreateCategory handles POST /categories
+func CreateCategory(svc *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req models.CreateCategoryRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondError(w, http.StatusBadRequest, models.ErrorCodeInvalidBody, "Invalid request body", err.Error())
+			return
+		}
+
+		category, err := svc.CreateCategory(r.Context(), req)
+		if err != nil {
+			respondServiceError(w, r, err)
+			return
+		}
+
+		respondJSON(w, http.StatusCreated, category)
+	}
+}
+
+// ListCategories handles GET /categories
+func ListCategories(svc *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		categories, err := svc.ListCategories(r.Context())
+		if err != nil {
+			respondServiceError(w, r, err)
+			return
+		}
+
+		respondJSON(w, http.StatusOK, models.CategoryListResponse{
+			Categories: categories,
+			Total:      len(categories),
+		})
+	}
+}
+
+// GetCategory handles GET /categories/:categoryId
+func GetCategory(svc *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		category, err := svc.GetCategory(r.Context(), chi.URLParam(r, "categoryId"))
+		if err != nil {
+			respondServiceError(w, r, err)
+			return
+		}
+
+		respondJSON(w, http.StatusOK, category)
+	}
+}
+
+// UpdateCategory handles PUT /categories/:categoryId
+func UpdateCategory(svc *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req models.UpdateCategoryRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondError(w, http.StatusBadRequest, models.ErrorCodeInvalidBody, "Invalid request body", err.Error())
+			return
+		}
+
+		category, err := svc.UpdateCategory(r.Context(), chi.URLParam(r, "categoryId"), req)
+		if err != nil {
+			respondServiceError(w, r, err)
+			return
+		}
+
+		respondJSON(w, http.StatusOK, category)
+	}
+}
+
+// DeleteCategory handles DELETE /categories/:categoryId
+func DeleteCategory(svc *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := svc.DeleteCategory(r.Context(), chi.URLParam(r, "categoryId")); err != nil {
+			respondServiceError(w, r, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"github.com/ec332/aegis/market/internal/service"
+	"github.com/ec332/aegis/market/pkg/models"
+)
+
+// errorMapping describes how a domain error is reported to clients
+type errorMapping struct {
+	target  error
+	status  int
+	code    string
+	message string
+	// expose returns the error text as Message; only for errors built from request data
+	expose bool
+}
+
+// errorMappings is checked in order; the first match wins
+var errorMappings = []errorMapping{
+	{service.ErrValidation, http.StatusBadRequest, models.ErrorCodeValidationFailed, "Validation failed", false},
+	{service.ErrUnauthenticated, http.StatusUnauthorized, models.ErrorCodeUnauthenticated, "Authentication required", true},
+	{service.ErrForbidden, http.StatusForbidden, models.ErrorCodeForbidden, "Forbidden", true},
+	{service.ErrNotFound, http.StatusNotFound, models.ErrorCodeNotFound, "Not found", true},
+	{service.ErrInvalidTransition, http.StatusConflict, models.ErrorCodeInvalidTransition, "Invalid status transition", true},
+	{service.ErrNotEditable, http.StatusConflict, models.ErrorCodeNotEditable, "Market not editable", true},
+	{service.ErrMarketClosed, http.StatusConflict, models.ErrorCodeMarketClosed, "Market closed", true},
+	{service.ErrInsufficientShares, http.StatusConflict, models.ErrorCodeInsufficientShares, "Insufficient shares", true},
+	{service.ErrOpenOrderLimit, http.StatusConflict, models.ErrorCodeOpenOrderLimit, "Open buy order limit exceeded", true},
+	{service.ErrPositionLimit, http.StatusConflict, models.ErrorCodePositionLimit, "Position limit exceeded", true},
+	{service.ErrTradeSizeLimit, http.StatusConflict, models.ErrorCodeTradeSizeLimit, "Trade size limit exceeded", true},
+	{service.ErrExposureLimit, http.StatusConflict, models.ErrorCodeExposureLimit, "Treasury exposure limit exceeded", true},
+	{service.ErrConflict, http.StatusConflict, models.ErrorCodeConflict, "Conflict", false},
+	{service.ErrUnavailable, http.StatusServiceUnavailable, models.ErrorCodeUnavailable, "Service unavailable", false},
+}
+
+// respondServiceError maps err to an HTTP status and error code. Unrecognised
+// errors are logged and reported as 500 without their details.
+func respondServiceError(w http.ResponseWriter, r *http.Request, err error) {
+	for _, m := range errorMappings {
+		if !errors.Is(err, m.target) {
+			continue
+		}
+
+		resp := models.ErrorResponse{Error: m.message, Code: m.code}
+		var verr *service.ValidationError
+		if errors.As(err, &verr) {
+			resp.Details = verr.Fields
+		}
+		if m.expose {
+			resp.Message = err.Error()
+		}
+		if m.status >= http.StatusInternalServerError {
+			slog.ErrorContext(r.Context(), "request failed", "error", err)
+		}
+		respondJSON(w, m.status, resp)
+		return
+	}
+
+	slog.ErrorContext(r.Context(), "request failed", "error", err)
+	respondError(w, http.StatusInternalServerError, models.ErrorCodeInternal, "Internal server error", "")
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"github.com/ec332/aegis/market/internal/service"
+	"github.com/ec332/aegis/market/pkg/models"
+	"github.com/go-chi/chi/v5"
+)
+
+// HaltMarket handles POST /markets/:marketId/halt (admins)
+func HaltMarket(svc *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req models.HaltMarketRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondError(w, http.StatusBadRequest, models.ErrorCodeInvalidBody, "Invalid request body", err.Error())
+			return
+		}
+
+		halt, err := svc.HaltMarket(r.Context(), chi.URLParam(r, "marketId"), req)
+		if err != nil {
+			respondServiceError(w, r, err)
+			return
+		}
+
+		respondJSON(w, http.StatusCreated, halt)
+	}
+}
+
+// ResumeMarket handles POST /markets/:marketId/resume (admins)
+func ResumeMarket(svc *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		market, err := svc.ResumeMarket(r.Context(), chi.URLParam(r, "marketId"))
+		if err != nil {
+			respondServiceError(w, r, err)
+			return
+		}
+
+		respondJSON(w, http.StatusOK, market)
+	}
+}
+
+// ListMarketHalts handles GET /markets/:marketId/halts
+func ListMarketHalts(svc *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		halts, err := svc.ListMarketHalts(r.Context(), chi.URLParam(r, "marketId"))
+		if err != nil {
+			respondServiceError(w, r, err)
+			return
+		}
+
+		respondJSON(w, http.StatusOK, models.MarketHaltListResponse{
+			Halts: halts,
+			Total: len(halts),
+		})
+	}
+}
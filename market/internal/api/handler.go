@@ -3,8 +3,11 @@ package api
 import (
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"strconv"
 	"time"
+	"github.com/ec332/aegis/market/internal/metrics"
 	"github.com/ec332/aegis/market/internal/service"
 	"github.com/ec332/aegis/market/pkg/models"
 	"github.com/go-chi/chi/v5"
@@ -15,13 +18,13 @@ func CreateMarket(svc *service.Service) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var req models.CreateMarketRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			respondError(w, http.StatusBadRequest, "Invalid request body", err)
+			respondError(w, http.StatusBadRequest, models.ErrorCodeInvalidBody, "Invalid request body", err.Error())
 			return
 		}
 
 		market, err := svc.CreateMarket(r.Context(), req)
 		if err != nil {
-			respondError(w, http.StatusBadRequest, "Failed to create market", err)
+			respondServiceError(w, r, err)
 			return
 		}
 
@@ -32,17 +35,14 @@ func CreateMarket(svc *service.Service) http.HandlerFunc {
 // ListMarkets handles GET /markets
 func ListMarkets(svc *service.Service) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// Optional status filter
-		var status *models.MarketStatus
-		statusParam := r.URL.Query().Get("status")
-		if statusParam != "" {
-			s := models.MarketStatus(statusParam)
-			status = &s
+		filter, ok := parseMarketFilter(w, r)
+		if !ok {
+			return
 		}
 
-		markets, err := svc.ListMarkets(r.Context(), status)
+		markets, err := svc.ListMarkets(r.Context(), filter)
 		if err != nil {
-			respondError(w, http.StatusInternalServerError, "Failed to list markets", err)
+			respondServiceError(w, r, err)
 			return
 		}
 
@@ -55,18 +55,36 @@ func ListMarkets(svc *service.Service) http.HandlerFunc {
 	}
 }
 
+// GetMarketFacets handles GET /markets/facets (market counts per category and tag)
+func GetMarketFacets(svc *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filter, ok := parseMarketFilter(w, r)
+		if !ok {
+			return
+		}
+
+		facets, err := svc.MarketFacets(r.Context(), filter)
+		if err != nil {
+			respondServiceError(w, r, err)
+			return
+		}
+
+		respondJSON(w, http.StatusOK, facets)
+	}
+}
+
 // GetMarket handles GET /markets/:marketId
 func GetMarket(svc *service.Service) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		marketID := chi.URLParam(r, "marketId")
 		if marketID == "" {
-			respondError(w, http.StatusBadRequest, "Market ID is required", nil)
+			respondError(w, http.StatusBadRequest, models.ErrorCodeInvalidRequest, "Market ID is required", "")
 			return
 		}
 
 		market, err := svc.GetMarket(r.Context(), marketID)
 		if err != nil {
-			respondError(w, http.StatusNotFound, "Market not found", err)
+			respondServiceError(w, r, err)
 			return
 		}
 
@@ -79,19 +97,19 @@ func UpdateMarket(svc *service.Service) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		marketID := chi.URLParam(r, "marketId")
 		if marketID == "" {
-			respondError(w, http.StatusBadRequest, "Market ID is required", nil)
+			respondError(w, http.StatusBadRequest, models.ErrorCodeInvalidRequest, "Market ID is required", "")
 			return
 		}
 
 		var req models.UpdateMarketRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			respondError(w, http.StatusBadRequest, "Invalid request body", err)
+			respondError(w, http.StatusBadRequest, models.ErrorCodeInvalidBody, "Invalid request body", err.Error())
 			return
 		}
 
 		market, err := svc.UpdateMarket(r.Context(), marketID, req)
 		if err != nil {
-			respondError(w, http.StatusBadRequest, "Failed to update market", err)
+			respondServiceError(w, r, err)
 			return
 		}
 
@@ -99,19 +117,35 @@ func UpdateMarket(svc *service.Service) http.HandlerFunc {
 	}
 }
 
-// StreamLiquidityUpdates handles GET /markets/:marketId/stream (SSE for liquidity pool updates)
-func StreamLiquidityUpdates(svc *service.Service) http.HandlerFunc {
+// ListMarketRevisions handles GET /markets/:marketId/revisions
+func ListMarketRevisions(svc *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		revisions, err := svc.ListMarketRevisions(r.Context(), chi.URLParam(r, "marketId"))
+		if err != nil {
+			respondServiceError(w, r, err)
+			return
+		}
+
+		respondJSON(w, http.StatusOK, models.MarketRevisionListResponse{
+			Revisions: revisions,
+			Total:     len(revisions),
+		})
+	}
+}
+
+// StreamMarketEvents handles GET /markets/:marketId/stream (SSE for liquidity pool and order book updates)
+func StreamMarketEvents(svc *service.Service, pingInterval func() time.Duration) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		marketID := chi.URLParam(r, "marketId")
 		if marketID == "" {
-			respondError(w, http.StatusBadRequest, "Market ID is required", nil)
+			respondError(w, http.StatusBadRequest, models.ErrorCodeInvalidRequest, "Market ID is required", "")
 			return
 		}
 
 		// Verify market exists
 		_, err := svc.GetMarket(r.Context(), marketID)
 		if err != nil {
-			respondError(w, http.StatusNotFound, "Market not found", err)
+			respondServiceError(w, r, err)
 			return
 		}
 
@@ -124,39 +158,46 @@ func StreamLiquidityUpdates(svc *service.Service) http.HandlerFunc {
 		// Get flusher
 		flusher, ok := w.(http.Flusher)
 		if !ok {
-			respondError(w, http.StatusInternalServerError, "Streaming not supported", nil)
+			respondError(w, http.StatusInternalServerError, models.ErrorCodeInternal, "Streaming not supported", "")
 			return
 		}
+		// The stream stays open past the server's write timeout
+		if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+			slog.WarnContext(r.Context(), "failed to clear stream write deadline", "market_id", marketID, "error", err)
+		}
 
 		// Subscribe to Redis updates
-		updatesCh, err := svc.SubscribeToLiquidityUpdates(r.Context(), marketID)
+		eventsCh, err := svc.SubscribeToMarketEvents(r.Context(), marketID)
 		if err != nil {
-			respondError(w, http.StatusInternalServerError, "Failed to subscribe to updates", err)
+			respondServiceError(w, r, err)
 			return
 		}
 
+		metrics.SSEActiveStreams.WithLabelValues(marketID).Inc()
+		defer metrics.SSEActiveStreams.WithLabelValues(marketID).Dec()
+
 		// Send initial connection message
 		fmt.Fprintf(w, "event: connected\ndata: {\"market_id\":\"%s\",\"timestamp\":\"%s\"}\n\n", marketID, time.Now().Format(time.RFC3339))
 		flusher.Flush()
 
 		// Keepalive ticker
-		ticker := time.NewTicker(30 * time.Second)
+		ticker := time.NewTicker(pingInterval())
 		defer ticker.Stop()
 
 		for {
 			select {
 			case <-r.Context().Done():
 				return
-			case update, ok := <-updatesCh:
+			case event, ok := <-eventsCh:
 				if !ok {
 					return
 				}
-				data, err := json.Marshal(update)
+				data, err := json.Marshal(event.Data)
 				if err != nil {
-					fmt.Printf("Error marshaling update: %v\n", err)
+					slog.WarnContext(r.Context(), "failed to marshal market event", "market_id", marketID, "event", event.Event, "error", err)
 					continue
 				}
-				fmt.Fprintf(w, "event: liquidity-update\ndata: %s\n\n", data)
+				fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Event, data)
 				flusher.Flush()
 			case <-ticker.C:
 				// Keepalive ping
@@ -169,20 +210,45 @@ func StreamLiquidityUpdates(svc *service.Service) http.HandlerFunc {
 
 // Helper functions
 
+// parseMarketFilter reads the status, category_id, parent_market_id, tag (repeatable) and featured query parameters
+func parseMarketFilter(w http.ResponseWriter, r *http.Request) (models.MarketFilter, bool) {
+	query := r.URL.Query()
+	filter := models.MarketFilter{Tags: query["tag"]}
+
+	if statusParam := query.Get("status"); statusParam != "" {
+		status := models.MarketStatus(statusParam)
+		filter.Status = &status
+	}
+	if categoryID := query.Get("category_id"); categoryID != "" {
+		filter.CategoryID = &categoryID
+	}
+	if parentID := query.Get("parent_market_id"); parentID != "" {
+		filter.ParentMarketID = &parentID
+	}
+	if featuredParam := query.Get("featured"); featuredParam != "" {
+		featured, err := strconv.ParseBool(featuredParam)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, models.ErrorCodeInvalidRequest, "Invalid featured parameter", "featured must be true or false")
+			return filter, false
+		}
+		filter.Featured = &featured
+	}
+
+	return filter, true
+}
+
 func respondJSON(w http.ResponseWriter, status int, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
 	if err := json.NewEncoder(w).Encode(data); err != nil {
-		fmt.Printf("Error encoding JSON response: %v\n", err)
+		slog.Error("failed to encode JSON response", "error", err)
 	}
 }
 
-func respondError(w http.ResponseWriter, status int, message string, err error) {
-	errResp := models.ErrorResponse{
-		Error: message,
-	}
-	if err != nil {
-		errResp.Message = err.Error()
-	}
-	respondJSON(w, status, errResp)
+func respondError(w http.ResponseWriter, status int, code, errMsg, message string) {
+	respondJSON(w, status, models.ErrorResponse{
+		Error:   errMsg,
+		Code:    code,
+		Message: message,
+	})
 }
@@ -0,0 +1,19 @@
+package api
+
+import (
+	"net/http"
+	"github.com/ec332/aegis/market/internal/service"
+)
+
+// GetExposureReport handles GET /risk/exposures[?market_id=] (admins)
+func GetExposureReport(svc *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report, err := svc.ExposureReport(r.Context(), r.URL.Query().Get("market_id"))
+		if err != nil {
+			respondServiceError(w, r, err)
+			return
+		}
+
+		respondJSON(w, http.StatusOK, report)
+	}
+}
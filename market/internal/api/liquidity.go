@@ -0,0 +1,76 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"github.com/ec332/aegis/market/internal/service"
+	"github.com/ec332/aegis/market/pkg/models"
+	"github.com/go-chi/chi/v5"
+)
+
+// AddLiquidity handles POST /markets/:marketId/liquidity/add
+func AddLiquidity(svc *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req models.AddLiquidityRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondError(w, http.StatusBadRequest, models.ErrorCodeInvalidBody, "Invalid request body", err.Error())
+			return
+		}
+
+		result, err := svc.AddLiquidity(r.Context(), chi.URLParam(r, "marketId"), req)
+		if err != nil {
+			respondServiceError(w, r, err)
+			return
+		}
+
+		respondJSON(w, http.StatusOK, result)
+	}
+}
+
+// RemoveLiquidity handles POST /markets/:marketId/liquidity/remove
+func RemoveLiquidity(svc *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req models.RemoveLiquidityRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondError(w, http.StatusBadRequest, models.ErrorCodeInvalidBody, "Invalid request body", err.Error())
+			return
+		}
+
+		result, err := svc.RemoveLiquidity(r.Context(), chi.URLParam(r, "marketId"), req)
+		if err != nil {
+			respondServiceError(w, r, err)
+			return
+		}
+
+		respondJSON(w, http.StatusOK, result)
+	}
+}
+
+// GetMarketLiquidity handles GET /markets/:marketId/liquidity
+func GetMarketLiquidity(svc *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		summary, err := svc.GetMarketLiquidity(r.Context(), chi.URLParam(r, "marketId"))
+		if err != nil {
+			respondServiceError(w, r, err)
+			return
+		}
+
+		respondJSON(w, http.StatusOK, summary)
+	}
+}
+
+// ListLiquidityPositions handles GET /positions/liquidity (the caller's LP positions)
+func ListLiquidityPositions(svc *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		positions, err := svc.ListLiquidityPositions(r.Context())
+		if err != nil {
+			respondServiceError(w, r, err)
+			return
+		}
+
+		respondJSON(w, http.StatusOK, models.LiquidityPositionListResponse{
+			Positions: positions,
+			Total:     len(positions),
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"github.com/ec332/aegis/market/internal/service"
+	"github.com/ec332/aegis/market/pkg/models"
+	"github.com/go-chi/chi/v5"
+)
+
+// CreateOption handles POST /markets/:marketId/options
+func CreateOption(svc *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req models.CreateOptionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondError(w, http.StatusBadRequest, models.ErrorCodeInvalidBody, "Invalid request body", err.Error())
+			return
+		}
+
+		option, err := svc.CreateOption(r.Context(), chi.URLParam(r, "marketId"), req)
+		if err != nil {
+			respondServiceError(w, r, err)
+			return
+		}
+
+		respondJSON(w, http.StatusCreated, option)
+	}
+}
+
+// UpdateOption handles PUT /markets/:marketId/options/:optionId
+func UpdateOption(svc *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req models.UpdateOptionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondError(w, http.StatusBadRequest, models.ErrorCodeInvalidBody, "Invalid request body", err.Error())
+			return
+		}
+
+		option, err := svc.UpdateOption(r.Context(), chi.URLParam(r, "marketId"), chi.URLParam(r, "optionId"), req)
+		if err != nil {
+			respondServiceError(w, r, err)
+			return
+		}
+
+		respondJSON(w, http.StatusOK, option)
+	}
+}
+
+// DeleteOption handles DELETE /markets/:marketId/options/:optionId
+func DeleteOption(svc *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := svc.DeleteOption(r.Context(), chi.URLParam(r, "marketId"), chi.URLParam(r, "optionId")); err != nil {
+			respondServiceError(w, r, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// ReorderOptions handles PUT /markets/:marketId/options/order
+func ReorderOptions(svc *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req models.ReorderOptionsRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondError(w, http.StatusBadRequest, models.ErrorCodeInvalidBody, "Invalid request body", err.Error())
+			return
+		}
+
+		options, err := svc.ReorderOptions(r.Context(), chi.URLParam(r, "marketId"), req)
+		if err != nil {
+			respondServiceError(w, r, err)
+			return
+		}
+
+		respondJSON(w, http.StatusOK, options)
+	}
+}
@@ -0,0 +1,171 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"github.com/ec332/aegis/market/internal/service"
+	"github.com/ec332/aegis/market/pkg/models"
+	"github.com/go-chi/chi/v5"
+)
+
+// PlaceOrder handles POST /markets/:marketId/orders
+func PlaceOrder(svc *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req models.PlaceOrderRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondError(w, http.StatusBadRequest, models.ErrorCodeInvalidBody, "Invalid request body", err.Error())
+			return
+		}
+
+		result, err := svc.PlaceOrder(r.Context(), chi.URLParam(r, "marketId"), req)
+		if err != nil {
+			respondServiceError(w, r, err)
+			return
+		}
+
+		respondJSON(w, http.StatusCreated, result)
+	}
+}
+
+// QuoteOrder handles GET /markets/:marketId/quote?option_id=&side=&shares=[&price=][&type=]
+func QuoteOrder(svc *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		req := models.PlaceOrderRequest{
+			OptionID: query.Get("option_id"),
+			Side:     models.OrderSide(query.Get("side")),
+			Type:     models.OrderType(query.Get("type")),
+		}
+		var err error
+		if req.Shares, err = strconv.ParseFloat(query.Get("shares"), 64); err != nil {
+			respondError(w, http.StatusBadRequest, models.ErrorCodeInvalidRequest, "Invalid shares parameter", "shares must be a number")
+			return
+		}
+		if priceParam := query.Get("price"); priceParam != "" {
+			price, err := strconv.ParseFloat(priceParam, 64)
+			if err != nil {
+				respondError(w, http.StatusBadRequest, models.ErrorCodeInvalidRequest, "Invalid price parameter", "price must be a number")
+				return
+			}
+			req.Price = &price
+		}
+
+		quote, err := svc.QuoteOrder(r.Context(), chi.URLParam(r, "marketId"), req)
+		if err != nil {
+			respondServiceError(w, r, err)
+			return
+		}
+
+		respondJSON(w, http.StatusOK, quote)
+	}
+}
+
+// ListOrders handles GET /markets/:marketId/orders (the caller's orders)
+func ListOrders(svc *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		orders, err := svc.ListOrders(r.Context(), chi.URLParam(r, "marketId"))
+		if err != nil {
+			respondServiceError(w, r, err)
+			return
+		}
+
+		respondJSON(w, http.StatusOK, models.OrderListResponse{
+			Orders: orders,
+			Total:  len(orders),
+		})
+	}
+}
+
+// CancelOrder handles DELETE /markets/:marketId/orders/:orderId
+func CancelOrder(svc *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		order, err := svc.CancelOrder(r.Context(), chi.URLParam(r, "marketId"), chi.URLParam(r, "orderId"))
+		if err != nil {
+			respondServiceError(w, r, err)
+			return
+		}
+
+		respondJSON(w, http.StatusOK, order)
+	}
+}
+
+// GetOrderBook handles GET /markets/:marketId/book?depth=N
+func GetOrderBook(svc *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		depth := 0
+		if depthParam := r.URL.Query().Get("depth"); depthParam != "" {
+			var err error
+			if depth, err = strconv.Atoi(depthParam); err != nil {
+				respondError(w, http.StatusBadRequest, models.ErrorCodeInvalidRequest, "Invalid depth parameter", "depth must be an integer")
+				return
+			}
+		}
+
+		book, err := svc.GetOrderBook(r.Context(), chi.URLParam(r, "marketId"), depth)
+		if err != nil {
+			respondServiceError(w, r, err)
+			return
+		}
+
+		respondJSON(w, http.StatusOK, book)
+	}
+}
+
+// GetMarketFees handles GET /markets/:marketId/fees
+func GetMarketFees(svc *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		summary, err := svc.GetMarketFees(r.Context(), chi.URLParam(r, "marketId"))
+		if err != nil {
+			respondServiceError(w, r, err)
+			return
+		}
+
+		respondJSON(w, http.StatusOK, summary)
+	}
+}
+
+// ListPositions handles GET /positions (the caller's holdings)
+func ListPositions(svc *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		positions, err := svc.ListPositions(r.Context())
+		if err != nil {
+			respondServiceError(w, r, err)
+			return
+		}
+
+		respondJSON(w, http.StatusOK, models.PositionListResponse{
+			Positions: positions,
+			Total:     len(positions),
+		})
+	}
+}
+
+// MintCompleteSet handles POST /markets/:marketId/sets/mint
+func MintCompleteSet(svc *service.Service) http.HandlerFunc {
+	return completeSetHandler(svc.MintCompleteSet)
+}
+
+// RedeemCompleteSet handles POST /markets/:marketId/sets/redeem
+func RedeemCompleteSet(svc *service.Service) http.HandlerFunc {
+	return completeSetHandler(svc.RedeemCompleteSet)
+}
+
+func completeSetHandler(exchange func(context.Context, string, models.CompleteSetRequest) (*models.CompleteSetResult, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req models.CompleteSetRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondError(w, http.StatusBadRequest, models.ErrorCodeInvalidBody, "Invalid request body", err.Error())
+			return
+		}
+
+		result, err := exchange(r.Context(), chi.URLParam(r, "marketId"), req)
+		if err != nil {
+			respondServiceError(w, r, err)
+			return
+		}
+
+		respondJSON(w, http.StatusOK, result)
+	}
+}
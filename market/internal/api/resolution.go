@@ -0,0 +1,95 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"github.com/ec332/aegis/market/internal/service"
+	"github.com/ec332/aegis/market/pkg/models"
+	"github.com/go-chi/chi/v5"
+)
+
+// ListResolutionProposals handles GET /markets/:marketId/resolution
+func ListResolutionProposals(svc *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		proposals, err := svc.ListResolutionProposals(r.Context(), chi.URLParam(r, "marketId"))
+		if err != nil {
+			respondServiceError(w, r, err)
+			return
+		}
+
+		respondJSON(w, http.StatusOK, models.ResolutionProposalListResponse{
+			Proposals: proposals,
+			Total:     len(proposals),
+		})
+	}
+}
+
+// ProposeResolution handles POST /markets/:marketId/resolution/proposals
+func ProposeResolution(svc *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req models.ProposeResolutionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondError(w, http.StatusBadRequest, models.ErrorCodeInvalidBody, "Invalid request body", err.Error())
+			return
+		}
+
+		proposal, err := svc.ProposeResolution(r.Context(), chi.URLParam(r, "marketId"), req)
+		if err != nil {
+			respondServiceError(w, r, err)
+			return
+		}
+
+		respondJSON(w, http.StatusCreated, proposal)
+	}
+}
+
+// DisputeResolution handles POST /markets/:marketId/resolution/disputes
+func DisputeResolution(svc *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req models.DisputeResolutionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondError(w, http.StatusBadRequest, models.ErrorCodeInvalidBody, "Invalid request body", err.Error())
+			return
+		}
+
+		proposal, err := svc.DisputeResolution(r.Context(), chi.URLParam(r, "marketId"), req)
+		if err != nil {
+			respondServiceError(w, r, err)
+			return
+		}
+
+		respondJSON(w, http.StatusCreated, proposal)
+	}
+}
+
+// ArbitrateResolution handles POST /markets/:marketId/resolution/arbitration
+func ArbitrateResolution(svc *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req models.ArbitrateResolutionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondError(w, http.StatusBadRequest, models.ErrorCodeInvalidBody, "Invalid request body", err.Error())
+			return
+		}
+
+		market, err := svc.ArbitrateResolution(r.Context(), chi.URLParam(r, "marketId"), req)
+		if err != nil {
+			respondServiceError(w, r, err)
+			return
+		}
+
+		respondJSON(w, http.StatusOK, market)
+	}
+}
+
+// FinalizeResolution handles POST /markets/:marketId/resolution/finalize
+func FinalizeResolution(svc *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		market, err := svc.FinalizeResolution(r.Context(), chi.URLParam(r, "marketId"))
+		if err != nil {
+			respondServiceError(w, r, err)
+			return
+		}
+
+		respondJSON(w, http.StatusOK, market)
+	}
+}
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"github.com/ec332/aegis/market/internal/service"
+	"github.com/ec332/aegis/market/pkg/models"
+	"github.com/go-chi/chi/v5"
+)
+
+// SubmitMarket handles POST /markets/:marketId/submit
+func SubmitMarket(svc *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		market, err := svc.SubmitMarket(r.Context(), chi.URLParam(r, "marketId"))
+		if err != nil {
+			respondServiceError(w, r, err)
+			return
+		}
+
+		respondJSON(w, http.StatusOK, market)
+	}
+}
+
+// CreateReview handles POST /markets/:marketId/reviews
+func CreateReview(svc *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req models.CreateReviewRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondError(w, http.StatusBadRequest, models.ErrorCodeInvalidBody, "Invalid request body", err.Error())
+			return
+		}
+
+		market, err := svc.ReviewMarket(r.Context(), chi.URLParam(r, "marketId"), req)
+		if err != nil {
+			respondServiceError(w, r, err)
+			return
+		}
+
+		respondJSON(w, http.StatusOK, market)
+	}
+}
+
+// ListMarketReviews handles GET /markets/:marketId/reviews
+func ListMarketReviews(svc *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		reviews, err := svc.ListMarketReviews(r.Context(), chi.URLParam(r, "marketId"))
+		if err != nil {
+			respondServiceError(w, r, err)
+			return
+		}
+
+		respondJSON(w, http.StatusOK, models.MarketReviewListResponse{
+			Reviews: reviews,
+			Total:   len(reviews),
+		})
+	}
+}
+
+// GetReviewQueue handles GET /moderation/queue
+func GetReviewQueue(svc *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		items, err := svc.ReviewQueue(r.Context())
+		if err != nil {
+			respondServiceError(w, r, err)
+			return
+		}
+
+		respondJSON(w, http.StatusOK, models.ReviewQueueResponse{
+			Items:             items,
+			Total:             len(items),
+			RequiredApprovals: svc.RequiredApprovals(),
+		})
+	}
+}
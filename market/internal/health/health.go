@@ -0,0 +1,178 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Component statuses reported by the probes
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// CheckFunc reports whether a dependency is healthy
+type CheckFunc func(ctx context.Context) error
+
+// CheckResult is the outcome of a single component check
+type CheckResult struct {
+	Status     string `json:"status"`
+	DurationMS int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"`
+}
+
+// Report is the JSON body returned by /livez and /readyz
+type Report struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+type check struct {
+	name    string
+	timeout time.Duration
+	fn      CheckFunc
+}
+
+// Checker aggregates dependency checks and background worker heartbeats
+type Checker struct {
+	mu       sync.RWMutex
+	checks   []check
+	workers  map[string]*Heartbeat
+	draining atomic.Bool
+}
+
+// New creates an empty checker
+func New() *Checker {
+	return &Checker{workers: map[string]*Heartbeat{}}
+}
+
+// AddReadinessCheck registers a dependency check run by /readyz, bounded by timeout
+func (c *Checker) AddReadinessCheck(name string, timeout time.Duration, fn CheckFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks = append(c.checks, check{name: name, timeout: timeout, fn: fn})
+}
+
+// RegisterWorker registers a background worker that must call Beat at least once
+// every maxSilence to be considered live
+func (c *Checker) RegisterWorker(name string, maxSilence time.Duration) *Heartbeat {
+	hb := &Heartbeat{maxSilence: maxSilence}
+	hb.Beat()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.workers[name] = hb
+	return hb
+}
+
+// SetDraining marks the instance as shutting down so readiness fails and
+// load balancers stop routing new traffic to it
+func (c *Checker) SetDraining() {
+	c.draining.Store(true)
+}
+
+// Liveness reports background worker health
+func (c *Checker) Liveness() Report {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	report := Report{Status: StatusOK, Checks: map[string]CheckResult{}}
+	for name, hb := range c.workers {
+		result := CheckResult{Status: StatusOK}
+		if err := hb.check(); err != nil {
+			result.Status = StatusFail
+			result.Error = err.Error()
+			report.Status = StatusFail
+		}
+		report.Checks["worker:"+name] = result
+	}
+	return report
+}
+
+// Readiness runs all dependency checks concurrently
+func (c *Checker) Readiness(ctx context.Context) Report {
+	c.mu.RLock()
+	checks := append([]check(nil), c.checks...)
+	c.mu.RUnlock()
+
+	report := Report{Status: StatusOK, Checks: map[string]CheckResult{}}
+	if c.draining.Load() {
+		report.Status = StatusFail
+		report.Checks["shutdown"] = CheckResult{Status: StatusFail, Error: "draining"}
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, chk := range checks {
+		wg.Add(1)
+		go func(chk check) {
+			defer wg.Done()
+			result := runCheck(ctx, chk)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[chk.name] = result
+			if result.Status != StatusOK {
+				report.Status = StatusFail
+			}
+		}(chk)
+	}
+	wg.Wait()
+
+	return report
+}
+
+// LivenessHandler handles GET /livez
+func (c *Checker) LivenessHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeReport(w, c.Liveness())
+	}
+}
+
+// ReadinessHandler handles GET /readyz
+func (c *Checker) ReadinessHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeReport(w, c.Readiness(r.Context()))
+	}
+}
+
+func runCheck(ctx context.Context, chk check) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, chk.timeout)
+	defer cancel()
+
+	start := time.Now()
+	errCh := make(chan error, 1)
+	go func() { errCh <- chk.fn(ctx) }()
+
+	var err error
+	select {
+	case err = <-errCh:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	result := CheckResult{Status: StatusOK, DurationMS: time.Since(start).Milliseconds()}
+	if err != nil {
+		result.Status = StatusFail
+		result.Error = err.Error()
+	}
+	return result
+}
+
+func writeReport(w http.ResponseWriter, report Report) {
+	status := http.StatusOK
+	if report.Status != StatusOK {
+		status = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		slog.Error("failed to encode health report", "error", err)
+	}
+}
@@ -0,0 +1,26 @@
+package health
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// Heartbeat tracks the last time a background worker made progress
+type Heartbeat struct {
+	last       atomic.Int64
+	maxSilence time.Duration
+}
+
+// Beat records that the worker is alive
+func (h *Heartbeat) Beat() {
+	h.last.Store(time.Now().UnixNano())
+}
+
+func (h *Heartbeat) check() error {
+	since := time.Since(time.Unix(0, h.last.Load()))
+	if since > h.maxSilence {
+		return fmt.Errorf("no heartbeat for %s", since.Round(time.Second))
+	}
+	return nil
+}
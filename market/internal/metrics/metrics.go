@@ -0,0 +1,165 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"net/http"
+	"time"
+	"github.com/ec332/aegis/market/pkg/models"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "market"
+
+var (
+	// HTTPRequestsTotal counts handled HTTP requests by route pattern and status
+	HTTPRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Total HTTP requests by method, route pattern and status code.",
+	}, []string{"method", "route", "status"})
+
+	// HTTPRequestDuration observes HTTP request latency by route pattern and status
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by method, route pattern and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	// RedisPublishFailures counts events that could not be published to Redis
+	RedisPublishFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "redis_publish_failures_total",
+		Help:      "Total failed Redis publishes by event kind.",
+	}, []string{"event"})
+
+	// RateLimitedTotal counts requests rejected by rate limiting
+	RateLimitedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limited_requests_total",
+		Help:      "Total requests rejected with 429 by limit name.",
+	}, []string{"limit"})
+
+	// TradingHalts counts markets halted, by cause
+	TradingHalts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "trading_halts_total",
+		Help:      "Total trading halts by cause.",
+	}, []string{"cause"})
+
+	// TradeRecords counts trades sent to the transaction service, by outcome
+	TradeRecords = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "trade_records_total",
+		Help:      "Total trades sent to the transaction service by outcome (recorded, rejected, failed).",
+	}, []string{"outcome"})
+
+	// ReconciliationDiscrepancies is the number of pools and positions that differed
+	// from the transaction service at the last reconciliation
+	ReconciliationDiscrepancies = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "reconciliation_discrepancies",
+		Help:      "Pools and positions that differed from the transaction service at the last reconciliation.",
+	})
+
+	// IngestEvents counts trade events read from the ingest stream, by outcome
+	IngestEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "ingest_events_total",
+		Help:      "Total trade events read from the ingest stream by outcome (applied, duplicate, stale, dead_lettered, failed).",
+	}, []string{"outcome"})
+
+	// IngestLag tracks the ingest stream entries not yet delivered to the consumer group
+	IngestLag = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "ingest_lag",
+		Help:      "Ingest stream entries not yet delivered to the consumer group.",
+	})
+
+	// IngestPending tracks entries delivered to the consumer group but not yet acknowledged
+	IngestPending = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "ingest_pending",
+		Help:      "Ingest stream entries delivered to the consumer group but not yet acknowledged.",
+	})
+
+	// IngestDelay observes how long trade events wait in the stream before they are processed
+	IngestDelay = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "ingest_delay_seconds",
+		Help:      "Time from a trade event entering the ingest stream to its processing.",
+		Buckets:   prometheus.ExponentialBuckets(0.005, 4, 10),
+	})
+
+	// SSEActiveStreams tracks open SSE streams per market
+	SSEActiveStreams = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "sse_active_streams",
+		Help:      "Number of open SSE streams per market.",
+	}, []string{"market_id"})
+)
+
+// Handler returns the HTTP handler serving /metrics
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// RegisterDBStats exposes connection pool statistics for db
+func RegisterDBStats(db *sql.DB) {
+	prometheus.MustRegister(collectors.NewDBStatsCollector(db, "market"))
+}
+
+// StatsSource provides aggregate market statistics
+type StatsSource interface {
+	MarketStats(ctx context.Context) (*models.MarketStats, error)
+}
+
+// RegisterBusinessStats exposes market gauges computed from src on every scrape
+func RegisterBusinessStats(src StatsSource, timeout time.Duration) {
+	prometheus.MustRegister(&businessCollector{src: src, timeout: timeout})
+}
+
+var (
+	marketsByStatusDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "markets"),
+		"Number of markets by status.",
+		[]string{"status"}, nil,
+	)
+	totalLiquidityDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "total_liquidity"),
+		"Sum of pool values across all liquidity pools.",
+		nil, nil,
+	)
+)
+
+// businessCollector queries market statistics at scrape time
+type businessCollector struct {
+	src     StatsSource
+	timeout time.Duration
+}
+
+func (c *businessCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- marketsByStatusDesc
+	ch <- totalLiquidityDesc
+}
+
+func (c *businessCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	stats, err := c.src.MarketStats(ctx)
+	if err != nil {
+		slog.Warn("failed to collect market stats", "error", err)
+		return
+	}
+
+	for status, count := range stats.MarketsByStatus {
+		ch <- prometheus.MustNewConstMetric(marketsByStatusDesc, prometheus.GaugeValue, float64(count), string(status))
+	}
+	ch <- prometheus.MustNewConstMetric(totalLiquidityDesc, prometheus.GaugeValue, stats.TotalLiquidity)
+}
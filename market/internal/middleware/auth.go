@@ -0,0 +1,107 @@
+package middleware
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+	"github.com/ec332/aegis/market/pkg/models"
+)
+
+const (
+	// UserIDHeader carries the authenticated user forwarded by the API gateway
+	UserIDHeader = "X-User-ID"
+	// ServiceKeyHeader carries the shared key identifying trusted internal callers
+	ServiceKeyHeader = "X-Service-Key"
+	// UserRolesHeader carries the user's comma-separated roles, trusted only with a
+	// valid X-Service-Key
+	UserRolesHeader = "X-User-Roles"
+)
+
+// Roles granted by the API gateway
+const (
+	RoleReviewer = "reviewer"
+	RoleResolver = "resolver"
+	RoleAdmin    = "admin"
+)
+
+// Principal identifies the caller of a request
+type Principal struct {
+	// UserID is the end user the request acts on behalf of, if any
+	UserID string
+	// Roles are the user's roles, such as RoleReviewer
+	Roles []string
+	// Service is true when the request carried a valid service key
+	Service bool
+}
+
+// HasRole reports whether the principal holds role. Admins hold every role.
+func (p Principal) HasRole(role string) bool {
+	for _, r := range p.Roles {
+		if r == role || r == RoleAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+type principalKey struct{}
+
+// Authenticate attaches the request's Principal to its context. When service keys
+// are configured, X-User-ID is only trusted on requests with a valid X-Service-Key;
+// without keys (local development) it is trusted as sent. Roles always need a
+// valid key, so without keys no request holds any.
+func Authenticate(serviceKeys []string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var p Principal
+			if len(serviceKeys) == 0 {
+				p.UserID = r.Header.Get(UserIDHeader)
+			} else if validKey(serviceKeys, r.Header.Get(ServiceKeyHeader)) {
+				p.Service = true
+				p.UserID = r.Header.Get(UserIDHeader)
+				p.Roles = parseRoles(r.Header.Get(UserRolesHeader))
+			}
+			// Requests claiming the treasury are treated as anonymous
+			if p.UserID == models.TreasuryUserID {
+				p = Principal{Service: p.Service}
+			}
+			ctx := context.WithValue(r.Context(), principalKey{}, p)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// PrincipalFromContext returns the principal attached by Authenticate
+func PrincipalFromContext(ctx context.Context) Principal {
+	p, _ := ctx.Value(principalKey{}).(Principal)
+	return p
+}
+
+// WithPrincipal returns a copy of ctx carrying p, for work done outside a request
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+func parseRoles(header string) []string {
+	var roles []string
+	for _, role := range strings.Split(header, ",") {
+		if role = strings.ToLower(strings.TrimSpace(role)); role != "" {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
+func validKey(keys []string, key string) bool {
+	if key == "" {
+		return false
+	}
+	valid := false
+	for _, k := range keys {
+		if subtle.ConstantTimeCompare([]byte(k), []byte(key)) == 1 {
+			valid = true
+		}
+	}
+	return valid
+}
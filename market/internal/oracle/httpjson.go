@@ -0,0 +1,281 @@
+package oracle
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+	"github.com/ec332/aegis/market/internal/tracing"
+	"github.com/ec332/aegis/market/pkg/models"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// HTTPJSONConfig is the resolution spec config of the http_json resolver
+type HTTPJSONConfig struct {
+	// URL is fetched with GET and must return JSON
+	URL string `json:"url"`
+	// Path selects the value in the response, e.g. "data.price" or "events[0].winner"
+	Path string `json:"path"`
+	// Threshold resolves a categorical market to Above when the value is at least
+	// Threshold and to Below otherwise
+	Threshold *float64 `json:"threshold,omitempty"`
+	Above     string   `json:"above,omitempty"`
+	Below     string   `json:"below,omitempty"`
+	// Outcomes maps source values to option titles; values not listed are matched
+	// against the option titles themselves
+	Outcomes map[string]string `json:"outcomes,omitempty"`
+}
+
+// ErrForbiddenSource is returned for sources the resolver may not fetch: hosts off
+// the allowlist, and addresses on the service's own networks
+var ErrForbiddenSource = errors.New("source not allowed")
+
+// maxRedirects bounds the redirects followed for one source
+const maxRedirects = 5
+
+// HTTPJSON resolves markets from a value in a JSON document served over HTTP.
+// Scalar markets resolve to the value; categorical markets map it to an option.
+// A missing or null value means the outcome is not known yet.
+type HTTPJSON struct {
+	client   *http.Client
+	maxBytes int64
+	// allowedHosts, when not empty, lists the only hosts sources may be on
+	allowedHosts map[string]bool
+}
+
+// NewHTTPJSON creates an HTTP JSON resolver with a per-request timeout and a cap on
+// response size. Sources must be on allowedHosts when it is not empty. Unless
+// allowPrivate is set, connections to loopback, private, link-local and unspecified
+// addresses are refused; the check is made on the address dialed, so neither DNS
+// answers nor redirects get around it.
+func NewHTTPJSON(timeout time.Duration, maxResponseBytes int, allowedHosts []string, allowPrivate bool) *HTTPJSON {
+	h := &HTTPJSON{maxBytes: int64(maxResponseBytes)}
+	if len(allowedHosts) > 0 {
+		h.allowedHosts = make(map[string]bool, len(allowedHosts))
+		for _, host := range allowedHosts {
+			h.allowedHosts[strings.ToLower(host)] = true
+		}
+	}
+
+	dialer := &net.Dialer{Timeout: timeout}
+	if !allowPrivate {
+		dialer.Control = refusePrivate
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// A proxy would make the dialed address the proxy's
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	h.client = &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			return h.checkURL(req.URL)
+		},
+	}
+	return h
+}
+
+// checkURL rejects URLs that are not absolute http or https URLs on an allowed host
+func (h *HTTPJSON) checkURL(u *url.URL) error {
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return fmt.Errorf("url must be an absolute http or https URL")
+	}
+	if h.allowedHosts != nil && !h.allowedHosts[strings.ToLower(u.Hostname())] {
+		return fmt.Errorf("%w: host %s is not on the allowlist", ErrForbiddenSource, u.Hostname())
+	}
+	return nil
+}
+
+// refusePrivate is a net.Dialer Control hook refusing addresses on the service's
+// own networks. It runs after name resolution, on each address dialed.
+func refusePrivate(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !publicIP(ip) {
+		return fmt.Errorf("%w: %s is not a public address", ErrForbiddenSource, host)
+	}
+	return nil
+}
+
+// publicIP reports whether ip may be reached from the internet
+func publicIP(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsUnspecified() &&
+		!ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() && !ip.IsInterfaceLocalMulticast()
+}
+
+// Validate checks the source URL, path and option mapping against market
+func (h *HTTPJSON) Validate(market *models.Market, config json.RawMessage) error {
+	var cfg HTTPJSONConfig
+	if err := decodeConfig(config, &cfg); err != nil {
+		return err
+	}
+
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return fmt.Errorf("url must be an absolute http or https URL")
+	}
+	if err := h.checkURL(u); err != nil {
+		return err
+	}
+	if _, err := parsePath(cfg.Path); err != nil {
+		return err
+	}
+
+	if market.Type == models.MarketTypeScalar {
+		if cfg.Threshold != nil || len(cfg.Outcomes) > 0 {
+			return fmt.Errorf("scalar markets resolve to the value itself; threshold and outcomes do not apply")
+		}
+		return nil
+	}
+
+	if cfg.Threshold != nil {
+		if len(cfg.Outcomes) > 0 {
+			return fmt.Errorf("threshold and outcomes cannot be combined")
+		}
+		for _, title := range []string{cfg.Above, cfg.Below} {
+			if _, ok := optionByTitle(market, title); !ok {
+				return fmt.Errorf("above and below must name options of the market, %q does not", title)
+			}
+		}
+		return nil
+	}
+	for value, title := range cfg.Outcomes {
+		if _, ok := optionByTitle(market, title); !ok {
+			return fmt.Errorf("outcomes[%q] must name an option of the market, %q does not", value, title)
+		}
+	}
+	return nil
+}
+
+// Resolve fetches the source and converts the selected value into an outcome
+func (h *HTTPJSON) Resolve(ctx context.Context, market *models.Market, config json.RawMessage) (_ *Outcome, err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "oracle.HTTPJSON.Resolve")
+	defer func() { tracing.End(span, err) }()
+
+	var cfg HTTPJSONConfig
+	if err := decodeConfig(config, &cfg); err != nil {
+		return nil, err
+	}
+	span.SetAttributes(attribute.String("market.id", market.ID), attribute.String("oracle.url", cfg.URL))
+
+	steps, err := parsePath(cfg.Path)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := h.fetch(ctx, cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+	value, found := lookup(doc, steps)
+	if !found || value == nil {
+		return nil, fmt.Errorf("%w: %s has no value at %s", ErrNoOutcome, cfg.URL, cfg.Path)
+	}
+
+	outcome := &Outcome{
+		Evidence: fmt.Sprintf("GET %s returned %s = %v at %s",
+			cfg.URL, cfg.Path, value, time.Now().UTC().Format(time.RFC3339)),
+	}
+	if market.Type == models.MarketTypeScalar {
+		n, err := number(value)
+		if err != nil {
+			return nil, fmt.Errorf("value at %s: %w", cfg.Path, err)
+		}
+		outcome.ResolvedValue = &n
+		return outcome, nil
+	}
+
+	title, err := cfg.optionTitle(value)
+	if err != nil {
+		return nil, err
+	}
+	option, ok := optionByTitle(market, title)
+	if !ok {
+		return nil, fmt.Errorf("value %v at %s does not match an option", value, cfg.Path)
+	}
+	outcome.WinningOptionID = &option.ID
+	return outcome, nil
+}
+
+// optionTitle maps a source value to the title of the winning option
+func (cfg HTTPJSONConfig) optionTitle(value interface{}) (string, error) {
+	if cfg.Threshold != nil {
+		n, err := number(value)
+		if err != nil {
+			return "", fmt.Errorf("value at %s: %w", cfg.Path, err)
+		}
+		if n >= *cfg.Threshold {
+			return cfg.Above, nil
+		}
+		return cfg.Below, nil
+	}
+
+	key := fmt.Sprint(value)
+	if title, ok := cfg.Outcomes[key]; ok {
+		return title, nil
+	}
+	return key, nil
+}
+
+func (h *HTTPJSON) fetch(ctx context.Context, sourceURL string) (interface{}, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, sourceURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("build request: %w", err)
+	}
+	// The allowlist may have changed since the spec was validated
+	if err := h.checkURL(req.URL); err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch %s: %w", sourceURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch %s: unexpected status %s", sourceURL, resp.Status)
+	}
+
+	var doc interface{}
+	dec := json.NewDecoder(io.LimitReader(resp.Body, h.maxBytes))
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("decode %s: %w", sourceURL, err)
+	}
+	return doc, nil
+}
+
+// number converts a JSON number or numeric string to a float
+func number(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case json.Number:
+		return v.Float64()
+	case string:
+		n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return 0, fmt.Errorf("%q is not a number", v)
+		}
+		return n, nil
+	default:
+		return 0, fmt.Errorf("%v is not a number", value)
+	}
+}
@@ -0,0 +1,286 @@
+package oracle
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+	"github.com/ec332/aegis/market/pkg/models"
+)
+
+func categoricalMarket() *models.Market {
+	return &models.Market{
+		ID:   "market-1",
+		Type: models.MarketTypeCategorical,
+		Options: []models.Option{
+			{ID: "opt-yes", Title: "Yes"},
+			{ID: "opt-no", Title: "No"},
+		},
+	}
+}
+
+func scalarMarket() *models.Market {
+	return &models.Market{ID: "market-2", Type: models.MarketTypeScalar}
+}
+
+// feed serves body with status at any path
+func feed(t *testing.T, status int, body string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			t.Errorf("method = %s, want GET", r.Method)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func specConfig(t *testing.T, cfg HTTPJSONConfig) json.RawMessage {
+	t.Helper()
+	raw, err := json.Marshal(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func TestHTTPJSONResolve(t *testing.T) {
+	threshold := 100000.0
+	tests := []struct {
+		name   string
+		market *models.Market
+		body   string
+		cfg    HTTPJSONConfig
+		value  *float64
+		option string
+		err    error
+	}{
+		{
+			name:   "scalar value",
+			market: scalarMarket(),
+			body:   `{"data": {"price": 101000.5}}`,
+			cfg:    HTTPJSONConfig{Path: "data.price"},
+			value:  floatPtr(101000.5),
+		},
+		{
+			name:   "scalar numeric string",
+			market: scalarMarket(),
+			body:   `{"data": {"price": " 42 "}}`,
+			cfg:    HTTPJSONConfig{Path: "data.price"},
+			value:  floatPtr(42),
+		},
+		{
+			name:   "threshold above",
+			market: categoricalMarket(),
+			body:   `{"data": {"price": 100000}}`,
+			cfg:    HTTPJSONConfig{Path: "data.price", Threshold: &threshold, Above: "Yes", Below: "No"},
+			option: "opt-yes",
+		},
+		{
+			name:   "threshold below",
+			market: categoricalMarket(),
+			body:   `{"data": {"price": 99999.99}}`,
+			cfg:    HTTPJSONConfig{Path: "data.price", Threshold: &threshold, Above: "Yes", Below: "No"},
+			option: "opt-no",
+		},
+		{
+			name:   "mapped outcome",
+			market: categoricalMarket(),
+			body:   `{"events": [{"winner": true}]}`,
+			cfg:    HTTPJSONConfig{Path: "events[0].winner", Outcomes: map[string]string{"true": "Yes", "false": "No"}},
+			option: "opt-yes",
+		},
+		{
+			name:   "option title",
+			market: categoricalMarket(),
+			body:   `{"events": [{"winner": "no"}]}`,
+			cfg:    HTTPJSONConfig{Path: "events[0].winner"},
+			option: "opt-no",
+		},
+		{
+			name:   "null value",
+			market: scalarMarket(),
+			body:   `{"data": {"price": null}}`,
+			cfg:    HTTPJSONConfig{Path: "data.price"},
+			err:    ErrNoOutcome,
+		},
+		{
+			name:   "missing value",
+			market: categoricalMarket(),
+			body:   `{"events": []}`,
+			cfg:    HTTPJSONConfig{Path: "events[0].winner"},
+			err:    ErrNoOutcome,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := feed(t, http.StatusOK, tt.body)
+			h := NewHTTPJSON(time.Second, 1<<10, nil, true)
+			tt.cfg.URL = srv.URL + "/feed"
+
+			outcome, err := h.Resolve(context.Background(), tt.market, specConfig(t, tt.cfg))
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("Resolve error = %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Resolve error = %v", err)
+			}
+			if !strings.Contains(outcome.Evidence, tt.cfg.URL) {
+				t.Errorf("evidence %q does not name the source", outcome.Evidence)
+			}
+			if tt.value != nil {
+				if outcome.ResolvedValue == nil || *outcome.ResolvedValue != *tt.value {
+					t.Errorf("resolved value = %v, want %v", outcome.ResolvedValue, *tt.value)
+				}
+				return
+			}
+			if outcome.WinningOptionID == nil || *outcome.WinningOptionID != tt.option {
+				t.Errorf("winning option = %v, want %s", outcome.WinningOptionID, tt.option)
+			}
+		})
+	}
+}
+
+func TestHTTPJSONResolveFailures(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		path   string
+		err    string
+	}{
+		{name: "status", status: http.StatusServiceUnavailable, body: `{}`, path: "price", err: "unexpected status"},
+		{name: "invalid json", status: http.StatusOK, body: `{"price": `, path: "price", err: "decode"},
+		{name: "too large", status: http.StatusOK, body: `{"price": 1, "pad": "` + strings.Repeat("x", 2048) + `"}`, path: "price", err: "decode"},
+		{name: "not a number", status: http.StatusOK, body: `{"price": "high"}`, path: "price", err: "not a number"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := feed(t, tt.status, tt.body)
+			h := NewHTTPJSON(time.Second, 1<<10, nil, true)
+			cfg := specConfig(t, HTTPJSONConfig{URL: srv.URL, Path: tt.path})
+
+			_, err := h.Resolve(context.Background(), scalarMarket(), cfg)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("Resolve error = %v, want %q", err, tt.err)
+			}
+			if errors.Is(err, ErrNoOutcome) {
+				t.Errorf("Resolve error = %v, must not be retried as a missing outcome", err)
+			}
+		})
+	}
+}
+
+func TestHTTPJSONRefusesPrivateAddresses(t *testing.T) {
+	srv := feed(t, http.StatusOK, `{"price": 1}`)
+	h := NewHTTPJSON(time.Second, 1<<10, nil, false)
+
+	// httptest listens on loopback
+	_, err := h.Resolve(context.Background(), scalarMarket(), specConfig(t, HTTPJSONConfig{URL: srv.URL, Path: "price"}))
+	if !errors.Is(err, ErrForbiddenSource) {
+		t.Fatalf("Resolve error = %v, want %v", err, ErrForbiddenSource)
+	}
+}
+
+func TestHTTPJSONRefusesRedirectOffAllowlist(t *testing.T) {
+	target := feed(t, http.StatusOK, `{"price": 1}`)
+	redirect := httptest.NewServer(http.RedirectHandler(strings.Replace(target.URL, "127.0.0.1", "localhost", 1), http.StatusFound))
+	t.Cleanup(redirect.Close)
+
+	u, err := url.Parse(redirect.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewHTTPJSON(time.Second, 1<<10, []string{u.Hostname()}, true)
+
+	_, err = h.Resolve(context.Background(), scalarMarket(), specConfig(t, HTTPJSONConfig{URL: redirect.URL, Path: "price"}))
+	if !errors.Is(err, ErrForbiddenSource) {
+		t.Fatalf("Resolve error = %v, want %v", err, ErrForbiddenSource)
+	}
+}
+
+func TestHTTPJSONValidate(t *testing.T) {
+	threshold := 0.5
+	tests := []struct {
+		name    string
+		market  *models.Market
+		cfg     HTTPJSONConfig
+		allowed []string
+		err     string
+	}{
+		{name: "scalar", market: scalarMarket(), cfg: HTTPJSONConfig{URL: "https://feeds.example.com/btc", Path: "data.price"}},
+		{name: "threshold", market: categoricalMarket(), cfg: HTTPJSONConfig{URL: "https://feeds.example.com/x", Path: "p", Threshold: &threshold, Above: "Yes", Below: "no"}},
+		{name: "outcomes", market: categoricalMarket(), cfg: HTTPJSONConfig{URL: "https://feeds.example.com/x", Path: "p", Outcomes: map[string]string{"1": "Yes"}}},
+		{name: "allowed host", market: scalarMarket(), cfg: HTTPJSONConfig{URL: "https://Feeds.Example.com/x", Path: "p"}, allowed: []string{"feeds.example.com"}},
+		{name: "host off allowlist", market: scalarMarket(), cfg: HTTPJSONConfig{URL: "https://evil.example.com/x", Path: "p"}, allowed: []string{"feeds.example.com"}, err: "not on the allowlist"},
+		{name: "scheme", market: scalarMarket(), cfg: HTTPJSONConfig{URL: "file:///etc/passwd", Path: "p"}, err: "absolute http or https"},
+		{name: "relative url", market: scalarMarket(), cfg: HTTPJSONConfig{URL: "/feeds/btc", Path: "p"}, err: "absolute http or https"},
+		{name: "path", market: scalarMarket(), cfg: HTTPJSONConfig{URL: "https://feeds.example.com/x", Path: ""}, err: "path is empty"},
+		{name: "scalar threshold", market: scalarMarket(), cfg: HTTPJSONConfig{URL: "https://feeds.example.com/x", Path: "p", Threshold: &threshold}, err: "do not apply"},
+		{name: "threshold and outcomes", market: categoricalMarket(), cfg: HTTPJSONConfig{URL: "https://feeds.example.com/x", Path: "p", Threshold: &threshold, Above: "Yes", Below: "No", Outcomes: map[string]string{"1": "Yes"}}, err: "cannot be combined"},
+		{name: "unknown option", market: categoricalMarket(), cfg: HTTPJSONConfig{URL: "https://feeds.example.com/x", Path: "p", Threshold: &threshold, Above: "Yes", Below: "Maybe"}, err: `"Maybe"`},
+		{name: "unknown outcome", market: categoricalMarket(), cfg: HTTPJSONConfig{URL: "https://feeds.example.com/x", Path: "p", Outcomes: map[string]string{"1": "Maybe"}}, err: `"Maybe"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHTTPJSON(time.Second, 1<<10, tt.allowed, false)
+			err := h.Validate(tt.market, specConfig(t, tt.cfg))
+			if tt.err == "" {
+				if err != nil {
+					t.Fatalf("Validate error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("Validate error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestHTTPJSONValidateUnknownField(t *testing.T) {
+	h := NewHTTPJSON(time.Second, 1<<10, nil, false)
+	err := h.Validate(scalarMarket(), json.RawMessage(`{"url": "https://feeds.example.com/x", "path": "p", "method": "POST"}`))
+	if err == nil || !strings.Contains(err.Error(), "invalid config") {
+		t.Fatalf("Validate error = %v, want invalid config", err)
+	}
+}
+
+func TestPublicIP(t *testing.T) {
+	tests := map[string]bool{
+		"8.8.8.8":         true,
+		"2606:4700::1111": true,
+		"127.0.0.1":       false,
+		"::1":             false,
+		"10.1.2.3":        false,
+		"172.16.0.1":      false,
+		"192.168.1.1":     false,
+		"169.254.169.254": false,
+		"fe80::1":         false,
+		"fd00::1":         false,
+		"0.0.0.0":         false,
+		"::":              false,
+		"::ffff:10.0.0.1": false,
+	}
+	for addr, want := range tests {
+		if got := publicIP(net.ParseIP(addr)); got != want {
+			t.Errorf("publicIP(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
+
+func floatPtr(f float64) *float64 {
+	return &f
+}
@@ -0,0 +1,21 @@
+package oracle
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/ec332/aegis/market/pkg/models"
+)
+
+// Manual leaves resolution to a user with the resolver role; it never proposes
+type Manual struct{}
+
+// Validate accepts an empty config
+func (Manual) Validate(_ *models.Market, config json.RawMessage) error {
+	return decodeConfig(config, &struct{}{})
+}
+
+// Resolve always reports ErrNoOutcome
+func (Manual) Resolve(context.Context, *models.Market, json.RawMessage) (*Outcome, error) {
+	return nil, fmt.Errorf("%w: awaiting a manual proposal", ErrNoOutcome)
+}
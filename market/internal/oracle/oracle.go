@@ -0,0 +1,96 @@
+package oracle
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"github.com/ec332/aegis/market/pkg/models"
+)
+
+// Resolver names shipped with the service
+const (
+	ResolverManual   = "manual"
+	ResolverHTTPJSON = "http_json"
+)
+
+// ErrNoOutcome is returned when a source has no outcome yet; the market is retried later
+var ErrNoOutcome = errors.New("no outcome available")
+
+// Outcome is a market result fetched from a source, proposed as its resolution
+type Outcome struct {
+	// WinningOptionID is set for categorical markets
+	WinningOptionID *string
+	// ResolvedValue is set for scalar markets
+	ResolvedValue *float64
+	// Evidence describes where and when the outcome was read
+	Evidence string
+}
+
+// Resolver fetches market outcomes from one kind of source. Each market carries its
+// resolver's settings in its resolution spec.
+type Resolver interface {
+	// Validate checks config for market when the spec is set
+	Validate(market *models.Market, config json.RawMessage) error
+	// Resolve fetches the outcome of market, or ErrNoOutcome if it is not known yet
+	Resolve(ctx context.Context, market *models.Market, config json.RawMessage) (*Outcome, error)
+}
+
+// Registry holds the available resolvers by name
+type Registry struct {
+	resolvers map[string]Resolver
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{resolvers: map[string]Resolver{}}
+}
+
+// Register adds a resolver under name, replacing any previous one
+func (r *Registry) Register(name string, resolver Resolver) {
+	r.resolvers[name] = resolver
+}
+
+// Get returns the resolver registered under name
+func (r *Registry) Get(name string) (Resolver, bool) {
+	resolver, ok := r.resolvers[name]
+	return resolver, ok
+}
+
+// Names lists the registered resolvers in alphabetical order
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.resolvers))
+	for name := range r.resolvers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// decodeConfig strictly decodes a resolver config; empty configs leave dst unchanged
+func decodeConfig(config json.RawMessage, dst interface{}) error {
+	if len(config) == 0 {
+		return nil
+	}
+	dec := json.NewDecoder(strings.NewReader(string(config)))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(dst); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+	return nil
+}
+
+// optionByTitle finds the option titled, or short-labelled, title ignoring case
+func optionByTitle(market *models.Market, title string) (*models.Option, bool) {
+	title = strings.TrimSpace(title)
+	for i := range market.Options {
+		option := &market.Options[i]
+		if strings.EqualFold(option.Title, title) ||
+			(option.ShortLabel != nil && strings.EqualFold(*option.ShortLabel, title)) {
+			return option, true
+		}
+	}
+	return nil, false
+}
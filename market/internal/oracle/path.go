@@ -0,0 +1,73 @@
+package oracle
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// pathStep is one key or array index of a JSON path
+type pathStep struct {
+	key   string
+	index int
+	isKey bool
+}
+
+// parsePath parses a dotted JSON path such as "data.price" or "$.events[0].winner"
+func parsePath(path string) ([]pathStep, error) {
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	if path == "" {
+		return nil, fmt.Errorf("path is empty")
+	}
+
+	var steps []pathStep
+	for _, part := range strings.Split(path, ".") {
+		key, rest, _ := strings.Cut(part, "[")
+		if key == "" && rest == "" {
+			return nil, fmt.Errorf("path %q has an empty segment", path)
+		}
+		if key != "" {
+			steps = append(steps, pathStep{key: key, isKey: true})
+		}
+		for rest != "" {
+			idx, after, ok := strings.Cut(rest, "]")
+			if !ok {
+				return nil, fmt.Errorf("path %q has an unclosed index", path)
+			}
+			n, err := strconv.Atoi(idx)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("path %q has an invalid index %q", path, idx)
+			}
+			steps = append(steps, pathStep{index: n})
+			if after != "" && !strings.HasPrefix(after, "[") {
+				return nil, fmt.Errorf("path %q has text after an index", path)
+			}
+			rest = strings.TrimPrefix(after, "[")
+		}
+	}
+	return steps, nil
+}
+
+// lookup follows steps through a decoded JSON document. Missing keys and indexes
+// report found as false.
+func lookup(doc interface{}, steps []pathStep) (value interface{}, found bool) {
+	value = doc
+	for _, step := range steps {
+		if step.isKey {
+			obj, ok := value.(map[string]interface{})
+			if !ok {
+				return nil, false
+			}
+			if value, ok = obj[step.key]; !ok {
+				return nil, false
+			}
+			continue
+		}
+		arr, ok := value.([]interface{})
+		if !ok || step.index >= len(arr) {
+			return nil, false
+		}
+		value = arr[step.index]
+	}
+	return value, true
+}
@@ -0,0 +1,88 @@
+package oracle
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParsePath(t *testing.T) {
+	tests := []struct {
+		path string
+		want []pathStep
+		err  string
+	}{
+		{path: "price", want: []pathStep{{key: "price", isKey: true}}},
+		{path: "data.price", want: []pathStep{{key: "data", isKey: true}, {key: "price", isKey: true}}},
+		{path: "$.data.price", want: []pathStep{{key: "data", isKey: true}, {key: "price", isKey: true}}},
+		{path: "events[0].winner", want: []pathStep{{key: "events", isKey: true}, {index: 0}, {key: "winner", isKey: true}}},
+		{path: "grid[1][2]", want: []pathStep{{key: "grid", isKey: true}, {index: 1}, {index: 2}}},
+		{path: "[3]", want: []pathStep{{index: 3}}},
+		{path: "", err: "path is empty"},
+		{path: "$", err: "path is empty"},
+		{path: "data..price", err: "empty segment"},
+		{path: "events[0", err: "unclosed index"},
+		{path: "events[x]", err: "invalid index"},
+		{path: "events[-1]", err: "invalid index"},
+		{path: "events[0]x", err: "text after an index"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, err := parsePath(tt.path)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("parsePath(%q) error = %v, want %q", tt.path, err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parsePath(%q) error = %v", tt.path, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parsePath(%q) = %+v, want %+v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLookup(t *testing.T) {
+	var doc interface{}
+	dec := json.NewDecoder(strings.NewReader(`{
+		"data": {"price": 101.5, "closed": null},
+		"events": [{"winner": "Yes"}, {"winner": "No"}],
+		"grid": [[1, 2], [3, 4]]
+	}`))
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path  string
+		want  interface{}
+		found bool
+	}{
+		{path: "data.price", want: json.Number("101.5"), found: true},
+		{path: "data.closed", want: nil, found: true},
+		{path: "events[1].winner", want: "No", found: true},
+		{path: "grid[1][0]", want: json.Number("3"), found: true},
+		{path: "data.volume"},
+		{path: "events[2].winner"},
+		{path: "data[0]"},
+		{path: "events.winner"},
+		{path: "data.price.usd"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			steps, err := parsePath(tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, found := lookup(doc, steps)
+			if found != tt.found || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("lookup(%q) = %v, %v, want %v, %v", tt.path, got, found, tt.want, tt.found)
+			}
+		})
+	}
+}
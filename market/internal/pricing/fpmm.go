@@ -0,0 +1,233 @@
+package pricing
+
+import (
+	"errors"
+	"math"
+)
+
+// ErrNoLiquidity is returned when a trade needs pool reserves the pool does not have
+var ErrNoLiquidity = errors.New("pool has no liquidity")
+
+// bisectIterations bounds the searches below; 100 halvings reach float64 precision
+const bisectIterations = 100
+
+// Trading against the pool follows the fixed product market maker: collateral paid
+// in mints complete sets into the pool, and the bought option's reserve shrinks until
+// the product of all reserves is back where it started. Selling runs the same in
+// reverse. Reserves are indexed like the market's pools.
+
+// Tradable reports whether every reserve is positive, so the pool can quote prices
+func Tradable(reserves []float64) bool {
+	if len(reserves) < 2 {
+		return false
+	}
+	for _, r := range reserves {
+		if r <= 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Price returns the marginal price of option i
+func Price(reserves []float64, i int) float64 {
+	var sum float64
+	for _, r := range reserves {
+		sum += 1 / r
+	}
+	return (1 / reserves[i]) / sum
+}
+
+// BuyCost returns the collateral needed to buy shares of option i and the reserves
+// after the trade
+func BuyCost(reserves []float64, i int, shares float64) (float64, []float64, error) {
+	if !Tradable(reserves) {
+		return 0, nil, ErrNoLiquidity
+	}
+	// Each unit of collateral yields at least one share, so cost <= shares
+	cost := bisect(0, shares, func(x float64) bool { return buyShares(reserves, i, x) >= shares })
+	after := make([]float64, len(reserves))
+	for j, r := range reserves {
+		after[j] = r + cost
+	}
+	after[i] -= shares
+	return cost, after, nil
+}
+
+// SellProceeds returns the collateral paid for selling shares of option i to the
+// pool and the reserves after the trade
+func SellProceeds(reserves []float64, i int, shares float64) (float64, []float64, error) {
+	if !Tradable(reserves) {
+		return 0, nil, ErrNoLiquidity
+	}
+	// The pool pays out by burning complete sets, so proceeds are capped by the
+	// smallest other reserve and by shares
+	upper := shares
+	for j, r := range reserves {
+		if j != i && r < upper {
+			upper = r
+		}
+	}
+	k := product(reserves)
+	proceeds := bisect(0, upper, func(x float64) bool {
+		p := reserves[i] + shares - x
+		for j, r := range reserves {
+			if j != i {
+				p *= r - x
+			}
+		}
+		return p <= k
+	})
+	after := make([]float64, len(reserves))
+	for j, r := range reserves {
+		after[j] = r - proceeds
+	}
+	after[i] += shares
+	return proceeds, after, nil
+}
+
+// BuyToPrice returns how many shares of option i can be bought before its price
+// reaches limit, capped at max
+func BuyToPrice(reserves []float64, i int, limit, max float64) float64 {
+	if !Tradable(reserves) || Price(reserves, i) >= limit {
+		return 0
+	}
+	// The collateral paid in refills option i's reserve, so any number of shares
+	// can be bought and the reserve never reaches 0
+	return bisect(0, max, func(n float64) bool {
+		_, after, err := BuyCost(reserves, i, n)
+		return err != nil || Price(after, i) > limit
+	})
+}
+
+// SellToPrice returns how many shares of option i can be sold before its price
+// falls to limit, capped at max
+func SellToPrice(reserves []float64, i int, limit, max float64) float64 {
+	if !Tradable(reserves) || Price(reserves, i) <= limit {
+		return 0
+	}
+	return bisect(0, max, func(n float64) bool {
+		_, after, err := SellProceeds(reserves, i, n)
+		return err != nil || Price(after, i) < limit
+	})
+}
+
+// AddFunding returns the reserves after funding a pool with amount of collateral,
+// the shares of each option sent back to the funder and the LP shares minted for
+// it, out of supply already issued. The collateral mints complete sets; the pool
+// keeps them in proportion to its reserves so prices do not move, and the rest go
+// back to the funder. A pool without LP shares keeps them in proportion to weights
+// instead (all kept when weights is nil), which sets its starting prices.
+func AddFunding(reserves []float64, supply, amount float64, weights []float64) (after, sendBack []float64, minted float64, err error) {
+	keep := weights
+	minted = amount
+	if supply > 0 {
+		if !Tradable(reserves) {
+			return nil, nil, 0, ErrNoLiquidity
+		}
+		keep = reserves
+	}
+
+	var heaviest float64
+	for _, w := range keep {
+		heaviest = math.Max(heaviest, w)
+	}
+	if supply > 0 {
+		minted = amount * supply / heaviest
+	}
+
+	after = make([]float64, len(reserves))
+	sendBack = make([]float64, len(reserves))
+	for i, r := range reserves {
+		kept := amount
+		if keep != nil {
+			kept = amount * keep[i] / heaviest
+		}
+		after[i] = r + kept
+		sendBack[i] = amount - kept
+	}
+	return after, sendBack, minted, nil
+}
+
+// RemoveFunding returns the reserves left after burning shares of supply LP shares
+// and the shares of each option they withdraw
+func RemoveFunding(reserves []float64, supply, shares float64) (after, withdrawn []float64) {
+	after = make([]float64, len(reserves))
+	withdrawn = make([]float64, len(reserves))
+	for i, r := range reserves {
+		withdrawn[i] = r * shares / supply
+		after[i] = r - withdrawn[i]
+	}
+	return after, withdrawn
+}
+
+// FeePool accounts for the fees owed to a pool's liquidity providers. Each provider
+// is owed its fraction of every fee the pool ever collected, less what it already
+// withdrew; providers joining later are debited the fees collected before them as
+// if withdrawn, so they only share in fees collected afterwards.
+type FeePool struct {
+	// Collected is the fees held for the providers
+	Collected float64
+	// Withdrawn sums every provider's withdrawn fees, entry debits included
+	Withdrawn float64
+	// Supply is the LP shares issued
+	Supply float64
+}
+
+// Owed returns the fees a provider holding shares, having withdrawn withdrawn, can
+// still withdraw
+func (f FeePool) Owed(shares, withdrawn float64) float64 {
+	if f.Supply <= 0 {
+		return 0
+	}
+	return math.Max(0, (f.Collected+f.Withdrawn)*shares/f.Supply-withdrawn)
+}
+
+// EntryDebit returns the fees counted as withdrawn by a provider minting shares.
+// The first provider of a pool is debited nothing, so fees collected while the
+// pool had no LP shares must be moved out of Collected before it joins.
+func (f FeePool) EntryDebit(shares float64) float64 {
+	if f.Supply <= 0 {
+		return 0
+	}
+	return (f.Collected + f.Withdrawn) * shares / f.Supply
+}
+
+// buyShares returns the shares of option i bought with collateral
+func buyShares(reserves []float64, i int, collateral float64) float64 {
+	others := 1.0
+	for j, r := range reserves {
+		if j != i {
+			others *= r + collateral
+		}
+	}
+	return reserves[i] + collateral - product(reserves)/others
+}
+
+func product(values []float64) float64 {
+	p := 1.0
+	for _, v := range values {
+		p *= v
+	}
+	return p
+}
+
+// bisect returns the smallest x in [lo, hi] for which past holds, assuming past is
+// monotonic; hi is returned when it never holds
+func bisect(lo, hi float64, past func(float64) bool) float64 {
+	if !past(hi) {
+		return hi
+	}
+	for n := 0; n < bisectIterations && lo < hi; n++ {
+		mid := lo + (hi-lo)/2
+		if mid == lo || mid == hi {
+			break
+		}
+		if past(mid) {
+			hi = mid
+		} else {
+			lo = mid
+		}
+	}
+	return hi
+}
@@ -0,0 +1,419 @@
+package pricing
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+)
+
+// tolerance is the largest error put down to the bisection and float rounding
+const tolerance = 1e-9
+
+var reserveCases = []struct {
+	name     string
+	reserves []float64
+}{
+	{name: "balanced binary", reserves: []float64{100, 100}},
+	{name: "skewed binary", reserves: []float64{30, 270}},
+	{name: "three options", reserves: []float64{50, 120, 400}},
+	{name: "thin pool", reserves: []float64{0.5, 2}},
+	{name: "deep pool", reserves: []float64{1e6, 2.5e6, 4e6, 1e5}},
+}
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) <= tolerance*math.Max(1, math.Max(math.Abs(a), math.Abs(b)))
+}
+
+func priceSum(reserves []float64) float64 {
+	var sum float64
+	for i := range reserves {
+		sum += Price(reserves, i)
+	}
+	return sum
+}
+
+func TestTradable(t *testing.T) {
+	tests := []struct {
+		reserves []float64
+		want     bool
+	}{
+		{reserves: []float64{1, 1}, want: true},
+		{reserves: []float64{1, 2, 3}, want: true},
+		{reserves: []float64{1}},
+		{reserves: nil},
+		{reserves: []float64{1, 0}},
+		{reserves: []float64{-1, 5}},
+	}
+	for _, tt := range tests {
+		if got := Tradable(tt.reserves); got != tt.want {
+			t.Errorf("Tradable(%v) = %v, want %v", tt.reserves, got, tt.want)
+		}
+	}
+}
+
+func TestPricesSumToOne(t *testing.T) {
+	for _, tc := range reserveCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if sum := priceSum(tc.reserves); !near(sum, 1) {
+				t.Errorf("prices sum to %v", sum)
+			}
+			// The smallest reserve is the most likely option
+			for i := range tc.reserves {
+				for j := range tc.reserves {
+					if tc.reserves[i] < tc.reserves[j] && Price(tc.reserves, i) <= Price(tc.reserves, j) {
+						t.Errorf("option %d with reserve %v is not priced above option %d with %v", i, tc.reserves[i], j, tc.reserves[j])
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestBuyCost(t *testing.T) {
+	for _, tc := range reserveCases {
+		for i := range tc.reserves {
+			shares := tc.reserves[i] / 4
+			t.Run(fmt.Sprintf("%s/option %d", tc.name, i), func(t *testing.T) {
+				cost, after, err := BuyCost(tc.reserves, i, shares)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if cost <= 0 || cost > shares {
+					t.Errorf("cost %v of %v shares is outside (0, shares]", cost, shares)
+				}
+				// The price paid lies between the marginal prices before and after
+				avg := cost / shares
+				if avg < Price(tc.reserves, i)-tolerance || avg > Price(after, i)+tolerance {
+					t.Errorf("average price %v outside [%v, %v]", avg, Price(tc.reserves, i), Price(after, i))
+				}
+				if !near(product(after), product(tc.reserves)) {
+					t.Errorf("product moved from %v to %v", product(tc.reserves), product(after))
+				}
+				if sum := priceSum(after); !near(sum, 1) {
+					t.Errorf("prices sum to %v after the buy", sum)
+				}
+			})
+		}
+	}
+}
+
+func TestSellProceeds(t *testing.T) {
+	for _, tc := range reserveCases {
+		for i := range tc.reserves {
+			shares := tc.reserves[i] / 4
+			t.Run(fmt.Sprintf("%s/option %d", tc.name, i), func(t *testing.T) {
+				proceeds, after, err := SellProceeds(tc.reserves, i, shares)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if proceeds <= 0 || proceeds > shares {
+					t.Errorf("proceeds %v of %v shares are outside (0, shares]", proceeds, shares)
+				}
+				avg := proceeds / shares
+				if avg > Price(tc.reserves, i)+tolerance || avg < Price(after, i)-tolerance {
+					t.Errorf("average price %v outside [%v, %v]", avg, Price(after, i), Price(tc.reserves, i))
+				}
+				if !near(product(after), product(tc.reserves)) {
+					t.Errorf("product moved from %v to %v", product(tc.reserves), product(after))
+				}
+				if sum := priceSum(after); !near(sum, 1) {
+					t.Errorf("prices sum to %v after the sale", sum)
+				}
+			})
+		}
+	}
+}
+
+func TestBuyThenSellRoundTrips(t *testing.T) {
+	for _, tc := range reserveCases {
+		for i := range tc.reserves {
+			shares := tc.reserves[i] / 3
+			t.Run(fmt.Sprintf("%s/option %d", tc.name, i), func(t *testing.T) {
+				cost, bought, err := BuyCost(tc.reserves, i, shares)
+				if err != nil {
+					t.Fatal(err)
+				}
+				proceeds, sold, err := SellProceeds(bought, i, shares)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !near(proceeds, cost) {
+					t.Errorf("bought for %v, sold back for %v", cost, proceeds)
+				}
+				for j := range tc.reserves {
+					if !near(sold[j], tc.reserves[j]) {
+						t.Errorf("reserve %d is %v after the round trip, want %v", j, sold[j], tc.reserves[j])
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestTradesNeedLiquidity(t *testing.T) {
+	for _, reserves := range [][]float64{{0, 10}, {10}, nil} {
+		if _, _, err := BuyCost(reserves, 0, 1); !errors.Is(err, ErrNoLiquidity) {
+			t.Errorf("BuyCost(%v) error = %v, want %v", reserves, err, ErrNoLiquidity)
+		}
+		if _, _, err := SellProceeds(reserves, 0, 1); !errors.Is(err, ErrNoLiquidity) {
+			t.Errorf("SellProceeds(%v) error = %v, want %v", reserves, err, ErrNoLiquidity)
+		}
+		if n := BuyToPrice(reserves, 0, 0.9, 10); n != 0 {
+			t.Errorf("BuyToPrice(%v) = %v, want 0", reserves, n)
+		}
+		if n := SellToPrice(reserves, 0, 0.1, 10); n != 0 {
+			t.Errorf("SellToPrice(%v) = %v, want 0", reserves, n)
+		}
+	}
+}
+
+func TestBuyToPrice(t *testing.T) {
+	reserves := []float64{100, 100}
+	tests := []struct {
+		name   string
+		limit  float64
+		max    float64
+		capped bool
+		zero   bool
+	}{
+		{name: "reaches limit", limit: 0.7, max: 1000},
+		{name: "near certainty", limit: 0.99, max: 1000},
+		{name: "capped", limit: 0.9, max: 5, capped: true},
+		{name: "already above", limit: 0.5, max: 1000, zero: true},
+		{name: "below price", limit: 0.3, max: 1000, zero: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := BuyToPrice(reserves, 0, tt.limit, tt.max)
+			switch {
+			case tt.zero:
+				if n != 0 {
+					t.Errorf("BuyToPrice = %v, want 0", n)
+				}
+			case tt.capped:
+				if n != tt.max {
+					t.Errorf("BuyToPrice = %v, want the cap %v", n, tt.max)
+				}
+			default:
+				_, after, err := BuyCost(reserves, 0, n)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if p := Price(after, 0); math.Abs(p-tt.limit) > 1e-6 {
+					t.Errorf("price after buying %v shares is %v, want %v", n, p, tt.limit)
+				}
+			}
+		})
+	}
+}
+
+func TestSellToPrice(t *testing.T) {
+	reserves := []float64{100, 100}
+	tests := []struct {
+		name   string
+		limit  float64
+		max    float64
+		capped bool
+		zero   bool
+	}{
+		{name: "reaches limit", limit: 0.3, max: 1000},
+		{name: "near zero", limit: 0.01, max: 1e6},
+		{name: "capped", limit: 0.1, max: 5, capped: true},
+		{name: "already below", limit: 0.5, max: 1000, zero: true},
+		{name: "above price", limit: 0.7, max: 1000, zero: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := SellToPrice(reserves, 0, tt.limit, tt.max)
+			switch {
+			case tt.zero:
+				if n != 0 {
+					t.Errorf("SellToPrice = %v, want 0", n)
+				}
+			case tt.capped:
+				if n != tt.max {
+					t.Errorf("SellToPrice = %v, want the cap %v", n, tt.max)
+				}
+			default:
+				_, after, err := SellProceeds(reserves, 0, n)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if p := Price(after, 0); math.Abs(p-tt.limit) > 1e-6 {
+					t.Errorf("price after selling %v shares is %v, want %v", n, p, tt.limit)
+				}
+			}
+		})
+	}
+}
+
+func TestAddFunding(t *testing.T) {
+	t.Run("first funding keeps every set", func(t *testing.T) {
+		after, sendBack, minted, err := AddFunding([]float64{0, 0, 0}, 0, 90, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if minted != 90 {
+			t.Errorf("minted %v LP shares, want 90", minted)
+		}
+		for i := range after {
+			if after[i] != 90 || sendBack[i] != 0 {
+				t.Errorf("option %d: reserve %v, sent back %v, want 90 and 0", i, after[i], sendBack[i])
+			}
+		}
+	})
+
+	t.Run("first funding sets prices from weights", func(t *testing.T) {
+		weights := []float64{1, 3}
+		after, sendBack, minted, err := AddFunding([]float64{0, 0}, 0, 60, weights)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if minted != 60 {
+			t.Errorf("minted %v LP shares, want 60", minted)
+		}
+		for i := range after {
+			if !near(after[i]+sendBack[i], 60) {
+				t.Errorf("option %d: kept %v and sent back %v of 60", i, after[i], sendBack[i])
+			}
+		}
+		// Prices are inversely proportional to the kept reserves
+		if p := Price(after, 0); !near(p, 0.75) {
+			t.Errorf("price of option 0 is %v, want 0.75", p)
+		}
+	})
+
+	for _, tc := range reserveCases {
+		t.Run(tc.name, func(t *testing.T) {
+			const supply, amount = 200.0, 50.0
+			after, sendBack, minted, err := AddFunding(tc.reserves, supply, amount, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			heaviest := 0.0
+			for i := range tc.reserves {
+				heaviest = math.Max(heaviest, tc.reserves[i])
+				if !near(Price(after, i), Price(tc.reserves, i)) {
+					t.Errorf("price of option %d moved from %v to %v", i, Price(tc.reserves, i), Price(after, i))
+				}
+				if sendBack[i] < -tolerance || !near(after[i]-tc.reserves[i]+sendBack[i], amount) {
+					t.Errorf("option %d: kept %v and sent back %v of %v", i, after[i]-tc.reserves[i], sendBack[i], amount)
+				}
+			}
+			// LP shares grow in proportion to the largest reserve
+			if !near(minted/supply, amount/heaviest) {
+				t.Errorf("minted %v of %v LP shares for %v, want %v", minted, supply, amount, supply*amount/heaviest)
+			}
+		})
+	}
+
+	t.Run("drained pool", func(t *testing.T) {
+		if _, _, _, err := AddFunding([]float64{0, 10}, 100, 10, nil); !errors.Is(err, ErrNoLiquidity) {
+			t.Errorf("AddFunding error = %v, want %v", err, ErrNoLiquidity)
+		}
+	})
+}
+
+func TestAddThenRemoveFundingRoundTrips(t *testing.T) {
+	for _, tc := range reserveCases {
+		t.Run(tc.name, func(t *testing.T) {
+			const supply = 100.0
+			funded, sendBack, minted, err := AddFunding(tc.reserves, supply, 25, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			after, withdrawn := RemoveFunding(funded, supply+minted, minted)
+			for i := range tc.reserves {
+				if !near(after[i], tc.reserves[i]) {
+					t.Errorf("reserve %d is %v after the round trip, want %v", i, after[i], tc.reserves[i])
+				}
+				// The funder gets back every share it minted
+				if !near(withdrawn[i]+sendBack[i], 25) {
+					t.Errorf("option %d: withdrew %v and was sent %v, want 25 in all", i, withdrawn[i], sendBack[i])
+				}
+				if !near(Price(after, i), Price(tc.reserves, i)) {
+					t.Errorf("price of option %d moved from %v to %v", i, Price(tc.reserves, i), Price(after, i))
+				}
+			}
+		})
+	}
+}
+
+func TestRemoveFundingAll(t *testing.T) {
+	reserves := []float64{40, 160}
+	after, withdrawn := RemoveFunding(reserves, 80, 80)
+	for i := range reserves {
+		if after[i] != 0 || withdrawn[i] != reserves[i] {
+			t.Errorf("option %d: left %v and withdrew %v, want 0 and %v", i, after[i], withdrawn[i], reserves[i])
+		}
+	}
+}
+
+func TestFeePool(t *testing.T) {
+	// A holds 100 LP shares and the pool collected 10 in fees
+	f := FeePool{Collected: 10, Supply: 100}
+	if owed := f.Owed(100, 0); !near(owed, 10) {
+		t.Fatalf("A is owed %v, want 10", owed)
+	}
+
+	// B mints 100 more; the fees collected so far are debited to it
+	debit := f.EntryDebit(100)
+	if !near(debit, 10) {
+		t.Fatalf("B's entry debit is %v, want 10", debit)
+	}
+	f.Withdrawn += debit
+	f.Supply += 100
+	if owed := f.Owed(100, debit); !near(owed, 0) {
+		t.Errorf("B is owed %v on entry, want 0", owed)
+	}
+	if owed := f.Owed(100, 0); !near(owed, 10) {
+		t.Errorf("A is owed %v after B joined, want 10", owed)
+	}
+
+	// Fees collected afterwards are shared by LP shares
+	f.Collected += 20
+	if owed := f.Owed(100, 0); !near(owed, 20) {
+		t.Errorf("A is owed %v, want 20", owed)
+	}
+	if owed := f.Owed(100, debit); !near(owed, 10) {
+		t.Errorf("B is owed %v, want 10", owed)
+	}
+
+	// A withdraws; what is left covers B exactly
+	f.Collected -= 20
+	f.Withdrawn += 20
+	if owed := f.Owed(100, 20); !near(owed, 0) {
+		t.Errorf("A is owed %v after withdrawing, want 0", owed)
+	}
+	if owed := f.Owed(100, debit); !near(owed, f.Collected) {
+		t.Errorf("B is owed %v, want the %v left", owed, f.Collected)
+	}
+}
+
+func TestFeePoolWithoutSupply(t *testing.T) {
+	f := FeePool{Collected: 5}
+	if owed := f.Owed(10, 0); owed != 0 {
+		t.Errorf("Owed = %v without LP shares, want 0", owed)
+	}
+}
+
+func TestBisect(t *testing.T) {
+	tests := []struct {
+		name string
+		past func(float64) bool
+		want float64
+	}{
+		{name: "threshold", past: func(x float64) bool { return x >= 3.25 }, want: 3.25},
+		{name: "always", past: func(float64) bool { return true }, want: 0},
+		{name: "never", past: func(float64) bool { return false }, want: 10},
+		{name: "square root", past: func(x float64) bool { return x*x >= 2 }, want: math.Sqrt2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := bisect(0, 10, tt.past); !near(got, tt.want) {
+				t.Errorf("bisect = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+// Package pricing derives outcome prices from liquidity pools and settlement
+// payouts from resolved markets. Categorical and scalar markets share both paths:
+// a scalar market is priced as a two-outcome (long/short) market whose payout is
+// split linearly between its bounds.
+package pricing
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ec332/aegis/market/pkg/models"
+)
+
+// Prices returns the price of each option, indexed like pools. Pool values are the
+// outcome-share reserves of a fixed product market maker, so an option's price is
+// inversely proportional to its reserve and prices sum to 1. Options with an empty
+// reserve take the whole price between them; a market with no reserves at all is
+// priced uniformly.
+func Prices(pools []models.LiquidityPool) []float64 {
+	prices := make([]float64, len(pools))
+	if len(pools) == 0 {
+		return prices
+	}
+
+	empty := 0
+	for _, pool := range pools {
+		if pool.PoolValue <= 0 {
+			empty++
+		}
+	}
+	if empty > 0 {
+		for i, pool := range pools {
+			if pool.PoolValue <= 0 {
+				prices[i] = 1 / float64(empty)
+			}
+		}
+		return prices
+	}
+
+	var sum float64
+	for _, pool := range pools {
+		sum += 1 / pool.PoolValue
+	}
+	for i, pool := range pools {
+		prices[i] = (1 / pool.PoolValue) / sum
+	}
+	return prices
+}
+
+// OptionPrices returns prices keyed by option ID
+func OptionPrices(pools []models.LiquidityPool) map[string]float64 {
+	prices := Prices(pools)
+	byOption := make(map[string]float64, len(pools))
+	for i, pool := range pools {
+		byOption[pool.OptionID] = prices[i]
+	}
+	return byOption
+}
+
+// ImpliedValue converts the long option's price of a scalar market into the
+// value the market currently expects
+func ImpliedValue(lower, upper, longPrice float64) float64 {
+	return lower + longPrice*(upper-lower)
+}
+
+// ScalarLongPayout returns what one long share pays when a scalar market resolves
+// to value: 0 at or below lower, 1 at or above upper and linear in between
+func ScalarLongPayout(lower, upper, value float64) float64 {
+	switch {
+	case value <= lower:
+		return 0
+	case value >= upper:
+		return 1
+	default:
+		return (value - lower) / (upper - lower)
+	}
+}
+
+// ErrUnresolved is returned by Payouts when the market lacks its resolution
+var ErrUnresolved = errors.New("market has no resolution")
+
+// Payouts returns what one share of each option pays out, keyed by option ID.
+// Categorical markets pay 1 on the winning option; scalar markets pay long and
+// short shares according to the resolved value. Voided markets pay every option
+// equally, so a complete set is refunded at its cost of 1.
+func Payouts(market *models.Market) (map[string]float64, error) {
+	payouts := make(map[string]float64, len(market.Options))
+
+	switch {
+	case market.Status == models.MarketStatusVoided:
+		for _, option := range market.Options {
+			payouts[option.ID] = 1 / float64(len(market.Options))
+		}
+
+	case market.Type == models.MarketTypeScalar:
+		if market.ResolvedValue == nil || market.LowerBound == nil || market.UpperBound == nil {
+			return nil, ErrUnresolved
+		}
+		long := ScalarLongPayout(*market.LowerBound, *market.UpperBound, *market.ResolvedValue)
+		for _, option := range market.Options {
+			switch option.Side {
+			case models.ScalarSideLong:
+				payouts[option.ID] = long
+			case models.ScalarSideShort:
+				payouts[option.ID] = 1 - long
+			default:
+				return nil, fmt.Errorf("scalar option %s has no side", option.ID)
+			}
+		}
+
+	default:
+		if market.WinningOptionID == nil {
+			return nil, ErrUnresolved
+		}
+		found := false
+		for _, option := range market.Options {
+			if option.ID == *market.WinningOptionID {
+				payouts[option.ID] = 1
+				found = true
+			} else {
+				payouts[option.ID] = 0
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("winning option %s is not an option of market %s", *market.WinningOptionID, market.ID)
+		}
+	}
+
+	return payouts, nil
+}
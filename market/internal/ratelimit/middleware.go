@@ -0,0 +1,160 @@
+package ratelimit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+	"github.com/ec332/aegis/market/internal/metrics"
+	"github.com/ec332/aegis/market/internal/middleware"
+	"github.com/ec332/aegis/market/pkg/models"
+)
+
+// DefaultPolicy is applied to routes whose policy name is not configured
+const DefaultPolicy = "default"
+
+// Middleware enforces rate limit policies on HTTP routes
+type Middleware struct {
+	limiter  *Limiter
+	policies map[string]Policy
+	enabled  bool
+	logger   *slog.Logger
+}
+
+// NewMiddleware creates route middleware using policies by name. When enabled is
+// false every request is let through.
+func NewMiddleware(limiter *Limiter, policies map[string]Policy, enabled bool, logger *slog.Logger) *Middleware {
+	return &Middleware{
+		limiter:  limiter,
+		policies: policies,
+		enabled:  enabled,
+		logger:   logger,
+	}
+}
+
+// Limit enforces the named policy, keyed by authenticated principal or client IP.
+// Redis failures are logged and the request is allowed.
+func (m *Middleware) Limit(name string) func(http.Handler) http.Handler {
+	policy, ok := m.policies[name]
+	if !ok {
+		policy = m.policies[DefaultPolicy]
+	}
+
+	return func(next http.Handler) http.Handler {
+		if !m.enabled || policy.Rate <= 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			result, err := m.limiter.Allow(r.Context(), policy, clientKey(r))
+			if err != nil {
+				m.logger.WarnContext(r.Context(), "rate limiter unavailable, allowing request", "policy", policy.Name, "error", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h := w.Header()
+			h.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+			h.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.ResetAfter)))
+			h.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d;burst=%d", policy.Rate, ceilSeconds(policy.Period), policy.Burst))
+
+			if !result.Allowed {
+				metrics.RateLimitedTotal.WithLabelValues(policy.Name).Inc()
+				h.Set("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+				tooManyRequests(w, fmt.Sprintf("rate limit %q exceeded, retry in %ds", policy.Name, ceilSeconds(result.RetryAfter)))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Concurrent caps the number of simultaneous requests per client, for long-lived
+// connections such as SSE streams. Slots are refreshed while the request is open.
+func (m *Middleware) Concurrent(name string, max int, ttl time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if !m.enabled || max <= 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := clientKey(r)
+			ok, err := m.limiter.Acquire(r.Context(), name, key, max, ttl)
+			if err != nil {
+				m.logger.WarnContext(r.Context(), "concurrency limiter unavailable, allowing request", "limit", name, "error", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+			if !ok {
+				metrics.RateLimitedTotal.WithLabelValues(name).Inc()
+				w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(ttl)))
+				tooManyRequests(w, fmt.Sprintf("at most %d concurrent %s connections allowed", max, name))
+				return
+			}
+
+			done := make(chan struct{})
+			go m.keepSlot(name, key, ttl, done)
+			defer func() {
+				close(done)
+				// The request context is cancelled by now
+				ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+				defer cancel()
+				if err := m.limiter.Release(ctx, name, key); err != nil {
+					m.logger.Warn("failed to release concurrency slot", "limit", name, "error", err)
+				}
+			}()
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// keepSlot refreshes a concurrency slot until done is closed
+func (m *Middleware) keepSlot(name, key string, ttl time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(ttl / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			if err := m.limiter.Refresh(ctx, name, key, ttl); err != nil {
+				m.logger.Warn("failed to refresh concurrency slot", "limit", name, "error", err)
+			}
+			cancel()
+		}
+	}
+}
+
+// clientKey identifies the caller by user when a service key vouched for it, and
+// by client IP otherwise, since an unvouched X-User-ID can change on every request
+func clientKey(r *http.Request) string {
+	if p := middleware.PrincipalFromContext(r.Context()); p.Service && p.UserID != "" {
+		return "user:" + p.UserID
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		// RealIP rewrites RemoteAddr without a port
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+func tooManyRequests(w http.ResponseWriter, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusTooManyRequests)
+	json.NewEncoder(w).Encode(models.ErrorResponse{
+		Error:   "Too many requests",
+		Code:    models.ErrorCodeRateLimited,
+		Message: message,
+	})
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
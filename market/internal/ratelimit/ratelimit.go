@@ -0,0 +1,162 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+	"github.com/redis/go-redis/v9"
+)
+
+// Policy allows Rate requests per Period on average, with bursts of up to Burst
+type Policy struct {
+	Name   string
+	Rate   int
+	Period time.Duration
+	Burst  int
+}
+
+// perSecond returns the bucket refill rate in tokens per second
+func (p Policy) perSecond() float64 {
+	return float64(p.Rate) / p.Period.Seconds()
+}
+
+// Result describes the bucket state after a request was counted
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// ResetAfter is the time until the bucket is full again
+	ResetAfter time.Duration
+	// RetryAfter is the time until the next request would be allowed (zero if allowed)
+	RetryAfter time.Duration
+}
+
+// tokenBucket refills and takes one token atomically. Time comes from the Redis
+// server so all replicas share one clock. Returns {allowed, tokens remaining}.
+var tokenBucket = redis.NewScript(`
+local key = KEYS[1]
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+
+local now = redis.call('TIME')
+now = tonumber(now[1]) + tonumber(now[2]) / 1000000
+
+local state = redis.call('HMGET', key, 'tokens', 'ts')
+local tokens = tonumber(state[1]) or burst
+local ts = tonumber(state[2]) or now
+
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate)
+
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+
+redis.call('HSET', key, 'tokens', tostring(tokens), 'ts', tostring(now))
+redis.call('EXPIRE', key, math.ceil(burst / rate) + 1)
+
+return {allowed, tostring(tokens)}
+`)
+
+// acquireSlot increments a concurrency counter unless it is already at the limit.
+// Returns 1 when a slot was taken.
+var acquireSlot = redis.NewScript(`
+local current = tonumber(redis.call('GET', KEYS[1]) or '0')
+if current >= tonumber(ARGV[1]) then
+	return 0
+end
+redis.call('INCR', KEYS[1])
+redis.call('EXPIRE', KEYS[1], ARGV[2])
+return 1
+`)
+
+// releaseSlot decrements a concurrency counter without going below zero
+var releaseSlot = redis.NewScript(`
+local current = tonumber(redis.call('GET', KEYS[1]) or '0')
+if current > 0 then
+	redis.call('DECR', KEYS[1])
+end
+return 1
+`)
+
+// Limiter enforces token bucket and concurrency limits stored in Redis,
+// so limits hold across every replica of the service
+type Limiter struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewLimiter creates a limiter storing its state under the "ratelimit:" key prefix
+func NewLimiter(client *redis.Client) *Limiter {
+	return &Limiter{client: client, prefix: "ratelimit"}
+}
+
+// Allow counts one request by key against policy p
+func (l *Limiter) Allow(ctx context.Context, p Policy, key string) (Result, error) {
+	redisKey := fmt.Sprintf("%s:%s:%s", l.prefix, p.Name, key)
+	rate := p.perSecond()
+
+	res, err := tokenBucket.Run(ctx, l.client, []string{redisKey}, rate, p.Burst).Slice()
+	if err != nil {
+		return Result{}, fmt.Errorf("run token bucket: %w", err)
+	}
+	if len(res) != 2 {
+		return Result{}, fmt.Errorf("unexpected token bucket reply %v", res)
+	}
+	allowed, _ := res[0].(int64)
+	tokensStr, _ := res[1].(string)
+	tokens, err := strconv.ParseFloat(tokensStr, 64)
+	if err != nil {
+		return Result{}, fmt.Errorf("parse remaining tokens: %w", err)
+	}
+
+	result := Result{
+		Allowed:    allowed == 1,
+		Limit:      p.Burst,
+		Remaining:  int(math.Floor(tokens)),
+		ResetAfter: secondsToDuration((float64(p.Burst) - tokens) / rate),
+	}
+	if !result.Allowed {
+		result.RetryAfter = secondsToDuration((1 - tokens) / rate)
+	}
+	return result, nil
+}
+
+// Acquire takes one of max concurrent slots for key. The slot expires after ttl
+// unless refreshed, so slots held by a crashed replica are eventually released.
+func (l *Limiter) Acquire(ctx context.Context, name, key string, max int, ttl time.Duration) (bool, error) {
+	redisKey := fmt.Sprintf("%s:%s:%s", l.prefix, name, key)
+	ok, err := acquireSlot.Run(ctx, l.client, []string{redisKey}, max, int(ttl.Seconds())).Int()
+	if err != nil {
+		return false, fmt.Errorf("acquire slot: %w", err)
+	}
+	return ok == 1, nil
+}
+
+// Refresh extends the expiry of the concurrency counter for key
+func (l *Limiter) Refresh(ctx context.Context, name, key string, ttl time.Duration) error {
+	redisKey := fmt.Sprintf("%s:%s:%s", l.prefix, name, key)
+	if err := l.client.Expire(ctx, redisKey, ttl).Err(); err != nil {
+		return fmt.Errorf("refresh slot: %w", err)
+	}
+	return nil
+}
+
+// Release returns a slot taken by Acquire
+func (l *Limiter) Release(ctx context.Context, name, key string) error {
+	redisKey := fmt.Sprintf("%s:%s:%s", l.prefix, name, key)
+	if err := releaseSlot.Run(ctx, l.client, []string{redisKey}).Err(); err != nil {
+		return fmt.Errorf("release slot: %w", err)
+	}
+	return nil
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	if seconds <= 0 {
+		return 0
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
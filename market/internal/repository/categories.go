@@ -0,0 +1,249 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+	"github.com/ec332/aegis/market/internal/tracing"
+	"github.com/ec332/aegis/market/pkg/models"
+)
+
+// CreateCategory inserts a new category
+func (r *Repository) CreateCategory(ctx context.Context, category *models.Category) (err error) {
+	ctx, span := startSpan(ctx, "CreateCategory")
+	defer func() { tracing.End(span, err) }()
+
+	query := `
+		INSERT INTO categories (id, parent_id, name, slug, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+	_, err = r.db.ExecContext(ctx, query,
+		category.ID, category.ParentID, category.Name, category.Slug,
+		category.CreatedAt, category.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("insert category: %w", mapError(err))
+	}
+
+	return nil
+}
+
+// GetCategory retrieves a category by ID
+func (r *Repository) GetCategory(ctx context.Context, categoryID string) (_ *models.Category, err error) {
+	ctx, span := startSpan(ctx, "GetCategory")
+	defer func() { tracing.End(span, err) }()
+
+	category := &models.Category{}
+	query := `
+		SELECT id, parent_id, name, slug, created_at, updated_at
+		FROM categories
+		WHERE id = $1
+	`
+	err = r.db.QueryRowContext(ctx, query, categoryID).Scan(
+		&category.ID, &category.ParentID, &category.Name, &category.Slug,
+		&category.CreatedAt, &category.UpdatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("category %s: %w", categoryID, ErrNotFound)
+		}
+		return nil, fmt.Errorf("query category: %w", mapError(err))
+	}
+
+	return category, nil
+}
+
+// ListCategories retrieves all categories ordered by name
+func (r *Repository) ListCategories(ctx context.Context) (_ []models.Category, err error) {
+	ctx, span := startSpan(ctx, "ListCategories")
+	defer func() { tracing.End(span, err) }()
+
+	query := `
+		SELECT id, parent_id, name, slug, created_at, updated_at
+		FROM categories
+		ORDER BY name ASC
+	`
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("query categories: %w", mapError(err))
+	}
+	defer rows.Close()
+
+	categories := []models.Category{}
+	for rows.Next() {
+		category := models.Category{}
+		err := rows.Scan(
+			&category.ID, &category.ParentID, &category.Name, &category.Slug,
+			&category.CreatedAt, &category.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("scan category: %w", err)
+		}
+		categories = append(categories, category)
+	}
+
+	return categories, nil
+}
+
+// UpdateCategory updates category fields. An empty ParentID makes the category top-level.
+func (r *Repository) UpdateCategory(ctx context.Context, categoryID string, updates models.UpdateCategoryRequest) (err error) {
+	ctx, span := startSpan(ctx, "UpdateCategory")
+	defer func() { tracing.End(span, err) }()
+
+	query := "UPDATE categories SET updated_at = $1"
+	args := []interface{}{time.Now()}
+	argCount := 2
+
+	if updates.Name != nil {
+		query += fmt.Sprintf(", name = $%d", argCount)
+		args = append(args, *updates.Name)
+		argCount++
+	}
+	if updates.Slug != nil {
+		query += fmt.Sprintf(", slug = $%d", argCount)
+		args = append(args, *updates.Slug)
+		argCount++
+	}
+	if updates.ParentID != nil {
+		var parentID *string
+		if *updates.ParentID != "" {
+			parentID = updates.ParentID
+		}
+		query += fmt.Sprintf(", parent_id = $%d", argCount)
+		args = append(args, parentID)
+		argCount++
+	}
+
+	query += fmt.Sprintf(" WHERE id = $%d", argCount)
+	args = append(args, categoryID)
+
+	result, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("update category: %w", mapError(err))
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("category %s: %w", categoryID, ErrNotFound)
+	}
+
+	return nil
+}
+
+// DeleteCategory removes a category. It fails with ErrConflict while the category
+// has subcategories or markets.
+func (r *Repository) DeleteCategory(ctx context.Context, categoryID string) (err error) {
+	ctx, span := startSpan(ctx, "DeleteCategory")
+	defer func() { tracing.End(span, err) }()
+
+	result, err := r.db.ExecContext(ctx, `DELETE FROM categories WHERE id = $1`, categoryID)
+	if err != nil {
+		return fmt.Errorf("delete category: %w", mapError(err))
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("category %s: %w", categoryID, ErrNotFound)
+	}
+
+	return nil
+}
+
+// IsCategoryDescendant reports whether candidateID is categoryID or one of its descendants
+func (r *Repository) IsCategoryDescendant(ctx context.Context, categoryID, candidateID string) (_ bool, err error) {
+	ctx, span := startSpan(ctx, "IsCategoryDescendant")
+	defer func() { tracing.End(span, err) }()
+
+	query := `
+		WITH RECURSIVE subtree AS (
+			SELECT id FROM categories WHERE id = $1
+			UNION ALL
+			SELECT c.id FROM categories c JOIN subtree s ON c.parent_id = s.id
+		)
+		SELECT EXISTS (SELECT 1 FROM subtree WHERE id = $2)
+	`
+	var descendant bool
+	if err := r.db.QueryRowContext(ctx, query, categoryID, candidateID).Scan(&descendant); err != nil {
+		return false, fmt.Errorf("query category subtree: %w", mapError(err))
+	}
+
+	return descendant, nil
+}
+
+// MarketFacets counts markets matching filter per category (direct members only),
+// without a category and per tag. The filter's CategoryID is ignored.
+func (r *Repository) MarketFacets(ctx context.Context, filter models.MarketFilter) (_ *models.MarketFacets, err error) {
+	ctx, span := startSpan(ctx, "MarketFacets")
+	defer func() { tracing.End(span, err) }()
+
+	filter.CategoryID = nil
+	where, args := marketFilterClause(filter, nil)
+	facets := &models.MarketFacets{
+		Categories: []models.CategoryCount{},
+		Tags:       []models.TagCount{},
+	}
+
+	query := `
+		SELECT c.id, c.parent_id, c.name, c.slug, COUNT(m.id)
+		FROM categories c
+		LEFT JOIN markets m ON m.category_id = c.id AND ` + where + `
+		GROUP BY c.id
+		ORDER BY c.name ASC
+	`
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query category counts: %w", mapError(err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		count := models.CategoryCount{}
+		if err := rows.Scan(&count.CategoryID, &count.ParentID, &count.Name, &count.Slug, &count.Count); err != nil {
+			return nil, fmt.Errorf("scan category count: %w", err)
+		}
+		facets.Categories = append(facets.Categories, count)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate category counts: %w", mapError(err))
+	}
+
+	query = `SELECT COUNT(*) FROM markets m WHERE m.category_id IS NULL AND ` + where
+	if err := r.db.QueryRowContext(ctx, query, args...).Scan(&facets.Uncategorized); err != nil {
+		return nil, fmt.Errorf("query uncategorized count: %w", mapError(err))
+	}
+
+	query = `
+		SELECT t.tag, COUNT(*)
+		FROM market_tags t
+		JOIN markets m ON m.id = t.market_id
+		WHERE ` + where + `
+		GROUP BY t.tag
+		ORDER BY COUNT(*) DESC, t.tag ASC
+		LIMIT 50
+	`
+	tagRows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query tag counts: %w", mapError(err))
+	}
+	defer tagRows.Close()
+
+	for tagRows.Next() {
+		count := models.TagCount{}
+		if err := tagRows.Scan(&count.Tag, &count.Count); err != nil {
+			return nil, fmt.Errorf("scan tag count: %w", err)
+		}
+		facets.Tags = append(facets.Tags, count)
+	}
+	if err := tagRows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate tag counts: %w", mapError(err))
+	}
+
+	return facets, nil
+}
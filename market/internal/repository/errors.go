@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"github.com/lib/pq"
+)
+
+var (
+	// ErrNotFound is returned when a requested row does not exist
+	ErrNotFound = errors.New("not found")
+	// ErrConflict is returned when a write violates a uniqueness or reference constraint
+	ErrConflict = errors.New("conflict")
+	// ErrUnavailable is returned when the database cannot be reached or is shutting down
+	ErrUnavailable = errors.New("database unavailable")
+	// ErrInsufficientShares is returned when removing more shares than a position holds
+	ErrInsufficientShares = errors.New("insufficient shares")
+)
+
+// mapError classifies a database error as one of the repository errors while
+// keeping the original error in the chain. Unrecognised errors are returned as is.
+func mapError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: %w", ErrNotFound, err)
+	}
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) ||
+		errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code.Class() {
+		case "23": // integrity constraint violation
+			return fmt.Errorf("%w: %w", ErrConflict, err)
+		case "08", "53", "57": // connection exception, insufficient resources, operator intervention
+			return fmt.Errorf("%w: %w", ErrUnavailable, err)
+		}
+		return err
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+
+	return err
+}
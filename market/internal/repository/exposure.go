@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"github.com/ec332/aegis/market/internal/tracing"
+	"github.com/ec332/aegis/market/pkg/models"
+)
+
+// TreasuryStake is what the treasury holds in a market
+type TreasuryStake struct {
+	// Shares is the treasury's part of Supply, the market's LP shares
+	Shares float64
+	Supply float64
+	// Positions holds the treasury's shares of each option by option ID
+	Positions map[string]float64
+	// Invested is the net collateral the treasury has paid into the market
+	Invested float64
+}
+
+// GetTreasuryStake reads what the treasury holds in a market
+func (r *Repository) GetTreasuryStake(ctx context.Context, marketID string) (_ TreasuryStake, err error) {
+	ctx, span := startSpan(ctx, "GetTreasuryStake")
+	defer func() { tracing.End(span, err) }()
+
+	return readTreasuryStake(ctx, r.db, marketID)
+}
+
+// LargestPositions lists the biggest user positions in a market, largest first,
+// leaving out the treasury's
+func (r *Repository) LargestPositions(ctx context.Context, marketID string, limit int) (_ []models.Position, err error) {
+	ctx, span := startSpan(ctx, "LargestPositions")
+	defer func() { tracing.End(span, err) }()
+
+	query := `
+		SELECT user_id, market_id, option_id, shares, updated_at
+		FROM positions
+		WHERE market_id = $1 AND user_id <> $2 AND shares > 0
+		ORDER BY shares DESC, user_id
+		LIMIT $3
+	`
+	return queryPositions(ctx, r.db, query, marketID, models.TreasuryUserID, limit)
+}
+
+// readHoldings returns the shares of each option, by option ID, userID holds in a
+// market counting those its open buy orders would add
+func readHoldings(ctx context.Context, db querier, marketID, userID string) (map[string]float64, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT option_id, SUM(shares)
+		FROM (
+			SELECT option_id, shares FROM positions WHERE market_id = $1 AND user_id = $2
+			UNION ALL
+			SELECT option_id, shares - filled_shares
+			FROM orders
+			WHERE market_id = $1 AND user_id = $2 AND side = $3 AND status IN ($4, $5)
+		) h
+		GROUP BY option_id
+	`, marketID, userID, models.OrderSideBuy, models.OrderStatusOpen, models.OrderStatusPartiallyFilled)
+	if err != nil {
+		return nil, fmt.Errorf("query holdings: %w", mapError(err))
+	}
+	defer rows.Close()
+
+	holdings := map[string]float64{}
+	for rows.Next() {
+		var optionID string
+		var shares float64
+		if err := rows.Scan(&optionID, &shares); err != nil {
+			return nil, fmt.Errorf("scan holding: %w", err)
+		}
+		holdings[optionID] = shares
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate holdings: %w", mapError(err))
+	}
+	return holdings, nil
+}
+
+func readTreasuryStake(ctx context.Context, db querier, marketID string) (TreasuryStake, error) {
+	stake := TreasuryStake{Positions: map[string]float64{}}
+	err := db.QueryRowContext(ctx, `
+		SELECT COALESCE(SUM(shares), 0), COALESCE(SUM(shares) FILTER (WHERE user_id = $2), 0)
+		FROM liquidity_providers
+		WHERE market_id = $1
+	`, marketID, models.TreasuryUserID).Scan(&stake.Supply, &stake.Shares)
+	if err != nil {
+		return stake, fmt.Errorf("query treasury LP shares: %w", mapError(err))
+	}
+
+	err = db.QueryRowContext(ctx,
+		`SELECT -COALESCE(SUM(amount), 0) FROM ledger_entries WHERE account = $1 AND market_id = $2`,
+		models.LedgerAccountUserPrefix+models.TreasuryUserID, marketID,
+	).Scan(&stake.Invested)
+	if err != nil {
+		return stake, fmt.Errorf("query treasury balance: %w", mapError(err))
+	}
+
+	rows, err := db.QueryContext(ctx,
+		`SELECT option_id, shares FROM positions WHERE market_id = $1 AND user_id = $2`,
+		marketID, models.TreasuryUserID,
+	)
+	if err != nil {
+		return stake, fmt.Errorf("query treasury positions: %w", mapError(err))
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var optionID string
+		var shares float64
+		if err := rows.Scan(&optionID, &shares); err != nil {
+			return stake, fmt.Errorf("scan treasury position: %w", err)
+		}
+		stake.Positions[optionID] = shares
+	}
+	if err := rows.Err(); err != nil {
+		return stake, fmt.Errorf("iterate treasury positions: %w", mapError(err))
+	}
+	return stake, nil
+}
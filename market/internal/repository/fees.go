@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"github.com/ec332/aegis/market/internal/tracing"
+	"github.com/ec332/aegis/market/pkg/models"
+)
+
+// FeeCredits sums the fees credited to each account on a market's trades and counts
+// the trades that paid a fee
+func (r *Repository) FeeCredits(ctx context.Context, marketID string) (_ map[string]float64, trades int, err error) {
+	ctx, span := startSpan(ctx, "FeeCredits")
+	defer func() { tracing.End(span, err) }()
+
+	query := `
+		SELECT account, SUM(amount)
+		FROM ledger_entries
+		WHERE market_id = $1 AND kind = $2 AND amount > 0
+		GROUP BY account
+	`
+	rows, err := r.db.QueryContext(ctx, query, marketID, models.LedgerKindFee)
+	if err != nil {
+		return nil, 0, fmt.Errorf("query fee credits: %w", mapError(err))
+	}
+	defer rows.Close()
+
+	credits := map[string]float64{}
+	for rows.Next() {
+		var account string
+		var amount float64
+		if err := rows.Scan(&account, &amount); err != nil {
+			return nil, 0, fmt.Errorf("scan fee credit: %w", err)
+		}
+		credits[account] = amount
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("iterate fee credits: %w", mapError(err))
+	}
+
+	err = r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM trades WHERE market_id = $1 AND fee > 0`, marketID).Scan(&trades)
+	if err != nil {
+		return nil, 0, fmt.Errorf("count fee trades: %w", mapError(err))
+	}
+	return credits, trades, nil
+}
@@ -0,0 +1,234 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+	"github.com/ec332/aegis/market/internal/tracing"
+	"github.com/ec332/aegis/market/pkg/models"
+)
+
+// PriceTick is an option's pool price at a point in time
+type PriceTick struct {
+	MarketID   string
+	OptionID   string
+	Price      float64
+	RecordedAt time.Time
+}
+
+// PriceRange is the lowest and highest price an option traded at over a period
+type PriceRange struct {
+	Low  float64
+	High float64
+}
+
+const haltColumns = `id, market_id, cause, reason, COALESCE(halted_by, ''), halted_at, resume_at, resumed_at,
+	COALESCE(resumed_by, '')`
+
+// HaltMarket halts trading on an active market and records the halt. Markets that
+// are no longer active yield ErrConflict.
+func (r *Repository) HaltMarket(ctx context.Context, halt *models.MarketHalt) (err error) {
+	ctx, span := startSpan(ctx, "HaltMarket")
+	defer func() { tracing.End(span, err) }()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", mapError(err))
+	}
+	defer tx.Rollback()
+
+	if err := setTradingStatus(ctx, tx, halt.MarketID, models.MarketStatusActive, models.MarketStatusHalted); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO market_halts (id, market_id, cause, reason, halted_by, halted_at, resume_at)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7)
+	`
+	_, err = tx.ExecContext(ctx, query,
+		halt.ID, halt.MarketID, halt.Cause, halt.Reason, halt.HaltedBy, halt.HaltedAt, halt.ResumeAt,
+	)
+	if err != nil {
+		return fmt.Errorf("insert market halt: %w", mapError(err))
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit transaction: %w", mapError(err))
+	}
+	return nil
+}
+
+// ResumeMarket reopens trading on a halted market, closing its open halt, which it
+// returns. Markets that are no longer halted yield ErrConflict.
+func (r *Repository) ResumeMarket(ctx context.Context, marketID, resumedBy string, now time.Time) (_ *models.MarketHalt, err error) {
+	ctx, span := startSpan(ctx, "ResumeMarket")
+	defer func() { tracing.End(span, err) }()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", mapError(err))
+	}
+	defer tx.Rollback()
+
+	if err := setTradingStatus(ctx, tx, marketID, models.MarketStatusHalted, models.MarketStatusActive); err != nil {
+		return nil, err
+	}
+	halts, err := closeHalts(ctx, tx, marketID, resumedBy, now)
+	if err != nil {
+		return nil, err
+	}
+	if len(halts) == 0 {
+		return nil, fmt.Errorf("market %s has no open halt: %w", marketID, ErrConflict)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit transaction: %w", mapError(err))
+	}
+	return &halts[len(halts)-1], nil
+}
+
+// ListMarketHalts retrieves a market's halts, newest first
+func (r *Repository) ListMarketHalts(ctx context.Context, marketID string) (_ []models.MarketHalt, err error) {
+	ctx, span := startSpan(ctx, "ListMarketHalts")
+	defer func() { tracing.End(span, err) }()
+
+	query := `SELECT ` + haltColumns + ` FROM market_halts WHERE market_id = $1 ORDER BY halted_at DESC`
+	return queryHalts(ctx, r.db, query, marketID)
+}
+
+// ListDueHalts retrieves the open halts of halted markets whose resume time has passed
+func (r *Repository) ListDueHalts(ctx context.Context, now time.Time) (_ []models.MarketHalt, err error) {
+	ctx, span := startSpan(ctx, "ListDueHalts")
+	defer func() { tracing.End(span, err) }()
+
+	query := `
+		SELECT ` + haltColumns + `
+		FROM market_halts
+		WHERE resumed_at IS NULL AND resume_at <= $1
+		  AND market_id IN (SELECT id FROM markets WHERE status = $2)
+		ORDER BY resume_at ASC
+	`
+	return queryHalts(ctx, r.db, query, now, models.MarketStatusHalted)
+}
+
+// PriceRanges returns the range of each option's recorded prices in a market since
+// the later of since and the market's last resumption, keyed by option ID
+func (r *Repository) PriceRanges(ctx context.Context, marketID string, since time.Time) (_ map[string]PriceRange, err error) {
+	ctx, span := startSpan(ctx, "PriceRanges")
+	defer func() { tracing.End(span, err) }()
+
+	query := `
+		SELECT option_id, MIN(price), MAX(price)
+		FROM price_history
+		WHERE market_id = $1
+		  AND recorded_at >= GREATEST($2, COALESCE((SELECT MAX(resumed_at) FROM market_halts WHERE market_id = $1), $2))
+		GROUP BY option_id
+	`
+	rows, err := r.db.QueryContext(ctx, query, marketID, since)
+	if err != nil {
+		return nil, fmt.Errorf("query price ranges: %w", mapError(err))
+	}
+	defer rows.Close()
+
+	ranges := map[string]PriceRange{}
+	for rows.Next() {
+		var optionID string
+		var pr PriceRange
+		if err := rows.Scan(&optionID, &pr.Low, &pr.High); err != nil {
+			return nil, fmt.Errorf("scan price range: %w", err)
+		}
+		ranges[optionID] = pr
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate price ranges: %w", mapError(err))
+	}
+	return ranges, nil
+}
+
+// PrunePriceHistory deletes up to limit price ticks recorded before before and
+// returns how many it deleted
+func (r *Repository) PrunePriceHistory(ctx context.Context, before time.Time, limit int) (_ int64, err error) {
+	ctx, span := startSpan(ctx, "PrunePriceHistory")
+	defer func() { tracing.End(span, err) }()
+
+	query := `
+		DELETE FROM price_history
+		WHERE ctid IN (SELECT ctid FROM price_history WHERE recorded_at < $1 LIMIT $2)
+	`
+	result, err := r.db.ExecContext(ctx, query, before, limit)
+	if err != nil {
+		return 0, fmt.Errorf("prune price history: %w", mapError(err))
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("get rows affected: %w", err)
+	}
+	return deleted, nil
+}
+
+// setTradingStatus moves a market from status from to status to, yielding
+// ErrConflict if it is no longer in from
+func setTradingStatus(ctx context.Context, tx *sql.Tx, marketID string, from, to models.MarketStatus) error {
+	result, err := tx.ExecContext(ctx,
+		`UPDATE markets SET status = $1, updated_at = NOW() WHERE id = $2 AND status = $3`,
+		to, marketID, from,
+	)
+	if err != nil {
+		return fmt.Errorf("update market status: %w", mapError(err))
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("market %s is not %s: %w", marketID, from, ErrConflict)
+	}
+	return nil
+}
+
+// closeHalts marks a market's open halts resumed at now, returning them
+func closeHalts(ctx context.Context, tx *sql.Tx, marketID, resumedBy string, now time.Time) ([]models.MarketHalt, error) {
+	query := `
+		UPDATE market_halts SET resumed_at = $3, resumed_by = NULLIF($2, '')
+		WHERE market_id = $1 AND resumed_at IS NULL
+		RETURNING ` + haltColumns
+	halts, err := queryHalts(ctx, tx, query, marketID, resumedBy, now)
+	if err != nil {
+		return nil, fmt.Errorf("close halts: %w", err)
+	}
+	return halts, nil
+}
+
+func queryHalts(ctx context.Context, db querier, query string, args ...interface{}) ([]models.MarketHalt, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query market halts: %w", mapError(err))
+	}
+	defer rows.Close()
+
+	halts := []models.MarketHalt{}
+	for rows.Next() {
+		h := models.MarketHalt{}
+		err := rows.Scan(&h.ID, &h.MarketID, &h.Cause, &h.Reason, &h.HaltedBy, &h.HaltedAt, &h.ResumeAt,
+			&h.ResumedAt, &h.ResumedBy)
+		if err != nil {
+			return nil, fmt.Errorf("scan market halt: %w", err)
+		}
+		halts = append(halts, h)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate market halts: %w", mapError(err))
+	}
+	return halts, nil
+}
+
+func insertPriceTicks(ctx context.Context, tx *sql.Tx, ticks []PriceTick) error {
+	query := `INSERT INTO price_history (market_id, option_id, price, recorded_at) VALUES ($1, $2, $3, $4)`
+	for _, t := range ticks {
+		if _, err := tx.ExecContext(ctx, query, t.MarketID, t.OptionID, t.Price, t.RecordedAt); err != nil {
+			return fmt.Errorf("insert price tick: %w", mapError(err))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+	"github.com/ec332/aegis/market/internal/tracing"
+	"github.com/ec332/aegis/market/pkg/models"
+)
+
+// TradeEventResult is what applying a trade event did
+type TradeEventResult struct {
+	// Applied is false for an event processed before, or one older than the last
+	// applied to its pool (Stale)
+	Applied bool
+	Stale   bool
+	// Before and Pools are the market's pools before and after the event
+	Before []models.LiquidityPool
+	Pools  []models.LiquidityPool
+}
+
+// ApplyTradeEvent sets a pool's value for a trade event, notes the event as
+// processed and records the prices the pools are left at, from prices, in one
+// transaction. An event processed before changes nothing; one whose sequence is
+// not above the pool's last is noted as processed but changes nothing either.
+// check decides from the market's status whether its pools can change; its error
+// is returned as is.
+func (r *Repository) ApplyTradeEvent(ctx context.Context, event models.TradeEvent, check func(status models.MarketStatus) error, prices func(pools []models.LiquidityPool) []PriceTick) (_ *TradeEventResult, err error) {
+	ctx, span := startSpan(ctx, "ApplyTradeEvent")
+	defer func() { tracing.End(span, err) }()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", mapError(err))
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO processed_events (event_id, market_id, processed_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (event_id) DO NOTHING
+	`, event.EventID, event.MarketID)
+	if err != nil {
+		return nil, fmt.Errorf("insert processed event: %w", mapError(err))
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return &TradeEventResult{}, nil
+	}
+
+	var status models.MarketStatus
+	err = tx.QueryRowContext(ctx, `SELECT status FROM markets WHERE id = $1 FOR UPDATE`, event.MarketID).Scan(&status)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("market %s: %w", event.MarketID, ErrNotFound)
+		}
+		return nil, fmt.Errorf("lock market: %w", mapError(err))
+	}
+	if err := check(status); err != nil {
+		return nil, err
+	}
+
+	var sequence int64
+	err = tx.QueryRowContext(ctx,
+		`SELECT event_sequence FROM liquidity_pool WHERE id = $1 AND market_id = $2`, event.PoolID, event.MarketID,
+	).Scan(&sequence)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("liquidity pool %s: %w", event.PoolID, ErrNotFound)
+		}
+		return nil, fmt.Errorf("query liquidity pool: %w", mapError(err))
+	}
+	if event.Sequence <= sequence {
+		if err := tx.Commit(); err != nil {
+			return nil, fmt.Errorf("commit transaction: %w", mapError(err))
+		}
+		return &TradeEventResult{Stale: true}, nil
+	}
+
+	before, err := queryPools(ctx, tx, event.MarketID)
+	if err != nil {
+		return nil, err
+	}
+	pool := models.LiquidityPool{ID: event.PoolID, MarketID: event.MarketID, PoolValue: event.PoolValue, UpdatedAt: time.Now()}
+	if _, err := setPoolValue(ctx, tx, pool, reserveSourceIngest, event.EventID); err != nil {
+		return nil, err
+	}
+	_, err = tx.ExecContext(ctx, `UPDATE liquidity_pool SET event_sequence = $1 WHERE id = $2`, event.Sequence, event.PoolID)
+	if err != nil {
+		return nil, fmt.Errorf("update event sequence: %w", mapError(err))
+	}
+
+	pools, err := queryPools(ctx, tx, event.MarketID)
+	if err != nil {
+		return nil, err
+	}
+	if err := insertPriceTicks(ctx, tx, prices(pools)); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit transaction: %w", mapError(err))
+	}
+	return &TradeEventResult{Applied: true, Before: before, Pools: pools}, nil
+}
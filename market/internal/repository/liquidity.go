@@ -0,0 +1,359 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+	"github.com/ec332/aegis/market/internal/pricing"
+	"github.com/ec332/aegis/market/internal/tracing"
+	"github.com/ec332/aegis/market/pkg/models"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// LiquidityProvider is a user's LP shares in a market and the fees counted as
+// withdrawn by it (see pricing.FeePool)
+type LiquidityProvider struct {
+	MarketID      string
+	UserID        string
+	Shares        float64
+	FeesWithdrawn float64
+	UpdatedAt     time.Time
+}
+
+// LiquidityState is what a liquidity change is computed from
+type LiquidityState struct {
+	Status models.MarketStatus
+	Pools  []models.LiquidityPool
+	// Supply is the LP shares issued and Providers the users holding them
+	Supply    float64
+	Providers int
+	// FeesCollected is the balance of the market's LP fee account; FeesWithdrawn
+	// sums the providers' withdrawn fees
+	FeesCollected float64
+	FeesWithdrawn float64
+	// Provider is the user's holding
+	Provider LiquidityProvider
+	// Holdings are the user's shares of each option by option ID, counting those
+	// its open buy orders would add
+	Holdings map[string]float64
+}
+
+// LiquidityChange is a liquidity event applied atomically by ExecuteLiquidity
+type LiquidityChange struct {
+	Event *models.LiquidityEvent
+	Pools []models.LiquidityPool
+	// Provider is the user's holding after the event
+	Provider  LiquidityProvider
+	Positions []PositionChange
+	Ledger    []models.LedgerEntry
+}
+
+// ExecuteLiquidity locks a market, reads the liquidity state of userID in it and
+// applies the change apply returns, all in one transaction
+func (r *Repository) ExecuteLiquidity(ctx context.Context, marketID, userID string, apply func(*LiquidityState) (*LiquidityChange, error)) (err error) {
+	ctx, span := startSpan(ctx, "ExecuteLiquidity")
+	defer func() { tracing.End(span, err) }()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", mapError(err))
+	}
+	defer tx.Rollback()
+
+	state, err := readLiquidityState(ctx, tx, marketID, userID, true)
+	if err != nil {
+		return err
+	}
+	change, err := apply(state)
+	if err != nil {
+		return err
+	}
+	if err := applyLiquidityChange(ctx, tx, marketID, change); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit transaction: %w", mapError(err))
+	}
+	return nil
+}
+
+// applyFunding applies an unpublished market's initial liquidity, failing with
+// ErrConflict if the market was activated or funded concurrently
+func applyFunding(ctx context.Context, tx *sql.Tx, marketID string, change *LiquidityChange) error {
+	var status models.MarketStatus
+	var supply float64
+	err := tx.QueryRowContext(ctx, `
+		SELECT m.status, COALESCE((SELECT SUM(shares) FROM liquidity_providers WHERE market_id = m.id), 0)
+		FROM markets m
+		WHERE m.id = $1
+		FOR UPDATE
+	`, marketID).Scan(&status, &supply)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("market %s: %w", marketID, ErrNotFound)
+		}
+		return fmt.Errorf("lock market: %w", mapError(err))
+	}
+	if (status != models.MarketStatusDraft && status != models.MarketStatusHidden) || supply > 0 {
+		return fmt.Errorf("market %s was funded or published concurrently: %w", marketID, ErrConflict)
+	}
+	return applyLiquidityChange(ctx, tx, marketID, change)
+}
+
+// applyLiquidityChange writes a liquidity event and everything it changed
+func applyLiquidityChange(ctx context.Context, tx *sql.Tx, marketID string, change *LiquidityChange) error {
+	if err := insertLiquidityEvent(ctx, tx, change.Event); err != nil {
+		return err
+	}
+	for _, pool := range change.Pools {
+		if _, err := setPoolValue(ctx, tx, pool, reserveSourceLiquidity, change.Event.ID); err != nil {
+			return err
+		}
+	}
+	p := change.Provider
+	query := `
+		INSERT INTO liquidity_providers (market_id, user_id, shares, fees_withdrawn, updated_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (market_id, user_id) DO UPDATE
+		SET shares = EXCLUDED.shares, fees_withdrawn = EXCLUDED.fees_withdrawn, updated_at = EXCLUDED.updated_at
+	`
+	if _, err := tx.ExecContext(ctx, query, marketID, change.Event.UserID, p.Shares, p.FeesWithdrawn, p.UpdatedAt); err != nil {
+		return fmt.Errorf("update liquidity provider: %w", mapError(err))
+	}
+	for _, c := range change.Positions {
+		if err := changePosition(ctx, tx, c); err != nil {
+			return err
+		}
+	}
+	return insertLedgerEntries(ctx, tx, change.Ledger)
+}
+
+// GetLiquidityState reads a market's liquidity state and userID's holding in it
+// without locking anything
+func (r *Repository) GetLiquidityState(ctx context.Context, marketID, userID string) (_ *LiquidityState, err error) {
+	ctx, span := startSpan(ctx, "GetLiquidityState")
+	defer func() { tracing.End(span, err) }()
+
+	return readLiquidityState(ctx, r.db, marketID, userID, false)
+}
+
+// ListLiquidityProviders retrieves a user's LP holdings, most recently changed first
+func (r *Repository) ListLiquidityProviders(ctx context.Context, userID string) (_ []LiquidityProvider, err error) {
+	ctx, span := startSpan(ctx, "ListLiquidityProviders")
+	defer func() { tracing.End(span, err) }()
+
+	query := `
+		SELECT market_id, user_id, shares, fees_withdrawn, updated_at
+		FROM liquidity_providers
+		WHERE user_id = $1 AND shares > 0
+		ORDER BY updated_at DESC
+	`
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("query liquidity providers: %w", mapError(err))
+	}
+	defer rows.Close()
+
+	providers := []LiquidityProvider{}
+	for rows.Next() {
+		p := LiquidityProvider{}
+		if err := rows.Scan(&p.MarketID, &p.UserID, &p.Shares, &p.FeesWithdrawn, &p.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("scan liquidity provider: %w", err)
+		}
+		providers = append(providers, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate liquidity providers: %w", mapError(err))
+	}
+	return providers, nil
+}
+
+// SumBalances returns the combined balance of the given ledger accounts
+func (r *Repository) SumBalances(ctx context.Context, accounts ...string) (_ float64, err error) {
+	ctx, span := startSpan(ctx, "SumBalances")
+	defer func() { tracing.End(span, err) }()
+
+	var balance float64
+	query := `SELECT COALESCE(SUM(amount), 0) FROM ledger_entries WHERE account = ANY($1)`
+	if err := r.db.QueryRowContext(ctx, query, pq.Array(accounts)).Scan(&balance); err != nil {
+		return 0, fmt.Errorf("sum balances: %w", mapError(err))
+	}
+	return balance, nil
+}
+
+// readLiquidityState reads the liquidity state of a market and userID's holding,
+// locking the market and the holding if lock is set
+func readLiquidityState(ctx context.Context, db querier, marketID, userID string, lock bool) (*LiquidityState, error) {
+	forUpdate := ""
+	if lock {
+		forUpdate = " FOR UPDATE"
+	}
+
+	state := &LiquidityState{Provider: LiquidityProvider{MarketID: marketID, UserID: userID}}
+	err := db.QueryRowContext(ctx, `SELECT status FROM markets WHERE id = $1`+forUpdate, marketID).Scan(&state.Status)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("market %s: %w", marketID, ErrNotFound)
+		}
+		return nil, fmt.Errorf("query market status: %w", mapError(err))
+	}
+
+	if state.Pools, err = queryPools(ctx, db, marketID); err != nil {
+		return nil, err
+	}
+	err = db.QueryRowContext(ctx, `
+		SELECT COALESCE(SUM(shares), 0), COUNT(*) FILTER (WHERE shares > 0), COALESCE(SUM(fees_withdrawn), 0)
+		FROM liquidity_providers
+		WHERE market_id = $1
+	`, marketID).Scan(&state.Supply, &state.Providers, &state.FeesWithdrawn)
+	if err != nil {
+		return nil, fmt.Errorf("query liquidity supply: %w", mapError(err))
+	}
+	err = db.QueryRowContext(ctx,
+		`SELECT COALESCE(SUM(amount), 0) FROM ledger_entries WHERE account = $1`,
+		models.LedgerAccountLPFeesPrefix+marketID,
+	).Scan(&state.FeesCollected)
+	if err != nil {
+		return nil, fmt.Errorf("query liquidity fees: %w", mapError(err))
+	}
+
+	err = db.QueryRowContext(ctx, `
+		SELECT shares, fees_withdrawn, updated_at
+		FROM liquidity_providers
+		WHERE market_id = $1 AND user_id = $2`+forUpdate,
+		marketID, userID,
+	).Scan(&state.Provider.Shares, &state.Provider.FeesWithdrawn, &state.Provider.UpdatedAt)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("query liquidity provider: %w", mapError(err))
+	}
+	if userID != "" {
+		if state.Holdings, err = readHoldings(ctx, db, marketID, userID); err != nil {
+			return nil, err
+		}
+	}
+	return state, nil
+}
+
+// settleLiquidity pays a settling market's liquidity providers their part of the
+// pool, valued at the options' payouts, and the fees they are owed, burns their LP
+// shares and empties the pool
+func settleLiquidity(ctx context.Context, tx *sql.Tx, marketID string, payouts map[string]float64) error {
+	query := `
+		SELECT market_id, user_id, shares, fees_withdrawn, updated_at
+		FROM liquidity_providers
+		WHERE market_id = $1 AND shares > 0
+		FOR UPDATE
+	`
+	rows, err := tx.QueryContext(ctx, query, marketID)
+	if err != nil {
+		return fmt.Errorf("query liquidity providers: %w", mapError(err))
+	}
+	providers := []LiquidityProvider{}
+	for rows.Next() {
+		p := LiquidityProvider{}
+		if err := rows.Scan(&p.MarketID, &p.UserID, &p.Shares, &p.FeesWithdrawn, &p.UpdatedAt); err != nil {
+			rows.Close()
+			return fmt.Errorf("scan liquidity provider: %w", err)
+		}
+		providers = append(providers, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("iterate liquidity providers: %w", mapError(err))
+	}
+	if len(providers) == 0 {
+		return emptyPools(ctx, tx, marketID)
+	}
+
+	state, err := readLiquidityState(ctx, tx, marketID, "", false)
+	if err != nil {
+		return err
+	}
+	var poolValue float64
+	for _, pool := range state.Pools {
+		poolValue += pool.PoolValue * payouts[pool.OptionID]
+	}
+	fees := pricing.FeePool{Collected: state.FeesCollected, Withdrawn: state.FeesWithdrawn, Supply: state.Supply}
+
+	now := time.Now()
+	for _, p := range providers {
+		event := &models.LiquidityEvent{
+			ID:        uuid.New().String(),
+			MarketID:  marketID,
+			UserID:    p.UserID,
+			Action:    models.LiquidityActionSettle,
+			Amount:    poolValue * p.Shares / state.Supply,
+			Shares:    p.Shares,
+			Fees:      fees.Owed(p.Shares, p.FeesWithdrawn),
+			CreatedAt: now,
+		}
+		if err := insertLiquidityEvent(ctx, tx, event); err != nil {
+			return err
+		}
+
+		_, err := tx.ExecContext(ctx, `
+			UPDATE liquidity_providers SET shares = 0, fees_withdrawn = 0, updated_at = $1
+			WHERE market_id = $2 AND user_id = $3
+		`, now, marketID, p.UserID)
+		if err != nil {
+			return fmt.Errorf("settle liquidity provider: %w", mapError(err))
+		}
+
+		user := models.LedgerAccountUserPrefix + p.UserID
+		entries := []models.LedgerEntry{
+			{Account: models.LedgerAccountPoolPrefix + marketID, Kind: models.LedgerKindLiquidity, Amount: -event.Amount},
+			{Account: user, Kind: models.LedgerKindLiquidity, Amount: event.Amount},
+		}
+		if event.Fees > 0 {
+			entries = append(entries,
+				models.LedgerEntry{Account: models.LedgerAccountLPFeesPrefix + marketID, Kind: models.LedgerKindFee, Amount: -event.Fees},
+				models.LedgerEntry{Account: user, Kind: models.LedgerKindFee, Amount: event.Fees},
+			)
+		}
+		for i := range entries {
+			entries[i].ID = uuid.New().String()
+			entries[i].MarketID = marketID
+			entries[i].RefID = event.ID
+			entries[i].CreatedAt = now
+		}
+		if err := insertLedgerEntries(ctx, tx, entries); err != nil {
+			return err
+		}
+	}
+	return emptyPools(ctx, tx, marketID)
+}
+
+// emptyPools zeroes a settled market's reserves, which were paid out
+func emptyPools(ctx context.Context, tx *sql.Tx, marketID string) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO reserve_changes (market_id, option_id, source, ref_id, change)
+		SELECT market_id, option_id, $2, market_id::text, -pool_value
+		FROM liquidity_pool
+		WHERE market_id = $1 AND pool_value <> 0
+	`, marketID, reserveSourceSettlement)
+	if err != nil {
+		return fmt.Errorf("insert reserve change: %w", mapError(err))
+	}
+	_, err = tx.ExecContext(ctx,
+		`UPDATE liquidity_pool SET pool_value = 0, updated_at = NOW() WHERE market_id = $1`, marketID,
+	)
+	if err != nil {
+		return fmt.Errorf("empty liquidity pools: %w", mapError(err))
+	}
+	return nil
+}
+
+func insertLiquidityEvent(ctx context.Context, db execer, e *models.LiquidityEvent) error {
+	query := `
+		INSERT INTO liquidity_events (id, market_id, user_id, action, amount, shares, fees, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+	_, err := db.ExecContext(ctx, query, e.ID, e.MarketID, e.UserID, e.Action, e.Amount, e.Shares, e.Fees, e.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("insert liquidity event: %w", mapError(err))
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/ec332/aegis/market/internal/tracing"
+	"github.com/ec332/aegis/market/pkg/models"
+)
+
+// optionColumns lists the options columns read by scanOption
+const optionColumns = `id, market_id, title, short_label, image_url, display_order, COALESCE(side, ''), payout, created_at`
+
+// scanOption reads a row selected with optionColumns
+func scanOption(row rowScanner) (*models.Option, error) {
+	option := &models.Option{}
+	err := row.Scan(
+		&option.ID, &option.MarketID, &option.Title, &option.ShortLabel, &option.ImageURL,
+		&option.DisplayOrder, &option.Side, &option.Payout, &option.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return option, nil
+}
+
+// GetOption retrieves an option of a market
+func (r *Repository) GetOption(ctx context.Context, marketID, optionID string) (_ *models.Option, err error) {
+	ctx, span := startSpan(ctx, "GetOption")
+	defer func() { tracing.End(span, err) }()
+
+	query := `SELECT ` + optionColumns + ` FROM options WHERE id = $1 AND market_id = $2`
+	option, err := scanOption(r.db.QueryRowContext(ctx, query, optionID, marketID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("option %s: %w", optionID, ErrNotFound)
+		}
+		return nil, fmt.Errorf("query option: %w", mapError(err))
+	}
+
+	return option, nil
+}
+
+// CreateOption adds an option and its liquidity pool, placing the option last
+func (r *Repository) CreateOption(ctx context.Context, option *models.Option, pool *models.LiquidityPool) (err error) {
+	ctx, span := startSpan(ctx, "CreateOption")
+	defer func() { tracing.End(span, err) }()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", mapError(err))
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO options (id, market_id, title, short_label, image_url, display_order, created_at)
+		SELECT $1, $2, $3, $4, $5, COALESCE(MAX(display_order) + 1, 0), $6
+		FROM options
+		WHERE market_id = $2
+		RETURNING display_order
+	`
+	err = tx.QueryRowContext(ctx, query,
+		option.ID, option.MarketID, option.Title, option.ShortLabel, option.ImageURL, option.CreatedAt,
+	).Scan(&option.DisplayOrder)
+	if err != nil {
+		return fmt.Errorf("insert option: %w", mapError(err))
+	}
+
+	poolQuery := `
+		INSERT INTO liquidity_pool (id, market_id, option_id, pool_value, updated_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+	_, err = tx.ExecContext(ctx, poolQuery,
+		pool.ID, pool.MarketID, pool.OptionID, pool.PoolValue, pool.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("insert liquidity pool: %w", mapError(err))
+	}
+	if err := insertReserveChange(ctx, tx, pool.MarketID, pool.OptionID, reserveSourceFunding, pool.ID, pool.PoolValue); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit transaction: %w", mapError(err))
+	}
+	return nil
+}
+
+// UpdateOption updates option fields. Empty short label or image URL values clear them.
+func (r *Repository) UpdateOption(ctx context.Context, marketID, optionID string, updates models.UpdateOptionRequest) (err error) {
+	ctx, span := startSpan(ctx, "UpdateOption")
+	defer func() { tracing.End(span, err) }()
+
+	query := "UPDATE options SET title = COALESCE($1, title)"
+	args := []interface{}{updates.Title}
+	argCount := 2
+
+	if updates.ShortLabel != nil {
+		query += fmt.Sprintf(", short_label = NULLIF($%d, '')", argCount)
+		args = append(args, *updates.ShortLabel)
+		argCount++
+	}
+	if updates.ImageURL != nil {
+		query += fmt.Sprintf(", image_url = NULLIF($%d, '')", argCount)
+		args = append(args, *updates.ImageURL)
+		argCount++
+	}
+
+	query += fmt.Sprintf(" WHERE id = $%d AND market_id = $%d", argCount, argCount+1)
+	args = append(args, optionID, marketID)
+
+	result, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("update option: %w", mapError(err))
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("option %s: %w", optionID, ErrNotFound)
+	}
+
+	return nil
+}
+
+// DeleteOption removes an option; its liquidity pool is removed with it
+func (r *Repository) DeleteOption(ctx context.Context, marketID, optionID string) (err error) {
+	ctx, span := startSpan(ctx, "DeleteOption")
+	defer func() { tracing.End(span, err) }()
+
+	result, err := r.db.ExecContext(ctx, `DELETE FROM options WHERE id = $1 AND market_id = $2`, optionID, marketID)
+	if err != nil {
+		return fmt.Errorf("delete option: %w", mapError(err))
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("option %s: %w", optionID, ErrNotFound)
+	}
+
+	return nil
+}
+
+// ReorderOptions sets each option's display order to its index in optionIDs
+func (r *Repository) ReorderOptions(ctx context.Context, marketID string, optionIDs []string) (err error) {
+	ctx, span := startSpan(ctx, "ReorderOptions")
+	defer func() { tracing.End(span, err) }()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", mapError(err))
+	}
+	defer tx.Rollback()
+
+	for i, optionID := range optionIDs {
+		result, err := tx.ExecContext(ctx,
+			`UPDATE options SET display_order = $1 WHERE id = $2 AND market_id = $3`,
+			i, optionID, marketID,
+		)
+		if err != nil {
+			return fmt.Errorf("update display order: %w", mapError(err))
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("get rows affected: %w", err)
+		}
+		if rowsAffected == 0 {
+			return fmt.Errorf("option %s: %w", optionID, ErrNotFound)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit transaction: %w", mapError(err))
+	}
+	return nil
+}
@@ -0,0 +1,523 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+	"github.com/ec332/aegis/market/internal/tracing"
+	"github.com/ec332/aegis/market/pkg/models"
+)
+
+// orderColumns lists the orders columns read by scanOrder
+const orderColumns = `id, market_id, option_id, user_id, side, order_type, price, shares, filled_shares, status,
+	created_at, updated_at`
+
+// openOrderStatuses matches orders resting on the book
+const openOrderStatuses = `('open', 'partially_filled')`
+
+// scanOrder reads a row selected with orderColumns
+func scanOrder(row rowScanner) (*models.Order, error) {
+	o := &models.Order{}
+	err := row.Scan(
+		&o.ID, &o.MarketID, &o.OptionID, &o.UserID, &o.Side, &o.Type, &o.Price, &o.Shares, &o.FilledShares, &o.Status,
+		&o.CreatedAt, &o.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// BookState is what an incoming order is matched against, read while the market is
+// locked
+type BookState struct {
+	Status models.MarketStatus
+	Pools  []models.LiquidityPool
+	// Resting lists the other users' orders on the opposite side of the option in
+	// priority order: best price first, then oldest
+	Resting []models.Order
+	// Position is the shares of the option the order's owner holds
+	Position float64
+	// OpenBuys is the unfilled shares of the owner's resting buy orders for the option
+	OpenBuys float64
+	// OpenBuyValue is what all the owner's resting buy orders, in every market, would
+	// pay if they filled
+	OpenBuyValue float64
+	// Treasury is what the treasury holds in the market
+	Treasury TreasuryStake
+}
+
+// PositionChange adds Shares (negative to remove) to a user's position
+type PositionChange struct {
+	UserID   string
+	MarketID string
+	OptionID string
+	Shares   float64
+}
+
+// OrderExecution is a matched order applied atomically by ExecuteOrder
+type OrderExecution struct {
+	// Order is the incoming order as it stands after matching
+	Order  *models.Order
+	Trades []models.Trade
+	// Makers are the resting orders that traded, with their new fill and status
+	Makers    []models.Order
+	Pools     []models.LiquidityPool
+	Positions []PositionChange
+	Ledger    []models.LedgerEntry
+	// Prices are the option prices the pools are left at, recorded in the price history
+	Prices []PriceTick
+}
+
+// ExecuteOrder locks the order's market, reads the book state match needs and
+// applies the execution match returns, all in one transaction. Resting orders
+// priced beyond limit (if set) are left out of the state.
+func (r *Repository) ExecuteOrder(ctx context.Context, order *models.Order, limit *float64, match func(*BookState) (*OrderExecution, error)) (err error) {
+	ctx, span := startSpan(ctx, "ExecuteOrder")
+	defer func() { tracing.End(span, err) }()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", mapError(err))
+	}
+	defer tx.Rollback()
+
+	state, err := readBookState(ctx, tx, order, limit, true)
+	if err != nil {
+		return err
+	}
+
+	exec, err := match(state)
+	if err != nil {
+		return err
+	}
+	if err := applyExecution(ctx, tx, exec); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit transaction: %w", mapError(err))
+	}
+	return nil
+}
+
+// ReadBookState reads the state order would be matched against without locking
+// anything, for quoting
+func (r *Repository) ReadBookState(ctx context.Context, order *models.Order, limit *float64) (_ *BookState, err error) {
+	ctx, span := startSpan(ctx, "ReadBookState")
+	defer func() { tracing.End(span, err) }()
+
+	return readBookState(ctx, r.db, order, limit, false)
+}
+
+// readBookState reads the market's status and pools, the resting orders order can
+// trade with and its owner's position, locking the market and those orders if lock
+// is set
+func readBookState(ctx context.Context, db querier, order *models.Order, limit *float64, lock bool) (*BookState, error) {
+	forUpdate := ""
+	if lock {
+		forUpdate = " FOR UPDATE"
+	}
+
+	state := &BookState{}
+	err := db.QueryRowContext(ctx, `SELECT status FROM markets WHERE id = $1`+forUpdate, order.MarketID).Scan(&state.Status)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("market %s: %w", order.MarketID, ErrNotFound)
+		}
+		return nil, fmt.Errorf("query market status: %w", mapError(err))
+	}
+
+	if state.Pools, err = queryPools(ctx, db, order.MarketID); err != nil {
+		return nil, err
+	}
+	if state.Resting, err = queryResting(ctx, db, order, limit, forUpdate); err != nil {
+		return nil, err
+	}
+	err = db.QueryRowContext(ctx,
+		`SELECT COALESCE((SELECT shares FROM positions WHERE user_id = $1 AND option_id = $2), 0)`,
+		order.UserID, order.OptionID,
+	).Scan(&state.Position)
+	if err != nil {
+		return nil, fmt.Errorf("query position: %w", mapError(err))
+	}
+	err = db.QueryRowContext(ctx, `
+		SELECT COALESCE(SUM(shares - filled_shares), 0)
+		FROM orders
+		WHERE user_id = $1 AND option_id = $2 AND side = $3 AND status IN ($4, $5)
+	`, order.UserID, order.OptionID, models.OrderSideBuy, models.OrderStatusOpen, models.OrderStatusPartiallyFilled,
+	).Scan(&state.OpenBuys)
+	if err != nil {
+		return nil, fmt.Errorf("query open buy orders: %w", mapError(err))
+	}
+	err = db.QueryRowContext(ctx, `
+		SELECT COALESCE(SUM((shares - filled_shares) * price), 0)
+		FROM orders
+		WHERE user_id = $1 AND side = $2 AND status IN ($3, $4)
+	`, order.UserID, models.OrderSideBuy, models.OrderStatusOpen, models.OrderStatusPartiallyFilled,
+	).Scan(&state.OpenBuyValue)
+	if err != nil {
+		return nil, fmt.Errorf("query open buy value: %w", mapError(err))
+	}
+	if state.Treasury, err = readTreasuryStake(ctx, db, order.MarketID); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// GetOrder retrieves an order by ID
+func (r *Repository) GetOrder(ctx context.Context, orderID string) (_ *models.Order, err error) {
+	ctx, span := startSpan(ctx, "GetOrder")
+	defer func() { tracing.End(span, err) }()
+
+	query := `SELECT ` + orderColumns + ` FROM orders WHERE id = $1`
+	order, err := scanOrder(r.db.QueryRowContext(ctx, query, orderID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("order %s: %w", orderID, ErrNotFound)
+		}
+		return nil, fmt.Errorf("query order: %w", mapError(err))
+	}
+	return order, nil
+}
+
+// ListOrders retrieves a user's orders in a market, newest first
+func (r *Repository) ListOrders(ctx context.Context, marketID, userID string) (_ []models.Order, err error) {
+	ctx, span := startSpan(ctx, "ListOrders")
+	defer func() { tracing.End(span, err) }()
+
+	query := `
+		SELECT ` + orderColumns + `
+		FROM orders
+		WHERE market_id = $1 AND user_id = $2
+		ORDER BY created_at DESC, seq DESC
+	`
+	return queryOrders(ctx, r.db, query, marketID, userID)
+}
+
+// CancelOrder cancels an order still resting on the book, returning the unfilled
+// shares of a sell order to its owner. Orders no longer resting yield ErrConflict.
+func (r *Repository) CancelOrder(ctx context.Context, orderID string) (_ *models.Order, err error) {
+	ctx, span := startSpan(ctx, "CancelOrder")
+	defer func() { tracing.End(span, err) }()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", mapError(err))
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE orders SET status = 'cancelled', updated_at = $1
+		WHERE id = $2 AND status IN ` + openOrderStatuses + `
+		RETURNING ` + orderColumns
+	order, err := scanOrder(tx.QueryRowContext(ctx, query, time.Now(), orderID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("order %s is no longer on the book: %w", orderID, ErrConflict)
+		}
+		return nil, fmt.Errorf("cancel order: %w", mapError(err))
+	}
+
+	if order.Side == models.OrderSideSell {
+		change := PositionChange{UserID: order.UserID, MarketID: order.MarketID, OptionID: order.OptionID, Shares: order.Remaining()}
+		if err := changePosition(ctx, tx, change); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit transaction: %w", mapError(err))
+	}
+	return order, nil
+}
+
+// BookDepth aggregates a market's resting orders into price levels keyed by option ID
+func (r *Repository) BookDepth(ctx context.Context, marketID string) (_ map[string]*models.OptionBook, err error) {
+	ctx, span := startSpan(ctx, "BookDepth")
+	defer func() { tracing.End(span, err) }()
+
+	query := `
+		SELECT option_id, side, price, SUM(shares - filled_shares), COUNT(*)
+		FROM orders
+		WHERE market_id = $1 AND status IN ` + openOrderStatuses + `
+		GROUP BY option_id, side, price
+		ORDER BY option_id, side, price
+	`
+	rows, err := r.db.QueryContext(ctx, query, marketID)
+	if err != nil {
+		return nil, fmt.Errorf("query book depth: %w", mapError(err))
+	}
+	defer rows.Close()
+
+	books := map[string]*models.OptionBook{}
+	for rows.Next() {
+		var optionID string
+		var side models.OrderSide
+		var level models.BookLevel
+		if err := rows.Scan(&optionID, &side, &level.Price, &level.Shares, &level.Orders); err != nil {
+			return nil, fmt.Errorf("scan book level: %w", err)
+		}
+		book, ok := books[optionID]
+		if !ok {
+			book = &models.OptionBook{OptionID: optionID, Bids: []models.BookLevel{}, Asks: []models.BookLevel{}}
+			books[optionID] = book
+		}
+		if side == models.OrderSideBuy {
+			// Rows come lowest price first; bids are listed best (highest) first
+			book.Bids = append([]models.BookLevel{level}, book.Bids...)
+		} else {
+			book.Asks = append(book.Asks, level)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate book depth: %w", mapError(err))
+	}
+	return books, nil
+}
+
+// ListPositions retrieves a user's non-empty positions, most recently changed first
+func (r *Repository) ListPositions(ctx context.Context, userID string) (_ []models.Position, err error) {
+	ctx, span := startSpan(ctx, "ListPositions")
+	defer func() { tracing.End(span, err) }()
+
+	query := `
+		SELECT user_id, market_id, option_id, shares, updated_at
+		FROM positions
+		WHERE user_id = $1 AND shares > 0
+		ORDER BY updated_at DESC
+	`
+	return queryPositions(ctx, r.db, query, userID)
+}
+
+// queryPositions runs a query selecting position rows
+func queryPositions(ctx context.Context, db querier, query string, args ...interface{}) ([]models.Position, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query positions: %w", mapError(err))
+	}
+	defer rows.Close()
+
+	positions := []models.Position{}
+	for rows.Next() {
+		p := models.Position{}
+		if err := rows.Scan(&p.UserID, &p.MarketID, &p.OptionID, &p.Shares, &p.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("scan position: %w", err)
+		}
+		positions = append(positions, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate positions: %w", mapError(err))
+	}
+	return positions, nil
+}
+
+// applyExecution writes the incoming order, its trades and everything they changed
+func applyExecution(ctx context.Context, tx *sql.Tx, exec *OrderExecution) error {
+	o := exec.Order
+	query := `
+		INSERT INTO orders (id, market_id, option_id, user_id, side, order_type, price, shares, filled_shares,
+		                    status, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+	`
+	_, err := tx.ExecContext(ctx, query,
+		o.ID, o.MarketID, o.OptionID, o.UserID, o.Side, o.Type, o.Price, o.Shares, o.FilledShares,
+		o.Status, o.CreatedAt, o.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("insert order: %w", mapError(err))
+	}
+
+	for _, m := range exec.Makers {
+		_, err := tx.ExecContext(ctx,
+			`UPDATE orders SET filled_shares = $1, status = $2, updated_at = $3 WHERE id = $4`,
+			m.FilledShares, m.Status, m.UpdatedAt, m.ID,
+		)
+		if err != nil {
+			return fmt.Errorf("update maker order: %w", mapError(err))
+		}
+	}
+
+	for _, pool := range exec.Pools {
+		_, err := tx.ExecContext(ctx,
+			`UPDATE liquidity_pool SET pool_value = $1, updated_at = $2 WHERE id = $3`,
+			pool.PoolValue, pool.UpdatedAt, pool.ID,
+		)
+		if err != nil {
+			return fmt.Errorf("update liquidity pool: %w", mapError(err))
+		}
+	}
+
+	for _, change := range exec.Positions {
+		if err := changePosition(ctx, tx, change); err != nil {
+			return err
+		}
+	}
+
+	for _, t := range exec.Trades {
+		query := `
+			INSERT INTO trades (id, market_id, option_id, order_id, user_id, side, venue, maker_order_id,
+			                    maker_user_id, shares, price, fee, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		`
+		_, err := tx.ExecContext(ctx, query,
+			t.ID, t.MarketID, t.OptionID, t.OrderID, t.UserID, t.Side, t.Venue, t.MakerOrderID,
+			t.MakerUserID, t.Shares, t.Price, t.Fee, t.CreatedAt,
+		)
+		if err != nil {
+			return fmt.Errorf("insert trade: %w", mapError(err))
+		}
+	}
+
+	if err := insertPriceTicks(ctx, tx, exec.Prices); err != nil {
+		return err
+	}
+	return insertLedgerEntries(ctx, tx, exec.Ledger)
+}
+
+// changePosition adds to a user's position. Removing more shares than the user
+// holds yields ErrInsufficientShares.
+func changePosition(ctx context.Context, tx *sql.Tx, c PositionChange) error {
+	if c.Shares < 0 {
+		result, err := tx.ExecContext(ctx, `
+			UPDATE positions SET shares = shares + $1, updated_at = NOW()
+			WHERE user_id = $2 AND option_id = $3 AND shares >= -$1
+		`, c.Shares, c.UserID, c.OptionID)
+		if err != nil {
+			return fmt.Errorf("update position: %w", mapError(err))
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("get rows affected: %w", err)
+		}
+		if rowsAffected == 0 {
+			return fmt.Errorf("option %s: %w", c.OptionID, ErrInsufficientShares)
+		}
+		return nil
+	}
+
+	query := `
+		INSERT INTO positions (user_id, option_id, market_id, shares, updated_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		ON CONFLICT (user_id, option_id) DO UPDATE
+		SET shares = positions.shares + EXCLUDED.shares, updated_at = EXCLUDED.updated_at
+	`
+	if _, err := tx.ExecContext(ctx, query, c.UserID, c.OptionID, c.MarketID, c.Shares); err != nil {
+		return fmt.Errorf("update position: %w", mapError(err))
+	}
+	return nil
+}
+
+// insertLedgerEntries records collateral movements
+func insertLedgerEntries(ctx context.Context, tx *sql.Tx, entries []models.LedgerEntry) error {
+	query := `
+		INSERT INTO ledger_entries (id, account, market_id, kind, amount, ref_id, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+	for _, e := range entries {
+		_, err := tx.ExecContext(ctx, query, e.ID, e.Account, e.MarketID, e.Kind, e.Amount, e.RefID, e.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("insert ledger entry: %w", mapError(err))
+		}
+	}
+	return nil
+}
+
+// cancelOpenOrders takes a settling market's orders off the book, returning the
+// shares held by sell orders to their owners
+func cancelOpenOrders(ctx context.Context, tx *sql.Tx, marketID string) error {
+	query := `
+		UPDATE orders SET status = 'cancelled', updated_at = NOW()
+		WHERE market_id = $1 AND status IN ` + openOrderStatuses + `
+		RETURNING ` + orderColumns
+	cancelled, err := queryOrders(ctx, tx, query, marketID)
+	if err != nil {
+		return fmt.Errorf("cancel open orders: %w", err)
+	}
+	for _, o := range cancelled {
+		if o.Side != models.OrderSideSell {
+			continue
+		}
+		change := PositionChange{UserID: o.UserID, MarketID: o.MarketID, OptionID: o.OptionID, Shares: o.Remaining()}
+		if err := changePosition(ctx, tx, change); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// querier is implemented by *sql.DB and *sql.Tx
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// queryResting reads the other users' resting orders order can trade with, in
+// priority order, locked when forUpdate is " FOR UPDATE"
+func queryResting(ctx context.Context, db querier, order *models.Order, limit *float64, forUpdate string) ([]models.Order, error) {
+	side, priority, cross := models.OrderSideSell, "price ASC", "price <= $4"
+	if order.Side == models.OrderSideSell {
+		side, priority, cross = models.OrderSideBuy, "price DESC", "price >= $4"
+	}
+	if limit == nil {
+		cross = "$4::DECIMAL IS NULL"
+	}
+	query := `
+		SELECT ` + orderColumns + `
+		FROM orders
+		WHERE option_id = $1 AND side = $2 AND user_id <> $3 AND ` + cross + `
+		  AND status IN ` + openOrderStatuses + `
+		ORDER BY ` + priority + `, seq ASC` + forUpdate
+	return queryOrders(ctx, db, query, order.OptionID, side, order.UserID, limit)
+}
+
+func queryOrders(ctx context.Context, db querier, query string, args ...interface{}) ([]models.Order, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query orders: %w", mapError(err))
+	}
+	defer rows.Close()
+
+	orders := []models.Order{}
+	for rows.Next() {
+		o, err := scanOrder(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan order: %w", err)
+		}
+		orders = append(orders, *o)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate orders: %w", mapError(err))
+	}
+	return orders, nil
+}
+
+// queryPools reads a market's liquidity pools in option display order
+func queryPools(ctx context.Context, db querier, marketID string) ([]models.LiquidityPool, error) {
+	query := `
+		SELECT lp.id, lp.market_id, lp.option_id, lp.pool_value, lp.updated_at
+		FROM liquidity_pool lp
+		JOIN options o ON o.id = lp.option_id
+		WHERE lp.market_id = $1
+		ORDER BY o.display_order ASC, o.created_at ASC
+	`
+	rows, err := db.QueryContext(ctx, query, marketID)
+	if err != nil {
+		return nil, fmt.Errorf("query liquidity pools: %w", mapError(err))
+	}
+	defer rows.Close()
+
+	pools := []models.LiquidityPool{}
+	for rows.Next() {
+		pool := models.LiquidityPool{}
+		if err := rows.Scan(&pool.ID, &pool.MarketID, &pool.OptionID, &pool.PoolValue, &pool.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("scan liquidity pool: %w", err)
+		}
+		pools = append(pools, pool)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate liquidity pools: %w", mapError(err))
+	}
+	return pools, nil
+}
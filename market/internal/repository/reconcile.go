@@ -0,0 +1,187 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/ec332/aegis/market/internal/tracing"
+	"github.com/ec332/aegis/market/pkg/models"
+)
+
+// Sources of the reserve changes pools take outside trades
+const (
+	reserveSourceFunding    = "funding"
+	reserveSourceManual     = "manual"
+	reserveSourceLiquidity  = "liquidity"
+	reserveSourceIngest     = "ingest"
+	reserveSourceReconcile  = "reconcile"
+	reserveSourceSettlement = "settlement"
+)
+
+// ReconciliationState is what reconciling a market compares with the transaction
+// service, read at one point in time
+type ReconciliationState struct {
+	Pools []models.LiquidityPool
+	// ReserveChanges sums the changes each option's reserve took outside trades,
+	// by option ID: funding, liquidity, ingested events and earlier repairs
+	ReserveChanges map[string]float64
+	// Trades are the market's trades, oldest first
+	Trades []TradeRecord
+	// Positions are every user's positions, empty ones included
+	Positions []models.Position
+}
+
+// ReadReconciliationState reads a market's pools, reserve changes, trades and
+// positions in one snapshot, so trades executing meanwhile are in all or none
+func (r *Repository) ReadReconciliationState(ctx context.Context, marketID string) (_ *ReconciliationState, err error) {
+	ctx, span := startSpan(ctx, "ReadReconciliationState")
+	defer func() { tracing.End(span, err) }()
+
+	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", mapError(err))
+	}
+	defer tx.Rollback()
+
+	state := &ReconciliationState{ReserveChanges: map[string]float64{}}
+	if state.Pools, err = queryPools(ctx, tx, marketID); err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.QueryContext(ctx,
+		`SELECT option_id, SUM(change) FROM reserve_changes WHERE market_id = $1 GROUP BY option_id`, marketID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query reserve changes: %w", mapError(err))
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var optionID string
+		var change float64
+		if err := rows.Scan(&optionID, &change); err != nil {
+			return nil, fmt.Errorf("scan reserve change: %w", err)
+		}
+		state.ReserveChanges[optionID] = change
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate reserve changes: %w", mapError(err))
+	}
+
+	query := `
+		SELECT ` + tradeColumns + `, recorded_at, record_error IS NOT NULL
+		FROM trades
+		WHERE market_id = $1
+		ORDER BY created_at, id
+	`
+	if state.Trades, err = queryTradeRecords(ctx, tx, query, marketID); err != nil {
+		return nil, err
+	}
+	query = `
+		SELECT user_id, market_id, option_id, shares, updated_at
+		FROM positions
+		WHERE market_id = $1
+		ORDER BY user_id, option_id
+	`
+	if state.Positions, err = queryPositions(ctx, tx, query, marketID); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// ApplyReconciliation corrects a market's pools by poolChanges, the amount to add
+// to each option's reserve by option ID, and its positions by positionChanges, and
+// writes the ledger entries balancing them, in one transaction under repairID. It
+// returns the market's pools as corrected. A correction that would take a position
+// below zero fails with ErrInsufficientShares.
+func (r *Repository) ApplyReconciliation(ctx context.Context, marketID, repairID string, poolChanges map[string]float64, positionChanges []PositionChange, ledger []models.LedgerEntry) (_ []models.LiquidityPool, err error) {
+	ctx, span := startSpan(ctx, "ApplyReconciliation")
+	defer func() { tracing.End(span, err) }()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", mapError(err))
+	}
+	defer tx.Rollback()
+
+	var locked int
+	if err := tx.QueryRowContext(ctx, `SELECT 1 FROM markets WHERE id = $1 FOR UPDATE`, marketID).Scan(&locked); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("market %s: %w", marketID, ErrNotFound)
+		}
+		return nil, fmt.Errorf("lock market: %w", mapError(err))
+	}
+
+	for optionID, change := range poolChanges {
+		result, err := tx.ExecContext(ctx, `
+			UPDATE liquidity_pool SET pool_value = pool_value + $1, updated_at = NOW()
+			WHERE market_id = $2 AND option_id = $3 AND pool_value + $1 >= 0
+		`, change, marketID, optionID)
+		if err != nil {
+			return nil, fmt.Errorf("update liquidity pool: %w", mapError(err))
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return nil, fmt.Errorf("get rows affected: %w", err)
+		}
+		if rowsAffected == 0 {
+			return nil, fmt.Errorf("pool of option %s cannot take %g: %w", optionID, change, ErrConflict)
+		}
+		if err := insertReserveChange(ctx, tx, marketID, optionID, reserveSourceReconcile, repairID, change); err != nil {
+			return nil, err
+		}
+	}
+	for _, c := range positionChanges {
+		if err := changePosition(ctx, tx, c); err != nil {
+			return nil, err
+		}
+	}
+	if err := insertLedgerEntries(ctx, tx, ledger); err != nil {
+		return nil, err
+	}
+
+	pools, err := queryPools(ctx, tx, marketID)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit transaction: %w", mapError(err))
+	}
+	return pools, nil
+}
+
+// setPoolValue sets a pool's reserve outside a trade, noting the change under
+// source and refID for reconciliation. It returns false if there is no such pool.
+func setPoolValue(ctx context.Context, tx *sql.Tx, pool models.LiquidityPool, source, refID string) (bool, error) {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO reserve_changes (market_id, option_id, source, ref_id, change, created_at)
+		SELECT market_id, option_id, $2, $3, $4 - pool_value, $5
+		FROM liquidity_pool
+		WHERE id = $1 AND market_id = $6 AND pool_value <> $4
+	`, pool.ID, source, refID, pool.PoolValue, pool.UpdatedAt, pool.MarketID)
+	if err != nil {
+		return false, fmt.Errorf("insert reserve change: %w", mapError(err))
+	}
+	result, err := tx.ExecContext(ctx,
+		`UPDATE liquidity_pool SET pool_value = $1, updated_at = $2 WHERE id = $3 AND market_id = $4`,
+		pool.PoolValue, pool.UpdatedAt, pool.ID, pool.MarketID,
+	)
+	if err != nil {
+		return false, fmt.Errorf("update liquidity pool: %w", mapError(err))
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("get rows affected: %w", err)
+	}
+	return rowsAffected > 0, nil
+}
+
+func insertReserveChange(ctx context.Context, tx *sql.Tx, marketID, optionID, source, refID string, change float64) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO reserve_changes (market_id, option_id, source, ref_id, change)
+		VALUES ($1, $2, $3, $4, $5)
+	`, marketID, optionID, source, refID, change)
+	if err != nil {
+		return fmt.Errorf("insert reserve change: %w", mapError(err))
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"github.com/ec332/aegis/market/internal/tracing"
+	"github.com/ec332/aegis/market/pkg/models"
+)
+
+const tradeColumns = `id, market_id, option_id, order_id, user_id, side, venue, maker_order_id, maker_user_id,
+	shares, price, fee, created_at`
+
+// TradeRecord is a trade with its recording status
+type TradeRecord struct {
+	models.Trade
+	// RecordedAt is when the trade was recorded with the transaction service, if it was
+	RecordedAt *time.Time
+	// Rejected is true when the service refused the trade
+	Rejected bool
+}
+
+// ListUnrecordedTrades retrieves up to limit trades not yet recorded with the
+// transaction service, oldest first, skipping trades it rejected
+func (r *Repository) ListUnrecordedTrades(ctx context.Context, limit int) (_ []models.Trade, err error) {
+	ctx, span := startSpan(ctx, "ListUnrecordedTrades")
+	defer func() { tracing.End(span, err) }()
+
+	query := `
+		SELECT ` + tradeColumns + `, recorded_at, record_error IS NOT NULL
+		FROM trades
+		WHERE recorded_at IS NULL AND record_error IS NULL
+		ORDER BY created_at, id
+		LIMIT $1
+	`
+	records, err := queryTradeRecords(ctx, r.db, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	trades := make([]models.Trade, len(records))
+	for i := range records {
+		trades[i] = records[i].Trade
+	}
+	return trades, nil
+}
+
+// MarkTradeRecorded notes that a trade was recorded with the transaction service
+func (r *Repository) MarkTradeRecorded(ctx context.Context, tradeID string, at time.Time) (err error) {
+	ctx, span := startSpan(ctx, "MarkTradeRecorded")
+	defer func() { tracing.End(span, err) }()
+
+	if _, err := r.db.ExecContext(ctx, `UPDATE trades SET recorded_at = $1 WHERE id = $2`, at, tradeID); err != nil {
+		return fmt.Errorf("mark trade recorded: %w", mapError(err))
+	}
+	return nil
+}
+
+// MarkTradeRejected notes why the transaction service refused a trade, so it is
+// not sent again
+func (r *Repository) MarkTradeRejected(ctx context.Context, tradeID, reason string) (err error) {
+	ctx, span := startSpan(ctx, "MarkTradeRejected")
+	defer func() { tracing.End(span, err) }()
+
+	if _, err := r.db.ExecContext(ctx, `UPDATE trades SET record_error = $1 WHERE id = $2`, reason, tradeID); err != nil {
+		return fmt.Errorf("mark trade rejected: %w", mapError(err))
+	}
+	return nil
+}
+
+func queryTradeRecords(ctx context.Context, db querier, query string, args ...interface{}) ([]TradeRecord, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query trades: %w", mapError(err))
+	}
+	defer rows.Close()
+
+	records := []TradeRecord{}
+	for rows.Next() {
+		rec := TradeRecord{}
+		t := &rec.Trade
+		err := rows.Scan(&t.ID, &t.MarketID, &t.OptionID, &t.OrderID, &t.UserID, &t.Side, &t.Venue,
+			&t.MakerOrderID, &t.MakerUserID, &t.Shares, &t.Price, &t.Fee, &t.CreatedAt, &rec.RecordedAt, &rec.Rejected)
+		if err != nil {
+			return nil, fmt.Errorf("scan trade: %w", err)
+		}
+		records = append(records, rec)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate trades: %w", mapError(err))
+	}
+	return records, nil
+}
@@ -3,10 +3,14 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"time"
+	"github.com/ec332/aegis/market/internal/tracing"
 	"github.com/ec332/aegis/market/pkg/models"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Repository handles database operations
@@ -20,38 +24,61 @@ func New(db *sql.DB) *Repository {
 }
 
 // CreateMarket creates a new market with options and liquidity pools in a transaction
-func (r *Repository) CreateMarket(ctx context.Context, market *models.Market, options []models.Option, pools []models.LiquidityPool) error {
+func (r *Repository) CreateMarket(ctx context.Context, market *models.Market, options []models.Option, pools []models.LiquidityPool) (err error) {
+	ctx, span := startSpan(ctx, "CreateMarket")
+	defer func() { tracing.End(span, err) }()
+
+	spec, err := specValue(market.ResolutionSpec)
+	if err != nil {
+		return err
+	}
+
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("begin transaction: %w", err)
+		return fmt.Errorf("begin transaction: %w", mapError(err))
 	}
 	defer tx.Rollback()
 
 	// Insert market
 	query := `
-		INSERT INTO markets (id, title, description, status, resolution_datetime, winning_option_id, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		INSERT INTO markets (id, title, description, resolution_rules, resolution_source, resolution_spec, status,
+		                     market_type, lower_bound, upper_bound,
+		                     resolution_datetime, winning_option_id, parent_market_id, condition_option_id,
+		                     category_id, featured_rank, created_by, review_state, fee_rate, min_fee,
+		                     max_position, max_trade_shares, max_treasury_exposure, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22,
+		        $23, $24, $25)
 	`
 	_, err = tx.ExecContext(ctx, query,
-		market.ID, market.Title, market.Description, market.Status,
+		market.ID, market.Title, market.Description, market.ResolutionRules, market.ResolutionSource, spec, market.Status,
+		market.Type, market.LowerBound, market.UpperBound,
 		market.ResolutionDatetime, market.WinningOptionID,
+		market.ParentMarketID, market.ConditionOptionID,
+		market.CategoryID, market.FeaturedRank,
+		market.CreatedBy, market.ReviewState, market.FeeRate, market.MinFee,
+		market.Limits.MaxPosition, market.Limits.MaxTradeShares, market.Limits.MaxTreasuryExposure,
 		market.CreatedAt, market.UpdatedAt,
 	)
 	if err != nil {
-		return fmt.Errorf("insert market: %w", err)
+		return fmt.Errorf("insert market: %w", mapError(err))
+	}
+
+	if err := setMarketTags(ctx, tx, market.ID, market.Tags); err != nil {
+		return err
 	}
 
 	// Insert options
 	optionQuery := `
-		INSERT INTO options (id, market_id, title, created_at)
-		VALUES ($1, $2, $3, $4)
+		INSERT INTO options (id, market_id, title, short_label, image_url, display_order, side, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 	`
 	for _, option := range options {
 		_, err = tx.ExecContext(ctx, optionQuery,
-			option.ID, option.MarketID, option.Title, option.CreatedAt,
+			option.ID, option.MarketID, option.Title, option.ShortLabel, option.ImageURL,
+			option.DisplayOrder, nullableSide(option.Side), option.CreatedAt,
 		)
 		if err != nil {
-			return fmt.Errorf("insert option: %w", err)
+			return fmt.Errorf("insert option: %w", mapError(err))
 		}
 	}
 
@@ -65,33 +92,32 @@ func (r *Repository) CreateMarket(ctx context.Context, market *models.Market, op
 			pool.ID, pool.MarketID, pool.OptionID, pool.PoolValue, pool.UpdatedAt,
 		)
 		if err != nil {
-			return fmt.Errorf("insert liquidity pool: %w", err)
+			return fmt.Errorf("insert liquidity pool: %w", mapError(err))
+		}
+		if err := insertReserveChange(ctx, tx, pool.MarketID, pool.OptionID, reserveSourceFunding, pool.ID, pool.PoolValue); err != nil {
+			return err
 		}
 	}
 
-	return tx.Commit()
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit transaction: %w", mapError(err))
+	}
+	return nil
 }
 
 
 // GetMarket retrieves a market by ID with its options and liquidity pools
-func (r *Repository) GetMarket(ctx context.Context, marketID string) (*models.Market, error) {
-	market := &models.Market{}
-	query := `
-		SELECT id, title, description, status, resolution_datetime, 
-		       winning_option_id, created_at, updated_at
-		FROM markets
-		WHERE id = $1
-	`
-	err := r.db.QueryRowContext(ctx, query, marketID).Scan(
-		&market.ID, &market.Title, &market.Description, &market.Status,
-		&market.ResolutionDatetime, &market.WinningOptionID,
-		&market.CreatedAt, &market.UpdatedAt,
-	)
+func (r *Repository) GetMarket(ctx context.Context, marketID string) (_ *models.Market, err error) {
+	ctx, span := startSpan(ctx, "GetMarket")
+	defer func() { tracing.End(span, err) }()
+
+	query := `SELECT ` + marketColumns + ` FROM markets m WHERE id = $1`
+	market, err := scanMarket(r.db.QueryRowContext(ctx, query, marketID))
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("market not found")
+			return nil, fmt.Errorf("market %s: %w", marketID, ErrNotFound)
 		}
-		return nil, fmt.Errorf("query market: %w", err)
+		return nil, fmt.Errorf("query market: %w", mapError(err))
 	}
 
 	// Get options
@@ -101,6 +127,12 @@ func (r *Repository) GetMarket(ctx context.Context, marketID string) (*models.Ma
 	}
 	market.Options = options
 
+	tags, err := r.GetTagsByMarketID(ctx, marketID)
+	if err != nil {
+		return nil, fmt.Errorf("get tags: %w", err)
+	}
+	market.Tags = tags
+
 	// Get liquidity pools
 	pools, err := r.GetLiquidityPoolsByMarketID(ctx, marketID)
 	if err != nil {
@@ -111,46 +143,39 @@ func (r *Repository) GetMarket(ctx context.Context, marketID string) (*models.Ma
 	return market, nil
 }
 
-// ListMarkets retrieves markets based on filter criteria
-func (r *Repository) ListMarkets(ctx context.Context, status *models.MarketStatus) ([]models.Market, error) {
+// ListMarkets retrieves markets based on filter criteria. Featured markets come
+// first in rank order, then the rest newest first.
+func (r *Repository) ListMarkets(ctx context.Context, filter models.MarketFilter) (_ []models.Market, err error) {
+	ctx, span := startSpan(ctx, "ListMarkets")
+	defer func() { tracing.End(span, err) }()
+
+	where, args := marketFilterClause(filter, nil)
 	query := `
-		SELECT id, title, description, status, resolution_datetime,
-		       winning_option_id, created_at, updated_at
-		FROM markets
-		WHERE 1=1
+		SELECT ` + marketColumns + `
+		FROM markets m
+		WHERE ` + where + `
+		ORDER BY featured_rank ASC NULLS LAST, created_at DESC
 	`
-	args := []interface{}{}
-	argCount := 1
-
-	if status != nil {
-		query += fmt.Sprintf(" AND status = $%d", argCount)
-		args = append(args, *status)
-		argCount++
-	}
-
-	query += " ORDER BY created_at DESC"
 
 	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, fmt.Errorf("query markets: %w", err)
+		return nil, fmt.Errorf("query markets: %w", mapError(err))
 	}
 	defer rows.Close()
 
 	markets := []models.Market{}
 	for rows.Next() {
-		market := models.Market{}
-		err := rows.Scan(
-			&market.ID, &market.Title, &market.Description, &market.Status,
-			&market.ResolutionDatetime, &market.WinningOptionID,
-			&market.CreatedAt, &market.UpdatedAt,
-		)
+		market, err := scanMarket(rows)
 		if err != nil {
 			return nil, fmt.Errorf("scan market: %w", err)
 		}
-		markets = append(markets, market)
+		markets = append(markets, *market)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate markets: %w", mapError(err))
 	}
 
-	// Fetch options and liquidity pools for each market
+	// Fetch options, tags and liquidity pools for each market
 	for i := range markets {
 		options, err := r.GetOptionsByMarketID(ctx, markets[i].ID)
 		if err != nil {
@@ -158,6 +183,12 @@ func (r *Repository) ListMarkets(ctx context.Context, status *models.MarketStatu
 		}
 		markets[i].Options = options
 
+		tags, err := r.GetTagsByMarketID(ctx, markets[i].ID)
+		if err != nil {
+			return nil, fmt.Errorf("get tags for market %s: %w", markets[i].ID, err)
+		}
+		markets[i].Tags = tags
+
 		pools, err := r.GetLiquidityPoolsByMarketID(ctx, markets[i].ID)
 		if err != nil {
 			return nil, fmt.Errorf("get liquidity pools for market %s: %w", markets[i].ID, err)
@@ -168,12 +199,65 @@ func (r *Repository) ListMarkets(ctx context.Context, status *models.MarketStatu
 	return markets, nil
 }
 
-// UpdateMarket updates market fields
-func (r *Repository) UpdateMarket(ctx context.Context, marketID string, updates models.UpdateMarketRequest) error {
+// MarketUpdate is a market change applied atomically by UpdateMarket
+type MarketUpdate struct {
+	models.UpdateMarketRequest
+	// Payouts records the options' settlement payouts, keyed by option ID; setting them
+	// also cancels the orders left on the market's book and pays out its liquidity
+	// providers
+	Payouts map[string]float64
+	// EditedBy is recorded on the revision saved when the market's texts change
+	EditedBy string
+	// Settlement closes the resolution proposal that resolved or voided the market
+	Settlement *ProposalSettlement
+	// Funding is the treasury's initial liquidity, applied while the market is still
+	// unpublished and unfunded
+	Funding *LiquidityChange
+	// ExpectedStatus is the status the change was validated against; when set, the
+	// update fails with ErrConflict if the market has moved on since
+	ExpectedStatus *models.MarketStatus
+}
+
+// UpdateMarket updates market fields in a transaction. When the title, description
+// or resolution texts change, the prior texts are saved as a new revision first.
+func (r *Repository) UpdateMarket(ctx context.Context, marketID string, updates MarketUpdate) (err error) {
+	ctx, span := startSpan(ctx, "UpdateMarket")
+	defer func() { tracing.End(span, err) }()
+
+	now := time.Now()
 	query := "UPDATE markets SET updated_at = $1"
-	args := []interface{}{time.Now()}
+	args := []interface{}{now}
 	argCount := 2
 
+	if updates.Title != nil {
+		query += fmt.Sprintf(", title = $%d", argCount)
+		args = append(args, *updates.Title)
+		argCount++
+	}
+	if updates.Description != nil {
+		query += fmt.Sprintf(", description = $%d", argCount)
+		args = append(args, *updates.Description)
+		argCount++
+	}
+	if updates.ResolutionRules != nil {
+		query += fmt.Sprintf(", resolution_rules = $%d", argCount)
+		args = append(args, *updates.ResolutionRules)
+		argCount++
+	}
+	if updates.ResolutionSource != nil {
+		query += fmt.Sprintf(", resolution_source = $%d", argCount)
+		args = append(args, *updates.ResolutionSource)
+		argCount++
+	}
+	if updates.ResolutionSpec != nil {
+		spec, err := specValue(updates.ResolutionSpec)
+		if err != nil {
+			return err
+		}
+		query += fmt.Sprintf(", resolution_spec = $%d", argCount)
+		args = append(args, spec)
+		argCount++
+	}
 	if updates.Status != nil {
 		query += fmt.Sprintf(", status = $%d", argCount)
 		args = append(args, *updates.Status)
@@ -184,18 +268,86 @@ func (r *Repository) UpdateMarket(ctx context.Context, marketID string, updates
 		args = append(args, *updates.WinningOptionID)
 		argCount++
 	}
+	if updates.ResolvedValue != nil {
+		query += fmt.Sprintf(", resolved_value = $%d", argCount)
+		args = append(args, *updates.ResolvedValue)
+		argCount++
+	}
 	if updates.ResolutionDatetime != nil {
 		query += fmt.Sprintf(", resolution_datetime = $%d", argCount)
 		args = append(args, updates.ResolutionDatetime)
 		argCount++
 	}
+	if updates.CategoryID != nil {
+		query += fmt.Sprintf(", category_id = $%d", argCount)
+		args = append(args, *updates.CategoryID)
+		argCount++
+	}
+	if updates.FeeRate != nil {
+		query += fmt.Sprintf(", fee_rate = $%d", argCount)
+		args = append(args, *updates.FeeRate)
+		argCount++
+	}
+	if updates.MinFee != nil {
+		query += fmt.Sprintf(", min_fee = $%d", argCount)
+		args = append(args, *updates.MinFee)
+		argCount++
+	}
+	if updates.Limits != nil {
+		query += fmt.Sprintf(", max_position = $%d, max_trade_shares = $%d, max_treasury_exposure = $%d",
+			argCount, argCount+1, argCount+2)
+		args = append(args, updates.Limits.MaxPosition, updates.Limits.MaxTradeShares, updates.Limits.MaxTreasuryExposure)
+		argCount += 3
+	}
+	if updates.Featured != nil {
+		var rank *int
+		if *updates.Featured {
+			rank = new(int)
+			if updates.FeaturedRank != nil {
+				rank = updates.FeaturedRank
+			}
+		}
+		query += fmt.Sprintf(", featured_rank = $%d", argCount)
+		args = append(args, rank)
+		argCount++
+	}
 
 	query += fmt.Sprintf(" WHERE id = $%d", argCount)
 	args = append(args, marketID)
 
-	result, err := r.db.ExecContext(ctx, query, args...)
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", mapError(err))
+	}
+	defer tx.Rollback()
+
+	if updates.ExpectedStatus != nil {
+		var status models.MarketStatus
+		err := tx.QueryRowContext(ctx, `SELECT status FROM markets WHERE id = $1 FOR UPDATE`, marketID).Scan(&status)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return fmt.Errorf("market %s: %w", marketID, ErrNotFound)
+			}
+			return fmt.Errorf("lock market: %w", mapError(err))
+		}
+		if status != *updates.ExpectedStatus {
+			return fmt.Errorf("market %s became %s concurrently: %w", marketID, status, ErrConflict)
+		}
+	}
+	if updates.ChangesText() {
+		if err := saveMarketRevision(ctx, tx, marketID, updates.EditedBy); err != nil {
+			return err
+		}
+	}
+	if updates.Funding != nil {
+		if err := applyFunding(ctx, tx, marketID, updates.Funding); err != nil {
+			return err
+		}
+	}
+
+	result, err := tx.ExecContext(ctx, query, args...)
 	if err != nil {
-		return fmt.Errorf("update market: %w", err)
+		return fmt.Errorf("update market: %w", mapError(err))
 	}
 
 	rowsAffected, err := result.RowsAffected()
@@ -203,43 +355,112 @@ func (r *Repository) UpdateMarket(ctx context.Context, marketID string, updates
 		return fmt.Errorf("get rows affected: %w", err)
 	}
 	if rowsAffected == 0 {
-		return fmt.Errorf("market not found")
+		return fmt.Errorf("market %s: %w", marketID, ErrNotFound)
 	}
 
+	// Leaving the halted status ends the market's halt
+	if updates.Status != nil && *updates.Status != models.MarketStatusHalted {
+		if _, err := closeHalts(ctx, tx, marketID, updates.EditedBy, now); err != nil {
+			return err
+		}
+	}
+
+	if updates.Tags != nil {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM market_tags WHERE market_id = $1`, marketID); err != nil {
+			return fmt.Errorf("clear tags: %w", mapError(err))
+		}
+		if err := setMarketTags(ctx, tx, marketID, *updates.Tags); err != nil {
+			return err
+		}
+	}
+
+	for optionID, payout := range updates.Payouts {
+		_, err := tx.ExecContext(ctx,
+			`UPDATE options SET payout = $1 WHERE id = $2 AND market_id = $3`,
+			payout, optionID, marketID,
+		)
+		if err != nil {
+			return fmt.Errorf("set option payout: %w", mapError(err))
+		}
+	}
+	if updates.Payouts != nil {
+		if err := cancelOpenOrders(ctx, tx, marketID); err != nil {
+			return err
+		}
+		if err := settleLiquidity(ctx, tx, marketID, updates.Payouts); err != nil {
+			return err
+		}
+	}
+
+	if updates.Settlement != nil {
+		if err := settleProposal(ctx, tx, updates.Settlement); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit transaction: %w", mapError(err))
+	}
 	return nil
 }
 
 // GetOptionsByMarketID retrieves all options for a market
-func (r *Repository) GetOptionsByMarketID(ctx context.Context, marketID string) ([]models.Option, error) {
+func (r *Repository) GetOptionsByMarketID(ctx context.Context, marketID string) (_ []models.Option, err error) {
+	ctx, span := startSpan(ctx, "GetOptionsByMarketID")
+	defer func() { tracing.End(span, err) }()
+
 	query := `
-		SELECT id, market_id, title, created_at
+		SELECT ` + optionColumns + `
 		FROM options
 		WHERE market_id = $1
-		ORDER BY created_at ASC
+		ORDER BY display_order ASC, created_at ASC
 	`
 	rows, err := r.db.QueryContext(ctx, query, marketID)
 	if err != nil {
-		return nil, fmt.Errorf("query options: %w", err)
+		return nil, fmt.Errorf("query options: %w", mapError(err))
 	}
 	defer rows.Close()
 
 	options := []models.Option{}
 	for rows.Next() {
-		option := models.Option{}
-		err := rows.Scan(
-			&option.ID, &option.MarketID, &option.Title, &option.CreatedAt,
-		)
+		option, err := scanOption(rows)
 		if err != nil {
 			return nil, fmt.Errorf("scan option: %w", err)
 		}
-		options = append(options, option)
+		options = append(options, *option)
 	}
 
 	return options, nil
 }
 
+// GetTagsByMarketID retrieves a market's tags in alphabetical order
+func (r *Repository) GetTagsByMarketID(ctx context.Context, marketID string) (_ []string, err error) {
+	ctx, span := startSpan(ctx, "GetTagsByMarketID")
+	defer func() { tracing.End(span, err) }()
+
+	rows, err := r.db.QueryContext(ctx, `SELECT tag FROM market_tags WHERE market_id = $1 ORDER BY tag`, marketID)
+	if err != nil {
+		return nil, fmt.Errorf("query tags: %w", mapError(err))
+	}
+	defer rows.Close()
+
+	tags := []string{}
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return nil, fmt.Errorf("scan tag: %w", err)
+		}
+		tags = append(tags, tag)
+	}
+
+	return tags, nil
+}
+
 // GetLiquidityPoolsByMarketID retrieves all liquidity pools for a market
-func (r *Repository) GetLiquidityPoolsByMarketID(ctx context.Context, marketID string) ([]models.LiquidityPool, error) {
+func (r *Repository) GetLiquidityPoolsByMarketID(ctx context.Context, marketID string) (_ []models.LiquidityPool, err error) {
+	ctx, span := startSpan(ctx, "GetLiquidityPoolsByMarketID")
+	defer func() { tracing.End(span, err) }()
+
 	query := `
 		SELECT id, market_id, option_id, pool_value, updated_at
 		FROM liquidity_pool
@@ -248,7 +469,7 @@ func (r *Repository) GetLiquidityPoolsByMarketID(ctx context.Context, marketID s
 	`
 	rows, err := r.db.QueryContext(ctx, query, marketID)
 	if err != nil {
-		return nil, fmt.Errorf("query liquidity pools: %w", err)
+		return nil, fmt.Errorf("query liquidity pools: %w", mapError(err))
 	}
 	defer rows.Close()
 
@@ -268,69 +489,187 @@ func (r *Repository) GetLiquidityPoolsByMarketID(ctx context.Context, marketID s
 }
 
 // UpdateLiquidityPool updates a liquidity pool value
-func (r *Repository) UpdateLiquidityPool(ctx context.Context, poolID string, poolValue float64) error {
-	query := `
-		UPDATE liquidity_pool
-		SET pool_value = $1, updated_at = $2
-		WHERE id = $3
-	`
-	result, err := r.db.ExecContext(ctx, query, poolValue, time.Now(), poolID)
+func (r *Repository) UpdateLiquidityPool(ctx context.Context, poolID string, poolValue float64) (err error) {
+	ctx, span := startSpan(ctx, "UpdateLiquidityPool")
+	defer func() { tracing.End(span, err) }()
+
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("update liquidity pool: %w", err)
+		return fmt.Errorf("begin transaction: %w", mapError(err))
 	}
+	defer tx.Rollback()
 
-	rowsAffected, err := result.RowsAffected()
+	var pool models.LiquidityPool
+	err = tx.QueryRowContext(ctx, `SELECT market_id FROM liquidity_pool WHERE id = $1 FOR UPDATE`, poolID).Scan(&pool.MarketID)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("liquidity pool %s: %w", poolID, ErrNotFound)
+	}
 	if err != nil {
-		return fmt.Errorf("get rows affected: %w", err)
+		return fmt.Errorf("lock liquidity pool: %w", mapError(err))
 	}
-	if rowsAffected == 0 {
-		return fmt.Errorf("liquidity pool not found")
+	pool.ID, pool.PoolValue, pool.UpdatedAt = poolID, poolValue, time.Now()
+	if _, err := setPoolValue(ctx, tx, pool, reserveSourceManual, poolID); err != nil {
+		return err
 	}
 
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit transaction: %w", mapError(err))
+	}
 	return nil
 }
 
-// InitSchema initializes the database schema
-func (r *Repository) InitSchema(ctx context.Context) error {
-	schema := `
-	CREATE TABLE IF NOT EXISTS markets (
-		id UUID PRIMARY KEY,
-		title VARCHAR(255) NOT NULL,
-		description TEXT NOT NULL,
-		status VARCHAR(50) NOT NULL DEFAULT 'draft',
-		resolution_datetime TIMESTAMP,
-		winning_option_id UUID,
-		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
-		updated_at TIMESTAMP NOT NULL DEFAULT NOW()
-	);
-
-	CREATE TABLE IF NOT EXISTS options (
-		id UUID PRIMARY KEY,
-		market_id UUID NOT NULL REFERENCES markets(id) ON DELETE CASCADE,
-		title VARCHAR(255) NOT NULL,
-		created_at TIMESTAMP NOT NULL DEFAULT NOW()
-	);
-
-	CREATE INDEX IF NOT EXISTS idx_options_market_id ON options(market_id);
-
-	CREATE TABLE IF NOT EXISTS liquidity_pool (
-		id UUID PRIMARY KEY,
-		market_id UUID NOT NULL REFERENCES markets(id) ON DELETE CASCADE,
-		option_id UUID NOT NULL REFERENCES options(id) ON DELETE CASCADE,
-		pool_value DECIMAL(20, 8) NOT NULL DEFAULT 0,
-		updated_at TIMESTAMP NOT NULL DEFAULT NOW()
-	);
-
-	CREATE INDEX IF NOT EXISTS idx_liquidity_pool_market_id ON liquidity_pool(market_id);
-	CREATE INDEX IF NOT EXISTS idx_liquidity_pool_option_id ON liquidity_pool(option_id);
-	CREATE INDEX IF NOT EXISTS idx_markets_status ON markets(status);
-	CREATE INDEX IF NOT EXISTS idx_markets_created_at ON markets(created_at);
-	`
+// MarketStats returns market counts by status and the total liquidity across all pools
+func (r *Repository) MarketStats(ctx context.Context) (_ *models.MarketStats, err error) {
+	ctx, span := startSpan(ctx, "MarketStats")
+	defer func() { tracing.End(span, err) }()
 
-	_, err := r.db.ExecContext(ctx, schema)
+	stats := &models.MarketStats{
+		MarketsByStatus: map[models.MarketStatus]int{},
+	}
+
+	rows, err := r.db.QueryContext(ctx, `SELECT status, COUNT(*) FROM markets GROUP BY status`)
 	if err != nil {
-		return fmt.Errorf("init schema: %w", err)
+		return nil, fmt.Errorf("query market counts: %w", mapError(err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var status models.MarketStatus
+		var count int
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, fmt.Errorf("scan market count: %w", err)
+		}
+		stats.MarketsByStatus[status] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate market counts: %w", err)
 	}
 
+	query := `SELECT COALESCE(SUM(pool_value), 0) FROM liquidity_pool`
+	if err := r.db.QueryRowContext(ctx, query).Scan(&stats.TotalLiquidity); err != nil {
+		return nil, fmt.Errorf("query total liquidity: %w", mapError(err))
+	}
+
+	return stats, nil
+}
+
+// rowScanner is implemented by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// marketColumns lists the markets columns read by scanMarket
+const marketColumns = `id, title, description, resolution_rules, resolution_source, resolution_spec, status, market_type, lower_bound, upper_bound, resolved_value,
+	resolution_datetime, winning_option_id, parent_market_id, condition_option_id, category_id, featured_rank,
+	created_by, review_state, fee_rate, min_fee, max_position, max_trade_shares, max_treasury_exposure,
+	created_at, updated_at`
+
+// specValue encodes a resolution spec for its JSONB column
+func specValue(spec *models.ResolutionSpec) (interface{}, error) {
+	if spec == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(spec)
+	if err != nil {
+		return nil, fmt.Errorf("encode resolution spec: %w", err)
+	}
+	return string(b), nil
+}
+
+// scanMarket reads a row selected with marketColumns
+func scanMarket(row rowScanner) (*models.Market, error) {
+	market := &models.Market{}
+	var spec []byte
+	err := row.Scan(
+		&market.ID, &market.Title, &market.Description, &market.ResolutionRules, &market.ResolutionSource, &spec,
+		&market.Status, &market.Type, &market.LowerBound, &market.UpperBound, &market.ResolvedValue,
+		&market.ResolutionDatetime, &market.WinningOptionID,
+		&market.ParentMarketID, &market.ConditionOptionID,
+		&market.CategoryID, &market.FeaturedRank,
+		&market.CreatedBy, &market.ReviewState, &market.FeeRate, &market.MinFee,
+		&market.Limits.MaxPosition, &market.Limits.MaxTradeShares, &market.Limits.MaxTreasuryExposure,
+		&market.CreatedAt, &market.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	market.Featured = market.FeaturedRank != nil
+	if spec != nil {
+		market.ResolutionSpec = &models.ResolutionSpec{}
+		if err := json.Unmarshal(spec, market.ResolutionSpec); err != nil {
+			return nil, fmt.Errorf("decode resolution spec: %w", err)
+		}
+	}
+	return market, nil
+}
+
+// marketFilterClause builds a WHERE condition on markets aliased as m, appending
+// its parameters to args
+func marketFilterClause(filter models.MarketFilter, args []interface{}) (string, []interface{}) {
+	where := "1=1"
+	if filter.Status != nil {
+		args = append(args, *filter.Status)
+		where += fmt.Sprintf(" AND m.status = $%d", len(args))
+	}
+	if filter.ParentMarketID != nil {
+		args = append(args, *filter.ParentMarketID)
+		where += fmt.Sprintf(" AND m.parent_market_id = $%d", len(args))
+	}
+	if filter.CategoryID != nil {
+		args = append(args, *filter.CategoryID)
+		where += fmt.Sprintf(` AND m.category_id IN (
+			WITH RECURSIVE subtree AS (
+				SELECT id FROM categories WHERE id = $%d
+				UNION ALL
+				SELECT c.id FROM categories c JOIN subtree s ON c.parent_id = s.id
+			)
+			SELECT id FROM subtree
+		)`, len(args))
+	}
+	if len(filter.Tags) > 0 {
+		args = append(args, pq.Array(filter.Tags), len(filter.Tags))
+		where += fmt.Sprintf(` AND m.id IN (
+			SELECT market_id FROM market_tags WHERE tag = ANY($%d)
+			GROUP BY market_id HAVING COUNT(*) = $%d
+		)`, len(args)-1, len(args))
+	}
+	if filter.Featured != nil {
+		if *filter.Featured {
+			where += " AND m.featured_rank IS NOT NULL"
+		} else {
+			where += " AND m.featured_rank IS NULL"
+		}
+	}
+	return where, args
+}
+
+// nullableSide stores categorical options without a side as NULL
+func nullableSide(side models.ScalarSide) *string {
+	if side == "" {
+		return nil
+	}
+	s := string(side)
+	return &s
+}
+
+// setMarketTags inserts tags for a market, ignoring duplicates
+func setMarketTags(ctx context.Context, tx *sql.Tx, marketID string, tags []string) error {
+	for _, tag := range tags {
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO market_tags (market_id, tag) VALUES ($1, $2) ON CONFLICT DO NOTHING`,
+			marketID, tag,
+		)
+		if err != nil {
+			return fmt.Errorf("insert tag: %w", mapError(err))
+		}
+	}
 	return nil
 }
+
+// startSpan starts a client span for a repository operation
+func startSpan(ctx context.Context, name string) (context.Context, trace.Span) {
+	return tracing.Tracer().Start(ctx, "repository."+name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemPostgreSQL),
+	)
+}
@@ -0,0 +1,395 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+	"github.com/ec332/aegis/market/internal/tracing"
+	"github.com/ec332/aegis/market/pkg/models"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// proposalColumns lists the resolution_proposals columns read by scanProposal
+const proposalColumns = `id, market_id, proposer_id, winning_option_id, resolved_value, evidence, status,
+	dispute_deadline, created_at, closed_at`
+
+// scanProposal reads a row selected with proposalColumns
+func scanProposal(row rowScanner) (*models.ResolutionProposal, error) {
+	p := &models.ResolutionProposal{}
+	err := row.Scan(
+		&p.ID, &p.MarketID, &p.ProposerID, &p.WinningOptionID, &p.ResolvedValue, &p.Evidence, &p.Status,
+		&p.DisputeDeadline, &p.CreatedAt, &p.ClosedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// ProposalSettlement closes a resolution proposal in the same transaction as the
+// market update that resolves or voids its market
+type ProposalSettlement struct {
+	ProposalID string
+	// From lists the statuses the proposal may be in; anything else is a conflict
+	From   []models.ProposalStatus
+	Status models.ProposalStatus
+	// DisputeStatus is applied to the proposal's pending disputes
+	DisputeStatus models.DisputeStatus
+	// Ruling, if set, is the arbitrated outcome recorded as a finalized proposal
+	Ruling *models.ResolutionProposal
+}
+
+// CreateResolutionProposal records a proposed outcome. A market with a proposal
+// still open or disputed yields ErrConflict.
+func (r *Repository) CreateResolutionProposal(ctx context.Context, p *models.ResolutionProposal) (err error) {
+	ctx, span := startSpan(ctx, "CreateResolutionProposal")
+	defer func() { tracing.End(span, err) }()
+
+	if err := insertProposal(ctx, r.db, p); err != nil {
+		return fmt.Errorf("insert resolution proposal: %w", err)
+	}
+	return nil
+}
+
+// GetPendingProposal retrieves the market's open or disputed proposal with its disputes
+func (r *Repository) GetPendingProposal(ctx context.Context, marketID string) (_ *models.ResolutionProposal, err error) {
+	ctx, span := startSpan(ctx, "GetPendingProposal")
+	defer func() { tracing.End(span, err) }()
+
+	query := `
+		SELECT ` + proposalColumns + `
+		FROM resolution_proposals
+		WHERE market_id = $1 AND status IN ('open', 'disputed')
+	`
+	p, err := scanProposal(r.db.QueryRowContext(ctx, query, marketID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("pending resolution proposal for market %s: %w", marketID, ErrNotFound)
+		}
+		return nil, fmt.Errorf("query resolution proposal: %w", mapError(err))
+	}
+
+	disputes, err := r.getDisputes(ctx, []string{p.ID})
+	if err != nil {
+		return nil, err
+	}
+	p.Disputes = disputes[p.ID]
+	return p, nil
+}
+
+// ListResolutionProposals retrieves a market's proposals with their disputes, oldest first
+func (r *Repository) ListResolutionProposals(ctx context.Context, marketID string) (_ []models.ResolutionProposal, err error) {
+	ctx, span := startSpan(ctx, "ListResolutionProposals")
+	defer func() { tracing.End(span, err) }()
+
+	query := `
+		SELECT ` + proposalColumns + `
+		FROM resolution_proposals
+		WHERE market_id = $1
+		ORDER BY created_at ASC, id ASC
+	`
+	proposals, err := r.queryProposals(ctx, query, marketID)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, len(proposals))
+	for i := range proposals {
+		ids[i] = proposals[i].ID
+	}
+	disputes, err := r.getDisputes(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for i := range proposals {
+		proposals[i].Disputes = disputes[proposals[i].ID]
+	}
+
+	return proposals, nil
+}
+
+// ListDueProposals retrieves open proposals whose dispute window closed by now
+func (r *Repository) ListDueProposals(ctx context.Context, now time.Time) (_ []models.ResolutionProposal, err error) {
+	ctx, span := startSpan(ctx, "ListDueProposals")
+	defer func() { tracing.End(span, err) }()
+
+	query := `
+		SELECT ` + proposalColumns + `
+		FROM resolution_proposals
+		WHERE status = 'open' AND dispute_deadline <= $1
+		ORDER BY dispute_deadline ASC
+	`
+	return r.queryProposals(ctx, query, now)
+}
+
+// AddResolutionDispute records a dispute, moves its proposal to disputed and moves
+// the bond from the disputer into the market's bond escrow. The proposal must still
+// be open or disputed with its window open, else ErrConflict.
+func (r *Repository) AddResolutionDispute(ctx context.Context, d *models.ResolutionDispute) (err error) {
+	ctx, span := startSpan(ctx, "AddResolutionDispute")
+	defer func() { tracing.End(span, err) }()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", mapError(err))
+	}
+	defer tx.Rollback()
+
+	var marketID string
+	err = tx.QueryRowContext(ctx, `
+		UPDATE resolution_proposals SET status = 'disputed'
+		WHERE id = $1 AND status IN ('open', 'disputed') AND dispute_deadline > $2
+		RETURNING market_id
+	`, d.ProposalID, d.CreatedAt).Scan(&marketID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("resolution proposal %s is closed to disputes: %w", d.ProposalID, ErrConflict)
+		}
+		return fmt.Errorf("update resolution proposal: %w", mapError(err))
+	}
+
+	query := `
+		INSERT INTO resolution_disputes (id, proposal_id, disputer_id, reason, bond, status, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+	_, err = tx.ExecContext(ctx, query,
+		d.ID, d.ProposalID, d.DisputerID, d.Reason, d.Bond, d.Status, d.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("insert resolution dispute: %w", mapError(err))
+	}
+
+	if d.Bond > 0 {
+		from := models.LedgerAccountUserPrefix + d.DisputerID
+		entries := bondEntries(marketID, d.ID, from, models.LedgerAccountBondsPrefix+marketID, d.Bond, d.CreatedAt)
+		if err := insertLedgerEntries(ctx, tx, entries); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit transaction: %w", mapError(err))
+	}
+	return nil
+}
+
+// settleProposal closes a proposal and its pending disputes, recording any ruling.
+// The bonds of the disputes leave the escrow: back to their disputers unless the
+// disputes are rejected, which forfeits them to the platform.
+func settleProposal(ctx context.Context, tx *sql.Tx, s *ProposalSettlement) error {
+	from := make([]string, len(s.From))
+	for i, status := range s.From {
+		from[i] = string(status)
+	}
+
+	var marketID string
+	err := tx.QueryRowContext(ctx, `
+		UPDATE resolution_proposals SET status = $1, closed_at = NOW()
+		WHERE id = $2 AND status = ANY($3)
+		RETURNING market_id
+	`, s.Status, s.ProposalID, pq.Array(from)).Scan(&marketID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("resolution proposal %s changed concurrently: %w", s.ProposalID, ErrConflict)
+		}
+		return fmt.Errorf("close resolution proposal: %w", mapError(err))
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		UPDATE resolution_disputes SET status = $1
+		WHERE proposal_id = $2 AND status = 'pending'
+		RETURNING id, disputer_id, bond
+	`, s.DisputeStatus, s.ProposalID)
+	if err != nil {
+		return fmt.Errorf("close resolution disputes: %w", mapError(err))
+	}
+	var closed []models.ResolutionDispute
+	for rows.Next() {
+		var d models.ResolutionDispute
+		if err := rows.Scan(&d.ID, &d.DisputerID, &d.Bond); err != nil {
+			rows.Close()
+			return fmt.Errorf("scan resolution dispute: %w", err)
+		}
+		closed = append(closed, d)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("iterate resolution disputes: %w", mapError(err))
+	}
+
+	now := time.Now()
+	for _, d := range closed {
+		if d.Bond <= 0 {
+			continue
+		}
+		to := models.LedgerAccountUserPrefix + d.DisputerID
+		if s.DisputeStatus == models.DisputeStatusRejected {
+			to = models.LedgerAccountPlatform
+		}
+		entries := bondEntries(marketID, d.ID, models.LedgerAccountBondsPrefix+marketID, to, d.Bond, now)
+		if err := insertLedgerEntries(ctx, tx, entries); err != nil {
+			return err
+		}
+	}
+
+	if s.Ruling != nil {
+		if err := insertProposal(ctx, tx, s.Ruling); err != nil {
+			return fmt.Errorf("insert ruling: %w", err)
+		}
+	}
+	return nil
+}
+
+// bondEntries moves a dispute's bond from one account to another
+func bondEntries(marketID, disputeID, from, to string, bond float64, now time.Time) []models.LedgerEntry {
+	entries := []models.LedgerEntry{
+		{Account: from, Amount: -bond},
+		{Account: to, Amount: bond},
+	}
+	for i := range entries {
+		entries[i].ID = uuid.New().String()
+		entries[i].MarketID = marketID
+		entries[i].Kind = models.LedgerKindBond
+		entries[i].RefID = disputeID
+		entries[i].CreatedAt = now
+	}
+	return entries
+}
+
+// execer is implemented by *sql.DB and *sql.Tx
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+func insertProposal(ctx context.Context, db execer, p *models.ResolutionProposal) error {
+	query := `
+		INSERT INTO resolution_proposals (id, market_id, proposer_id, winning_option_id, resolved_value,
+		                                  evidence, status, dispute_deadline, created_at, closed_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`
+	_, err := db.ExecContext(ctx, query,
+		p.ID, p.MarketID, p.ProposerID, p.WinningOptionID, p.ResolvedValue,
+		p.Evidence, p.Status, p.DisputeDeadline, p.CreatedAt, p.ClosedAt,
+	)
+	return mapError(err)
+}
+
+func (r *Repository) queryProposals(ctx context.Context, query string, args ...interface{}) ([]models.ResolutionProposal, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query resolution proposals: %w", mapError(err))
+	}
+	defer rows.Close()
+
+	proposals := []models.ResolutionProposal{}
+	for rows.Next() {
+		p, err := scanProposal(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan resolution proposal: %w", err)
+		}
+		p.Disputes = []models.ResolutionDispute{}
+		proposals = append(proposals, *p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate resolution proposals: %w", mapError(err))
+	}
+	return proposals, nil
+}
+
+// getDisputes retrieves the disputes of the given proposals keyed by proposal ID
+func (r *Repository) getDisputes(ctx context.Context, proposalIDs []string) (map[string][]models.ResolutionDispute, error) {
+	query := `
+		SELECT id, proposal_id, disputer_id, reason, bond, status, created_at
+		FROM resolution_disputes
+		WHERE proposal_id = ANY($1)
+		ORDER BY created_at ASC, id ASC
+	`
+	rows, err := r.db.QueryContext(ctx, query, pq.Array(proposalIDs))
+	if err != nil {
+		return nil, fmt.Errorf("query resolution disputes: %w", mapError(err))
+	}
+	defer rows.Close()
+
+	disputes := make(map[string][]models.ResolutionDispute, len(proposalIDs))
+	for _, id := range proposalIDs {
+		disputes[id] = []models.ResolutionDispute{}
+	}
+	for rows.Next() {
+		d := models.ResolutionDispute{}
+		err := rows.Scan(&d.ID, &d.ProposalID, &d.DisputerID, &d.Reason, &d.Bond, &d.Status, &d.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("scan resolution dispute: %w", err)
+		}
+		disputes[d.ProposalID] = append(disputes[d.ProposalID], d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate resolution disputes: %w", mapError(err))
+	}
+	return disputes, nil
+}
+
+// ListMarketsDueForResolution retrieves active and halted markets whose resolution
+// time has passed
+func (r *Repository) ListMarketsDueForResolution(ctx context.Context, now time.Time) (_ []models.Market, err error) {
+	ctx, span := startSpan(ctx, "ListMarketsDueForResolution")
+	defer func() { tracing.End(span, err) }()
+
+	query := `
+		SELECT ` + marketColumns + `
+		FROM markets m
+		WHERE m.status IN ('active', 'halted') AND m.resolution_datetime <= $1
+		ORDER BY m.resolution_datetime ASC
+	`
+	return r.queryMarketsWithOptions(ctx, query, now)
+}
+
+// ListMarketsAwaitingOracle retrieves resolving markets with a resolution spec and no
+// pending proposal
+func (r *Repository) ListMarketsAwaitingOracle(ctx context.Context) (_ []models.Market, err error) {
+	ctx, span := startSpan(ctx, "ListMarketsAwaitingOracle")
+	defer func() { tracing.End(span, err) }()
+
+	query := `
+		SELECT ` + marketColumns + `
+		FROM markets m
+		WHERE m.status = 'resolving' AND m.resolution_spec IS NOT NULL
+		  AND NOT EXISTS (
+			SELECT 1 FROM resolution_proposals p
+			WHERE p.market_id = m.id AND p.status IN ('open', 'disputed')
+		  )
+		ORDER BY m.resolution_datetime ASC NULLS LAST
+	`
+	return r.queryMarketsWithOptions(ctx, query)
+}
+
+// queryMarketsWithOptions runs a market query and loads each market's options
+func (r *Repository) queryMarketsWithOptions(ctx context.Context, query string, args ...interface{}) ([]models.Market, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query markets: %w", mapError(err))
+	}
+	defer rows.Close()
+
+	markets := []models.Market{}
+	for rows.Next() {
+		market, err := scanMarket(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan market: %w", err)
+		}
+		markets = append(markets, *market)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate markets: %w", mapError(err))
+	}
+
+	for i := range markets {
+		options, err := r.GetOptionsByMarketID(ctx, markets[i].ID)
+		if err != nil {
+			return nil, fmt.Errorf("get options for market %s: %w", markets[i].ID, err)
+		}
+		markets[i].Options = options
+	}
+	return markets, nil
+}
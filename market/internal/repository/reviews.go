@@ -0,0 +1,244 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/ec332/aegis/market/internal/tracing"
+	"github.com/ec332/aegis/market/pkg/models"
+)
+
+// approvalsSinceSubmit counts the distinct users other than the creator who approved
+// market m since it was last submitted
+const approvalsSinceSubmit = `
+	SELECT COUNT(DISTINCT r.reviewer_id)
+	FROM market_reviews r
+	WHERE r.market_id = m.id
+	  AND r.action = 'approve'
+	  AND r.reviewer_id <> m.created_by
+	  AND r.created_at > (
+		SELECT COALESCE(MAX(s.created_at), '-infinity')
+		FROM market_reviews s
+		WHERE s.market_id = m.id AND s.action = 'submit'
+	  )`
+
+// AddMarketReview records a review history entry. When state is set the market's
+// review state is changed in the same transaction, with the market locked so the
+// change does not interleave with ApproveMarket.
+func (r *Repository) AddMarketReview(ctx context.Context, review *models.MarketReview, state models.ReviewState) (err error) {
+	ctx, span := startSpan(ctx, "AddMarketReview")
+	defer func() { tracing.End(span, err) }()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", mapError(err))
+	}
+	defer tx.Rollback()
+
+	if state != "" {
+		var id string
+		err = tx.QueryRowContext(ctx, `SELECT id FROM markets WHERE id = $1 FOR UPDATE`, review.MarketID).Scan(&id)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return fmt.Errorf("market %s: %w", review.MarketID, ErrNotFound)
+			}
+			return fmt.Errorf("lock market: %w", mapError(err))
+		}
+	}
+
+	query := `
+		INSERT INTO market_reviews (id, market_id, reviewer_id, action, comment, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+	_, err = tx.ExecContext(ctx, query,
+		review.ID, review.MarketID, review.ReviewerID, review.Action, review.Comment, review.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("insert market review: %w", mapError(err))
+	}
+
+	if state != "" {
+		result, err := tx.ExecContext(ctx,
+			`UPDATE markets SET review_state = $1, updated_at = $2 WHERE id = $3`,
+			state, review.CreatedAt, review.MarketID,
+		)
+		if err != nil {
+			return fmt.Errorf("update review state: %w", mapError(err))
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("get rows affected: %w", err)
+		}
+		if rowsAffected == 0 {
+			return fmt.Errorf("market %s: %w", review.MarketID, ErrNotFound)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit transaction: %w", mapError(err))
+	}
+	return nil
+}
+
+// ApproveMarket records an approval and, once required users other than the creator
+// approved since the market was last submitted, moves it to approved. The market is
+// locked while approvals are counted, so concurrent approvals are all counted. It
+// returns the review state the market is left in, or ErrConflict unless the market
+// is a draft pending review.
+func (r *Repository) ApproveMarket(ctx context.Context, review *models.MarketReview, required int) (_ models.ReviewState, err error) {
+	ctx, span := startSpan(ctx, "ApproveMarket")
+	defer func() { tracing.End(span, err) }()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("begin transaction: %w", mapError(err))
+	}
+	defer tx.Rollback()
+
+	var status models.MarketStatus
+	var state models.ReviewState
+	err = tx.QueryRowContext(ctx,
+		`SELECT status, review_state FROM markets WHERE id = $1 FOR UPDATE`, review.MarketID,
+	).Scan(&status, &state)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", fmt.Errorf("market %s: %w", review.MarketID, ErrNotFound)
+		}
+		return "", fmt.Errorf("lock market: %w", mapError(err))
+	}
+	if status != models.MarketStatusDraft || state != models.ReviewStatePending {
+		return "", fmt.Errorf("market %s is %s and %s: %w", review.MarketID, status, state, ErrConflict)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO market_reviews (id, market_id, reviewer_id, action, comment, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, review.ID, review.MarketID, review.ReviewerID, review.Action, review.Comment, review.CreatedAt)
+	if err != nil {
+		return "", fmt.Errorf("insert market review: %w", mapError(err))
+	}
+
+	// The approval just inserted is counted, once however often its reviewer approved
+	var approvals int
+	query := `SELECT (` + approvalsSinceSubmit + `) FROM markets m WHERE m.id = $1`
+	if err := tx.QueryRowContext(ctx, query, review.MarketID).Scan(&approvals); err != nil {
+		return "", fmt.Errorf("count approvals: %w", mapError(err))
+	}
+	if approvals >= required {
+		state = models.ReviewStateApproved
+		_, err = tx.ExecContext(ctx,
+			`UPDATE markets SET review_state = $1, updated_at = $2 WHERE id = $3`,
+			state, review.CreatedAt, review.MarketID,
+		)
+		if err != nil {
+			return "", fmt.Errorf("update review state: %w", mapError(err))
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("commit transaction: %w", mapError(err))
+	}
+	return state, nil
+}
+
+// ListMarketReviews retrieves a market's review history, oldest first
+func (r *Repository) ListMarketReviews(ctx context.Context, marketID string) (_ []models.MarketReview, err error) {
+	ctx, span := startSpan(ctx, "ListMarketReviews")
+	defer func() { tracing.End(span, err) }()
+
+	query := `
+		SELECT id, market_id, reviewer_id, action, comment, created_at
+		FROM market_reviews
+		WHERE market_id = $1
+		ORDER BY created_at ASC, id ASC
+	`
+	rows, err := r.db.QueryContext(ctx, query, marketID)
+	if err != nil {
+		return nil, fmt.Errorf("query market reviews: %w", mapError(err))
+	}
+	defer rows.Close()
+
+	reviews := []models.MarketReview{}
+	for rows.Next() {
+		review := models.MarketReview{}
+		err := rows.Scan(
+			&review.ID, &review.MarketID, &review.ReviewerID, &review.Action, &review.Comment, &review.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("scan market review: %w", err)
+		}
+		reviews = append(reviews, review)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate market reviews: %w", mapError(err))
+	}
+
+	return reviews, nil
+}
+
+// ReviewQueue retrieves draft markets awaiting review, longest waiting first
+func (r *Repository) ReviewQueue(ctx context.Context) (_ []models.ReviewQueueItem, err error) {
+	ctx, span := startSpan(ctx, "ReviewQueue")
+	defer func() { tracing.End(span, err) }()
+
+	query := `
+		SELECT ` + marketColumns + `,
+		       (` + approvalsSinceSubmit + `),
+		       (SELECT MAX(s.created_at) FROM market_reviews s WHERE s.market_id = m.id AND s.action = 'submit') AS submitted_at
+		FROM markets m
+		WHERE m.status = 'draft' AND m.review_state = 'pending'
+		ORDER BY submitted_at ASC NULLS FIRST, m.created_at ASC
+	`
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("query review queue: %w", mapError(err))
+	}
+	defer rows.Close()
+
+	items := []models.ReviewQueueItem{}
+	for rows.Next() {
+		var item models.ReviewQueueItem
+		market, err := scanMarket(queueRow{rows, &item})
+		if err != nil {
+			return nil, fmt.Errorf("scan review queue: %w", err)
+		}
+		item.Market = *market
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate review queue: %w", mapError(err))
+	}
+
+	for i := range items {
+		market := &items[i].Market
+		options, err := r.GetOptionsByMarketID(ctx, market.ID)
+		if err != nil {
+			return nil, fmt.Errorf("get options for market %s: %w", market.ID, err)
+		}
+		market.Options = options
+
+		tags, err := r.GetTagsByMarketID(ctx, market.ID)
+		if err != nil {
+			return nil, fmt.Errorf("get tags for market %s: %w", market.ID, err)
+		}
+		market.Tags = tags
+	}
+
+	return items, nil
+}
+
+// queueRow appends the review queue columns to those read by scanMarket
+type queueRow struct {
+	row  rowScanner
+	item *models.ReviewQueueItem
+}
+
+func (q queueRow) Scan(dest ...interface{}) error {
+	var submittedAt sql.NullTime
+	dest = append(dest, &q.item.Approvals, &submittedAt)
+	if err := q.row.Scan(dest...); err != nil {
+		return err
+	}
+	q.item.SubmittedAt = submittedAt.Time
+	return nil
+}
@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/ec332/aegis/market/internal/tracing"
+	"github.com/ec332/aegis/market/pkg/models"
+	"github.com/google/uuid"
+)
+
+// saveMarketRevision copies the market's current texts into the next revision.
+// The market row is locked so concurrent edits get distinct revision numbers.
+func saveMarketRevision(ctx context.Context, tx *sql.Tx, marketID, editedBy string) error {
+	if _, err := tx.ExecContext(ctx, `SELECT 1 FROM markets WHERE id = $1 FOR UPDATE`, marketID); err != nil {
+		return fmt.Errorf("lock market: %w", mapError(err))
+	}
+
+	query := `
+		INSERT INTO market_revisions (id, market_id, revision, title, description,
+		                              resolution_rules, resolution_source, edited_by, created_at)
+		SELECT $1, m.id,
+		       COALESCE((SELECT MAX(revision) FROM market_revisions WHERE market_id = m.id), 0) + 1,
+		       m.title, m.description, m.resolution_rules, m.resolution_source, $2, NOW()
+		FROM markets m
+		WHERE m.id = $3
+	`
+	if _, err := tx.ExecContext(ctx, query, uuid.New().String(), editedBy, marketID); err != nil {
+		return fmt.Errorf("save market revision: %w", mapError(err))
+	}
+	return nil
+}
+
+// ListMarketRevisions retrieves a market's prior texts, newest first
+func (r *Repository) ListMarketRevisions(ctx context.Context, marketID string) (_ []models.MarketRevision, err error) {
+	ctx, span := startSpan(ctx, "ListMarketRevisions")
+	defer func() { tracing.End(span, err) }()
+
+	query := `
+		SELECT id, market_id, revision, title, description, resolution_rules, resolution_source, edited_by, created_at
+		FROM market_revisions
+		WHERE market_id = $1
+		ORDER BY revision DESC
+	`
+	rows, err := r.db.QueryContext(ctx, query, marketID)
+	if err != nil {
+		return nil, fmt.Errorf("query market revisions: %w", mapError(err))
+	}
+	defer rows.Close()
+
+	revisions := []models.MarketRevision{}
+	for rows.Next() {
+		rev := models.MarketRevision{}
+		err := rows.Scan(
+			&rev.ID, &rev.MarketID, &rev.Revision, &rev.Title, &rev.Description,
+			&rev.ResolutionRules, &rev.ResolutionSource, &rev.EditedBy, &rev.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("scan market revision: %w", err)
+		}
+		revisions = append(revisions, rev)
+	}
+
+	return revisions, nil
+}
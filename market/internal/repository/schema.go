@@ -0,0 +1,489 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"github.com/ec332/aegis/market/internal/tracing"
+)
+
+// migrationLockID serializes schema migrations across replicas starting at the same time
+const migrationLockID = 7_245_118_301
+
+// migrations are applied in order by InitSchema. Each entry's version is its
+// 1-based index; append new migrations and never edit ones already released.
+var migrations = []string{
+	// 1: markets, options and liquidity pools
+	`
+	CREATE TABLE IF NOT EXISTS markets (
+		id UUID PRIMARY KEY,
+		title VARCHAR(255) NOT NULL,
+		description TEXT NOT NULL,
+		status VARCHAR(50) NOT NULL DEFAULT 'draft',
+		resolution_datetime TIMESTAMP,
+		winning_option_id UUID,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+	);
+
+	CREATE TABLE IF NOT EXISTS options (
+		id UUID PRIMARY KEY,
+		market_id UUID NOT NULL REFERENCES markets(id) ON DELETE CASCADE,
+		title VARCHAR(255) NOT NULL,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW()
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_options_market_id ON options(market_id);
+
+	CREATE TABLE IF NOT EXISTS liquidity_pool (
+		id UUID PRIMARY KEY,
+		market_id UUID NOT NULL REFERENCES markets(id) ON DELETE CASCADE,
+		option_id UUID NOT NULL REFERENCES options(id) ON DELETE CASCADE,
+		pool_value DECIMAL(20, 8) NOT NULL DEFAULT 0,
+		updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_liquidity_pool_market_id ON liquidity_pool(market_id);
+	CREATE INDEX IF NOT EXISTS idx_liquidity_pool_option_id ON liquidity_pool(option_id);
+	CREATE INDEX IF NOT EXISTS idx_markets_status ON markets(status);
+	CREATE INDEX IF NOT EXISTS idx_markets_created_at ON markets(created_at);
+	`,
+
+	// 2: hierarchical categories, tags and featured markets
+	`
+	CREATE TABLE IF NOT EXISTS categories (
+		id UUID PRIMARY KEY,
+		parent_id UUID REFERENCES categories(id) ON DELETE RESTRICT,
+		name VARCHAR(100) NOT NULL,
+		slug VARCHAR(100) NOT NULL UNIQUE,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_categories_parent_id ON categories(parent_id);
+
+	ALTER TABLE markets
+		ADD COLUMN IF NOT EXISTS category_id UUID REFERENCES categories(id) ON DELETE RESTRICT,
+		ADD COLUMN IF NOT EXISTS featured_rank INT;
+
+	CREATE INDEX IF NOT EXISTS idx_markets_category_id ON markets(category_id);
+	CREATE INDEX IF NOT EXISTS idx_markets_featured_rank ON markets(featured_rank) WHERE featured_rank IS NOT NULL;
+
+	CREATE TABLE IF NOT EXISTS market_tags (
+		market_id UUID NOT NULL REFERENCES markets(id) ON DELETE CASCADE,
+		tag VARCHAR(50) NOT NULL,
+		PRIMARY KEY (market_id, tag)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_market_tags_tag ON market_tags(tag);
+	`,
+
+	// 3: scalar markets and settlement payouts
+	`
+	ALTER TABLE markets
+		ADD COLUMN IF NOT EXISTS market_type VARCHAR(20) NOT NULL DEFAULT 'categorical',
+		ADD COLUMN IF NOT EXISTS lower_bound DECIMAL(30, 8),
+		ADD COLUMN IF NOT EXISTS upper_bound DECIMAL(30, 8),
+		ADD COLUMN IF NOT EXISTS resolved_value DECIMAL(30, 8);
+
+	ALTER TABLE markets ADD CONSTRAINT markets_scalar_bounds CHECK (
+		market_type <> 'scalar' OR (lower_bound IS NOT NULL AND upper_bound IS NOT NULL AND lower_bound < upper_bound)
+	);
+
+	ALTER TABLE options
+		ADD COLUMN IF NOT EXISTS side VARCHAR(10),
+		ADD COLUMN IF NOT EXISTS payout DECIMAL(20, 8);
+	`,
+
+	// 4: conditional markets
+	`
+	ALTER TABLE markets
+		ADD COLUMN IF NOT EXISTS parent_market_id UUID REFERENCES markets(id) ON DELETE RESTRICT,
+		ADD COLUMN IF NOT EXISTS condition_option_id UUID REFERENCES options(id) ON DELETE RESTRICT;
+
+	ALTER TABLE markets ADD CONSTRAINT markets_condition_complete CHECK (
+		(parent_market_id IS NULL) = (condition_option_id IS NULL)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_markets_parent_market_id ON markets(parent_market_id) WHERE parent_market_id IS NOT NULL;
+	`,
+
+	// 5: option display order and metadata
+	`
+	ALTER TABLE options
+		ADD COLUMN IF NOT EXISTS display_order INT NOT NULL DEFAULT 0,
+		ADD COLUMN IF NOT EXISTS short_label VARCHAR(50),
+		ADD COLUMN IF NOT EXISTS image_url VARCHAR(2048);
+
+	-- Keep the creation order existing options were listed in
+	UPDATE options o SET display_order = ranked.position
+	FROM (
+		SELECT id, ROW_NUMBER() OVER (PARTITION BY market_id ORDER BY created_at, id) - 1 AS position
+		FROM options
+	) ranked
+	WHERE o.id = ranked.id;
+
+	CREATE INDEX IF NOT EXISTS idx_options_market_display_order ON options(market_id, display_order);
+	`,
+
+	// 6: resolution rules and source, and text revision history
+	`
+	ALTER TABLE markets
+		ADD COLUMN IF NOT EXISTS resolution_rules TEXT NOT NULL DEFAULT '',
+		ADD COLUMN IF NOT EXISTS resolution_source VARCHAR(2048) NOT NULL DEFAULT '';
+
+	CREATE TABLE IF NOT EXISTS market_revisions (
+		id UUID PRIMARY KEY,
+		market_id UUID NOT NULL REFERENCES markets(id) ON DELETE CASCADE,
+		revision INT NOT NULL,
+		title VARCHAR(255) NOT NULL,
+		description TEXT NOT NULL,
+		resolution_rules TEXT NOT NULL,
+		resolution_source VARCHAR(2048) NOT NULL,
+		edited_by VARCHAR(255) NOT NULL DEFAULT '',
+		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		UNIQUE (market_id, revision)
+	);
+	`,
+
+	// 7: market creators, review state and review history
+	`
+	ALTER TABLE markets
+		ADD COLUMN IF NOT EXISTS created_by VARCHAR(255) NOT NULL DEFAULT '',
+		ADD COLUMN IF NOT EXISTS review_state VARCHAR(20) NOT NULL DEFAULT 'unsubmitted';
+
+	-- Markets that already left draft were approved before reviews existed
+	UPDATE markets SET review_state = 'approved' WHERE status <> 'draft';
+
+	CREATE TABLE IF NOT EXISTS market_reviews (
+		id UUID PRIMARY KEY,
+		market_id UUID NOT NULL REFERENCES markets(id) ON DELETE CASCADE,
+		reviewer_id VARCHAR(255) NOT NULL,
+		action VARCHAR(20) NOT NULL,
+		comment TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMP NOT NULL DEFAULT NOW()
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_market_reviews_market_id ON market_reviews(market_id, created_at);
+	CREATE INDEX IF NOT EXISTS idx_markets_review_state ON markets(review_state) WHERE status = 'draft';
+	`,
+
+	// 8: resolution proposals and disputes
+	`
+	CREATE TABLE IF NOT EXISTS resolution_proposals (
+		id UUID PRIMARY KEY,
+		market_id UUID NOT NULL REFERENCES markets(id) ON DELETE CASCADE,
+		proposer_id VARCHAR(255) NOT NULL,
+		winning_option_id UUID REFERENCES options(id) ON DELETE RESTRICT,
+		resolved_value DECIMAL(30, 8),
+		evidence TEXT NOT NULL,
+		status VARCHAR(20) NOT NULL,
+		dispute_deadline TIMESTAMP NOT NULL,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		closed_at TIMESTAMP
+	);
+
+	-- A market has at most one proposal awaiting finalization or arbitration
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_resolution_proposals_pending
+		ON resolution_proposals(market_id) WHERE status IN ('open', 'disputed');
+	CREATE INDEX IF NOT EXISTS idx_resolution_proposals_due
+		ON resolution_proposals(dispute_deadline) WHERE status = 'open';
+
+	CREATE TABLE IF NOT EXISTS resolution_disputes (
+		id UUID PRIMARY KEY,
+		proposal_id UUID NOT NULL REFERENCES resolution_proposals(id) ON DELETE CASCADE,
+		disputer_id VARCHAR(255) NOT NULL,
+		reason TEXT NOT NULL,
+		bond DECIMAL(20, 8) NOT NULL,
+		status VARCHAR(20) NOT NULL DEFAULT 'pending',
+		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		UNIQUE (proposal_id, disputer_id)
+	);
+	`,
+
+	// 9: oracle resolution specs
+	`
+	ALTER TABLE markets ADD COLUMN IF NOT EXISTS resolution_spec JSONB;
+
+	CREATE INDEX IF NOT EXISTS idx_markets_resolution_due ON markets(resolution_datetime) WHERE status = 'active';
+	`,
+
+	// 10: order book, trades, positions and the collateral ledger
+	`
+	CREATE TABLE IF NOT EXISTS orders (
+		id UUID PRIMARY KEY,
+		seq BIGSERIAL NOT NULL,
+		market_id UUID NOT NULL REFERENCES markets(id) ON DELETE CASCADE,
+		option_id UUID NOT NULL REFERENCES options(id) ON DELETE CASCADE,
+		user_id VARCHAR(255) NOT NULL,
+		side VARCHAR(4) NOT NULL,
+		order_type VARCHAR(10) NOT NULL,
+		price DECIMAL(20, 8),
+		shares DECIMAL(20, 8) NOT NULL CHECK (shares > 0),
+		filled_shares DECIMAL(20, 8) NOT NULL DEFAULT 0 CHECK (filled_shares >= 0 AND filled_shares <= shares),
+		status VARCHAR(20) NOT NULL,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+	);
+
+	-- Price-time priority over the resting orders of each option and side
+	CREATE INDEX IF NOT EXISTS idx_orders_book
+		ON orders(option_id, side, price, seq) WHERE status IN ('open', 'partially_filled');
+	CREATE INDEX IF NOT EXISTS idx_orders_user ON orders(user_id, market_id, created_at);
+
+	CREATE TABLE IF NOT EXISTS trades (
+		id UUID PRIMARY KEY,
+		market_id UUID NOT NULL REFERENCES markets(id) ON DELETE CASCADE,
+		option_id UUID NOT NULL REFERENCES options(id) ON DELETE CASCADE,
+		order_id UUID NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+		user_id VARCHAR(255) NOT NULL,
+		side VARCHAR(4) NOT NULL,
+		venue VARCHAR(10) NOT NULL,
+		maker_order_id UUID REFERENCES orders(id) ON DELETE CASCADE,
+		maker_user_id VARCHAR(255),
+		shares DECIMAL(20, 8) NOT NULL,
+		price DECIMAL(20, 8) NOT NULL,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW()
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_trades_market_id ON trades(market_id, created_at);
+
+	CREATE TABLE IF NOT EXISTS positions (
+		user_id VARCHAR(255) NOT NULL,
+		option_id UUID NOT NULL REFERENCES options(id) ON DELETE CASCADE,
+		market_id UUID NOT NULL REFERENCES markets(id) ON DELETE CASCADE,
+		shares DECIMAL(20, 8) NOT NULL DEFAULT 0 CHECK (shares >= 0),
+		updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		PRIMARY KEY (user_id, option_id)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_positions_market_id ON positions(market_id);
+
+	CREATE TABLE IF NOT EXISTS ledger_entries (
+		id UUID PRIMARY KEY,
+		account VARCHAR(255) NOT NULL,
+		market_id UUID REFERENCES markets(id) ON DELETE CASCADE,
+		kind VARCHAR(20) NOT NULL,
+		amount DECIMAL(20, 8) NOT NULL,
+		ref_id UUID NOT NULL,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW()
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_ledger_entries_account ON ledger_entries(account, created_at);
+	CREATE INDEX IF NOT EXISTS idx_ledger_entries_market_id ON ledger_entries(market_id, kind);
+	`,
+
+	// 11: complete set mints and redemptions
+	`
+	CREATE TABLE IF NOT EXISTS complete_sets (
+		id UUID PRIMARY KEY,
+		market_id UUID NOT NULL REFERENCES markets(id) ON DELETE CASCADE,
+		user_id VARCHAR(255) NOT NULL,
+		action VARCHAR(10) NOT NULL,
+		shares DECIMAL(20, 8) NOT NULL CHECK (shares > 0),
+		created_at TIMESTAMP NOT NULL DEFAULT NOW()
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_complete_sets_market_id ON complete_sets(market_id, created_at);
+	`,
+
+	// 12: trading fees
+	`
+	ALTER TABLE markets
+		ADD COLUMN IF NOT EXISTS fee_rate DECIMAL(10, 8) NOT NULL DEFAULT 0,
+		ADD COLUMN IF NOT EXISTS min_fee DECIMAL(20, 8) NOT NULL DEFAULT 0;
+
+	ALTER TABLE trades ADD COLUMN IF NOT EXISTS fee DECIMAL(20, 8) NOT NULL DEFAULT 0;
+	`,
+
+	// 13: liquidity providers and their deposits and withdrawals
+	`
+	CREATE TABLE IF NOT EXISTS liquidity_providers (
+		market_id UUID NOT NULL REFERENCES markets(id) ON DELETE CASCADE,
+		user_id VARCHAR(255) NOT NULL,
+		shares DECIMAL(20, 8) NOT NULL DEFAULT 0 CHECK (shares >= 0),
+		fees_withdrawn DECIMAL(20, 8) NOT NULL DEFAULT 0,
+		updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		PRIMARY KEY (market_id, user_id)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_liquidity_providers_user_id ON liquidity_providers(user_id);
+
+	CREATE TABLE IF NOT EXISTS liquidity_events (
+		id UUID PRIMARY KEY,
+		market_id UUID NOT NULL REFERENCES markets(id) ON DELETE CASCADE,
+		user_id VARCHAR(255) NOT NULL,
+		action VARCHAR(10) NOT NULL,
+		amount DECIMAL(20, 8) NOT NULL,
+		shares DECIMAL(20, 8) NOT NULL,
+		fees DECIMAL(20, 8) NOT NULL DEFAULT 0,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW()
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_liquidity_events_market_id ON liquidity_events(market_id, created_at);
+	`,
+
+	// 14: per-market risk limits
+	`
+	ALTER TABLE markets
+		ADD COLUMN IF NOT EXISTS max_position DECIMAL(20, 8),
+		ADD COLUMN IF NOT EXISTS max_trade_shares DECIMAL(20, 8),
+		ADD COLUMN IF NOT EXISTS max_treasury_exposure DECIMAL(20, 8);
+
+	CREATE INDEX IF NOT EXISTS idx_positions_market_shares ON positions(market_id, shares DESC);
+	`,
+
+	// 15: trading halts and the pool price history circuit breakers watch
+	`
+	CREATE TABLE IF NOT EXISTS market_halts (
+		id UUID PRIMARY KEY,
+		market_id UUID NOT NULL REFERENCES markets(id) ON DELETE CASCADE,
+		cause VARCHAR(20) NOT NULL,
+		reason TEXT NOT NULL,
+		halted_by VARCHAR(255),
+		halted_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		resume_at TIMESTAMP,
+		resumed_at TIMESTAMP,
+		resumed_by VARCHAR(255)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_market_halts_market_id ON market_halts(market_id, halted_at);
+	CREATE INDEX IF NOT EXISTS idx_market_halts_due ON market_halts(resume_at) WHERE resumed_at IS NULL;
+
+	CREATE TABLE IF NOT EXISTS price_history (
+		market_id UUID NOT NULL REFERENCES markets(id) ON DELETE CASCADE,
+		option_id UUID NOT NULL REFERENCES options(id) ON DELETE CASCADE,
+		price DECIMAL(10, 8) NOT NULL,
+		recorded_at TIMESTAMP NOT NULL DEFAULT NOW()
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_price_history_market_id ON price_history(market_id, recorded_at);
+
+	DROP INDEX IF EXISTS idx_markets_resolution_due;
+	CREATE INDEX idx_markets_resolution_due ON markets(resolution_datetime) WHERE status IN ('active', 'halted');
+	`,
+
+	// 16: price ticks are pruned by age across markets
+	`
+	CREATE INDEX IF NOT EXISTS idx_price_history_recorded_at ON price_history(recorded_at);
+	`,
+
+	// 17: trades awaiting recording with the transaction service
+	`
+	ALTER TABLE trades
+		ADD COLUMN IF NOT EXISTS recorded_at TIMESTAMP,
+		ADD COLUMN IF NOT EXISTS record_error TEXT;
+
+	CREATE INDEX IF NOT EXISTS idx_trades_unrecorded ON trades(created_at)
+		WHERE recorded_at IS NULL AND record_error IS NULL;
+	`,
+
+	// 18: the changes pool reserves take outside trades, which reconciliation replays
+	// together with the recorded transactions. Existing pools start from a baseline:
+	// their reserves less what their pool trades moved them by.
+	`
+	CREATE TABLE IF NOT EXISTS reserve_changes (
+		id BIGSERIAL PRIMARY KEY,
+		market_id UUID NOT NULL REFERENCES markets(id) ON DELETE CASCADE,
+		option_id UUID NOT NULL REFERENCES options(id) ON DELETE CASCADE,
+		source VARCHAR(20) NOT NULL,
+		ref_id VARCHAR(255) NOT NULL,
+		change DECIMAL(20, 8) NOT NULL,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW()
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_reserve_changes_market_id ON reserve_changes(market_id, option_id);
+
+	INSERT INTO reserve_changes (market_id, option_id, source, ref_id, change)
+	SELECT lp.market_id, lp.option_id, 'baseline', lp.id::text, lp.pool_value - COALESCE(SUM(
+		CASE WHEN t.side = 'sell' THEN 1 ELSE -1 END * t.shares *
+		(CASE WHEN t.option_id = lp.option_id THEN 1 ELSE 0 END - t.price)
+	), 0)
+	FROM liquidity_pool lp
+	LEFT JOIN trades t ON t.market_id = lp.market_id AND t.venue = 'pool'
+	GROUP BY lp.id, lp.market_id, lp.option_id, lp.pool_value;
+	`,
+
+	// 19: trade events ingested from the Redis Stream, by the ID their producer gave
+	`
+	CREATE TABLE IF NOT EXISTS processed_events (
+		event_id VARCHAR(255) PRIMARY KEY,
+		market_id UUID NOT NULL,
+		processed_at TIMESTAMP NOT NULL DEFAULT NOW()
+	);
+	`,
+
+	// 20: the sequence of the last trade event applied to each pool, so older
+	// events arriving late do not overwrite newer ones
+	`
+	ALTER TABLE liquidity_pool ADD COLUMN IF NOT EXISTS event_sequence BIGINT NOT NULL DEFAULT 0;
+	`,
+}
+
+// SchemaVersion returns the schema version this build expects
+func SchemaVersion() int {
+	return len(migrations)
+}
+
+// InitSchema initializes the database schema by applying pending migrations
+func (r *Repository) InitSchema(ctx context.Context) error {
+	_, err := r.db.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INT PRIMARY KEY,
+			applied_at TIMESTAMP NOT NULL DEFAULT NOW()
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("init schema: create schema_migrations: %w", err)
+	}
+
+	for i, migration := range migrations {
+		if err := r.applyMigration(ctx, i+1, migration); err != nil {
+			return fmt.Errorf("init schema: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// applyMigration runs migration under an advisory lock unless it has already been applied
+func (r *Repository) applyMigration(ctx context.Context, version int, migration string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, migrationLockID); err != nil {
+		return fmt.Errorf("acquire migration lock: %w", err)
+	}
+
+	var applied bool
+	query := `SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)`
+	if err := tx.QueryRowContext(ctx, query, version).Scan(&applied); err != nil {
+		return fmt.Errorf("check migration %d: %w", version, err)
+	}
+	if applied {
+		return nil
+	}
+
+	if _, err := tx.ExecContext(ctx, migration); err != nil {
+		return fmt.Errorf("apply migration %d: %w", version, err)
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version) VALUES ($1)`, version); err != nil {
+		return fmt.Errorf("record migration %d: %w", version, err)
+	}
+
+	return tx.Commit()
+}
+
+// CurrentSchemaVersion returns the highest migration version applied to the database
+func (r *Repository) CurrentSchemaVersion(ctx context.Context) (version int, err error) {
+	ctx, span := startSpan(ctx, "CurrentSchemaVersion")
+	defer func() { tracing.End(span, err) }()
+
+	query := `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`
+	if err := r.db.QueryRowContext(ctx, query).Scan(&version); err != nil {
+		return 0, fmt.Errorf("query schema version: %w", err)
+	}
+	return version, nil
+}
@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/ec332/aegis/market/internal/tracing"
+	"github.com/ec332/aegis/market/pkg/models"
+)
+
+// SetState is what a complete set exchange is checked against
+type SetState struct {
+	Status models.MarketStatus
+	// Holdings are the user's shares of each option by option ID, counting those
+	// its open buy orders would add
+	Holdings map[string]float64
+}
+
+// ExchangeCompleteSet records a mint or redemption, adding (mint) or removing
+// (redeem) set.Shares of each of optionIDs to the user's positions together with
+// its ledger entries. Redeeming more than the user holds of any option yields
+// ErrInsufficientShares and changes nothing. The market is locked and its state
+// passed to check first; an error from check aborts the exchange.
+func (r *Repository) ExchangeCompleteSet(ctx context.Context, set *models.CompleteSet, optionIDs []string, ledger []models.LedgerEntry, check func(*SetState) error) (err error) {
+	ctx, span := startSpan(ctx, "ExchangeCompleteSet")
+	defer func() { tracing.End(span, err) }()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", mapError(err))
+	}
+	defer tx.Rollback()
+
+	state := &SetState{}
+	err = tx.QueryRowContext(ctx, `SELECT status FROM markets WHERE id = $1 FOR UPDATE`, set.MarketID).Scan(&state.Status)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("market %s: %w", set.MarketID, ErrNotFound)
+		}
+		return fmt.Errorf("lock market: %w", mapError(err))
+	}
+	if state.Holdings, err = readHoldings(ctx, tx, set.MarketID, set.UserID); err != nil {
+		return err
+	}
+	if err := check(state); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO complete_sets (id, market_id, user_id, action, shares, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+	_, err = tx.ExecContext(ctx, query, set.ID, set.MarketID, set.UserID, set.Action, set.Shares, set.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("insert complete set: %w", mapError(err))
+	}
+
+	shares := set.Shares
+	if set.Action == models.CompleteSetRedeem {
+		shares = -shares
+	}
+	for _, optionID := range optionIDs {
+		change := PositionChange{UserID: set.UserID, MarketID: set.MarketID, OptionID: optionID, Shares: shares}
+		if err := changePosition(ctx, tx, change); err != nil {
+			return err
+		}
+	}
+
+	if err := insertLedgerEntries(ctx, tx, ledger); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit transaction: %w", mapError(err))
+	}
+	return nil
+}
+
+// ListMarketPositions retrieves a user's positions in one market
+func (r *Repository) ListMarketPositions(ctx context.Context, marketID, userID string) (_ []models.Position, err error) {
+	ctx, span := startSpan(ctx, "ListMarketPositions")
+	defer func() { tracing.End(span, err) }()
+
+	query := `
+		SELECT p.user_id, p.market_id, p.option_id, p.shares, p.updated_at
+		FROM positions p
+		JOIN options o ON o.id = p.option_id
+		WHERE p.market_id = $1 AND p.user_id = $2
+		ORDER BY o.display_order ASC
+	`
+	return queryPositions(ctx, r.db, query, marketID, userID)
+}
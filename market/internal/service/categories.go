@@ -0,0 +1,234 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"github.com/ec332/aegis/market/internal/tracing"
+	"github.com/ec332/aegis/market/pkg/models"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	maxCategoryNameLength = 100
+	maxTagLength          = 50
+	maxTagsPerMarket      = 20
+)
+
+// slugPattern matches lowercase URL-safe slugs such as "us-politics"
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// CreateCategory creates a category, optionally nested under a parent
+func (s *Service) CreateCategory(ctx context.Context, req models.CreateCategoryRequest) (_ *models.Category, err error) {
+	ctx, span := startSpan(ctx, "CreateCategory")
+	defer func() { tracing.End(span, err) }()
+
+	verr := &ValidationError{}
+	validateCategoryName(verr, req.Name)
+	validateSlug(verr, req.Slug)
+	if req.ParentID != nil {
+		s.validateCategoryRef(ctx, verr, "parent_id", *req.ParentID)
+	}
+	if err := verr.err(); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	category := &models.Category{
+		ID:        uuid.New().String(),
+		ParentID:  req.ParentID,
+		Name:      req.Name,
+		Slug:      req.Slug,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	span.SetAttributes(attribute.String("category.id", category.ID))
+
+	if err := s.repo.CreateCategory(ctx, category); err != nil {
+		return nil, fmt.Errorf("create category: %w", err)
+	}
+
+	return category, nil
+}
+
+// GetCategory retrieves a category by ID
+func (s *Service) GetCategory(ctx context.Context, categoryID string) (_ *models.Category, err error) {
+	ctx, span := startSpan(ctx, "GetCategory", attribute.String("category.id", categoryID))
+	defer func() { tracing.End(span, err) }()
+
+	if err := validateID("category", categoryID); err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetCategory(ctx, categoryID)
+}
+
+// ListCategories retrieves all categories
+func (s *Service) ListCategories(ctx context.Context) (_ []models.Category, err error) {
+	ctx, span := startSpan(ctx, "ListCategories")
+	defer func() { tracing.End(span, err) }()
+
+	return s.repo.ListCategories(ctx)
+}
+
+// UpdateCategory renames or moves a category. A category cannot be moved below itself.
+func (s *Service) UpdateCategory(ctx context.Context, categoryID string, req models.UpdateCategoryRequest) (_ *models.Category, err error) {
+	ctx, span := startSpan(ctx, "UpdateCategory", attribute.String("category.id", categoryID))
+	defer func() { tracing.End(span, err) }()
+
+	if err := validateID("category", categoryID); err != nil {
+		return nil, err
+	}
+
+	verr := &ValidationError{}
+	if req.Name != nil {
+		validateCategoryName(verr, *req.Name)
+	}
+	if req.Slug != nil {
+		validateSlug(verr, *req.Slug)
+	}
+	if req.ParentID != nil && *req.ParentID != "" {
+		if s.validateCategoryRef(ctx, verr, "parent_id", *req.ParentID) {
+			cycle, err := s.repo.IsCategoryDescendant(ctx, categoryID, *req.ParentID)
+			if err != nil {
+				return nil, err
+			}
+			if cycle {
+				verr.add("parent_id", "a category cannot be moved below itself")
+			}
+		}
+	}
+	if err := verr.err(); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.UpdateCategory(ctx, categoryID, req); err != nil {
+		return nil, fmt.Errorf("update category: %w", err)
+	}
+
+	return s.repo.GetCategory(ctx, categoryID)
+}
+
+// DeleteCategory removes an empty category
+func (s *Service) DeleteCategory(ctx context.Context, categoryID string) (err error) {
+	ctx, span := startSpan(ctx, "DeleteCategory", attribute.String("category.id", categoryID))
+	defer func() { tracing.End(span, err) }()
+
+	if err := validateID("category", categoryID); err != nil {
+		return err
+	}
+
+	if err := s.repo.DeleteCategory(ctx, categoryID); err != nil {
+		if errors.Is(err, ErrConflict) {
+			return fmt.Errorf("category %s still has subcategories or markets: %w", categoryID, err)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// MarketFacets counts markets matching filter per category and tag. Category totals
+// include markets in descendant categories.
+func (s *Service) MarketFacets(ctx context.Context, filter models.MarketFilter) (_ *models.MarketFacets, err error) {
+	ctx, span := startSpan(ctx, "MarketFacets")
+	defer func() { tracing.End(span, err) }()
+
+	if err := s.validateMarketFilter(ctx, &filter); err != nil {
+		return nil, err
+	}
+
+	facets, err := s.repo.MarketFacets(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	children := map[string][]int{}
+	for i, c := range facets.Categories {
+		if c.ParentID != nil {
+			children[*c.ParentID] = append(children[*c.ParentID], i)
+		}
+	}
+	var total func(i int) int
+	total = func(i int) int {
+		sum := facets.Categories[i].Count
+		for _, child := range children[facets.Categories[i].CategoryID] {
+			sum += total(child)
+		}
+		facets.Categories[i].Total = sum
+		return sum
+	}
+	for i, c := range facets.Categories {
+		if c.ParentID == nil {
+			total(i)
+		}
+	}
+
+	return facets, nil
+}
+
+// validateCategoryRef records a field error unless id names an existing category
+func (s *Service) validateCategoryRef(ctx context.Context, verr *ValidationError, field, id string) bool {
+	if _, err := uuid.Parse(id); err != nil {
+		verr.add(field, "must be a valid UUID")
+		return false
+	}
+	if _, err := s.repo.GetCategory(ctx, id); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			verr.add(field, "category does not exist")
+		} else {
+			verr.add(field, "category could not be checked")
+			s.logger.WarnContext(ctx, "failed to look up category", "category_id", id, "error", err)
+		}
+		return false
+	}
+	return true
+}
+
+func validateCategoryName(verr *ValidationError, name string) {
+	switch {
+	case strings.TrimSpace(name) == "":
+		verr.add("name", "name is required")
+	case len(name) > maxCategoryNameLength:
+		verr.add("name", fmt.Sprintf("name must be at most %d characters", maxCategoryNameLength))
+	}
+}
+
+func validateSlug(verr *ValidationError, slug string) {
+	switch {
+	case slug == "":
+		verr.add("slug", "slug is required")
+	case len(slug) > maxCategoryNameLength || !slugPattern.MatchString(slug):
+		verr.add("slug", "slug must be lowercase letters, digits and single dashes")
+	}
+}
+
+// normalizeTags lowercases, trims and de-duplicates tags, recording invalid ones
+func normalizeTags(verr *ValidationError, tags []string) []string {
+	if len(tags) > maxTagsPerMarket {
+		verr.add("tags", fmt.Sprintf("at most %d tags are allowed", maxTagsPerMarket))
+	}
+
+	normalized := make([]string, 0, len(tags))
+	seen := map[string]bool{}
+	for i, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		switch {
+		case tag == "":
+			verr.add(fmt.Sprintf("tags[%d]", i), "tag must not be empty")
+			continue
+		case len(tag) > maxTagLength:
+			verr.add(fmt.Sprintf("tags[%d]", i), fmt.Sprintf("tag must be at most %d characters", maxTagLength))
+			continue
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			normalized = append(normalized, tag)
+		}
+	}
+	return normalized
+}
@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ec332/aegis/market/internal/repository"
+	"github.com/ec332/aegis/market/internal/tracing"
+	"github.com/ec332/aegis/market/pkg/models"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// isFinal reports whether a market in status can no longer change
+func isFinal(status models.MarketStatus) bool {
+	return status == models.MarketStatusResolved || status == models.MarketStatusVoided
+}
+
+// validateConditionRef checks that a conditional market points at an option of an
+// existing categorical market whose outcome does not already rule the condition out
+func (s *Service) validateConditionRef(ctx context.Context, verr *ValidationError, parentID, optionID *string) {
+	if parentID == nil && optionID == nil {
+		return
+	}
+	if parentID == nil || optionID == nil {
+		verr.add("parent_market_id", "parent_market_id and condition_option_id must be set together")
+		return
+	}
+	if _, err := uuid.Parse(*parentID); err != nil {
+		verr.add("parent_market_id", "must be a valid UUID")
+		return
+	}
+
+	parent, err := s.repo.GetMarket(ctx, *parentID)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			verr.add("parent_market_id", "market does not exist")
+		} else {
+			verr.add("parent_market_id", "market could not be checked")
+			s.logger.WarnContext(ctx, "failed to look up parent market", "market_id", *parentID, "error", err)
+		}
+		return
+	}
+
+	switch {
+	case parent.Type != models.MarketTypeCategorical:
+		verr.add("parent_market_id", "only categorical markets can be conditioned on")
+	case !hasOption(parent, *optionID):
+		verr.add("condition_option_id", "not an option of the parent market")
+	case parent.Status == models.MarketStatusVoided:
+		verr.add("parent_market_id", "parent market is voided")
+	case parent.Status == models.MarketStatusResolved &&
+		(parent.WinningOptionID == nil || *parent.WinningOptionID != *optionID):
+		verr.add("condition_option_id", "parent market already resolved to another option")
+	}
+}
+
+// checkCondition rejects activating a conditional market before its parent has
+// resolved to the condition option
+func (s *Service) checkCondition(ctx context.Context, market *models.Market, to models.MarketStatus) error {
+	if market.ParentMarketID == nil || to != models.MarketStatusActive {
+		return nil
+	}
+
+	parent, err := s.repo.GetMarket(ctx, *market.ParentMarketID)
+	if err != nil {
+		return fmt.Errorf("get parent market: %w", err)
+	}
+	if parent.Status != models.MarketStatusResolved || parent.WinningOptionID == nil ||
+		*parent.WinningOptionID != *market.ConditionOptionID {
+		return fmt.Errorf("%w: market is conditional on market %s resolving to option %s",
+			ErrInvalidTransition, parent.ID, *market.ConditionOptionID)
+	}
+	return nil
+}
+
+// settleDependents drives markets conditional on parent once it is final: when the
+// condition is met approved and funded drafts go active, otherwise they are voided
+// and refunded.
+// Voiding cascades to markets conditional on the voided ones.
+func (s *Service) settleDependents(ctx context.Context, parent *models.Market) (err error) {
+	ctx, span := startSpan(ctx, "settleDependents", attribute.String("market.id", parent.ID))
+	defer func() { tracing.End(span, err) }()
+
+	children, err := s.repo.ListMarkets(ctx, models.MarketFilter{ParentMarketID: &parent.ID})
+	if err != nil {
+		return fmt.Errorf("list conditional markets: %w", err)
+	}
+
+	var errs []error
+	for i := range children {
+		child := &children[i]
+		if isFinal(child.Status) {
+			continue
+		}
+
+		met := parent.Status == models.MarketStatusResolved && parent.WinningOptionID != nil &&
+			*parent.WinningOptionID == *child.ConditionOptionID
+
+		var to models.MarketStatus
+		switch {
+		case !met:
+			to = models.MarketStatusVoided
+		case child.Status == models.MarketStatusDraft && s.checkReview(child, models.MarketStatusActive) == nil:
+			if _, err := s.checkFunding(ctx, child, models.MarketStatusActive, nil); err != nil {
+				if !errors.Is(err, ErrInvalidTransition) {
+					errs = append(errs, fmt.Errorf("market %s: %w", child.ID, err))
+				}
+				continue
+			}
+			to = models.MarketStatusActive
+		default:
+			continue
+		}
+
+		if err := s.transitionDependent(ctx, child, to); err != nil {
+			errs = append(errs, fmt.Errorf("market %s: %w", child.ID, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// transitionDependent moves a conditional market to status to, recording refund
+// payouts when it is voided
+func (s *Service) transitionDependent(ctx context.Context, market *models.Market, to models.MarketStatus) error {
+	if err := s.validateStatusTransition(market.Status, to); err != nil {
+		return err
+	}
+
+	req := models.UpdateMarketRequest{Status: &to}
+	payouts, err := s.resolutionPayouts(market, req)
+	if err != nil {
+		return err
+	}
+	update := repository.MarketUpdate{UpdateMarketRequest: req, Payouts: payouts}
+	if to == models.MarketStatusVoided {
+		if update.Settlement, err = s.cancelSettlement(ctx, market); err != nil {
+			return err
+		}
+	}
+	if err := s.repo.UpdateMarket(ctx, market.ID, update); err != nil {
+		return fmt.Errorf("update market: %w", err)
+	}
+
+	s.logger.InfoContext(ctx, "conditional market transitioned",
+		"market_id", market.ID,
+		"parent_market_id", *market.ParentMarketID,
+		"from", market.Status,
+		"to", to,
+	)
+	if err := s.publishLiquidityUpdate(ctx, market.ID, market.LiquidityPools); err != nil {
+		s.logger.WarnContext(ctx, "failed to publish market update", "market_id", market.ID, "error", err)
+	}
+
+	if to == models.MarketStatusVoided {
+		market.Status = to
+		return s.settleDependents(ctx, market)
+	}
+	return nil
+}
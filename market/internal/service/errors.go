@@ -0,0 +1,70 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"github.com/ec332/aegis/market/internal/repository"
+	"github.com/ec332/aegis/market/pkg/models"
+)
+
+var (
+	// ErrNotFound is returned when a market or related record does not exist
+	ErrNotFound = repository.ErrNotFound
+	// ErrConflict is returned when a change conflicts with existing data
+	ErrConflict = repository.ErrConflict
+	// ErrUnavailable is returned when a backing store cannot be reached
+	ErrUnavailable = repository.ErrUnavailable
+	// ErrValidation is matched by every *ValidationError
+	ErrValidation = errors.New("validation failed")
+	// ErrInvalidTransition is returned when a market cannot move to the requested status
+	ErrInvalidTransition = errors.New("invalid status transition")
+	// ErrNotEditable is returned when a change is not allowed in the market's current status
+	ErrNotEditable = errors.New("market not editable")
+	// ErrUnauthenticated is returned when an action needs a user and none was given
+	ErrUnauthenticated = errors.New("authentication required")
+	// ErrForbidden is returned when the user may not perform an action
+	ErrForbidden = errors.New("forbidden")
+	// ErrMarketClosed is returned when trading on a market that is not active
+	ErrMarketClosed = errors.New("market not open for trading")
+	// ErrInsufficientShares is returned when selling or redeeming more shares than the user holds
+	ErrInsufficientShares = repository.ErrInsufficientShares
+	// ErrOpenOrderLimit is returned when a resting buy would take the value of a user's open buy orders past the cap
+	ErrOpenOrderLimit = errors.New("open buy order limit exceeded")
+	// ErrPositionLimit is returned when a buy, mint or liquidity send-back would take a user's position past the market's cap
+	ErrPositionLimit = errors.New("position limit exceeded")
+	// ErrTradeSizeLimit is returned when an order is larger than the market allows
+	ErrTradeSizeLimit = errors.New("trade size limit exceeded")
+	// ErrExposureLimit is returned when a trade or subsidy would raise the treasury's exposure past the market's cap
+	ErrExposureLimit = errors.New("treasury exposure limit exceeded")
+)
+
+// ValidationError lists the request fields that failed validation
+type ValidationError struct {
+	Fields []models.FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		msgs[i] = f.Field + ": " + f.Message
+	}
+	return "validation failed: " + strings.Join(msgs, "; ")
+}
+
+// Is reports ErrValidation as a match so callers can use errors.Is
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrValidation
+}
+
+// add records a failed field
+func (e *ValidationError) add(field, message string) {
+	e.Fields = append(e.Fields, models.FieldError{Field: field, Message: message})
+}
+
+// err returns e if any field failed, nil otherwise
+func (e *ValidationError) err() error {
+	if len(e.Fields) == 0 {
+		return nil
+	}
+	return e
+}
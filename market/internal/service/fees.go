@@ -0,0 +1,137 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+	"github.com/ec332/aegis/market/internal/middleware"
+	"github.com/ec332/aegis/market/internal/repository"
+	"github.com/ec332/aegis/market/internal/tracing"
+	"github.com/ec332/aegis/market/pkg/models"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// feeSchedule is what a market charges takers and who receives it
+type feeSchedule struct {
+	rate    float64
+	min     float64
+	creator string
+	split   FeeSplit
+}
+
+func (s *Service) feeSchedule(market *models.Market) feeSchedule {
+	return feeSchedule{rate: market.FeeRate, min: market.MinFee, creator: market.CreatedBy, split: s.cfg.FeeSplit}
+}
+
+// charge sets the fee of trade, its rate of the trade's value, and records the
+// taker paying it to the recipients
+func (f feeSchedule) charge(exec *repository.OrderExecution, trade *models.Trade, now time.Time) {
+	f.add(exec, trade, trade.Shares*trade.Price*f.rate, now)
+}
+
+// chargeMinimum tops the fees of the order's trades up to the minimum, charging
+// the difference on its last trade, so the minimum applies once per order. The
+// fees never come to more than the trades' value, so a small sell cannot owe more
+// than it receives.
+func (f feeSchedule) chargeMinimum(exec *repository.OrderExecution, now time.Time) {
+	if len(exec.Trades) == 0 {
+		return
+	}
+	var total, value float64
+	for _, t := range exec.Trades {
+		total += t.Fee
+		value += t.Shares * t.Price
+	}
+	f.add(exec, &exec.Trades[len(exec.Trades)-1], math.Min(f.min, value)-total, now)
+}
+
+// add charges fee on trade, recording the taker paying it to the recipients
+func (f feeSchedule) add(exec *repository.OrderExecution, trade *models.Trade, fee float64, now time.Time) {
+	if fee <= 0 {
+		return
+	}
+	trade.Fee += fee
+
+	creator := fee * f.split.Creator
+	if f.creator == "" {
+		creator = 0
+	}
+	lps := fee * f.split.LiquidityProviders
+	credits := []struct {
+		account string
+		amount  float64
+	}{
+		{models.LedgerAccountPlatform, fee - creator - lps},
+		{userAccount(f.creator), creator},
+		{lpFeesAccount(trade.MarketID), lps},
+	}
+
+	exec.Ledger = append(exec.Ledger, ledgerEntry(userAccount(trade.UserID), trade.MarketID, models.LedgerKindFee, -fee, trade.ID, now))
+	for _, c := range credits {
+		if c.amount > 0 {
+			exec.Ledger = append(exec.Ledger, ledgerEntry(c.account, trade.MarketID, models.LedgerKindFee, c.amount, trade.ID, now))
+		}
+	}
+}
+
+// GetMarketFees totals the fees collected on a market's trades by recipient
+func (s *Service) GetMarketFees(ctx context.Context, marketID string) (_ *models.FeeSummary, err error) {
+	ctx, span := startSpan(ctx, "GetMarketFees", attribute.String("market.id", marketID))
+	defer func() { tracing.End(span, err) }()
+
+	if err := validateID("market", marketID); err != nil {
+		return nil, err
+	}
+	market, err := s.repo.GetMarket(ctx, marketID)
+	if err != nil {
+		return nil, err
+	}
+
+	credits, trades, err := s.repo.FeeCredits(ctx, marketID)
+	if err != nil {
+		return nil, err
+	}
+	summary := &models.FeeSummary{
+		MarketID: marketID,
+		FeeRate:  market.FeeRate,
+		MinFee:   market.MinFee,
+		Trades:   trades,
+	}
+	for account, amount := range credits {
+		summary.Total += amount
+		switch account {
+		case models.LedgerAccountPlatform:
+			summary.Platform += amount
+		case lpFeesAccount(marketID):
+			summary.LiquidityProviders += amount
+		case userAccount(market.CreatedBy):
+			summary.Creator += amount
+		}
+	}
+	return summary, nil
+}
+
+// checkFeeOverride only lets admins set a market's fees
+func checkFeeOverride(ctx context.Context, feeRate, minFee *float64) error {
+	if feeRate == nil && minFee == nil {
+		return nil
+	}
+	if !middleware.PrincipalFromContext(ctx).HasRole(middleware.RoleAdmin) {
+		return fmt.Errorf("%w: only admins can set trading fees", ErrForbidden)
+	}
+	return nil
+}
+
+func validateFees(verr *ValidationError, feeRate, minFee *float64) {
+	if feeRate != nil && (*feeRate < 0 || *feeRate > models.MaxFeeRate || math.IsNaN(*feeRate)) {
+		verr.add("fee_rate", fmt.Sprintf("must be between 0 and %g", models.MaxFeeRate))
+	}
+	if minFee != nil && (*minFee < 0 || math.IsNaN(*minFee) || math.IsInf(*minFee, 0)) {
+		verr.add("min_fee", "must not be negative")
+	}
+}
+
+func lpFeesAccount(marketID string) string {
+	return models.LedgerAccountLPFeesPrefix + marketID
+}
@@ -0,0 +1,177 @@
+package service
+
+import (
+	"math"
+	"testing"
+	"github.com/ec332/aegis/market/internal/repository"
+	"github.com/ec332/aegis/market/pkg/models"
+)
+
+func feeTrade(id string, side models.OrderSide, shares, price float64) models.Trade {
+	return models.Trade{ID: id, MarketID: "market-1", OptionID: "opt-yes", UserID: "alice", Side: side, Shares: shares, Price: price}
+}
+
+// feeCredits sums the fee ledger entries by account, checking they balance
+func feeCredits(t *testing.T, exec *repository.OrderExecution) map[string]float64 {
+	t.Helper()
+	credits := map[string]float64{}
+	var sum float64
+	for _, e := range exec.Ledger {
+		if e.Kind != models.LedgerKindFee {
+			continue
+		}
+		credits[e.Account] += e.Amount
+		sum += e.Amount
+	}
+	if math.Abs(sum) > 1e-12 {
+		t.Errorf("fee entries sum to %g, want 0", sum)
+	}
+	return credits
+}
+
+func TestFeeCharge(t *testing.T) {
+	split := FeeSplit{Creator: 0.2, LiquidityProviders: 0.3}
+	tests := []struct {
+		name    string
+		fees    feeSchedule
+		credits map[string]float64
+	}{
+		{
+			name: "split three ways",
+			fees: feeSchedule{rate: 0.02, creator: "carol", split: split},
+			credits: map[string]float64{
+				userAccount("alice"):         -0.1,
+				models.LedgerAccountPlatform: 0.05,
+				userAccount("carol"):         0.02,
+				lpFeesAccount("market-1"):    0.03,
+			},
+		},
+		{
+			name: "platform keeps the share of a market without a creator",
+			fees: feeSchedule{rate: 0.02, split: split},
+			credits: map[string]float64{
+				userAccount("alice"):         -0.1,
+				models.LedgerAccountPlatform: 0.07,
+				lpFeesAccount("market-1"):    0.03,
+			},
+		},
+		{
+			name:    "no fee",
+			fees:    feeSchedule{split: split},
+			credits: map[string]float64{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exec := &repository.OrderExecution{}
+			trade := feeTrade("t1", models.OrderSideBuy, 10, 0.5)
+			tt.fees.charge(exec, &trade, matchTime)
+
+			var want float64
+			for account, amount := range tt.credits {
+				if account != userAccount("alice") {
+					want += amount
+				}
+			}
+			if math.Abs(trade.Fee-want) > 1e-12 {
+				t.Errorf("fee = %g, want %g", trade.Fee, want)
+			}
+			credits := feeCredits(t, exec)
+			if len(credits) != len(tt.credits) {
+				t.Errorf("fee credits = %v, want %v", credits, tt.credits)
+			}
+			for account, amount := range tt.credits {
+				if math.Abs(credits[account]-amount) > 1e-12 {
+					t.Errorf("%s gets %g, want %g", account, credits[account], amount)
+				}
+			}
+		})
+	}
+}
+
+func TestChargeMinimum(t *testing.T) {
+	tests := []struct {
+		name   string
+		rate   float64
+		min    float64
+		trades []models.Trade
+		total  float64
+		last   float64
+	}{
+		{
+			name:   "topped up on the last trade",
+			rate:   0.01,
+			min:    0.5,
+			trades: []models.Trade{feeTrade("t1", models.OrderSideBuy, 10, 0.5), feeTrade("t2", models.OrderSideBuy, 10, 0.6)},
+			total:  0.5,
+			last:   0.06 + 0.39,
+		},
+		{
+			name:   "rate above the minimum",
+			rate:   0.02,
+			min:    0.1,
+			trades: []models.Trade{feeTrade("t1", models.OrderSideBuy, 100, 0.5)},
+			total:  1,
+			last:   1,
+		},
+		{
+			name:   "capped at the value of a small sell",
+			min:    1,
+			trades: []models.Trade{feeTrade("t1", models.OrderSideSell, 2, 0.03)},
+			total:  0.06,
+			last:   0.06,
+		},
+		{
+			name:   "capped after the rate",
+			rate:   0.1,
+			min:    1,
+			trades: []models.Trade{feeTrade("t1", models.OrderSideSell, 1, 0.2), feeTrade("t2", models.OrderSideSell, 1, 0.1)},
+			total:  0.3,
+			last:   0.01 + 0.27,
+		},
+		{
+			name: "no trades",
+			min:  1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fees := feeSchedule{rate: tt.rate, min: tt.min, split: FeeSplit{Creator: 0.2, LiquidityProviders: 0.3}}
+			exec := &repository.OrderExecution{Trades: tt.trades}
+			for i := range exec.Trades {
+				fees.charge(exec, &exec.Trades[i], matchTime)
+			}
+			fees.chargeMinimum(exec, matchTime)
+
+			var total float64
+			for _, trade := range exec.Trades {
+				total += trade.Fee
+			}
+			if math.Abs(total-tt.total) > 1e-12 {
+				t.Errorf("fees come to %g, want %g", total, tt.total)
+			}
+			if n := len(exec.Trades); n > 0 && math.Abs(exec.Trades[n-1].Fee-tt.last) > 1e-12 {
+				t.Errorf("last trade's fee = %g, want %g", exec.Trades[n-1].Fee, tt.last)
+			}
+			if credits := feeCredits(t, exec); math.Abs(credits[userAccount("alice")]+tt.total) > 1e-12 {
+				t.Errorf("taker pays %g in fees, want %g", -credits[userAccount("alice")], tt.total)
+			}
+		})
+	}
+}
+
+func TestSmallSellKeepsProceeds(t *testing.T) {
+	order := newOrder("taker", "alice", models.OrderSideSell, floatPtr(0.05), 1)
+	state := &repository.BookState{Resting: []models.Order{newOrder("bid", "bob", models.OrderSideBuy, floatPtr(0.05), 1)}}
+	exec := matchOrder(&order, state, feeSchedule{rate: 0.02, min: 1}, matchTime)
+
+	var proceeds float64
+	for _, e := range exec.Ledger {
+		if e.Account == userAccount("alice") {
+			proceeds += e.Amount
+		}
+	}
+	if proceeds < 0 {
+		t.Errorf("seller's proceeds = %g, want at least 0", proceeds)
+	}
+}
@@ -0,0 +1,273 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+	"github.com/ec332/aegis/market/internal/metrics"
+	"github.com/ec332/aegis/market/internal/middleware"
+	"github.com/ec332/aegis/market/internal/pricing"
+	"github.com/ec332/aegis/market/internal/repository"
+	"github.com/ec332/aegis/market/internal/tracing"
+	"github.com/ec332/aegis/market/pkg/models"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const maxHaltReasonLength = 1000
+
+// priceHistoryPruneBatch bounds the price ticks deleted per statement
+const priceHistoryPruneBatch = 10000
+
+// HaltMarket pauses trading on an active market, which stays listed (admins only).
+// Trading resumes at req.ResumeAt when it is set, otherwise when an admin resumes it.
+func (s *Service) HaltMarket(ctx context.Context, marketID string, req models.HaltMarketRequest) (_ *models.MarketHalt, err error) {
+	ctx, span := startSpan(ctx, "HaltMarket", attribute.String("market.id", marketID))
+	defer func() { tracing.End(span, err) }()
+
+	p, err := requireRole(ctx, middleware.RoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateID("market", marketID); err != nil {
+		return nil, err
+	}
+	verr := &ValidationError{}
+	validateText(verr, "reason", req.Reason, maxHaltReasonLength, true)
+	if req.ResumeAt != nil && !req.ResumeAt.After(time.Now()) {
+		verr.add("resume_at", "must be in the future")
+	}
+	if err := verr.err(); err != nil {
+		return nil, err
+	}
+
+	market, err := s.repo.GetMarket(ctx, marketID)
+	if err != nil {
+		return nil, err
+	}
+	if market.Status != models.MarketStatusActive {
+		return nil, fmt.Errorf("%w: only active markets can be halted, market is %s", ErrInvalidTransition, market.Status)
+	}
+
+	return s.haltMarket(ctx, marketID, models.HaltCauseManual, strings.TrimSpace(req.Reason), p.UserID, req.ResumeAt)
+}
+
+// ResumeMarket reopens trading on a halted market (admins only)
+func (s *Service) ResumeMarket(ctx context.Context, marketID string) (_ *models.Market, err error) {
+	ctx, span := startSpan(ctx, "ResumeMarket", attribute.String("market.id", marketID))
+	defer func() { tracing.End(span, err) }()
+
+	p, err := requireRole(ctx, middleware.RoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateID("market", marketID); err != nil {
+		return nil, err
+	}
+	market, err := s.repo.GetMarket(ctx, marketID)
+	if err != nil {
+		return nil, err
+	}
+	if market.Status != models.MarketStatusHalted {
+		return nil, fmt.Errorf("%w: market is %s, not halted", ErrInvalidTransition, market.Status)
+	}
+
+	if _, err := s.resumeMarket(ctx, marketID, p.UserID); err != nil {
+		return nil, err
+	}
+	return s.GetMarket(ctx, marketID)
+}
+
+// ListMarketHalts retrieves a market's trading halts, newest first
+func (s *Service) ListMarketHalts(ctx context.Context, marketID string) (_ []models.MarketHalt, err error) {
+	ctx, span := startSpan(ctx, "ListMarketHalts", attribute.String("market.id", marketID))
+	defer func() { tracing.End(span, err) }()
+
+	if err := validateID("market", marketID); err != nil {
+		return nil, err
+	}
+	if _, err := s.repo.GetMarket(ctx, marketID); err != nil {
+		return nil, err
+	}
+
+	return s.repo.ListMarketHalts(ctx, marketID)
+}
+
+// ResumeDueHalts reopens trading on halted markets whose resume time has passed and
+// returns how many were resumed. Markets resumed meanwhile, e.g. by another replica
+// or an admin, are left alone.
+func (s *Service) ResumeDueHalts(ctx context.Context) (_ int, err error) {
+	ctx, span := startSpan(ctx, "ResumeDueHalts")
+	defer func() { tracing.End(span, err) }()
+
+	halts, err := s.repo.ListDueHalts(ctx, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("list due halts: %w", err)
+	}
+
+	resumed := 0
+	var errs []error
+	for _, halt := range halts {
+		_, err := s.resumeMarket(ctx, halt.MarketID, "")
+		if errors.Is(err, ErrConflict) {
+			s.logger.DebugContext(ctx, "market resumed before its halt was due", "market_id", halt.MarketID, "error", err)
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("market %s: %w", halt.MarketID, err))
+			continue
+		}
+		resumed++
+	}
+
+	return resumed, errors.Join(errs...)
+}
+
+// PrunePriceHistory deletes the price ticks older than the circuit breaker window,
+// the only one they are read over, and returns how many were deleted
+func (s *Service) PrunePriceHistory(ctx context.Context) (_ int, err error) {
+	ctx, span := startSpan(ctx, "PrunePriceHistory")
+	defer func() { tracing.End(span, err) }()
+
+	before := time.Now().Add(-s.cfg.CircuitBreaker.Window)
+	pruned := 0
+	for {
+		n, err := s.repo.PrunePriceHistory(ctx, before, priceHistoryPruneBatch)
+		if err != nil {
+			return pruned, err
+		}
+		pruned += int(n)
+		if n < priceHistoryPruneBatch {
+			return pruned, nil
+		}
+	}
+}
+
+// checkCircuitBreaker halts market for the cooldown when a trade that moved its
+// pools from before to after leaves an option's price range within the window
+// wider than the breaker allows. Failures are logged; the trade already stands.
+func (s *Service) checkCircuitBreaker(ctx context.Context, market *models.Market, before, after []models.LiquidityPool) {
+	rules := s.cfg.CircuitBreaker
+	if rules.PriceMove <= 0 {
+		return
+	}
+
+	now := time.Now()
+	ranges, err := s.repo.PriceRanges(ctx, market.ID, now.Add(-rules.Window))
+	if err != nil {
+		s.logger.WarnContext(ctx, "failed to check circuit breaker", "market_id", market.ID, "error", err)
+		return
+	}
+
+	optionID, low, high, tripped := breakerTripped(rules.PriceMove, ranges, before, after)
+	if !tripped {
+		return
+	}
+
+	reason := fmt.Sprintf("price of option %s moved between %.4f and %.4f within %s", optionID, low, high, rules.Window)
+	resumeAt := now.Add(rules.Cooldown)
+	_, err = s.haltMarket(ctx, market.ID, models.HaltCauseCircuitBreaker, reason, "", &resumeAt)
+	if err != nil && !errors.Is(err, ErrConflict) {
+		s.logger.ErrorContext(ctx, "failed to halt market", "market_id", market.ID, "error", err)
+	}
+}
+
+// breakerTripped reports the first option whose price range, over the earlier
+// ranges and the move from before to after, is wider than priceMove
+func breakerTripped(priceMove float64, ranges map[string]repository.PriceRange, before, after []models.LiquidityPool) (optionID string, low, high float64, tripped bool) {
+	prior := pricing.OptionPrices(before)
+	for i, price := range pricing.Prices(after) {
+		optionID := after[i].OptionID
+		low, high := math.Min(prior[optionID], price), math.Max(prior[optionID], price)
+		if r, ok := ranges[optionID]; ok {
+			low, high = math.Min(low, r.Low), math.Max(high, r.High)
+		}
+		if high-low > priceMove {
+			return optionID, low, high, true
+		}
+	}
+	return "", 0, 0, false
+}
+
+// haltMarket halts trading on an active market and announces it to stream subscribers
+func (s *Service) haltMarket(ctx context.Context, marketID string, cause models.HaltCause, reason, haltedBy string, resumeAt *time.Time) (*models.MarketHalt, error) {
+	halt := &models.MarketHalt{
+		ID:       uuid.New().String(),
+		MarketID: marketID,
+		Cause:    cause,
+		Reason:   reason,
+		HaltedBy: haltedBy,
+		HaltedAt: time.Now(),
+		ResumeAt: resumeAt,
+	}
+	if err := s.repo.HaltMarket(ctx, halt); err != nil {
+		return nil, fmt.Errorf("halt market: %w", err)
+	}
+	metrics.TradingHalts.WithLabelValues(string(cause)).Inc()
+
+	s.logger.InfoContext(ctx, "trading halted",
+		"market_id", marketID,
+		"halt_id", halt.ID,
+		"cause", cause,
+		"reason", reason,
+		"resume_at", resumeAt,
+	)
+	if err := s.publishTradingUpdate(ctx, true, halt); err != nil {
+		s.logger.WarnContext(ctx, "failed to publish trading halt", "market_id", marketID, "error", err)
+	}
+	return halt, nil
+}
+
+// resumeMarket reopens trading on a halted market and announces it to stream subscribers
+func (s *Service) resumeMarket(ctx context.Context, marketID, resumedBy string) (*models.MarketHalt, error) {
+	halt, err := s.repo.ResumeMarket(ctx, marketID, resumedBy, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("resume market: %w", err)
+	}
+
+	s.logger.InfoContext(ctx, "trading resumed", "market_id", marketID, "halt_id", halt.ID, "resumed_by", resumedBy)
+	if err := s.publishTradingUpdate(ctx, false, halt); err != nil {
+		s.logger.WarnContext(ctx, "failed to publish trading resumption", "market_id", marketID, "error", err)
+	}
+	return halt, nil
+}
+
+func (s *Service) publishTradingUpdate(ctx context.Context, halted bool, halt *models.MarketHalt) (err error) {
+	ctx, span := startSpan(ctx, "publishTradingUpdate", attribute.String("market.id", halt.MarketID))
+	defer func() { tracing.End(span, err) }()
+
+	update := models.TradingUpdate{
+		MarketID:     halt.MarketID,
+		Halted:       halted,
+		Halt:         *halt,
+		Timestamp:    time.Now(),
+		TraceContext: tracing.Inject(ctx),
+	}
+	data, err := json.Marshal(update)
+	if err != nil {
+		return fmt.Errorf("marshal trading update: %w", err)
+	}
+	if err := s.redisClient.Publish(ctx, tradingChannel(halt.MarketID), data).Err(); err != nil {
+		metrics.RedisPublishFailures.WithLabelValues("trading").Inc()
+		return fmt.Errorf("publish to redis: %w", err)
+	}
+	return nil
+}
+
+// priceTicks records the option prices pools are left at
+func priceTicks(pools []models.LiquidityPool, now time.Time) []repository.PriceTick {
+	prices := pricing.Prices(pools)
+	ticks := make([]repository.PriceTick, len(pools))
+	for i, pool := range pools {
+		ticks[i] = repository.PriceTick{MarketID: pool.MarketID, OptionID: pool.OptionID, Price: prices[i], RecordedAt: now}
+	}
+	return ticks
+}
+
+func tradingChannel(marketID string) string {
+	return fmt.Sprintf("market:%s:trading", marketID)
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"math"
+	"testing"
+	"github.com/ec332/aegis/market/internal/repository"
+	"github.com/ec332/aegis/market/pkg/models"
+)
+
+func TestBreakerTripped(t *testing.T) {
+	// Reserves of 100/100 price both options at 0.5; 90/110 prices yes at 0.55
+	// and 60/140 at 0.7
+	tests := []struct {
+		name      string
+		priceMove float64
+		ranges    map[string]repository.PriceRange
+		after     []models.LiquidityPool
+		option    string
+		low       float64
+		high      float64
+	}{
+		{
+			name:      "small move",
+			priceMove: 0.1,
+			after:     binaryPools(90, 110),
+		},
+		{
+			name:      "large move within the threshold",
+			priceMove: 0.25,
+			after:     binaryPools(60, 140),
+		},
+		{
+			name:      "move past the threshold",
+			priceMove: 0.1,
+			after:     binaryPools(60, 140),
+			option:    "opt-yes",
+			low:       0.5,
+			high:      0.7,
+		},
+		{
+			name:      "earlier prices widen the range",
+			priceMove: 0.1,
+			ranges:    map[string]repository.PriceRange{"opt-yes": {Low: 0.42, High: 0.5}},
+			after:     binaryPools(90, 110),
+			option:    "opt-yes",
+			low:       0.42,
+			high:      0.55,
+		},
+		{
+			name:      "earlier prices within the range",
+			priceMove: 0.1,
+			ranges:    map[string]repository.PriceRange{"opt-yes": {Low: 0.48, High: 0.52}, "opt-no": {Low: 0.45, High: 0.5}},
+			after:     binaryPools(90, 110),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			option, low, high, tripped := breakerTripped(tt.priceMove, tt.ranges, binaryPools(100, 100), tt.after)
+			if tripped != (tt.option != "") || option != tt.option {
+				t.Fatalf("breakerTripped = %q, %v, want %q", option, tripped, tt.option)
+			}
+			if math.Abs(low-tt.low) > 1e-9 || math.Abs(high-tt.high) > 1e-9 {
+				t.Errorf("range = %g to %g, want %g to %g", low, high, tt.low, tt.high)
+			}
+		})
+	}
+}
@@ -0,0 +1,345 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"github.com/ec332/aegis/market/internal/health"
+	"github.com/ec332/aegis/market/internal/metrics"
+	"github.com/ec332/aegis/market/internal/repository"
+	"github.com/ec332/aegis/market/internal/tracing"
+	"github.com/ec332/aegis/market/pkg/models"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// maxEventIDLength matches the processed_events column
+const maxEventIDLength = 255
+
+// Ingest names the Redis Stream other services push trade events to and how the
+// service reads it as a member of a consumer group
+type Ingest struct {
+	Stream   string
+	Group    string
+	Consumer string
+	// DeadLetterStream receives events that cannot be applied
+	DeadLetterStream string
+	// BatchSize bounds the entries read at once; a read waits up to Block for new ones
+	BatchSize int
+	Block     time.Duration
+	// An event still failing after MaxDeliveries deliveries is dead-lettered
+	MaxDeliveries int
+	// Entries another consumer left unacknowledged for ClaimIdle are taken over
+	ClaimIdle time.Duration
+}
+
+// RunTradeIngester applies the trade events pushed to cfg.Stream until ctx is done.
+// Each entry is acknowledged once applied, so entries of a consumer that stops are
+// delivered again, and applied once thanks to their event ID. An entry that fails
+// is retried before later ones.
+func (s *Service) RunTradeIngester(ctx context.Context, cfg Ingest, hb *health.Heartbeat) {
+	grouped := false
+	for {
+		hb.Beat()
+		var err error
+		if !grouped {
+			err = s.createIngestGroup(ctx, cfg)
+			grouped = err == nil
+		}
+		if grouped {
+			err = s.ingestTrades(ctx, cfg, hb)
+			s.recordIngestLag(ctx, cfg)
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		if err == nil {
+			continue
+		}
+
+		// The group is gone when the stream was deleted
+		if noGroup(err) {
+			grouped = false
+		}
+		s.logger.WarnContext(ctx, "failed to ingest trade events", "stream", cfg.Stream, "error", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(cfg.Block):
+		}
+	}
+}
+
+// createIngestGroup creates the consumer group, and the stream with it, reading
+// from the start of the stream so events pushed before the first start are applied
+func (s *Service) createIngestGroup(ctx context.Context, cfg Ingest) error {
+	err := s.redisClient.XGroupCreateMkStream(ctx, cfg.Stream, cfg.Group, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return fmt.Errorf("create consumer group: %w", err)
+	}
+	return nil
+}
+
+// noGroup reports whether err, however wrapped, is Redis saying the stream or
+// consumer group does not exist
+func noGroup(err error) bool {
+	var rerr redis.Error
+	return errors.As(err, &rerr) && strings.HasPrefix(rerr.Error(), "NOGROUP")
+}
+
+// ingestTrades applies one batch: the entries delivered to this consumer but not
+// yet acknowledged when there are any, new entries otherwise
+func (s *Service) ingestTrades(ctx context.Context, cfg Ingest, hb *health.Heartbeat) error {
+	// Take over entries left by consumers that stopped
+	err := s.redisClient.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+		Stream:   cfg.Stream,
+		Group:    cfg.Group,
+		Consumer: cfg.Consumer,
+		MinIdle:  cfg.ClaimIdle,
+		Start:    "0-0",
+		Count:    int64(cfg.BatchSize),
+	}).Err()
+	if err != nil {
+		return fmt.Errorf("claim idle entries: %w", err)
+	}
+
+	messages, err := s.readIngestStream(ctx, cfg, "0", -1)
+	if err != nil {
+		return err
+	}
+	if len(messages) == 0 {
+		if messages, err = s.readIngestStream(ctx, cfg, ">", cfg.Block); err != nil {
+			return err
+		}
+	}
+
+	for _, msg := range messages {
+		hb.Beat()
+		if err := s.ingestTradeEvent(ctx, cfg, msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readIngestStream reads this consumer's entries after id, or new entries when id
+// is ">", waiting up to block for them; a negative block returns at once
+func (s *Service) readIngestStream(ctx context.Context, cfg Ingest, id string, block time.Duration) ([]redis.XMessage, error) {
+	args := &redis.XReadGroupArgs{
+		Group:    cfg.Group,
+		Consumer: cfg.Consumer,
+		Streams:  []string{cfg.Stream, id},
+		Count:    int64(cfg.BatchSize),
+		Block:    block,
+	}
+	streams, err := s.redisClient.XReadGroup(ctx, args).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read stream: %w", err)
+	}
+	if len(streams) == 0 {
+		return nil, nil
+	}
+	return streams[0].Messages, nil
+}
+
+// ingestTradeEvent applies one entry and acknowledges it. Entries that can never
+// apply, and entries still failing after cfg.MaxDeliveries deliveries while the
+// database is up, go to the dead-letter stream; other failures are returned,
+// leaving the entry pending.
+func (s *Service) ingestTradeEvent(ctx context.Context, cfg Ingest, msg redis.XMessage) (err error) {
+	fields := make(map[string]string, len(msg.Values))
+	for k, v := range msg.Values {
+		if str, ok := v.(string); ok {
+			fields[k] = str
+		}
+	}
+	ctx = tracing.Extract(ctx, fields)
+	ctx, span := startSpan(ctx, "ingestTradeEvent", attribute.String("stream.id", msg.ID))
+	defer func() { tracing.End(span, err) }()
+
+	var result *repository.TradeEventResult
+	event, err := parseTradeEvent(fields)
+	if err == nil {
+		span.SetAttributes(attribute.String("event.id", event.EventID), attribute.String("market.id", event.MarketID))
+		result, err = s.applyTradeEvent(ctx, event)
+	}
+
+	switch {
+	case err == nil:
+		if err := s.redisClient.XAck(ctx, cfg.Stream, cfg.Group, msg.ID).Err(); err != nil {
+			return fmt.Errorf("acknowledge entry %s: %w", msg.ID, err)
+		}
+		outcome := "duplicate"
+		switch {
+		case result.Applied:
+			outcome = "applied"
+		case result.Stale:
+			outcome = "stale"
+		}
+		metrics.IngestEvents.WithLabelValues(outcome).Inc()
+		if added, ok := streamEntryTime(msg.ID); ok {
+			metrics.IngestDelay.Observe(time.Since(added).Seconds())
+		}
+		return nil
+	case neverApplies(err):
+		return s.deadLetter(ctx, cfg, msg, err)
+	}
+
+	// An unreachable database fails every event alike, so they wait for it
+	if !errors.Is(err, ErrUnavailable) && ctx.Err() == nil {
+		pending, perr := s.redisClient.XPendingExt(ctx, &redis.XPendingExtArgs{
+			Stream: cfg.Stream,
+			Group:  cfg.Group,
+			Start:  msg.ID,
+			End:    msg.ID,
+			Count:  1,
+		}).Result()
+		if perr == nil && len(pending) == 1 && pending[0].RetryCount >= int64(cfg.MaxDeliveries) {
+			return s.deadLetter(ctx, cfg, msg, err)
+		}
+	}
+	metrics.IngestEvents.WithLabelValues("failed").Inc()
+	return fmt.Errorf("entry %s: %w", msg.ID, err)
+}
+
+// neverApplies reports whether an event failed for good: it is invalid, its market
+// or pool does not exist, or its market is resolved or voided
+func neverApplies(err error) bool {
+	return errors.Is(err, ErrValidation) || errors.Is(err, ErrNotFound) || errors.Is(err, ErrConflict)
+}
+
+// deadLetter moves an entry to the dead-letter stream with the reason it failed
+func (s *Service) deadLetter(ctx context.Context, cfg Ingest, msg redis.XMessage, cause error) error {
+	values := make(map[string]interface{}, len(msg.Values)+2)
+	for k, v := range msg.Values {
+		values[k] = v
+	}
+	values["source_id"] = msg.ID
+	values["error"] = cause.Error()
+
+	_, err := s.redisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.XAdd(ctx, &redis.XAddArgs{Stream: cfg.DeadLetterStream, Values: values})
+		pipe.XAck(ctx, cfg.Stream, cfg.Group, msg.ID)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("dead-letter entry %s: %w", msg.ID, err)
+	}
+	metrics.IngestEvents.WithLabelValues("dead_lettered").Inc()
+	s.logger.ErrorContext(ctx, "trade event dead-lettered", "stream_id", msg.ID, "dead_letter_stream", cfg.DeadLetterStream, "error", cause)
+	return nil
+}
+
+// recordIngestLag exposes how far the consumer group is behind the stream
+func (s *Service) recordIngestLag(ctx context.Context, cfg Ingest) {
+	groups, err := s.redisClient.XInfoGroups(ctx, cfg.Stream).Result()
+	if err != nil {
+		s.logger.DebugContext(ctx, "failed to read consumer group info", "stream", cfg.Stream, "error", err)
+		return
+	}
+	for _, g := range groups {
+		if g.Name != cfg.Group {
+			continue
+		}
+		// Redis reports -1 when it cannot tell, e.g. after entries were deleted
+		if g.Lag >= 0 {
+			metrics.IngestLag.Set(float64(g.Lag))
+		}
+		metrics.IngestPending.Set(float64(g.Pending))
+	}
+}
+
+// applyTradeEvent sets the event's pool value unless the event was applied before
+// or is stale, recording the prices it leaves, then publishes the market's pools
+// and checks the circuit breaker like an order filled against the pool
+func (s *Service) applyTradeEvent(ctx context.Context, event models.TradeEvent) (*repository.TradeEventResult, error) {
+	now := time.Now()
+	result, err := s.repo.ApplyTradeEvent(ctx, event, tradeEventStatus, func(pools []models.LiquidityPool) []repository.PriceTick {
+		return priceTicks(pools, now)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if result.Stale {
+		s.logger.DebugContext(ctx, "stale trade event ignored", "event_id", event.EventID, "pool_id", event.PoolID, "sequence", event.Sequence)
+	}
+	if !result.Applied {
+		return result, nil
+	}
+
+	if err := s.publishLiquidityUpdate(ctx, event.MarketID, result.Pools); err != nil {
+		s.logger.WarnContext(ctx, "failed to publish liquidity update", "market_id", event.MarketID, "error", err)
+	}
+	market, err := s.repo.GetMarket(ctx, event.MarketID)
+	if err != nil {
+		s.logger.WarnContext(ctx, "failed to check circuit breaker", "market_id", event.MarketID, "error", err)
+		return result, nil
+	}
+	s.checkCircuitBreaker(ctx, market, result.Before, result.Pools)
+	return result, nil
+}
+
+// tradeEventStatus lets events change the pools of active markets only. Events for
+// a market that is resolved or voided never apply (ErrConflict); other markets,
+// e.g. halted ones, may reopen, so their events are retried (ErrMarketClosed).
+func tradeEventStatus(status models.MarketStatus) error {
+	switch {
+	case status == models.MarketStatusActive:
+		return nil
+	case isFinal(status):
+		return fmt.Errorf("market is %s: %w", status, ErrConflict)
+	default:
+		return fmt.Errorf("market is %s: %w", status, ErrMarketClosed)
+	}
+}
+
+// parseTradeEvent reads a trade event from a stream entry's fields
+func parseTradeEvent(fields map[string]string) (models.TradeEvent, error) {
+	verr := &ValidationError{}
+	event := models.TradeEvent{
+		EventID:  fields["event_id"],
+		MarketID: fields["market_id"],
+		PoolID:   fields["pool_id"],
+	}
+	switch {
+	case strings.TrimSpace(event.EventID) == "":
+		verr.add("event_id", "event_id is required")
+	case len(event.EventID) > maxEventIDLength:
+		verr.add("event_id", fmt.Sprintf("event_id must be at most %d characters", maxEventIDLength))
+	}
+	if _, err := uuid.Parse(event.MarketID); err != nil {
+		verr.add("market_id", "market_id must be a UUID")
+	}
+	if _, err := uuid.Parse(event.PoolID); err != nil {
+		verr.add("pool_id", "pool_id must be a UUID")
+	}
+	value, err := strconv.ParseFloat(fields["pool_value"], 64)
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) || value < 0 {
+		verr.add("pool_value", "pool_value must be a number of at least 0")
+	}
+	event.PoolValue = value
+	sequence, err := strconv.ParseInt(fields["sequence"], 10, 64)
+	if err != nil || sequence < 1 {
+		verr.add("sequence", "sequence must be an integer of at least 1")
+	}
+	event.Sequence = sequence
+	return event, verr.err()
+}
+
+// streamEntryTime returns when an entry was added, from the milliseconds its ID starts with
+func streamEntryTime(id string) (time.Time, bool) {
+	ms, _, _ := strings.Cut(id, "-")
+	n, err := strconv.ParseInt(ms, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.UnixMilli(n), true
+}
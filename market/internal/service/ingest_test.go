@@ -0,0 +1,210 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"github.com/ec332/aegis/market/internal/health"
+	"github.com/ec332/aegis/market/pkg/models"
+	"github.com/redis/go-redis/v9"
+)
+
+// fakeStream is a Redis server whose consumer group is gone: reads fail with
+// NOGROUP while creating the group succeeds, and it counts the commands it gets
+type fakeStream struct {
+	mu       sync.Mutex
+	commands map[string]int
+}
+
+func (f *fakeStream) count(name string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.commands[name]
+}
+
+func (f *fakeStream) serve(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.handle(conn)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func (f *fakeStream) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		name := strings.ToUpper(args[0])
+		if name == "XGROUP" && len(args) > 1 {
+			name += " " + strings.ToUpper(args[1])
+		}
+		f.mu.Lock()
+		f.commands[name]++
+		f.mu.Unlock()
+
+		var reply string
+		switch name {
+		case "XGROUP CREATE":
+			reply = "+OK\r\n"
+		case "XAUTOCLAIM":
+			reply = "*3\r\n$3\r\n0-0\r\n*0\r\n*0\r\n"
+		case "XREADGROUP":
+			reply = "-NOGROUP No such key 'trades' or consumer group 'market' in XREADGROUP with GROUP option\r\n"
+		default:
+			reply = fmt.Sprintf("-ERR unknown command '%s'\r\n", args[0])
+		}
+		if _, err := io.WriteString(conn, reply); err != nil {
+			return
+		}
+	}
+}
+
+// readCommand reads a command sent as a RESP array of bulk strings
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil || n < 1 {
+		return nil, fmt.Errorf("bad command header %q", line)
+	}
+	args := make([]string, n)
+	for i := range args {
+		if line, err = r.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "$")))
+		if err != nil {
+			return nil, fmt.Errorf("bad argument header %q", line)
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+func TestRunTradeIngesterRecreatesGroup(t *testing.T) {
+	fake := &fakeStream{commands: map[string]int{}}
+	client := redis.NewClient(&redis.Options{Addr: fake.serve(t), Protocol: 2})
+	defer client.Close()
+	s := &Service{redisClient: client, logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	cfg := Ingest{Stream: "trades", Group: "market", Consumer: "c1", BatchSize: 10, Block: 10 * time.Millisecond, MaxDeliveries: 3}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.RunTradeIngester(ctx, cfg, health.New().RegisterWorker("ingest", time.Minute))
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for fake.count("XGROUP CREATE") < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	if fake.count("XREADGROUP") == 0 {
+		t.Fatal("the ingester never read the stream")
+	}
+	if n := fake.count("XGROUP CREATE"); n < 2 {
+		t.Errorf("consumer group created %d times, want it created again after NOGROUP", n)
+	}
+}
+
+// redisError is an error reply from Redis
+type redisError string
+
+func (e redisError) Error() string { return string(e) }
+func (redisError) RedisError()     {}
+
+func TestNoGroup(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "wrapped reply", err: fmt.Errorf("read stream: %w", redisError("NOGROUP No such key 'trades'")), want: true},
+		{name: "other reply", err: fmt.Errorf("read stream: %w", redisError("ERR syntax error"))},
+		{name: "not a reply", err: errors.New("NOGROUP in a message")},
+		{name: "connection error", err: fmt.Errorf("read stream: %w", io.EOF)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := noGroup(tt.err); got != tt.want {
+				t.Errorf("noGroup(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTradeEventStatus(t *testing.T) {
+	tests := []struct {
+		status models.MarketStatus
+		err    error
+	}{
+		{status: models.MarketStatusActive},
+		{status: models.MarketStatusHalted, err: ErrMarketClosed},
+		{status: models.MarketStatusResolving, err: ErrMarketClosed},
+		{status: models.MarketStatusResolved, err: ErrConflict},
+		{status: models.MarketStatusVoided, err: ErrConflict},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.status), func(t *testing.T) {
+			err := tradeEventStatus(tt.status)
+			if !errors.Is(err, tt.err) || (tt.err == nil && err != nil) {
+				t.Errorf("tradeEventStatus error = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestNeverApplies(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "invalid event", err: &ValidationError{}, want: true},
+		{name: "unknown pool", err: fmt.Errorf("pool: %w", ErrNotFound), want: true},
+		{name: "resolved market", err: tradeEventStatus(models.MarketStatusResolved), want: true},
+		{name: "halted market", err: tradeEventStatus(models.MarketStatusHalted)},
+		{name: "database down", err: fmt.Errorf("lock market: %w", ErrUnavailable)},
+		{name: "other failure", err: errors.New("deadlock detected")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := neverApplies(tt.err); got != tt.want {
+				t.Errorf("neverApplies(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+	"github.com/ec332/aegis/market/internal/middleware"
+	"github.com/ec332/aegis/market/internal/repository"
+	"github.com/ec332/aegis/market/internal/tracing"
+	"github.com/ec332/aegis/market/pkg/models"
+)
+
+// largestPositionsListed bounds the user positions listed per market in exposure reports
+const largestPositionsListed = 10
+
+// ExposureReport measures the open markets, or just marketID when it is set,
+// against their risk limits (admins only)
+func (s *Service) ExposureReport(ctx context.Context, marketID string) (_ *models.ExposureReport, err error) {
+	ctx, span := startSpan(ctx, "ExposureReport")
+	defer func() { tracing.End(span, err) }()
+
+	if _, err := requireRole(ctx, middleware.RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	var markets []models.Market
+	if marketID != "" {
+		if err := validateID("market", marketID); err != nil {
+			return nil, err
+		}
+		market, err := s.repo.GetMarket(ctx, marketID)
+		if err != nil {
+			return nil, err
+		}
+		markets = append(markets, *market)
+	} else {
+		for _, status := range []models.MarketStatus{models.MarketStatusActive, models.MarketStatusHalted, models.MarketStatusHidden, models.MarketStatusResolving} {
+			list, err := s.repo.ListMarkets(ctx, models.MarketFilter{Status: &status})
+			if err != nil {
+				return nil, err
+			}
+			markets = append(markets, list...)
+		}
+	}
+
+	report := &models.ExposureReport{Markets: make([]models.MarketExposure, 0, len(markets)), Timestamp: time.Now()}
+	for i := range markets {
+		market := &markets[i]
+		stake, err := s.repo.GetTreasuryStake(ctx, market.ID)
+		if err != nil {
+			return nil, err
+		}
+		positions, err := s.repo.LargestPositions(ctx, market.ID, largestPositionsListed)
+		if err != nil {
+			return nil, err
+		}
+
+		exposure := models.MarketExposure{
+			MarketID:         market.ID,
+			Title:            market.Title,
+			Status:           market.Status,
+			Limits:           s.marketLimits(market),
+			TreasuryInvested: stake.Invested,
+			TreasuryExposure: treasuryExposure(stake, market.LiquidityPools),
+			LargestPositions: positions,
+		}
+		report.Markets = append(report.Markets, exposure)
+		report.TreasuryInvested += exposure.TreasuryInvested
+		report.TreasuryExposure += exposure.TreasuryExposure
+	}
+	report.Total = len(report.Markets)
+	return report, nil
+}
+
+// marketLimits returns the limits in force on market: its own, otherwise the defaults
+func (s *Service) marketLimits(market *models.Market) models.MarketLimits {
+	limits := market.Limits
+	if limits.MaxPosition == nil && s.cfg.Limits.MaxPosition > 0 {
+		limits.MaxPosition = &s.cfg.Limits.MaxPosition
+	}
+	if limits.MaxTradeShares == nil && s.cfg.Limits.MaxTradeShares > 0 {
+		limits.MaxTradeShares = &s.cfg.Limits.MaxTradeShares
+	}
+	if limits.MaxTreasuryExposure == nil && s.cfg.Limits.MaxTreasuryExposure > 0 {
+		limits.MaxTreasuryExposure = &s.cfg.Limits.MaxTreasuryExposure
+	}
+	return limits
+}
+
+// checkOrderLimits rejects an order that breaks limits once matched into exec:
+// one that is too large, a buy that takes the user's position in the option past
+// the cap, or one that raises the treasury's exposure past its cap
+func checkOrderLimits(limits models.MarketLimits, order *models.Order, state *repository.BookState, exec *repository.OrderExecution) error {
+	if limit := limits.MaxTradeShares; limit != nil && order.Shares > *limit+shareDust {
+		return fmt.Errorf("%w: order of %g shares is over the %g share limit", ErrTradeSizeLimit, order.Shares, *limit)
+	}
+	if limit := limits.MaxPosition; limit != nil && order.Side == models.OrderSideBuy &&
+		state.Position+state.OpenBuys+order.Shares > *limit+shareDust {
+		return fmt.Errorf("%w: holding %g shares with %g on order, buying %g would pass the %g share limit",
+			ErrPositionLimit, state.Position, state.OpenBuys, order.Shares, *limit)
+	}
+	if limit := limits.MaxTreasuryExposure; limit != nil && len(exec.Pools) > 0 {
+		before := treasuryExposure(state.Treasury, state.Pools)
+		after := treasuryExposure(state.Treasury, exec.Pools)
+		if after > *limit && after > before+shareDust {
+			return fmt.Errorf("%w: order would raise the treasury's exposure to %g, over the %g limit",
+				ErrExposureLimit, after, *limit)
+		}
+	}
+	return nil
+}
+
+// checkPositionLimit rejects receiving shares of options, by option ID, that take
+// the user's holdings in any of them past the market's cap
+func checkPositionLimit(limits models.MarketLimits, holdings, received map[string]float64) error {
+	limit := limits.MaxPosition
+	if limit == nil {
+		return nil
+	}
+	for optionID, shares := range received {
+		if held := holdings[optionID]; held+shares > *limit+shareDust {
+			return fmt.Errorf("%w: holding %g shares of option %s with those on order, receiving %g would pass the %g share limit",
+				ErrPositionLimit, held, optionID, shares, *limit)
+		}
+	}
+	return nil
+}
+
+// treasuryExposure is what the treasury would lose if the option worst for it won,
+// given the market's pools
+func treasuryExposure(stake repository.TreasuryStake, pools []models.LiquidityPool) float64 {
+	var exposure float64
+	for _, pool := range pools {
+		payout := stake.Positions[pool.OptionID]
+		if stake.Supply > 0 {
+			payout += pool.PoolValue * stake.Shares / stake.Supply
+		}
+		exposure = math.Max(exposure, stake.Invested-payout)
+	}
+	return exposure
+}
+
+// checkLimitsOverride only lets admins set a market's risk limits
+func checkLimitsOverride(ctx context.Context, limits *models.MarketLimits) error {
+	if limits == nil {
+		return nil
+	}
+	if !middleware.PrincipalFromContext(ctx).HasRole(middleware.RoleAdmin) {
+		return fmt.Errorf("%w: only admins can set risk limits", ErrForbidden)
+	}
+	return nil
+}
+
+func validateLimits(verr *ValidationError, limits *models.MarketLimits) {
+	if limits == nil {
+		return
+	}
+	fields := []struct {
+		name  string
+		value *float64
+	}{
+		{"limits.max_position", limits.MaxPosition},
+		{"limits.max_trade_shares", limits.MaxTradeShares},
+		{"limits.max_treasury_exposure", limits.MaxTreasuryExposure},
+	}
+	for _, f := range fields {
+		if f.value != nil && !(*f.value > 0 && *f.value <= maxOrderShares) {
+			verr.add(f.name, fmt.Sprintf("must be above 0 and at most %g", float64(maxOrderShares)))
+		}
+	}
+}
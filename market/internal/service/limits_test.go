@@ -0,0 +1,150 @@
+package service
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"github.com/ec332/aegis/market/internal/repository"
+	"github.com/ec332/aegis/market/pkg/models"
+)
+
+func TestMarketLimits(t *testing.T) {
+	s := &Service{cfg: Config{Limits: Limits{MaxPosition: 100, MaxTradeShares: 50}}}
+	own := models.MarketLimits{MaxPosition: floatPtr(10)}
+	limits := s.marketLimits(&models.Market{Limits: own})
+
+	if limits.MaxPosition == nil || *limits.MaxPosition != 10 {
+		t.Errorf("max position = %v, want the market's 10", limits.MaxPosition)
+	}
+	if limits.MaxTradeShares == nil || *limits.MaxTradeShares != 50 {
+		t.Errorf("max trade shares = %v, want the default 50", limits.MaxTradeShares)
+	}
+	if limits.MaxTreasuryExposure != nil {
+		t.Errorf("max treasury exposure = %v, want none as the default is disabled", *limits.MaxTreasuryExposure)
+	}
+}
+
+func TestCheckOrderLimits(t *testing.T) {
+	// The treasury put 100 into a binary pool it owns entirely
+	treasury := repository.TreasuryStake{Shares: 1, Supply: 1, Positions: map[string]float64{}, Invested: 100}
+	tests := []struct {
+		name   string
+		limits models.MarketLimits
+		order  models.Order
+		state  repository.BookState
+		after  []models.LiquidityPool
+		err    error
+	}{
+		{
+			name:   "within every limit",
+			limits: models.MarketLimits{MaxPosition: floatPtr(100), MaxTradeShares: floatPtr(50)},
+			order:  newOrder("o", "alice", models.OrderSideBuy, floatPtr(0.5), 50),
+			state:  repository.BookState{Position: 30, OpenBuys: 20},
+		},
+		{
+			name:   "trade too large",
+			limits: models.MarketLimits{MaxTradeShares: floatPtr(50)},
+			order:  newOrder("o", "alice", models.OrderSideSell, floatPtr(0.5), 51),
+			err:    ErrTradeSizeLimit,
+		},
+		{
+			name:   "position counts open buys",
+			limits: models.MarketLimits{MaxPosition: floatPtr(100)},
+			order:  newOrder("o", "alice", models.OrderSideBuy, floatPtr(0.5), 50),
+			state:  repository.BookState{Position: 30, OpenBuys: 21},
+			err:    ErrPositionLimit,
+		},
+		{
+			name:   "sells ignore the position limit",
+			limits: models.MarketLimits{MaxPosition: floatPtr(10)},
+			order:  newOrder("o", "alice", models.OrderSideSell, floatPtr(0.5), 50),
+			state:  repository.BookState{Position: 50},
+		},
+		{
+			name:   "exposure raised past the cap",
+			limits: models.MarketLimits{MaxTreasuryExposure: floatPtr(20)},
+			order:  newOrder("o", "alice", models.OrderSideBuy, nil, 30),
+			state:  repository.BookState{Pools: binaryPools(100, 100), Treasury: treasury},
+			after:  binaryPools(75, 130),
+			err:    ErrExposureLimit,
+		},
+		{
+			name:   "exposure lowered while over the cap",
+			limits: models.MarketLimits{MaxTreasuryExposure: floatPtr(20)},
+			order:  newOrder("o", "alice", models.OrderSideSell, nil, 30),
+			state:  repository.BookState{Pools: binaryPools(75, 130), Treasury: treasury},
+			after:  binaryPools(100, 100),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exec := &repository.OrderExecution{Pools: tt.after}
+			err := checkOrderLimits(tt.limits, &tt.order, &tt.state, exec)
+			if !errors.Is(err, tt.err) || (tt.err == nil && err != nil) {
+				t.Errorf("checkOrderLimits error = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestCheckPositionLimit(t *testing.T) {
+	limits := models.MarketLimits{MaxPosition: floatPtr(100)}
+	tests := []struct {
+		name     string
+		limits   models.MarketLimits
+		holdings map[string]float64
+		received map[string]float64
+		err      error
+	}{
+		{name: "up to the cap", limits: limits, holdings: map[string]float64{"yes": 60}, received: map[string]float64{"yes": 40, "no": 40}},
+		{name: "one option past the cap", limits: limits, holdings: map[string]float64{"no": 70}, received: map[string]float64{"yes": 40, "no": 40}, err: ErrPositionLimit},
+		{name: "no cap", holdings: map[string]float64{"yes": 1e6}, received: map[string]float64{"yes": 1e6}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkPositionLimit(tt.limits, tt.holdings, tt.received)
+			if !errors.Is(err, tt.err) || (tt.err == nil && err != nil) {
+				t.Errorf("checkPositionLimit error = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestTreasuryExposure(t *testing.T) {
+	tests := []struct {
+		name  string
+		stake repository.TreasuryStake
+		pools []models.LiquidityPool
+		want  float64
+	}{
+		{
+			name:  "balanced pool owned outright",
+			stake: repository.TreasuryStake{Shares: 1, Supply: 1, Invested: 100},
+			pools: binaryPools(100, 100),
+		},
+		{
+			name:  "the thinner reserve is the worst outcome",
+			stake: repository.TreasuryStake{Shares: 1, Supply: 1, Invested: 100},
+			pools: binaryPools(75, 130),
+			want:  25,
+		},
+		{
+			name:  "half the LP shares and held positions",
+			stake: repository.TreasuryStake{Shares: 1, Supply: 2, Invested: 100, Positions: map[string]float64{"opt-yes": 10}},
+			pools: binaryPools(100, 160),
+			want:  40,
+		},
+		{
+			name:  "nothing invested",
+			stake: repository.TreasuryStake{},
+			pools: binaryPools(100, 100),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := treasuryExposure(tt.stake, tt.pools); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("treasuryExposure = %g, want %g", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,430 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+	"github.com/ec332/aegis/market/internal/middleware"
+	"github.com/ec332/aegis/market/internal/pricing"
+	"github.com/ec332/aegis/market/internal/repository"
+	"github.com/ec332/aegis/market/internal/tracing"
+	"github.com/ec332/aegis/market/pkg/models"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// maxProbabilityError is how far a subsidy's probabilities may sum from 1
+const maxProbabilityError = 1e-6
+
+// AddLiquidity funds an active market's pool with collateral in exchange for LP
+// shares. The pool keeps the complete sets the collateral mints in proportion to
+// its reserves, so prices do not move, and the option shares it does not keep go
+// to the provider's positions. The first provider funds every option equally.
+func (s *Service) AddLiquidity(ctx context.Context, marketID string, req models.AddLiquidityRequest) (_ *models.LiquidityResult, err error) {
+	ctx, span := startSpan(ctx, "AddLiquidity", attribute.String("market.id", marketID))
+	defer func() { tracing.End(span, err) }()
+
+	p := middleware.PrincipalFromContext(ctx)
+	if p.UserID == "" {
+		return nil, ErrUnauthenticated
+	}
+	if err := validateID("market", marketID); err != nil {
+		return nil, err
+	}
+	if req.Amount < shareDust || req.Amount > maxOrderShares || math.IsNaN(req.Amount) {
+		verr := &ValidationError{}
+		verr.add("amount", fmt.Sprintf("must be between %g and %g", shareDust, float64(maxOrderShares)))
+		return nil, verr
+	}
+
+	market, err := s.repo.GetMarket(ctx, marketID)
+	if err != nil {
+		return nil, err
+	}
+	limits := s.marketLimits(market)
+
+	return s.changeLiquidity(ctx, marketID, p.UserID, models.LiquidityActionAdd, func(state *repository.LiquidityState, change *repository.LiquidityChange) error {
+		if state.Status != models.MarketStatusActive {
+			return fmt.Errorf("%w: market is %s", ErrMarketClosed, state.Status)
+		}
+		if err := fundPool(state, change, req.Amount, nil); err != nil {
+			return err
+		}
+		// The shares the pool sends back count towards the position cap
+		received := make(map[string]float64, len(change.Positions))
+		for _, c := range change.Positions {
+			received[c.OptionID] += c.Shares
+		}
+		return checkPositionLimit(limits, state.Holdings, received)
+	})
+}
+
+// RemoveLiquidity burns a provider's LP shares for their part of the pool reserves
+// and pays out the fees owed to the provider. Reserves that make up complete sets
+// are returned as collateral, the rest as option positions. Liquidity can be
+// removed until the market settles, which pays out the remaining providers.
+func (s *Service) RemoveLiquidity(ctx context.Context, marketID string, req models.RemoveLiquidityRequest) (_ *models.LiquidityResult, err error) {
+	ctx, span := startSpan(ctx, "RemoveLiquidity", attribute.String("market.id", marketID))
+	defer func() { tracing.End(span, err) }()
+
+	p := middleware.PrincipalFromContext(ctx)
+	if p.UserID == "" {
+		return nil, ErrUnauthenticated
+	}
+	if err := validateID("market", marketID); err != nil {
+		return nil, err
+	}
+	if req.Shares < shareDust || math.IsNaN(req.Shares) || math.IsInf(req.Shares, 0) {
+		verr := &ValidationError{}
+		verr.add("shares", fmt.Sprintf("must be at least %g", shareDust))
+		return nil, verr
+	}
+
+	return s.changeLiquidity(ctx, marketID, p.UserID, models.LiquidityActionRemove, func(state *repository.LiquidityState, change *repository.LiquidityChange) error {
+		if state.Status == models.MarketStatusDraft || isFinal(state.Status) {
+			return fmt.Errorf("%w: market is %s", ErrMarketClosed, state.Status)
+		}
+		provider := &change.Provider
+		if provider.Shares < req.Shares-shareDust {
+			return fmt.Errorf("%w: removing %g LP shares but holding %g", ErrInsufficientShares, req.Shares, provider.Shares)
+		}
+		event := change.Event
+		event.Shares = math.Min(req.Shares, provider.Shares)
+		now := event.CreatedAt
+
+		// Pay out every fee owed, then drop the burned shares' part of the withdrawn fees
+		fees := pricing.FeePool{Collected: state.FeesCollected, Withdrawn: state.FeesWithdrawn, Supply: state.Supply}
+		event.Fees = fees.Owed(provider.Shares, provider.FeesWithdrawn)
+		provider.FeesWithdrawn += event.Fees
+		provider.FeesWithdrawn -= provider.FeesWithdrawn * event.Shares / provider.Shares
+		provider.Shares -= event.Shares
+
+		after, withdrawn := pricing.RemoveFunding(poolReserves(state.Pools), state.Supply, event.Shares)
+		event.Amount = math.Inf(1)
+		for _, w := range withdrawn {
+			event.Amount = math.Min(event.Amount, w)
+		}
+		for i, pool := range state.Pools {
+			if rest := withdrawn[i] - event.Amount; rest > shareDust {
+				change.Positions = append(change.Positions, repository.PositionChange{
+					UserID: event.UserID, MarketID: event.MarketID, OptionID: pool.OptionID, Shares: rest,
+				})
+			}
+		}
+		change.Pools = withReserves(state.Pools, after, now)
+
+		change.Ledger = append(change.Ledger,
+			ledgerEntry(poolAccount(event.MarketID), event.MarketID, models.LedgerKindLiquidity, -event.Amount, event.ID, now),
+			ledgerEntry(userAccount(event.UserID), event.MarketID, models.LedgerKindLiquidity, event.Amount, event.ID, now),
+		)
+		if event.Fees > 0 {
+			change.Ledger = append(change.Ledger,
+				ledgerEntry(lpFeesAccount(event.MarketID), event.MarketID, models.LedgerKindFee, -event.Fees, event.ID, now),
+				ledgerEntry(userAccount(event.UserID), event.MarketID, models.LedgerKindFee, event.Fees, event.ID, now),
+			)
+		}
+		return nil
+	})
+}
+
+// GetMarketLiquidity summarizes a market's pool and the collateral it holds
+func (s *Service) GetMarketLiquidity(ctx context.Context, marketID string) (_ *models.MarketLiquidity, err error) {
+	ctx, span := startSpan(ctx, "GetMarketLiquidity", attribute.String("market.id", marketID))
+	defer func() { tracing.End(span, err) }()
+
+	if err := validateID("market", marketID); err != nil {
+		return nil, err
+	}
+	state, err := s.repo.GetLiquidityState(ctx, marketID, "")
+	if err != nil {
+		return nil, err
+	}
+	locked, err := s.repo.SumBalances(ctx, poolAccount(marketID), lpFeesAccount(marketID), collateralAccount(marketID))
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &models.MarketLiquidity{
+		MarketID:         marketID,
+		TotalShares:      state.Supply,
+		Providers:        state.Providers,
+		FeesCollected:    state.FeesCollected,
+		TotalValueLocked: locked,
+		Timestamp:        time.Now(),
+	}
+	if state.Supply > 0 {
+		summary.PoolValue = poolValue(state.Pools)
+	}
+	return summary, nil
+}
+
+// ListLiquidityPositions retrieves the caller's LP positions across markets
+func (s *Service) ListLiquidityPositions(ctx context.Context) (_ []models.LiquidityPosition, err error) {
+	ctx, span := startSpan(ctx, "ListLiquidityPositions")
+	defer func() { tracing.End(span, err) }()
+
+	p := middleware.PrincipalFromContext(ctx)
+	if p.UserID == "" {
+		return nil, ErrUnauthenticated
+	}
+	providers, err := s.repo.ListLiquidityProviders(ctx, p.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	positions := make([]models.LiquidityPosition, 0, len(providers))
+	for _, provider := range providers {
+		state, err := s.repo.GetLiquidityState(ctx, provider.MarketID, p.UserID)
+		if err != nil {
+			return nil, err
+		}
+		positions = append(positions, liquidityPosition(state))
+	}
+	return positions, nil
+}
+
+// changeLiquidity runs apply on the caller's liquidity in a locked market, filling
+// in change, and publishes the pools it leaves
+func (s *Service) changeLiquidity(ctx context.Context, marketID, userID string, action models.LiquidityAction, apply func(*repository.LiquidityState, *repository.LiquidityChange) error) (*models.LiquidityResult, error) {
+	now := time.Now()
+	event := &models.LiquidityEvent{
+		ID:        uuid.New().String(),
+		MarketID:  marketID,
+		UserID:    userID,
+		Action:    action,
+		CreatedAt: now,
+	}
+
+	var after repository.LiquidityState
+	err := s.repo.ExecuteLiquidity(ctx, marketID, userID, func(state *repository.LiquidityState) (*repository.LiquidityChange, error) {
+		change := &repository.LiquidityChange{Event: event, Provider: state.Provider}
+		change.Provider.UpdatedAt = now
+		if err := apply(state, change); err != nil {
+			return nil, err
+		}
+
+		after = *state
+		after.Pools = change.Pools
+		after.Provider = change.Provider
+		after.Supply += change.Provider.Shares - state.Provider.Shares
+		after.FeesWithdrawn += change.Provider.FeesWithdrawn - state.Provider.FeesWithdrawn
+		after.FeesCollected -= event.Fees
+		return change, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s liquidity: %w", action, err)
+	}
+
+	s.logger.InfoContext(ctx, "liquidity changed",
+		"market_id", marketID,
+		"event_id", event.ID,
+		"user_id", userID,
+		"action", action,
+		"amount", event.Amount,
+		"shares", event.Shares,
+		"fees", event.Fees,
+	)
+
+	if err := s.publishLiquidityUpdate(ctx, marketID, after.Pools); err != nil {
+		s.logger.WarnContext(ctx, "failed to publish liquidity update", "market_id", marketID, "error", err)
+	}
+	if market, err := s.repo.GetMarket(ctx, marketID); err != nil {
+		s.logger.WarnContext(ctx, "failed to publish order book", "market_id", marketID, "error", err)
+	} else {
+		s.publishOrderBook(ctx, market)
+	}
+
+	positions, err := s.repo.ListMarketPositions(ctx, marketID, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &models.LiquidityResult{Event: *event, Provider: liquidityPosition(&after), Positions: positions}, nil
+}
+
+// checkFunding rejects activating a market whose pool has no liquidity. When the
+// market is unfunded, subsidy funds it from the treasury at its probabilities and
+// the returned change applies it; the subsidy must fit the market's exposure limit.
+func (s *Service) checkFunding(ctx context.Context, market *models.Market, to models.MarketStatus, subsidy *models.MarketSubsidy) (*repository.LiquidityChange, error) {
+	if to != models.MarketStatusActive {
+		return nil, nil
+	}
+	state, err := s.repo.GetLiquidityState(ctx, market.ID, models.TreasuryUserID)
+	if err != nil {
+		return nil, err
+	}
+	if state.Supply > 0 {
+		if subsidy != nil {
+			return nil, fmt.Errorf("%w: market is already funded, add liquidity once it is active", ErrConflict)
+		}
+		return nil, nil
+	}
+	if subsidy == nil {
+		return nil, fmt.Errorf("%w: market needs initial liquidity before it can go active", ErrInvalidTransition)
+	}
+
+	weights, err := subsidyWeights(state.Pools, subsidy.Probabilities)
+	if err != nil {
+		return nil, err
+	}
+	// A provider can lose its whole deposit, so the subsidy counts in full
+	if limit := s.marketLimits(market).MaxTreasuryExposure; limit != nil {
+		stake, err := s.repo.GetTreasuryStake(ctx, market.ID)
+		if err != nil {
+			return nil, err
+		}
+		if exposure := treasuryExposure(stake, state.Pools) + subsidy.Amount; exposure > *limit+shareDust {
+			return nil, fmt.Errorf("%w: subsidy would raise the treasury's exposure to %g, over the %g limit",
+				ErrExposureLimit, exposure, *limit)
+		}
+	}
+	now := time.Now()
+	change := &repository.LiquidityChange{
+		Event: &models.LiquidityEvent{
+			ID:        uuid.New().String(),
+			MarketID:  market.ID,
+			UserID:    models.TreasuryUserID,
+			Action:    models.LiquidityActionAdd,
+			CreatedAt: now,
+		},
+		Provider: state.Provider,
+	}
+	change.Provider.UpdatedAt = now
+	if err := fundPool(state, change, subsidy.Amount, weights); err != nil {
+		return nil, err
+	}
+	return change, nil
+}
+
+// subsidyWeights returns the pool weights that start each option at its probability,
+// indexed like pools. Every option is priced equally when probabilities is empty.
+func subsidyWeights(pools []models.LiquidityPool, probabilities map[string]float64) ([]float64, error) {
+	weights := make([]float64, len(pools))
+	if len(probabilities) == 0 {
+		for i := range weights {
+			weights[i] = 1
+		}
+		return weights, nil
+	}
+
+	verr := &ValidationError{}
+	var sum float64
+	for i, pool := range pools {
+		p, ok := probabilities[pool.OptionID]
+		if !ok {
+			verr.add("subsidy.probabilities", fmt.Sprintf("missing option %s", pool.OptionID))
+			continue
+		}
+		if !(p > 0 && p < 1) {
+			verr.add("subsidy.probabilities", fmt.Sprintf("option %s must be between 0 and 1 exclusive", pool.OptionID))
+			continue
+		}
+		// Prices are inversely proportional to reserves
+		weights[i] = 1 / p
+		sum += p
+	}
+	if len(probabilities) != len(pools) {
+		verr.add("subsidy.probabilities", "must only include the market's options")
+	}
+	if err := verr.err(); err != nil {
+		return nil, err
+	}
+	if math.Abs(sum-1) > maxProbabilityError {
+		verr.add("subsidy.probabilities", fmt.Sprintf("must sum to 1, got %g", sum))
+		return nil, verr
+	}
+	return weights, nil
+}
+
+// fundPool adds amount of the provider's collateral to the pool for LP shares.
+// weights sets the reserves a pool without LP shares starts from (equal when nil).
+// Fees collected while the pool had no LP shares are owed to no provider, so they
+// go to the platform rather than to the first one; state is updated to match.
+func fundPool(state *repository.LiquidityState, change *repository.LiquidityChange, amount float64, weights []float64) error {
+	event := change.Event
+	now := event.CreatedAt
+
+	if state.Supply <= 0 && state.FeesCollected > 0 {
+		change.Ledger = append(change.Ledger,
+			ledgerEntry(lpFeesAccount(event.MarketID), event.MarketID, models.LedgerKindFee, -state.FeesCollected, event.ID, now),
+			ledgerEntry(models.LedgerAccountPlatform, event.MarketID, models.LedgerKindFee, state.FeesCollected, event.ID, now),
+		)
+		state.FeesCollected = 0
+	}
+
+	after, sendBack, minted, err := pricing.AddFunding(poolReserves(state.Pools), state.Supply, amount, weights)
+	if errors.Is(err, pricing.ErrNoLiquidity) {
+		return fmt.Errorf("%w: the pool has an empty reserve", ErrConflict)
+	}
+	if err != nil {
+		return err
+	}
+
+	// New providers only share in fees collected from now on
+	fees := pricing.FeePool{Collected: state.FeesCollected, Withdrawn: state.FeesWithdrawn, Supply: state.Supply}
+	change.Provider.Shares += minted
+	change.Provider.FeesWithdrawn += fees.EntryDebit(minted)
+	event.Amount = amount
+	event.Shares = minted
+
+	for i, pool := range state.Pools {
+		if sendBack[i] > shareDust {
+			change.Positions = append(change.Positions, repository.PositionChange{
+				UserID: event.UserID, MarketID: event.MarketID, OptionID: pool.OptionID, Shares: sendBack[i],
+			})
+		}
+	}
+	change.Pools = withReserves(state.Pools, after, now)
+	change.Ledger = append(change.Ledger,
+		ledgerEntry(userAccount(event.UserID), event.MarketID, models.LedgerKindLiquidity, -amount, event.ID, now),
+		ledgerEntry(poolAccount(event.MarketID), event.MarketID, models.LedgerKindLiquidity, amount, event.ID, now),
+	)
+	return nil
+}
+
+// liquidityPosition values the provider of state
+func liquidityPosition(state *repository.LiquidityState) models.LiquidityPosition {
+	p := state.Provider
+	position := models.LiquidityPosition{
+		MarketID:  p.MarketID,
+		UserID:    p.UserID,
+		Shares:    p.Shares,
+		UpdatedAt: p.UpdatedAt,
+	}
+	if state.Supply > 0 {
+		position.PoolShare = p.Shares / state.Supply
+		position.Value = poolValue(state.Pools) * position.PoolShare
+	}
+	fees := pricing.FeePool{Collected: state.FeesCollected, Withdrawn: state.FeesWithdrawn, Supply: state.Supply}
+	position.FeesOwed = fees.Owed(p.Shares, p.FeesWithdrawn)
+	return position
+}
+
+// poolValue marks pool reserves to current prices
+func poolValue(pools []models.LiquidityPool) float64 {
+	var value float64
+	for i, price := range pricing.Prices(pools) {
+		value += pools[i].PoolValue * price
+	}
+	return value
+}
+
+func poolReserves(pools []models.LiquidityPool) []float64 {
+	reserves := make([]float64, len(pools))
+	for i, pool := range pools {
+		reserves[i] = pool.PoolValue
+	}
+	return reserves
+}
+
+// withReserves returns copies of pools holding reserves
+func withReserves(pools []models.LiquidityPool, reserves []float64, now time.Time) []models.LiquidityPool {
+	updated := make([]models.LiquidityPool, len(pools))
+	for i, pool := range pools {
+		pool.PoolValue = reserves[i]
+		pool.UpdatedAt = now
+		updated[i] = pool
+	}
+	return updated
+}
@@ -0,0 +1,242 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+	"github.com/ec332/aegis/market/internal/tracing"
+	"github.com/ec332/aegis/market/pkg/models"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	maxOptionTitleLength = 255
+	maxShortLabelLength  = 50
+	maxImageURLLength    = 2048
+)
+
+// CreateOption adds an option with an empty liquidity pool to a draft categorical market
+func (s *Service) CreateOption(ctx context.Context, marketID string, req models.CreateOptionRequest) (_ *models.Option, err error) {
+	ctx, span := startSpan(ctx, "CreateOption", attribute.String("market.id", marketID))
+	defer func() { tracing.End(span, err) }()
+
+	market, err := s.draftMarket(ctx, marketID)
+	if err != nil {
+		return nil, err
+	}
+	if market.Type == models.MarketTypeScalar {
+		return nil, fmt.Errorf("%w: scalar markets have a fixed long and short option", ErrNotEditable)
+	}
+
+	verr := &ValidationError{}
+	validateOptionTitle(verr, "title", req.Title)
+	validateOptionMetadata(verr, req.ShortLabel, req.ImageURL)
+	if err := verr.err(); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	option := &models.Option{
+		ID:         uuid.New().String(),
+		MarketID:   marketID,
+		Title:      req.Title,
+		ShortLabel: nonEmpty(req.ShortLabel),
+		ImageURL:   nonEmpty(req.ImageURL),
+		CreatedAt:  now,
+	}
+	pool := &models.LiquidityPool{
+		ID:        uuid.New().String(),
+		MarketID:  marketID,
+		OptionID:  option.ID,
+		PoolValue: 0,
+		UpdatedAt: now,
+	}
+	span.SetAttributes(attribute.String("option.id", option.ID))
+
+	if err := s.repo.CreateOption(ctx, option, pool); err != nil {
+		return nil, fmt.Errorf("create option: %w", err)
+	}
+	if err := s.resetReview(ctx, market); err != nil {
+		return nil, err
+	}
+
+	s.publishMarketPools(ctx, marketID)
+	return option, nil
+}
+
+// UpdateOption edits an option's title and metadata while the market is a draft
+func (s *Service) UpdateOption(ctx context.Context, marketID, optionID string, req models.UpdateOptionRequest) (_ *models.Option, err error) {
+	ctx, span := startSpan(ctx, "UpdateOption",
+		attribute.String("market.id", marketID),
+		attribute.String("option.id", optionID),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	if err := validateID("option", optionID); err != nil {
+		return nil, err
+	}
+	market, err := s.draftMarket(ctx, marketID)
+	if err != nil {
+		return nil, err
+	}
+
+	verr := &ValidationError{}
+	if req.Title != nil {
+		validateOptionTitle(verr, "title", *req.Title)
+	}
+	validateOptionMetadata(verr, req.ShortLabel, req.ImageURL)
+	if err := verr.err(); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.UpdateOption(ctx, marketID, optionID, req); err != nil {
+		return nil, fmt.Errorf("update option: %w", err)
+	}
+	if err := s.resetReview(ctx, market); err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetOption(ctx, marketID, optionID)
+}
+
+// DeleteOption removes an option and its liquidity pool from a draft categorical
+// market, keeping at least two options
+func (s *Service) DeleteOption(ctx context.Context, marketID, optionID string) (err error) {
+	ctx, span := startSpan(ctx, "DeleteOption",
+		attribute.String("market.id", marketID),
+		attribute.String("option.id", optionID),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	if err := validateID("option", optionID); err != nil {
+		return err
+	}
+	market, err := s.draftMarket(ctx, marketID)
+	if err != nil {
+		return err
+	}
+	if market.Type == models.MarketTypeScalar {
+		return fmt.Errorf("%w: scalar markets have a fixed long and short option", ErrNotEditable)
+	}
+	if !hasOption(market, optionID) {
+		return fmt.Errorf("option %s: %w", optionID, ErrNotFound)
+	}
+	if len(market.Options) <= 2 {
+		verr := &ValidationError{}
+		verr.add("options", "a market needs at least 2 options")
+		return verr
+	}
+
+	if err := s.repo.DeleteOption(ctx, marketID, optionID); err != nil {
+		return fmt.Errorf("delete option: %w", err)
+	}
+	if err := s.resetReview(ctx, market); err != nil {
+		return err
+	}
+
+	s.publishMarketPools(ctx, marketID)
+	return nil
+}
+
+// ReorderOptions sets the display order of a draft market's options. Every option
+// must be listed exactly once.
+func (s *Service) ReorderOptions(ctx context.Context, marketID string, req models.ReorderOptionsRequest) (_ []models.Option, err error) {
+	ctx, span := startSpan(ctx, "ReorderOptions", attribute.String("market.id", marketID))
+	defer func() { tracing.End(span, err) }()
+
+	market, err := s.draftMarket(ctx, marketID)
+	if err != nil {
+		return nil, err
+	}
+
+	verr := &ValidationError{}
+	seen := map[string]bool{}
+	for i, optionID := range req.OptionIDs {
+		switch {
+		case !hasOption(market, optionID):
+			verr.add(fmt.Sprintf("option_ids[%d]", i), "not an option of this market")
+		case seen[optionID]:
+			verr.add(fmt.Sprintf("option_ids[%d]", i), "listed more than once")
+		}
+		seen[optionID] = true
+	}
+	if len(req.OptionIDs) != len(market.Options) {
+		verr.add("option_ids", fmt.Sprintf("must list all %d options", len(market.Options)))
+	}
+	if err := verr.err(); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.ReorderOptions(ctx, marketID, req.OptionIDs); err != nil {
+		return nil, fmt.Errorf("reorder options: %w", err)
+	}
+	if err := s.resetReview(ctx, market); err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetOptionsByMarketID(ctx, marketID)
+}
+
+// draftMarket returns the market, or ErrNotEditable unless it is a draft
+func (s *Service) draftMarket(ctx context.Context, marketID string) (*models.Market, error) {
+	if err := validateID("market", marketID); err != nil {
+		return nil, err
+	}
+	market, err := s.repo.GetMarket(ctx, marketID)
+	if err != nil {
+		return nil, err
+	}
+	if market.Status != models.MarketStatusDraft {
+		return nil, fmt.Errorf("%w: options can only be changed while the market is draft, not %s",
+			ErrNotEditable, market.Status)
+	}
+	return market, nil
+}
+
+// publishMarketPools publishes the market's current pools after options changed
+func (s *Service) publishMarketPools(ctx context.Context, marketID string) {
+	pools, err := s.repo.GetLiquidityPoolsByMarketID(ctx, marketID)
+	if err == nil {
+		err = s.publishLiquidityUpdate(ctx, marketID, pools)
+	}
+	if err != nil {
+		s.logger.WarnContext(ctx, "failed to publish option change", "market_id", marketID, "error", err)
+	}
+}
+
+func validateOptionTitle(verr *ValidationError, field, title string) {
+	switch {
+	case strings.TrimSpace(title) == "":
+		verr.add(field, "option title is required")
+	case utf8.RuneCountInString(title) > maxOptionTitleLength:
+		verr.add(field, fmt.Sprintf("option title must be at most %d characters", maxOptionTitleLength))
+	}
+}
+
+// validateOptionMetadata checks the optional short label and image URL; empty values are allowed
+func validateOptionMetadata(verr *ValidationError, shortLabel, imageURL *string) {
+	if shortLabel != nil && utf8.RuneCountInString(*shortLabel) > maxShortLabelLength {
+		verr.add("short_label", fmt.Sprintf("must be at most %d characters", maxShortLabelLength))
+	}
+	if imageURL != nil && *imageURL != "" {
+		u, err := url.Parse(*imageURL)
+		switch {
+		case len(*imageURL) > maxImageURLLength:
+			verr.add("image_url", fmt.Sprintf("must be at most %d characters", maxImageURLLength))
+		case err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "":
+			verr.add("image_url", "must be an absolute http or https URL")
+		}
+	}
+}
+
+// nonEmpty returns nil for nil or empty strings
+func nonEmpty(s *string) *string {
+	if s == nil || *s == "" {
+		return nil
+	}
+	return s
+}
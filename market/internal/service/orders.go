@@ -0,0 +1,629 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+	"github.com/ec332/aegis/market/internal/metrics"
+	"github.com/ec332/aegis/market/internal/middleware"
+	"github.com/ec332/aegis/market/internal/pricing"
+	"github.com/ec332/aegis/market/internal/repository"
+	"github.com/ec332/aegis/market/internal/tracing"
+	"github.com/ec332/aegis/market/pkg/models"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	// shareDust is the precision of share amounts; smaller remainders count as filled
+	shareDust      = 1e-8
+	maxOrderShares = 1e9
+	// defaultBookDepth and maxBookDepth bound the price levels listed per side
+	defaultBookDepth = 20
+	maxBookDepth     = 100
+)
+
+// PlaceOrder matches an order against the option's resting orders and its pool,
+// filling from whichever prices better at each step. The unfilled rest of a limit
+// order stays on the book; that of a market order is cancelled. Sell orders need
+// the shares they sell.
+func (s *Service) PlaceOrder(ctx context.Context, marketID string, req models.PlaceOrderRequest) (_ *models.OrderResult, err error) {
+	ctx, span := startSpan(ctx, "PlaceOrder", attribute.String("market.id", marketID))
+	defer func() { tracing.End(span, err) }()
+
+	p := middleware.PrincipalFromContext(ctx)
+	if p.UserID == "" {
+		return nil, ErrUnauthenticated
+	}
+	if err := validateID("market", marketID); err != nil {
+		return nil, err
+	}
+	market, err := s.repo.GetMarket(ctx, marketID)
+	if err != nil {
+		return nil, err
+	}
+	if err := validatePlaceOrderRequest(market, &req); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	order := &models.Order{
+		ID:        uuid.New().String(),
+		MarketID:  marketID,
+		OptionID:  req.OptionID,
+		UserID:    p.UserID,
+		Side:      req.Side,
+		Type:      req.Type,
+		Price:     req.Price,
+		Shares:    req.Shares,
+		Status:    models.OrderStatusOpen,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	span.SetAttributes(attribute.String("order.id", order.ID))
+
+	var exec *repository.OrderExecution
+	var before []models.LiquidityPool
+	err = s.repo.ExecuteOrder(ctx, order, req.Price, func(state *repository.BookState) (*repository.OrderExecution, error) {
+		if state.Status != models.MarketStatusActive {
+			return nil, fmt.Errorf("%w: market is %s", ErrMarketClosed, state.Status)
+		}
+		if order.Side == models.OrderSideSell && state.Position < order.Shares-shareDust {
+			return nil, fmt.Errorf("%w: selling %g shares but holding %g", ErrInsufficientShares, order.Shares, state.Position)
+		}
+		before = state.Pools
+		exec = matchOrder(order, state, s.feeSchedule(market), now)
+		if err := checkOrderLimits(s.marketLimits(market), order, state, exec); err != nil {
+			return nil, err
+		}
+		if err := checkOpenBuys(s.cfg.MaxOpenBuyValue, order, state); err != nil {
+			return nil, err
+		}
+		if len(exec.Pools) > 0 {
+			exec.Prices = priceTicks(exec.Pools, now)
+		}
+		return exec, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("execute order: %w", err)
+	}
+
+	s.logger.InfoContext(ctx, "order placed",
+		"market_id", marketID,
+		"order_id", order.ID,
+		"side", order.Side,
+		"type", order.Type,
+		"shares", order.Shares,
+		"filled_shares", order.FilledShares,
+		"status", order.Status,
+		"trades", len(exec.Trades),
+	)
+
+	if len(exec.Pools) > 0 {
+		market.LiquidityPools = exec.Pools
+		if err := s.publishLiquidityUpdate(ctx, marketID, exec.Pools); err != nil {
+			s.logger.WarnContext(ctx, "failed to publish liquidity update", "market_id", marketID, "error", err)
+		}
+	}
+	s.publishOrderBook(ctx, market)
+	if len(exec.Pools) > 0 {
+		s.checkCircuitBreaker(ctx, market, before, exec.Pools)
+	}
+
+	return &models.OrderResult{Order: *exec.Order, Trades: exec.Trades}, nil
+}
+
+// QuoteOrder estimates how an order would fill against the current book and pool,
+// with its fees, without placing it
+func (s *Service) QuoteOrder(ctx context.Context, marketID string, req models.PlaceOrderRequest) (_ *models.Quote, err error) {
+	ctx, span := startSpan(ctx, "QuoteOrder", attribute.String("market.id", marketID))
+	defer func() { tracing.End(span, err) }()
+
+	if err := validateID("market", marketID); err != nil {
+		return nil, err
+	}
+	market, err := s.repo.GetMarket(ctx, marketID)
+	if err != nil {
+		return nil, err
+	}
+	if err := validatePlaceOrderRequest(market, &req); err != nil {
+		return nil, err
+	}
+	if market.Status != models.MarketStatusActive {
+		return nil, fmt.Errorf("%w: market is %s", ErrMarketClosed, market.Status)
+	}
+
+	now := time.Now()
+	order := &models.Order{
+		ID:        uuid.New().String(),
+		MarketID:  marketID,
+		OptionID:  req.OptionID,
+		UserID:    middleware.PrincipalFromContext(ctx).UserID,
+		Side:      req.Side,
+		Type:      req.Type,
+		Price:     req.Price,
+		Shares:    req.Shares,
+		Status:    models.OrderStatusOpen,
+		CreatedAt: now,
+	}
+	state, err := s.repo.ReadBookState(ctx, order, req.Price)
+	if err != nil {
+		return nil, err
+	}
+	exec := matchOrder(order, state, s.feeSchedule(market), now)
+	if err := checkOrderLimits(s.marketLimits(market), order, state, exec); err != nil {
+		return nil, err
+	}
+
+	quote := &models.Quote{
+		MarketID:     marketID,
+		OptionID:     req.OptionID,
+		Side:         req.Side,
+		Shares:       req.Shares,
+		FilledShares: order.FilledShares,
+		Timestamp:    now,
+	}
+	for _, t := range exec.Trades {
+		quote.Amount += t.Shares * t.Price
+		quote.Fee += t.Fee
+	}
+	if order.FilledShares > 0 {
+		quote.AveragePrice = quote.Amount / order.FilledShares
+	}
+	quote.Total = quote.Amount + quote.Fee
+	if req.Side == models.OrderSideSell {
+		quote.Total = quote.Amount - quote.Fee
+	}
+	return quote, nil
+}
+
+// CancelOrder takes an order off the book. Only its owner or an admin can cancel it.
+func (s *Service) CancelOrder(ctx context.Context, marketID, orderID string) (_ *models.Order, err error) {
+	ctx, span := startSpan(ctx, "CancelOrder",
+		attribute.String("market.id", marketID),
+		attribute.String("order.id", orderID),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	p := middleware.PrincipalFromContext(ctx)
+	if p.UserID == "" {
+		return nil, ErrUnauthenticated
+	}
+	if err := validateID("market", marketID); err != nil {
+		return nil, err
+	}
+	if err := validateID("order", orderID); err != nil {
+		return nil, err
+	}
+
+	order, err := s.repo.GetOrder(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if order.MarketID != marketID {
+		return nil, fmt.Errorf("order %s: %w", orderID, ErrNotFound)
+	}
+	if order.UserID != p.UserID && !p.HasRole(middleware.RoleAdmin) {
+		return nil, fmt.Errorf("%w: only the order's owner can cancel it", ErrForbidden)
+	}
+
+	cancelled, err := s.repo.CancelOrder(ctx, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("cancel order: %w", err)
+	}
+	s.logger.InfoContext(ctx, "order cancelled", "market_id", marketID, "order_id", orderID, "user_id", p.UserID)
+
+	market, err := s.repo.GetMarket(ctx, marketID)
+	if err != nil {
+		s.logger.WarnContext(ctx, "failed to publish order book", "market_id", marketID, "error", err)
+	} else {
+		s.publishOrderBook(ctx, market)
+	}
+
+	return cancelled, nil
+}
+
+// ListOrders retrieves the caller's orders in a market, newest first
+func (s *Service) ListOrders(ctx context.Context, marketID string) (_ []models.Order, err error) {
+	ctx, span := startSpan(ctx, "ListOrders", attribute.String("market.id", marketID))
+	defer func() { tracing.End(span, err) }()
+
+	p := middleware.PrincipalFromContext(ctx)
+	if p.UserID == "" {
+		return nil, ErrUnauthenticated
+	}
+	if err := validateID("market", marketID); err != nil {
+		return nil, err
+	}
+	if _, err := s.repo.GetMarket(ctx, marketID); err != nil {
+		return nil, err
+	}
+
+	return s.repo.ListOrders(ctx, marketID, p.UserID)
+}
+
+// ListPositions retrieves the caller's holdings across markets
+func (s *Service) ListPositions(ctx context.Context) (_ []models.Position, err error) {
+	ctx, span := startSpan(ctx, "ListPositions")
+	defer func() { tracing.End(span, err) }()
+
+	p := middleware.PrincipalFromContext(ctx)
+	if p.UserID == "" {
+		return nil, ErrUnauthenticated
+	}
+	return s.repo.ListPositions(ctx, p.UserID)
+}
+
+// GetOrderBook returns up to depth price levels per side for each option of a
+// market, with the pool's price alongside
+func (s *Service) GetOrderBook(ctx context.Context, marketID string, depth int) (_ *models.OrderBook, err error) {
+	ctx, span := startSpan(ctx, "GetOrderBook", attribute.String("market.id", marketID))
+	defer func() { tracing.End(span, err) }()
+
+	if err := validateID("market", marketID); err != nil {
+		return nil, err
+	}
+	if depth == 0 {
+		depth = defaultBookDepth
+	}
+	if depth < 0 || depth > maxBookDepth {
+		verr := &ValidationError{}
+		verr.add("depth", fmt.Sprintf("must be between 1 and %d", maxBookDepth))
+		return nil, verr
+	}
+
+	market, err := s.repo.GetMarket(ctx, marketID)
+	if err != nil {
+		return nil, err
+	}
+	return s.orderBook(ctx, market, depth)
+}
+
+// orderBook builds the book of market's options in display order
+func (s *Service) orderBook(ctx context.Context, market *models.Market, depth int) (*models.OrderBook, error) {
+	books, err := s.repo.BookDepth(ctx, market.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	reserves := optionReserves(market.Options, market.LiquidityPools)
+	tradable := pricing.Tradable(reserves)
+
+	result := &models.OrderBook{
+		MarketID:  market.ID,
+		Options:   make([]models.OptionBook, len(market.Options)),
+		Timestamp: time.Now(),
+	}
+	for i, option := range market.Options {
+		book := models.OptionBook{OptionID: option.ID, Bids: []models.BookLevel{}, Asks: []models.BookLevel{}}
+		if b, ok := books[option.ID]; ok {
+			book.Bids = b.Bids[:min(depth, len(b.Bids))]
+			book.Asks = b.Asks[:min(depth, len(b.Asks))]
+		}
+		if tradable {
+			price := pricing.Price(reserves, i)
+			book.PoolPrice = &price
+		}
+		result.Options[i] = book
+	}
+	return result, nil
+}
+
+// publishOrderBook publishes market's book depth to its stream subscribers
+func (s *Service) publishOrderBook(ctx context.Context, market *models.Market) {
+	if err := s.publishBookUpdate(ctx, market); err != nil {
+		s.logger.WarnContext(ctx, "failed to publish order book", "market_id", market.ID, "error", err)
+	}
+}
+
+func (s *Service) publishBookUpdate(ctx context.Context, market *models.Market) (err error) {
+	ctx, span := startSpan(ctx, "publishBookUpdate", attribute.String("market.id", market.ID))
+	defer func() { tracing.End(span, err) }()
+
+	book, err := s.orderBook(ctx, market, defaultBookDepth)
+	if err != nil {
+		return err
+	}
+	book.TraceContext = tracing.Inject(ctx)
+
+	data, err := json.Marshal(book)
+	if err != nil {
+		return fmt.Errorf("marshal order book: %w", err)
+	}
+	if err := s.redisClient.Publish(ctx, bookChannel(market.ID), data).Err(); err != nil {
+		metrics.RedisPublishFailures.WithLabelValues("book").Inc()
+		return fmt.Errorf("publish to redis: %w", err)
+	}
+	return nil
+}
+
+func validatePlaceOrderRequest(market *models.Market, req *models.PlaceOrderRequest) error {
+	verr := &ValidationError{}
+	if !hasOption(market, req.OptionID) {
+		verr.add("option_id", "not an option of this market")
+	}
+	if req.Side != models.OrderSideBuy && req.Side != models.OrderSideSell {
+		verr.add("side", "must be buy or sell")
+	}
+	if req.Type == "" {
+		req.Type = models.OrderTypeMarket
+		if req.Price != nil {
+			req.Type = models.OrderTypeLimit
+		}
+	}
+	switch req.Type {
+	case models.OrderTypeLimit:
+		if req.Price == nil {
+			verr.add("price", "price is required for limit orders")
+		} else if *req.Price <= 0 || *req.Price >= 1 {
+			verr.add("price", "must be between 0 and 1")
+		}
+	case models.OrderTypeMarket:
+		if req.Price != nil {
+			verr.add("price", "market orders take no price")
+		}
+	default:
+		verr.add("type", fmt.Sprintf("unknown order type %q", req.Type))
+	}
+	if req.Shares < shareDust || req.Shares > maxOrderShares || math.IsNaN(req.Shares) {
+		verr.add("shares", fmt.Sprintf("must be between %g and %g", shareDust, float64(maxOrderShares)))
+	}
+	return verr.err()
+}
+
+// matchOrder fills order from state, taking the resting order or the pool that
+// prices better until the order is filled, its limit is reached or neither is left.
+// Each trade charges the order's owner the market's fee.
+func matchOrder(order *models.Order, state *repository.BookState, fees feeSchedule, now time.Time) *repository.OrderExecution {
+	exec := &repository.OrderExecution{Order: order}
+	buy := order.Side == models.OrderSideBuy
+
+	idx := -1
+	reserves := make([]float64, len(state.Pools))
+	for i, pool := range state.Pools {
+		reserves[i] = pool.PoolValue
+		if pool.OptionID == order.OptionID {
+			idx = i
+		}
+	}
+	poolTraded := false
+
+	// Orders never trade with the same user's resting orders
+	var resting []models.Order
+	for i := range state.Resting {
+		if state.Resting[i].UserID != order.UserID {
+			resting = append(resting, state.Resting[i])
+		}
+	}
+	for order.Remaining() > shareDust {
+		var maker *models.Order
+		if len(resting) > 0 {
+			maker = &resting[0]
+		}
+		tradable := idx >= 0 && pricing.Tradable(reserves)
+		var poolPrice float64
+		if tradable {
+			poolPrice = pricing.Price(reserves, idx)
+		}
+
+		// Resting orders fill first when they price at least as well as the pool
+		if maker != nil && (!tradable || (buy && *maker.Price <= poolPrice) || (!buy && *maker.Price >= poolPrice)) {
+			shares := math.Min(order.Remaining(), maker.Remaining())
+			fillFromBook(exec, order, maker, shares, fees, now)
+			if maker.Remaining() <= shareDust {
+				resting = resting[1:]
+			}
+			continue
+		}
+		if !tradable {
+			break
+		}
+
+		// Take from the pool until its price reaches the next resting order or the limit
+		bound := order.Price
+		if maker != nil && (bound == nil || (buy && *maker.Price < *bound) || (!buy && *maker.Price > *bound)) {
+			bound = maker.Price
+		}
+		shares := order.Remaining()
+		switch {
+		case bound != nil && buy:
+			shares = pricing.BuyToPrice(reserves, idx, *bound, shares)
+		case bound != nil:
+			shares = pricing.SellToPrice(reserves, idx, *bound, shares)
+		}
+		shares = math.Floor(shares/shareDust) * shareDust
+		if shares <= shareDust {
+			if maker == nil {
+				break
+			}
+			// The pool is at the resting order's price
+			fillFromBook(exec, order, maker, math.Min(order.Remaining(), maker.Remaining()), fees, now)
+			if maker.Remaining() <= shareDust {
+				resting = resting[1:]
+			}
+			continue
+		}
+
+		var err error
+		if reserves, err = fillFromPool(exec, order, reserves, idx, shares, fees, now); err != nil {
+			break
+		}
+		poolTraded = true
+	}
+
+	fees.chargeMinimum(exec, now)
+
+	if poolTraded {
+		exec.Pools = make([]models.LiquidityPool, len(state.Pools))
+		for i, pool := range state.Pools {
+			pool.PoolValue = reserves[i]
+			pool.UpdatedAt = now
+			exec.Pools[i] = pool
+		}
+	}
+
+	// Settle the order's status and the shares it moves
+	switch {
+	case order.Remaining() <= shareDust:
+		order.FilledShares = order.Shares
+		order.Status = models.OrderStatusFilled
+	case order.Type == models.OrderTypeMarket:
+		order.Status = models.OrderStatusCancelled
+	case order.FilledShares > 0:
+		order.Status = models.OrderStatusPartiallyFilled
+	}
+	order.UpdatedAt = now
+
+	change := repository.PositionChange{UserID: order.UserID, MarketID: order.MarketID, OptionID: order.OptionID}
+	switch {
+	case buy:
+		change.Shares = order.FilledShares
+	case order.Status == models.OrderStatusCancelled:
+		change.Shares = -order.FilledShares
+	default:
+		// Resting sell orders hold their unfilled shares. Resting buys hold nothing,
+		// so checkOpenBuys caps what they may add up to.
+		change.Shares = -order.Shares
+	}
+	if change.Shares != 0 {
+		exec.Positions = append(exec.Positions, change)
+	}
+	return exec
+}
+
+// checkOpenBuys rejects a buy whose unfilled rest, once resting, would take what
+// the owner's resting buys would pay past max. Buys hold no collateral: the service
+// keeps no balances to reserve it from, and each fill is paid when it trades.
+func checkOpenBuys(max float64, order *models.Order, state *repository.BookState) error {
+	resting := order.Status == models.OrderStatusOpen || order.Status == models.OrderStatusPartiallyFilled
+	if max <= 0 || order.Side != models.OrderSideBuy || !resting {
+		return nil
+	}
+	value := state.OpenBuyValue + order.Remaining()**order.Price
+	if value > max+shareDust {
+		return fmt.Errorf("%w: open buy orders would come to %g, above the cap of %g", ErrOpenOrderLimit, value, max)
+	}
+	return nil
+}
+
+// fillFromBook trades shares between order and the resting maker at the maker's price
+func fillFromBook(exec *repository.OrderExecution, order, maker *models.Order, shares float64, fees feeSchedule, now time.Time) {
+	order.FilledShares += shares
+	maker.FilledShares += shares
+	maker.Status = models.OrderStatusPartiallyFilled
+	if maker.Remaining() <= shareDust {
+		maker.FilledShares = maker.Shares
+		maker.Status = models.OrderStatusFilled
+	}
+	maker.UpdatedAt = now
+	exec.Makers = append(exec.Makers, *maker)
+
+	trade := newTrade(order, models.TradeVenueBook, shares, *maker.Price, now)
+	trade.MakerOrderID = &maker.ID
+	trade.MakerUserID = &maker.UserID
+	fees.charge(exec, &trade, now)
+	exec.Trades = append(exec.Trades, trade)
+
+	// The maker's sold shares were held by its order; a buying maker receives them
+	if maker.Side == models.OrderSideBuy {
+		exec.Positions = append(exec.Positions, repository.PositionChange{
+			UserID: maker.UserID, MarketID: maker.MarketID, OptionID: maker.OptionID, Shares: shares,
+		})
+	}
+
+	buyer, seller := order.UserID, maker.UserID
+	if order.Side == models.OrderSideSell {
+		buyer, seller = seller, buyer
+	}
+	amount := shares * *maker.Price
+	exec.Ledger = append(exec.Ledger,
+		ledgerEntry(userAccount(buyer), order.MarketID, models.LedgerKindTrade, -amount, trade.ID, now),
+		ledgerEntry(userAccount(seller), order.MarketID, models.LedgerKindTrade, amount, trade.ID, now),
+	)
+}
+
+// fillFromPool trades shares of option idx between order and the pool and returns
+// the pool's reserves after the trade
+func fillFromPool(exec *repository.OrderExecution, order *models.Order, reserves []float64, idx int, shares float64, fees feeSchedule, now time.Time) ([]float64, error) {
+	var amount float64
+	var after []float64
+	var err error
+	if order.Side == models.OrderSideBuy {
+		amount, after, err = pricing.BuyCost(reserves, idx, shares)
+	} else {
+		amount, after, err = pricing.SellProceeds(reserves, idx, shares)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	trade := newTrade(order, models.TradeVenuePool, shares, amount/shares, now)
+	order.FilledShares += shares
+	fees.charge(exec, &trade, now)
+	exec.Trades = append(exec.Trades, trade)
+
+	// Collateral paid for shares stays in the pool backing its reserves
+	if order.Side == models.OrderSideBuy {
+		amount = -amount
+	}
+	exec.Ledger = append(exec.Ledger,
+		ledgerEntry(userAccount(order.UserID), order.MarketID, models.LedgerKindTrade, amount, trade.ID, now),
+		ledgerEntry(poolAccount(order.MarketID), order.MarketID, models.LedgerKindTrade, -amount, trade.ID, now),
+	)
+	return after, nil
+}
+
+func newTrade(order *models.Order, venue models.TradeVenue, shares, price float64, now time.Time) models.Trade {
+	return models.Trade{
+		ID:        uuid.New().String(),
+		MarketID:  order.MarketID,
+		OptionID:  order.OptionID,
+		OrderID:   order.ID,
+		UserID:    order.UserID,
+		Side:      order.Side,
+		Venue:     venue,
+		Shares:    shares,
+		Price:     price,
+		CreatedAt: now,
+	}
+}
+
+func ledgerEntry(account, marketID string, kind models.LedgerKind, amount float64, refID string, now time.Time) models.LedgerEntry {
+	return models.LedgerEntry{
+		ID:        uuid.New().String(),
+		Account:   account,
+		MarketID:  marketID,
+		Kind:      kind,
+		Amount:    amount,
+		RefID:     refID,
+		CreatedAt: now,
+	}
+}
+
+func userAccount(userID string) string {
+	return models.LedgerAccountUserPrefix + userID
+}
+
+func poolAccount(marketID string) string {
+	return models.LedgerAccountPoolPrefix + marketID
+}
+
+// optionReserves returns the pool reserves indexed like options; options without a
+// pool have none
+func optionReserves(options []models.Option, pools []models.LiquidityPool) []float64 {
+	byOption := make(map[string]float64, len(pools))
+	for _, pool := range pools {
+		byOption[pool.OptionID] = pool.PoolValue
+	}
+	reserves := make([]float64, len(options))
+	for i, option := range options {
+		reserves[i] = byOption[option.ID]
+	}
+	return reserves
+}
+
+func bookChannel(marketID string) string {
+	return fmt.Sprintf("market:%s:book", marketID)
+}
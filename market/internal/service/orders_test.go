@@ -0,0 +1,258 @@
+package service
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+	"github.com/ec332/aegis/market/internal/pricing"
+	"github.com/ec332/aegis/market/internal/repository"
+	"github.com/ec332/aegis/market/pkg/models"
+)
+
+var matchTime = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func newOrder(id, user string, side models.OrderSide, price *float64, shares float64) models.Order {
+	typ := models.OrderTypeLimit
+	if price == nil {
+		typ = models.OrderTypeMarket
+	}
+	return models.Order{
+		ID:       id,
+		MarketID: "market-1",
+		OptionID: "opt-yes",
+		UserID:   user,
+		Side:     side,
+		Type:     typ,
+		Price:    price,
+		Shares:   shares,
+		Status:   models.OrderStatusOpen,
+	}
+}
+
+func binaryPools(yes, no float64) []models.LiquidityPool {
+	return []models.LiquidityPool{
+		{ID: "pool-yes", MarketID: "market-1", OptionID: "opt-yes", PoolValue: yes},
+		{ID: "pool-no", MarketID: "market-1", OptionID: "opt-no", PoolValue: no},
+	}
+}
+
+type fill struct {
+	maker  string
+	venue  models.TradeVenue
+	shares float64
+	price  float64
+}
+
+func TestMatchOrderAgainstBook(t *testing.T) {
+	tests := []struct {
+		name    string
+		order   models.Order
+		resting []models.Order
+		fills   []fill
+		status  models.OrderStatus
+		makers  map[string]models.OrderStatus
+	}{
+		{
+			name:  "price then time priority",
+			order: newOrder("taker", "alice", models.OrderSideBuy, floatPtr(0.6), 12),
+			resting: []models.Order{
+				newOrder("old", "bob", models.OrderSideSell, floatPtr(0.5), 5),
+				newOrder("new", "carol", models.OrderSideSell, floatPtr(0.5), 5),
+				newOrder("worse", "dave", models.OrderSideSell, floatPtr(0.55), 10),
+			},
+			fills: []fill{
+				{maker: "old", venue: models.TradeVenueBook, shares: 5, price: 0.5},
+				{maker: "new", venue: models.TradeVenueBook, shares: 5, price: 0.5},
+				{maker: "worse", venue: models.TradeVenueBook, shares: 2, price: 0.55},
+			},
+			status: models.OrderStatusFilled,
+			makers: map[string]models.OrderStatus{
+				"old":   models.OrderStatusFilled,
+				"new":   models.OrderStatusFilled,
+				"worse": models.OrderStatusPartiallyFilled,
+			},
+		},
+		{
+			name:    "limit order rests partially filled",
+			order:   newOrder("taker", "alice", models.OrderSideSell, floatPtr(0.4), 20),
+			resting: []models.Order{newOrder("bid", "bob", models.OrderSideBuy, floatPtr(0.45), 8)},
+			fills:   []fill{{maker: "bid", venue: models.TradeVenueBook, shares: 8, price: 0.45}},
+			status:  models.OrderStatusPartiallyFilled,
+			makers:  map[string]models.OrderStatus{"bid": models.OrderStatusFilled},
+		},
+		{
+			name:    "market order cancels its rest",
+			order:   newOrder("taker", "alice", models.OrderSideBuy, nil, 20),
+			resting: []models.Order{newOrder("ask", "bob", models.OrderSideSell, floatPtr(0.5), 8)},
+			fills:   []fill{{maker: "ask", venue: models.TradeVenueBook, shares: 8, price: 0.5}},
+			status:  models.OrderStatusCancelled,
+			makers:  map[string]models.OrderStatus{"ask": models.OrderStatusFilled},
+		},
+		{
+			name:  "own resting orders are skipped",
+			order: newOrder("taker", "alice", models.OrderSideBuy, floatPtr(0.6), 5),
+			resting: []models.Order{
+				newOrder("own", "alice", models.OrderSideSell, floatPtr(0.4), 5),
+				newOrder("other", "bob", models.OrderSideSell, floatPtr(0.5), 5),
+			},
+			fills:  []fill{{maker: "other", venue: models.TradeVenueBook, shares: 5, price: 0.5}},
+			status: models.OrderStatusFilled,
+			makers: map[string]models.OrderStatus{"other": models.OrderStatusFilled},
+		},
+		{
+			name:    "only own resting orders",
+			order:   newOrder("taker", "alice", models.OrderSideSell, floatPtr(0.3), 5),
+			resting: []models.Order{newOrder("own", "alice", models.OrderSideBuy, floatPtr(0.5), 5)},
+			status:  models.OrderStatusOpen,
+			makers:  map[string]models.OrderStatus{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := tt.order
+			state := &repository.BookState{Status: models.MarketStatusActive, Resting: tt.resting}
+			exec := matchOrder(&order, state, feeSchedule{}, matchTime)
+
+			if len(exec.Trades) != len(tt.fills) {
+				t.Fatalf("got %d trades, want %d", len(exec.Trades), len(tt.fills))
+			}
+			var filled float64
+			for i, want := range tt.fills {
+				got := exec.Trades[i]
+				if got.MakerOrderID == nil || *got.MakerOrderID != want.maker || got.Venue != want.venue ||
+					got.Shares != want.shares || got.Price != want.price {
+					t.Errorf("trade %d = %+v, want %+v", i, got, want)
+				}
+				if got.MakerUserID != nil && *got.MakerUserID == order.UserID {
+					t.Errorf("trade %d matched the taker's own order", i)
+				}
+				filled += got.Shares
+			}
+			if order.FilledShares != filled || order.Status != tt.status {
+				t.Errorf("order filled %g with status %s, want %g and %s", order.FilledShares, order.Status, filled, tt.status)
+			}
+			if len(exec.Makers) != len(tt.fills) {
+				t.Errorf("got %d maker updates, want one per fill", len(exec.Makers))
+			}
+			for _, m := range exec.Makers {
+				if want, ok := tt.makers[m.ID]; !ok || m.Status != want {
+					t.Errorf("maker %s status = %s, want %s", m.ID, m.Status, want)
+				}
+			}
+			if exec.Pools != nil {
+				t.Errorf("pools changed without a pool trade")
+			}
+		})
+	}
+}
+
+func TestMatchOrderPositions(t *testing.T) {
+	// A sell that rests holds all its shares; a buying maker receives what it bought
+	order := newOrder("taker", "alice", models.OrderSideSell, floatPtr(0.4), 20)
+	state := &repository.BookState{Resting: []models.Order{newOrder("bid", "bob", models.OrderSideBuy, floatPtr(0.45), 8)}}
+	exec := matchOrder(&order, state, feeSchedule{}, matchTime)
+
+	want := map[string]float64{"alice": -20, "bob": 8}
+	got := map[string]float64{}
+	for _, c := range exec.Positions {
+		got[c.UserID] += c.Shares
+	}
+	for user, shares := range want {
+		if got[user] != shares {
+			t.Errorf("%s's position changes by %g, want %g", user, got[user], shares)
+		}
+	}
+
+	// The buyer pays the seller the maker's price
+	balances := map[string]float64{}
+	for _, e := range exec.Ledger {
+		balances[e.Account] += e.Amount
+	}
+	if balances[userAccount("bob")] != -3.6 || balances[userAccount("alice")] != 3.6 {
+		t.Errorf("ledger = %v, want bob paying alice 3.6", balances)
+	}
+}
+
+func TestMatchOrderRouting(t *testing.T) {
+	tests := []struct {
+		name    string
+		order   models.Order
+		resting []models.Order
+		venues  []models.TradeVenue
+	}{
+		{
+			name:    "book prices better than the pool",
+			order:   newOrder("taker", "alice", models.OrderSideBuy, nil, 5),
+			resting: []models.Order{newOrder("ask", "bob", models.OrderSideSell, floatPtr(0.45), 10)},
+			venues:  []models.TradeVenue{models.TradeVenueBook},
+		},
+		{
+			name:    "pool prices better than the book",
+			order:   newOrder("taker", "alice", models.OrderSideBuy, nil, 5),
+			resting: []models.Order{newOrder("ask", "bob", models.OrderSideSell, floatPtr(0.7), 10)},
+			venues:  []models.TradeVenue{models.TradeVenuePool},
+		},
+		{
+			name:   "pool up to the limit",
+			order:  newOrder("taker", "alice", models.OrderSideBuy, floatPtr(0.55), 1000),
+			venues: []models.TradeVenue{models.TradeVenuePool},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := tt.order
+			state := &repository.BookState{Pools: binaryPools(100, 100), Resting: tt.resting}
+			exec := matchOrder(&order, state, feeSchedule{}, matchTime)
+
+			if len(exec.Trades) != len(tt.venues) {
+				t.Fatalf("got %d trades, want %d", len(exec.Trades), len(tt.venues))
+			}
+			for i, venue := range tt.venues {
+				if exec.Trades[i].Venue != venue {
+					t.Errorf("trade %d venue = %s, want %s", i, exec.Trades[i].Venue, venue)
+				}
+			}
+			if tt.venues[0] == models.TradeVenuePool && len(exec.Pools) != 2 {
+				t.Errorf("pool trade left %d pools, want 2", len(exec.Pools))
+			}
+			if order.Price != nil {
+				price := pricing.Price([]float64{exec.Pools[0].PoolValue, exec.Pools[1].PoolValue}, 0)
+				if math.Abs(price-*order.Price) > 1e-6 {
+					t.Errorf("pool price = %v, want it taken to the limit %v", price, *order.Price)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckOpenBuys(t *testing.T) {
+	tests := []struct {
+		name   string
+		max    float64
+		open   float64
+		order  models.Order
+		status models.OrderStatus
+		err    error
+	}{
+		{name: "within the cap", max: 100, open: 50, order: newOrder("o", "alice", models.OrderSideBuy, floatPtr(0.5), 100), status: models.OrderStatusOpen},
+		{name: "past the cap", max: 100, open: 60, order: newOrder("o", "alice", models.OrderSideBuy, floatPtr(0.5), 100), status: models.OrderStatusOpen, err: ErrOpenOrderLimit},
+		{name: "disabled", max: 0, open: 1e9, order: newOrder("o", "alice", models.OrderSideBuy, floatPtr(0.5), 100), status: models.OrderStatusOpen},
+		{name: "filled buy does not rest", max: 100, open: 100, order: newOrder("o", "alice", models.OrderSideBuy, floatPtr(0.5), 100), status: models.OrderStatusFilled},
+		{name: "sell", max: 100, open: 100, order: newOrder("o", "alice", models.OrderSideSell, floatPtr(0.5), 100), status: models.OrderStatusOpen},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := tt.order
+			order.Status = tt.status
+			err := checkOpenBuys(tt.max, &order, &repository.BookState{OpenBuyValue: tt.open})
+			if !errors.Is(err, tt.err) || (tt.err == nil && err != nil) {
+				t.Errorf("checkOpenBuys error = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func floatPtr(f float64) *float64 {
+	return &f
+}
@@ -0,0 +1,373 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+	"github.com/ec332/aegis/market/internal/health"
+	"github.com/ec332/aegis/market/internal/metrics"
+	"github.com/ec332/aegis/market/internal/pricing"
+	"github.com/ec332/aegis/market/internal/repository"
+	"github.com/ec332/aegis/market/internal/tracing"
+	"github.com/ec332/aegis/market/internal/txservice"
+	"github.com/ec332/aegis/market/pkg/models"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// reconcileTolerance is the largest difference between a stored and a replayed
+// amount put down to rounding
+const reconcileTolerance = 1e-6
+
+// ErrNoTransactionService is returned when reconciling without a transaction service
+var ErrNoTransactionService = errors.New("transaction service not configured")
+
+// RunReconciler compares pools and positions with the transaction service every
+// interval until ctx is done, logging discrepancies without repairing them
+func (s *Service) RunReconciler(ctx context.Context, interval time.Duration, hb *health.Heartbeat) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		hb.Beat()
+		report, err := s.Reconcile(ctx, "", false)
+		if err != nil {
+			s.logger.WarnContext(ctx, "failed to reconcile with the transaction service", "error", err)
+		} else {
+			metrics.ReconciliationDiscrepancies.Set(float64(report.Discrepancies))
+			if report.Discrepancies > 0 {
+				s.logger.WarnContext(ctx, "pools and positions differ from the transaction service", "discrepancies", report.Discrepancies)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Reconcile replays the transactions the transaction service recorded for a market,
+// or for every open market when marketID is empty, and reports the pools and
+// positions that differ from the replay. With repair set it corrects them to the
+// replay, except for positions that would go negative and users the market does
+// not know, and books the value of the shares it adds or removes against the
+// reconciliation ledger account.
+//
+// Pools are replayed in full: from the reserve changes they took outside trades
+// (funding, liquidity, ingested events and earlier repairs) plus their recorded
+// transactions, and the market's trades the service cannot know of yet. Positions
+// also move through sets, liquidity and orders the service never sees, so their
+// replay is relative: the expected value is the stored one shifted by how much the
+// recorded transactions differ from the market's own trades.
+func (s *Service) Reconcile(ctx context.Context, marketID string, repair bool) (_ *models.ReconciliationReport, err error) {
+	ctx, span := startSpan(ctx, "Reconcile", attribute.String("market.id", marketID), attribute.Bool("repair", repair))
+	defer func() { tracing.End(span, err) }()
+
+	if s.cfg.Transactions == nil {
+		return nil, ErrNoTransactionService
+	}
+
+	var markets []models.Market
+	if marketID != "" {
+		if err := validateID("market", marketID); err != nil {
+			return nil, err
+		}
+		market, err := s.repo.GetMarket(ctx, marketID)
+		if err != nil {
+			return nil, err
+		}
+		if isFinal(market.Status) {
+			return nil, fmt.Errorf("market is %s: %w", market.Status, ErrMarketClosed)
+		}
+		markets = append(markets, *market)
+	} else {
+		for _, status := range []models.MarketStatus{models.MarketStatusActive, models.MarketStatusHalted, models.MarketStatusHidden, models.MarketStatusResolving} {
+			list, err := s.repo.ListMarkets(ctx, models.MarketFilter{Status: &status})
+			if err != nil {
+				return nil, err
+			}
+			markets = append(markets, list...)
+		}
+	}
+
+	report := &models.ReconciliationReport{Markets: make([]models.MarketReconciliation, 0, len(markets)), Timestamp: time.Now()}
+	for i := range markets {
+		rec, err := s.reconcileMarket(ctx, &markets[i], repair)
+		if err != nil {
+			return nil, fmt.Errorf("market %s: %w", markets[i].ID, err)
+		}
+		for _, p := range rec.Pools {
+			report.Discrepancies++
+			report.Repaired = report.Repaired || p.Repaired
+		}
+		for _, p := range rec.Positions {
+			report.Discrepancies++
+			report.Repaired = report.Repaired || p.Repaired
+		}
+		report.Markets = append(report.Markets, *rec)
+	}
+	return report, nil
+}
+
+// positionKey identifies a position by the transaction service's user UUID
+type positionKey struct {
+	user   string
+	option string
+}
+
+// replay accumulates how transactions move a market's pools and positions
+type replay struct {
+	poolUser  string
+	pools     []models.LiquidityPool
+	reserves  map[string]float64
+	positions map[positionKey]float64
+}
+
+// addReserves applies t to the reserves if the pool is a party to it. A pool
+// buying shares pays for them by burning complete sets, so every reserve falls by
+// the price paid and the option's rises by the shares; selling does the reverse.
+func (r *replay) addReserves(t txservice.Transaction) {
+	if txservice.UserUUID(t.UserID) != r.poolUser {
+		return
+	}
+	shares := t.Shares
+	if t.Type == txservice.TypeSell {
+		shares = -shares
+	}
+	for _, pool := range r.pools {
+		r.reserves[pool.OptionID] -= shares * t.PricePerShare
+	}
+	r.reserves[t.OptionID] += shares
+}
+
+// addPosition applies t to its user's position, or takes it back when sign is -1,
+// unless the user is the pool
+func (r *replay) addPosition(t txservice.Transaction, sign float64) {
+	user := txservice.UserUUID(t.UserID)
+	if user == r.poolUser {
+		return
+	}
+	shares := sign * t.Shares
+	if t.Type == txservice.TypeSell {
+		shares = -shares
+	}
+	r.positions[positionKey{user, t.OptionID}] += shares
+}
+
+func (s *Service) reconcileMarket(ctx context.Context, market *models.Market, repair bool) (*models.MarketReconciliation, error) {
+	// Trades recorded after the transactions were fetched are not in them
+	cutoff := time.Now()
+	transactions, err := s.cfg.Transactions.List(ctx, market.ID)
+	if err != nil {
+		return nil, fmt.Errorf("list transactions: %w", err)
+	}
+	state, err := s.repo.ReadReconciliationState(ctx, market.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	c := compareMarket(market, state, transactions, cutoff, repair)
+	if len(c.poolChanges) == 0 && len(c.positionChanges) == 0 {
+		return c.rec, nil
+	}
+
+	// Shares added or removed are valued at the market's prices: the pool's against
+	// its account, users' against the collateral behind them
+	repairID := uuid.New().String()
+	now := time.Now()
+	var ledger []models.LedgerEntry
+	for _, e := range []struct {
+		account string
+		value   float64
+	}{
+		{poolAccount(market.ID), c.poolRepair},
+		{collateralAccount(market.ID), c.positionRepair},
+	} {
+		if e.value == 0 {
+			continue
+		}
+		ledger = append(ledger,
+			ledgerEntry(e.account, market.ID, models.LedgerKindReconciliation, e.value, repairID, now),
+			ledgerEntry(models.LedgerAccountReconciliation, market.ID, models.LedgerKindReconciliation, -e.value, repairID, now),
+		)
+	}
+
+	pools, err := s.repo.ApplyReconciliation(ctx, market.ID, repairID, c.poolChanges, c.positionChanges, ledger)
+	if err != nil {
+		return nil, fmt.Errorf("apply reconciliation: %w", err)
+	}
+	s.logger.InfoContext(ctx, "market reconciled with the transaction service",
+		"market_id", market.ID, "repair_id", repairID, "pools", len(c.poolChanges), "positions", len(c.positionChanges),
+		"pool_value", c.poolRepair, "position_value", c.positionRepair)
+	if len(c.poolChanges) > 0 {
+		if err := s.publishLiquidityUpdate(ctx, market.ID, pools); err != nil {
+			s.logger.WarnContext(ctx, "failed to publish liquidity update", "market_id", market.ID, "error", err)
+		}
+	}
+	return c.rec, nil
+}
+
+// comparison is what comparing a market with its recorded transactions found:
+// the discrepancies and, when repairing, the changes that correct them and the
+// value of the shares they add to the pool and to users
+type comparison struct {
+	rec             *models.MarketReconciliation
+	poolChanges     map[string]float64
+	positionChanges []repository.PositionChange
+	poolRepair      float64
+	positionRepair  float64
+}
+
+// compareMarket replays the transactions recorded for market before cutoff over
+// its state
+func compareMarket(market *models.Market, state *repository.ReconciliationState, transactions []txservice.Transaction, cutoff time.Time, repair bool) *comparison {
+	rec := &models.MarketReconciliation{MarketID: market.ID, Status: market.Status, Transactions: len(transactions)}
+	r := &replay{
+		poolUser:  txservice.UserUUID(poolAccount(market.ID)),
+		pools:     state.Pools,
+		reserves:  map[string]float64{},
+		positions: map[positionKey]float64{},
+	}
+	for optionID, change := range state.ReserveChanges {
+		r.reserves[optionID] = change
+	}
+
+	// The market's own trades are taken back from the positions, leaving what the
+	// service differs by. Trades the service does not have yet still moved the pool.
+	expected := map[string]txservice.Transaction{}
+	exact := map[string]txservice.Transaction{}
+	excluded := map[string]bool{}
+	users := map[string]string{}
+	for i := range state.Trades {
+		record := &state.Trades[i]
+		if record.RecordedAt == nil && !record.Rejected {
+			rec.PendingTrades++
+		}
+		for _, t := range tradeTransactions(&record.Trade) {
+			users[txservice.UserUUID(t.UserID)] = t.UserID
+			if record.RecordedAt == nil || record.RecordedAt.After(cutoff) {
+				excluded[t.ID] = true
+				r.addReserves(t)
+				continue
+			}
+			exact[t.ID] = t
+			// The service stores amounts to four decimals
+			t.Shares = roundAmount(t.Shares)
+			t.PricePerShare = roundAmount(t.PricePerShare)
+			expected[t.ID] = t
+			r.addPosition(t, -1)
+		}
+	}
+
+	// The service's record stands, replayed at the trade's exact amounts where it
+	// agrees with them
+	seen := map[string]bool{}
+	for _, t := range transactions {
+		if excluded[t.ID] {
+			continue
+		}
+		want, ok := expected[t.ID]
+		switch {
+		case !ok:
+			rec.UnexpectedTransactions = append(rec.UnexpectedTransactions, t.ID)
+			r.addReserves(t)
+		case !sameTransaction(t, want):
+			rec.MismatchedTransactions = append(rec.MismatchedTransactions, t.ID)
+			r.addReserves(t)
+		default:
+			r.addReserves(exact[t.ID])
+		}
+		seen[t.ID] = true
+		r.addPosition(t, 1)
+	}
+	for id := range expected {
+		if !seen[id] {
+			rec.MissingTransactions = append(rec.MissingTransactions, id)
+		}
+	}
+	sort.Strings(rec.MissingTransactions)
+
+	prices := pricing.OptionPrices(state.Pools)
+	var poolRepair, positionRepair float64
+	poolChanges := map[string]float64{}
+	for _, pool := range state.Pools {
+		diff := r.reserves[pool.OptionID] - pool.PoolValue
+		if math.Abs(diff) <= reconcileTolerance {
+			continue
+		}
+		d := models.PoolDiscrepancy{
+			OptionID:   pool.OptionID,
+			PoolValue:  pool.PoolValue,
+			Expected:   r.reserves[pool.OptionID],
+			Difference: diff,
+		}
+		if repair && d.Expected >= 0 {
+			poolChanges[pool.OptionID] = diff
+			poolRepair += diff * prices[pool.OptionID]
+			d.Repaired = true
+		}
+		rec.Pools = append(rec.Pools, d)
+	}
+
+	held := map[positionKey]float64{}
+	for _, p := range state.Positions {
+		held[positionKey{p.UserID, p.OptionID}] = p.Shares
+	}
+	var positionChanges []repository.PositionChange
+	for key, diff := range r.positions {
+		if math.Abs(diff) <= reconcileTolerance {
+			continue
+		}
+		d := models.PositionDiscrepancy{UserID: key.user, OptionID: key.option, Difference: diff}
+		userID, known := users[key.user]
+		if known {
+			d.UserID = userID
+			d.Shares = held[positionKey{userID, key.option}]
+		}
+		d.Expected = d.Shares + diff
+		if repair && known && d.Expected >= 0 {
+			positionChanges = append(positionChanges, repository.PositionChange{
+				UserID:   userID,
+				MarketID: market.ID,
+				OptionID: key.option,
+				Shares:   diff,
+			})
+			positionRepair += diff * prices[key.option]
+			d.Repaired = true
+		}
+		rec.Positions = append(rec.Positions, d)
+	}
+	sort.Slice(rec.Positions, func(i, j int) bool {
+		if rec.Positions[i].UserID != rec.Positions[j].UserID {
+			return rec.Positions[i].UserID < rec.Positions[j].UserID
+		}
+		return rec.Positions[i].OptionID < rec.Positions[j].OptionID
+	})
+
+	return &comparison{
+		rec:             rec,
+		poolChanges:     poolChanges,
+		positionChanges: positionChanges,
+		poolRepair:      poolRepair,
+		positionRepair:  positionRepair,
+	}
+}
+
+// sameTransaction reports whether a recorded transaction matches the one expected
+func sameTransaction(got, want txservice.Transaction) bool {
+	return txservice.UserUUID(got.UserID) == txservice.UserUUID(want.UserID) &&
+		got.OptionID == want.OptionID &&
+		got.Type == want.Type &&
+		math.Abs(got.Shares-want.Shares) <= reconcileTolerance &&
+		math.Abs(got.PricePerShare-want.PricePerShare) <= reconcileTolerance
+}
+
+// roundAmount rounds to the four decimals the transaction service keeps
+func roundAmount(x float64) float64 {
+	return math.Round(x*1e4) / 1e4
+}
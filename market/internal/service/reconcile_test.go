@@ -0,0 +1,157 @@
+package service
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+	"github.com/ec332/aegis/market/internal/repository"
+	"github.com/ec332/aegis/market/internal/txservice"
+	"github.com/ec332/aegis/market/pkg/models"
+)
+
+func TestCompareMarket(t *testing.T) {
+	// Funded at 100 a side, the pool sold alice 10 yes at 0.5: the 5 she paid
+	// went into both reserves and the 10 shares came out of yes
+	trade := models.Trade{
+		ID:        "3d6f0a8e-1b2c-4d5e-9f60-718293a4b5c6",
+		MarketID:  "market-1",
+		OptionID:  "opt-yes",
+		UserID:    "alice",
+		Side:      models.OrderSideBuy,
+		Venue:     models.TradeVenuePool,
+		Shares:    10,
+		Price:     0.5,
+		CreatedAt: matchTime,
+	}
+	txs := tradeTransactions(&trade)
+	taker, counter := txs[0], txs[1]
+	short := taker
+	short.Shares = 9
+	stray := txservice.Transaction{ID: "stray", UserID: "bob", MarketID: "market-1", OptionID: "opt-yes", Type: txservice.TypeBuy, Shares: 5, PricePerShare: 0.5}
+
+	recorded := matchTime.Add(time.Minute)
+	late := matchTime.Add(time.Hour)
+	cutoff := matchTime.Add(30 * time.Minute)
+	tests := []struct {
+		name         string
+		pools        []models.LiquidityPool
+		recordedAt   *time.Time
+		transactions []txservice.Transaction
+		pending      int
+		missing      []string
+		unexpected   []string
+		mismatched   []string
+		// reserves and positions map what differs to the difference
+		reserves  map[string]float64
+		positions map[string]float64
+	}{
+		{
+			name:         "recorded as traded",
+			pools:        binaryPools(95, 105),
+			recordedAt:   &recorded,
+			transactions: txs,
+		},
+		{
+			name:         "pool drifted",
+			pools:        binaryPools(95, 110),
+			recordedAt:   &recorded,
+			transactions: txs,
+			reserves:     map[string]float64{"opt-no": -5},
+		},
+		{
+			name:    "pending trade still moved the pool",
+			pools:   binaryPools(95, 105),
+			pending: 1,
+		},
+		{
+			name:         "recorded after the cutoff",
+			pools:        binaryPools(95, 105),
+			recordedAt:   &late,
+			transactions: txs,
+		},
+		{
+			name:         "pool's side missing",
+			pools:        binaryPools(95, 105),
+			recordedAt:   &recorded,
+			transactions: []txservice.Transaction{taker},
+			missing:      []string{counter.ID},
+			reserves:     map[string]float64{"opt-yes": 5, "opt-no": -5},
+		},
+		{
+			name:         "shares recorded short",
+			pools:        binaryPools(95, 105),
+			recordedAt:   &recorded,
+			transactions: []txservice.Transaction{short, counter},
+			mismatched:   []string{taker.ID},
+			positions:    map[string]float64{"alice": -1},
+		},
+		{
+			name:         "transaction for no trade",
+			pools:        binaryPools(95, 105),
+			recordedAt:   &recorded,
+			transactions: append([]txservice.Transaction{stray}, txs...),
+			unexpected:   []string{"stray"},
+			positions:    map[string]float64{txservice.UserUUID("bob"): 5},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := &repository.ReconciliationState{
+				Pools:          tt.pools,
+				ReserveChanges: map[string]float64{"opt-yes": 100, "opt-no": 100},
+				Trades:         []repository.TradeRecord{{Trade: trade, RecordedAt: tt.recordedAt}},
+				Positions:      []models.Position{{UserID: "alice", MarketID: "market-1", OptionID: "opt-yes", Shares: 10}},
+			}
+			market := &models.Market{ID: "market-1", Status: models.MarketStatusActive}
+			c := compareMarket(market, state, tt.transactions, cutoff, true)
+			rec := c.rec
+
+			if rec.PendingTrades != tt.pending {
+				t.Errorf("pending trades = %d, want %d", rec.PendingTrades, tt.pending)
+			}
+			for _, ids := range []struct {
+				kind      string
+				got, want []string
+			}{
+				{"missing", rec.MissingTransactions, tt.missing},
+				{"unexpected", rec.UnexpectedTransactions, tt.unexpected},
+				{"mismatched", rec.MismatchedTransactions, tt.mismatched},
+			} {
+				if len(ids.got) != 0 || len(ids.want) != 0 {
+					if !reflect.DeepEqual(ids.got, ids.want) {
+						t.Errorf("%s transactions = %v, want %v", ids.kind, ids.got, ids.want)
+					}
+				}
+			}
+
+			if len(rec.Pools) != len(tt.reserves) {
+				t.Errorf("pool discrepancies = %+v, want %v", rec.Pools, tt.reserves)
+			}
+			for _, d := range rec.Pools {
+				if want, ok := tt.reserves[d.OptionID]; !ok || math.Abs(d.Difference-want) > 1e-9 {
+					t.Errorf("option %s reserve differs by %g, want %g", d.OptionID, d.Difference, want)
+				}
+				if !d.Repaired || math.Abs(c.poolChanges[d.OptionID]-d.Difference) > 1e-9 {
+					t.Errorf("option %s reserve repaired by %g, want %g", d.OptionID, c.poolChanges[d.OptionID], d.Difference)
+				}
+			}
+
+			if len(rec.Positions) != len(tt.positions) {
+				t.Errorf("position discrepancies = %+v, want %v", rec.Positions, tt.positions)
+			}
+			for _, d := range rec.Positions {
+				if want, ok := tt.positions[d.UserID]; !ok || math.Abs(d.Difference-want) > 1e-9 {
+					t.Errorf("%s's position differs by %g, want %g", d.UserID, d.Difference, want)
+				}
+				// Only users the market knows are repaired
+				if known := d.UserID == "alice"; d.Repaired != known {
+					t.Errorf("%s's position repaired = %v, want %v", d.UserID, d.Repaired, known)
+				}
+			}
+			if len(c.positionChanges) > 1 {
+				t.Errorf("position changes = %+v, want at most alice's", c.positionChanges)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"github.com/ec332/aegis/market/internal/health"
+	"github.com/ec332/aegis/market/internal/metrics"
+	"github.com/ec332/aegis/market/internal/tracing"
+	"github.com/ec332/aegis/market/internal/txservice"
+	"github.com/ec332/aegis/market/pkg/models"
+	"github.com/google/uuid"
+)
+
+// recordBatchSize bounds the trades sent to the transaction service per pass
+const recordBatchSize = 100
+
+// RunTradeRecorder sends executed trades to the transaction service every interval
+// until ctx is done. Trades wait in the database until they are recorded, so none
+// are lost while the service is down.
+func (s *Service) RunTradeRecorder(ctx context.Context, interval time.Duration, hb *health.Heartbeat) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		hb.Beat()
+		if n, err := s.RecordTrades(ctx); err != nil {
+			s.logger.WarnContext(ctx, "failed to record trades", "recorded", n, "error", err)
+		} else if n > 0 {
+			s.logger.DebugContext(ctx, "trades recorded", "count", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RecordTrades sends the trades not yet recorded to the transaction service, oldest
+// first, and returns how many were recorded. Each trade is recorded as a transaction
+// for its taker and one for its counterparty. The pass stops at the first trade the
+// service fails on, to be retried next pass; trades it rejects are set aside.
+func (s *Service) RecordTrades(ctx context.Context) (_ int, err error) {
+	ctx, span := startSpan(ctx, "RecordTrades")
+	defer func() { tracing.End(span, err) }()
+
+	if s.cfg.Transactions == nil {
+		return 0, nil
+	}
+	trades, err := s.repo.ListUnrecordedTrades(ctx, recordBatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("list unrecorded trades: %w", err)
+	}
+
+	recorded := 0
+	for i := range trades {
+		err := s.recordTrade(ctx, &trades[i])
+		switch {
+		case errors.Is(err, txservice.ErrRejected):
+			metrics.TradeRecords.WithLabelValues("rejected").Inc()
+			s.logger.ErrorContext(ctx, "transaction service rejected trade", "trade_id", trades[i].ID, "error", err)
+			if err := s.repo.MarkTradeRejected(ctx, trades[i].ID, err.Error()); err != nil {
+				return recorded, err
+			}
+		case err != nil:
+			metrics.TradeRecords.WithLabelValues("failed").Inc()
+			return recorded, fmt.Errorf("trade %s: %w", trades[i].ID, err)
+		default:
+			metrics.TradeRecords.WithLabelValues("recorded").Inc()
+			recorded++
+		}
+	}
+	return recorded, nil
+}
+
+func (s *Service) recordTrade(ctx context.Context, trade *models.Trade) error {
+	if err := s.sendTrade(ctx, trade); err != nil {
+		return err
+	}
+	return s.repo.MarkTradeRecorded(ctx, trade.ID, time.Now())
+}
+
+// sendTrade creates a trade's transactions. Those created by an earlier pass that
+// failed part way are found rather than sent twice.
+func (s *Service) sendTrade(ctx context.Context, trade *models.Trade) error {
+	for _, t := range tradeTransactions(trade) {
+		if _, err := s.cfg.Transactions.Create(ctx, t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tradeTransactions splits a trade into its taker's transaction, which shares the
+// trade's ID, and its counterparty's: the maker of a book trade or the market's
+// pool account for a pool trade
+func tradeTransactions(trade *models.Trade) []txservice.Transaction {
+	takerType, counterType := txservice.TypeBuy, txservice.TypeSell
+	if trade.Side == models.OrderSideSell {
+		takerType, counterType = counterType, takerType
+	}
+	counterparty := poolAccount(trade.MarketID)
+	if trade.MakerUserID != nil {
+		counterparty = *trade.MakerUserID
+	}
+
+	taker := txservice.Transaction{
+		ID:            trade.ID,
+		UserID:        trade.UserID,
+		MarketID:      trade.MarketID,
+		OptionID:      trade.OptionID,
+		Type:          takerType,
+		Shares:        trade.Shares,
+		PricePerShare: trade.Price,
+		CreatedAt:     trade.CreatedAt,
+	}
+	counter := taker
+	counter.ID = uuid.NewSHA1(uuid.MustParse(trade.ID), []byte("counterparty")).String()
+	counter.UserID = counterparty
+	counter.Type = counterType
+	return []txservice.Transaction{taker, counter}
+}
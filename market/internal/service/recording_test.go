@@ -0,0 +1,122 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+	"github.com/ec332/aegis/market/internal/txservice"
+	"github.com/ec332/aegis/market/internal/txservice/txstub"
+	"github.com/ec332/aegis/market/pkg/models"
+	"github.com/go-chi/chi/v5"
+)
+
+func bookTrade(side models.OrderSide) *models.Trade {
+	maker := "bob"
+	return &models.Trade{
+		ID:          "3d6f0a8e-1b2c-4d5e-9f60-718293a4b5c6",
+		MarketID:    "0b7e8f6a-2c1d-4e5f-8a9b-1c2d3e4f5a6b",
+		OptionID:    "9a8b7c6d-5e4f-4a3b-8c2d-1e0f9a8b7c6d",
+		UserID:      "alice",
+		Side:        side,
+		Venue:       models.TradeVenueBook,
+		MakerUserID: &maker,
+		Shares:      10,
+		Price:       0.6,
+		CreatedAt:   time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestTradeTransactions(t *testing.T) {
+	pool := bookTrade(models.OrderSideSell)
+	pool.Venue, pool.MakerUserID = models.TradeVenuePool, nil
+
+	tests := []struct {
+		name         string
+		trade        *models.Trade
+		takerType    string
+		counterparty string
+		counterType  string
+	}{
+		{name: "book buy", trade: bookTrade(models.OrderSideBuy), takerType: txservice.TypeBuy, counterparty: "bob", counterType: txservice.TypeSell},
+		{name: "pool sell", trade: pool, takerType: txservice.TypeSell, counterparty: poolAccount(pool.MarketID), counterType: txservice.TypeBuy},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			txs := tradeTransactions(tt.trade)
+			if len(txs) != 2 {
+				t.Fatalf("got %d transactions, want 2", len(txs))
+			}
+			taker, counter := txs[0], txs[1]
+			if taker.ID != tt.trade.ID || taker.UserID != "alice" || taker.Type != tt.takerType {
+				t.Errorf("taker = %+v, want %s by alice under the trade ID", taker, tt.takerType)
+			}
+			if counter.UserID != tt.counterparty || counter.Type != tt.counterType {
+				t.Errorf("counterparty = %+v, want %s by %s", counter, tt.counterType, tt.counterparty)
+			}
+			if counter.ID == taker.ID || counter.ID != tradeTransactions(tt.trade)[1].ID {
+				t.Errorf("counterparty ID %s must differ from the taker's and be fixed", counter.ID)
+			}
+			if counter.Shares != 10 || counter.PricePerShare != 0.6 || !counter.CreatedAt.Equal(tt.trade.CreatedAt) {
+				t.Errorf("counterparty = %+v, want the trade's shares, price and time", counter)
+			}
+		})
+	}
+}
+
+// A pass that records the taker and then fails on the counterparty must not
+// leave the trade stuck: with no retries the next pass finds the taker recorded
+func TestSendTradeAfterCounterpartyFailure(t *testing.T) {
+	trade := bookTrade(models.OrderSideBuy)
+	counterID := tradeTransactions(trade)[1].ID
+
+	var failures atomic.Int32
+	failures.Store(1)
+	store := txstub.New()
+	r := chi.NewRouter()
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodPost {
+				body, err := io.ReadAll(r.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				r.Body = io.NopCloser(bytes.NewReader(body))
+				if bytes.Contains(body, []byte(counterID)) && failures.Add(-1) >= 0 {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+	store.Routes(r)
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	s := &Service{cfg: Config{Transactions: txservice.New(txservice.Config{URL: srv.URL, Timeout: time.Second})}}
+	ctx := context.Background()
+
+	err := s.sendTrade(ctx, trade)
+	if err == nil || errors.Is(err, txservice.ErrRejected) {
+		t.Fatalf("first pass error = %v, want the service's failure", err)
+	}
+	if n := store.Len(); n != 1 {
+		t.Fatalf("stub holds %d transactions after the first pass, want the taker's", n)
+	}
+
+	// Once more in case marking the trade recorded failed after the second pass
+	for pass := 2; pass <= 3; pass++ {
+		if err := s.sendTrade(ctx, trade); err != nil {
+			t.Fatalf("pass %d error = %v", pass, err)
+		}
+		if n := store.Len(); n != 2 {
+			t.Fatalf("stub holds %d transactions after pass %d, want 2", n, pass)
+		}
+	}
+}
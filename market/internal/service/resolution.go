@@ -0,0 +1,389 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"github.com/ec332/aegis/market/internal/middleware"
+	"github.com/ec332/aegis/market/internal/repository"
+	"github.com/ec332/aegis/market/internal/tracing"
+	"github.com/ec332/aegis/market/pkg/models"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	maxEvidenceLength      = 20000
+	maxDisputeReasonLength = 5000
+)
+
+// ProposeResolution proposes the outcome of a resolving market, opening the dispute
+// window. Proposals need the resolver role and a market has one pending at a time.
+func (s *Service) ProposeResolution(ctx context.Context, marketID string, req models.ProposeResolutionRequest) (_ *models.ResolutionProposal, err error) {
+	ctx, span := startSpan(ctx, "ProposeResolution", attribute.String("market.id", marketID))
+	defer func() { tracing.End(span, err) }()
+
+	p, err := requireRole(ctx, middleware.RoleResolver)
+	if err != nil {
+		return nil, err
+	}
+	market, err := s.resolvingMarket(ctx, marketID)
+	if err != nil {
+		return nil, err
+	}
+
+	verr := &ValidationError{}
+	validateText(verr, "evidence", req.Evidence, maxEvidenceLength, true)
+	if err := verr.err(); err != nil {
+		return nil, err
+	}
+	if err := s.validateOutcome(market, req.WinningOptionID, req.ResolvedValue); err != nil {
+		return nil, err
+	}
+
+	return s.createProposal(ctx, market, p.UserID, req.WinningOptionID, req.ResolvedValue, req.Evidence)
+}
+
+// createProposal records a validated outcome for market, opening its dispute window
+func (s *Service) createProposal(ctx context.Context, market *models.Market, proposerID string, winningOptionID *string, resolvedValue *float64, evidence string) (*models.ResolutionProposal, error) {
+	now := time.Now()
+	proposal := &models.ResolutionProposal{
+		ID:              uuid.New().String(),
+		MarketID:        market.ID,
+		ProposerID:      proposerID,
+		WinningOptionID: winningOptionID,
+		ResolvedValue:   resolvedValue,
+		Evidence:        evidence,
+		Status:          models.ProposalStatusOpen,
+		DisputeDeadline: now.Add(s.cfg.DisputeWindow),
+		Disputes:        []models.ResolutionDispute{},
+		CreatedAt:       now,
+	}
+
+	if err := s.repo.CreateResolutionProposal(ctx, proposal); err != nil {
+		if errors.Is(err, ErrConflict) {
+			return nil, fmt.Errorf("%w: market already has a pending resolution proposal", ErrConflict)
+		}
+		return nil, fmt.Errorf("create resolution proposal: %w", err)
+	}
+
+	s.logger.InfoContext(ctx, "resolution proposed",
+		"market_id", market.ID,
+		"proposal_id", proposal.ID,
+		"proposer_id", proposerID,
+		"dispute_deadline", proposal.DisputeDeadline,
+	)
+	return proposal, nil
+}
+
+// DisputeResolution challenges the market's pending proposal before its deadline,
+// staking the configured bond. Disputed proposals wait for arbitration.
+func (s *Service) DisputeResolution(ctx context.Context, marketID string, req models.DisputeResolutionRequest) (_ *models.ResolutionProposal, err error) {
+	ctx, span := startSpan(ctx, "DisputeResolution", attribute.String("market.id", marketID))
+	defer func() { tracing.End(span, err) }()
+
+	p := middleware.PrincipalFromContext(ctx)
+	if p.UserID == "" {
+		return nil, ErrUnauthenticated
+	}
+
+	verr := &ValidationError{}
+	validateText(verr, "reason", req.Reason, maxDisputeReasonLength, true)
+	if err := verr.err(); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.resolvingMarket(ctx, marketID); err != nil {
+		return nil, err
+	}
+	proposal, err := s.repo.GetPendingProposal(ctx, marketID)
+	if err != nil {
+		return nil, err
+	}
+	if proposal.ProposerID == p.UserID {
+		return nil, fmt.Errorf("%w: proposers cannot dispute their own proposal", ErrForbidden)
+	}
+
+	now := time.Now()
+	if !now.Before(proposal.DisputeDeadline) {
+		return nil, fmt.Errorf("%w: dispute window closed at %s", ErrInvalidTransition,
+			proposal.DisputeDeadline.Format(time.RFC3339))
+	}
+
+	dispute := &models.ResolutionDispute{
+		ID:         uuid.New().String(),
+		ProposalID: proposal.ID,
+		DisputerID: p.UserID,
+		Reason:     req.Reason,
+		Bond:       s.cfg.DisputeBond,
+		Status:     models.DisputeStatusPending,
+		CreatedAt:  now,
+	}
+	if err := s.repo.AddResolutionDispute(ctx, dispute); err != nil {
+		return nil, fmt.Errorf("add resolution dispute: %w", err)
+	}
+
+	s.logger.InfoContext(ctx, "resolution disputed",
+		"market_id", marketID,
+		"proposal_id", proposal.ID,
+		"disputer_id", p.UserID,
+		"bond", dispute.Bond,
+	)
+	return s.repo.GetPendingProposal(ctx, marketID)
+}
+
+// ArbitrateResolution settles a disputed proposal and resolves the market with the
+// admin's outcome. Upholding the proposal forfeits the dispute bonds; overturning it
+// returns them and records the ruling as the finalized proposal.
+func (s *Service) ArbitrateResolution(ctx context.Context, marketID string, req models.ArbitrateResolutionRequest) (_ *models.Market, err error) {
+	ctx, span := startSpan(ctx, "ArbitrateResolution", attribute.String("market.id", marketID))
+	defer func() { tracing.End(span, err) }()
+
+	p, err := requireRole(ctx, middleware.RoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+	market, err := s.resolvingMarket(ctx, marketID)
+	if err != nil {
+		return nil, err
+	}
+
+	verr := &ValidationError{}
+	validateText(verr, "reason", req.Reason, maxEvidenceLength, true)
+	if err := verr.err(); err != nil {
+		return nil, err
+	}
+	if err := s.validateOutcome(market, req.WinningOptionID, req.ResolvedValue); err != nil {
+		return nil, err
+	}
+
+	proposal, err := s.repo.GetPendingProposal(ctx, marketID)
+	if err != nil {
+		return nil, err
+	}
+	if proposal.Status != models.ProposalStatusDisputed {
+		return nil, fmt.Errorf("%w: only disputed proposals go to arbitration, proposal is %s",
+			ErrInvalidTransition, proposal.Status)
+	}
+
+	settlement := &repository.ProposalSettlement{
+		ProposalID:    proposal.ID,
+		From:          []models.ProposalStatus{models.ProposalStatusDisputed},
+		Status:        models.ProposalStatusFinalized,
+		DisputeStatus: models.DisputeStatusRejected,
+	}
+	if !sameOutcome(proposal, req.WinningOptionID, req.ResolvedValue) {
+		now := time.Now()
+		settlement.Status = models.ProposalStatusOverturned
+		settlement.DisputeStatus = models.DisputeStatusUpheld
+		settlement.Ruling = &models.ResolutionProposal{
+			ID:              uuid.New().String(),
+			MarketID:        marketID,
+			ProposerID:      p.UserID,
+			WinningOptionID: req.WinningOptionID,
+			ResolvedValue:   req.ResolvedValue,
+			Evidence:        req.Reason,
+			Status:          models.ProposalStatusFinalized,
+			DisputeDeadline: now,
+			CreatedAt:       now,
+			ClosedAt:        &now,
+		}
+	}
+
+	outcome := models.UpdateMarketRequest{WinningOptionID: req.WinningOptionID, ResolvedValue: req.ResolvedValue}
+	return s.resolveMarket(ctx, market, outcome, settlement)
+}
+
+// FinalizeResolution resolves the market with its undisputed proposal once the
+// dispute window has closed
+func (s *Service) FinalizeResolution(ctx context.Context, marketID string) (_ *models.Market, err error) {
+	ctx, span := startSpan(ctx, "FinalizeResolution", attribute.String("market.id", marketID))
+	defer func() { tracing.End(span, err) }()
+
+	market, err := s.resolvingMarket(ctx, marketID)
+	if err != nil {
+		return nil, err
+	}
+	proposal, err := s.repo.GetPendingProposal(ctx, marketID)
+	if err != nil {
+		return nil, err
+	}
+	return s.finalizeProposal(ctx, market, proposal)
+}
+
+// FinalizeDueResolutions finalizes every undisputed proposal whose window has closed
+// and returns how many markets were resolved
+func (s *Service) FinalizeDueResolutions(ctx context.Context) (_ int, err error) {
+	ctx, span := startSpan(ctx, "FinalizeDueResolutions")
+	defer func() { tracing.End(span, err) }()
+
+	proposals, err := s.repo.ListDueProposals(ctx, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("list due proposals: %w", err)
+	}
+
+	finalized := 0
+	var errs []error
+	for i := range proposals {
+		proposal := &proposals[i]
+		market, err := s.repo.GetMarket(ctx, proposal.MarketID)
+		if err == nil {
+			_, err = s.finalizeProposal(ctx, market, proposal)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("market %s: %w", proposal.MarketID, err))
+			continue
+		}
+		finalized++
+	}
+
+	return finalized, errors.Join(errs...)
+}
+
+// ListResolutionProposals retrieves a market's proposals and disputes, oldest first
+func (s *Service) ListResolutionProposals(ctx context.Context, marketID string) (_ []models.ResolutionProposal, err error) {
+	ctx, span := startSpan(ctx, "ListResolutionProposals", attribute.String("market.id", marketID))
+	defer func() { tracing.End(span, err) }()
+
+	if err := validateID("market", marketID); err != nil {
+		return nil, err
+	}
+	if _, err := s.repo.GetMarket(ctx, marketID); err != nil {
+		return nil, err
+	}
+
+	return s.repo.ListResolutionProposals(ctx, marketID)
+}
+
+// finalizeProposal resolves market with proposal once its dispute window is over
+func (s *Service) finalizeProposal(ctx context.Context, market *models.Market, proposal *models.ResolutionProposal) (*models.Market, error) {
+	if proposal.Status != models.ProposalStatusOpen {
+		return nil, fmt.Errorf("%w: proposal is %s and awaits arbitration", ErrInvalidTransition, proposal.Status)
+	}
+	if time.Now().Before(proposal.DisputeDeadline) {
+		return nil, fmt.Errorf("%w: dispute window is open until %s", ErrInvalidTransition,
+			proposal.DisputeDeadline.Format(time.RFC3339))
+	}
+
+	settlement := &repository.ProposalSettlement{
+		ProposalID:    proposal.ID,
+		From:          []models.ProposalStatus{models.ProposalStatusOpen},
+		Status:        models.ProposalStatusFinalized,
+		DisputeStatus: models.DisputeStatusReturned,
+	}
+	outcome := models.UpdateMarketRequest{WinningOptionID: proposal.WinningOptionID, ResolvedValue: proposal.ResolvedValue}
+	return s.resolveMarket(ctx, market, outcome, settlement)
+}
+
+// resolveMarket moves market to resolved with outcome, records settlement payouts
+// and closes the proposal, then settles markets conditional on it
+func (s *Service) resolveMarket(ctx context.Context, market *models.Market, outcome models.UpdateMarketRequest, settlement *repository.ProposalSettlement) (*models.Market, error) {
+	status := models.MarketStatusResolved
+	outcome.Status = &status
+	if err := s.validateStatusTransition(market.Status, status); err != nil {
+		return nil, err
+	}
+	payouts, err := s.resolutionPayouts(market, outcome)
+	if err != nil {
+		return nil, err
+	}
+
+	update := repository.MarketUpdate{
+		UpdateMarketRequest: outcome,
+		Payouts:             payouts,
+		EditedBy:            middleware.PrincipalFromContext(ctx).UserID,
+		Settlement:          settlement,
+	}
+	if err := s.repo.UpdateMarket(ctx, market.ID, update); err != nil {
+		return nil, fmt.Errorf("resolve market: %w", err)
+	}
+	s.logger.InfoContext(ctx, "market resolved",
+		"market_id", market.ID,
+		"proposal_id", settlement.ProposalID,
+		"proposal_status", settlement.Status,
+	)
+
+	resolved, err := s.repo.GetMarket(ctx, market.ID)
+	if err != nil {
+		return nil, err
+	}
+	priceMarket(resolved)
+
+	if err := s.publishLiquidityUpdate(ctx, market.ID, resolved.LiquidityPools); err != nil {
+		s.logger.WarnContext(ctx, "failed to publish market update", "market_id", market.ID, "error", err)
+	}
+	s.publishOrderBook(ctx, resolved)
+	if err := s.settleDependents(ctx, resolved); err != nil {
+		s.logger.ErrorContext(ctx, "failed to settle conditional markets", "market_id", market.ID, "error", err)
+	}
+
+	return resolved, nil
+}
+
+// cancelSettlement returns the settlement cancelling market's pending proposal when
+// it is voided, returning any dispute bonds, or nil if there is none
+func (s *Service) cancelSettlement(ctx context.Context, market *models.Market) (*repository.ProposalSettlement, error) {
+	if market.Status != models.MarketStatusResolving {
+		return nil, nil
+	}
+	proposal, err := s.repo.GetPendingProposal(ctx, market.ID)
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &repository.ProposalSettlement{
+		ProposalID:    proposal.ID,
+		From:          []models.ProposalStatus{models.ProposalStatusOpen, models.ProposalStatusDisputed},
+		Status:        models.ProposalStatusCancelled,
+		DisputeStatus: models.DisputeStatusReturned,
+	}, nil
+}
+
+// validateOutcome checks a proposed outcome against market
+func (s *Service) validateOutcome(market *models.Market, winningOptionID *string, resolvedValue *float64) error {
+	status := models.MarketStatusResolved
+	_, err := s.resolutionPayouts(market, models.UpdateMarketRequest{
+		Status:          &status,
+		WinningOptionID: winningOptionID,
+		ResolvedValue:   resolvedValue,
+	})
+	return err
+}
+
+// resolvingMarket returns the market, or ErrInvalidTransition unless it is resolving
+func (s *Service) resolvingMarket(ctx context.Context, marketID string) (*models.Market, error) {
+	if err := validateID("market", marketID); err != nil {
+		return nil, err
+	}
+	market, err := s.repo.GetMarket(ctx, marketID)
+	if err != nil {
+		return nil, err
+	}
+	if market.Status != models.MarketStatusResolving {
+		return nil, fmt.Errorf("%w: market is %s, not resolving", ErrInvalidTransition, market.Status)
+	}
+	return market, nil
+}
+
+// requireRole returns the current principal, or an error unless it holds role
+func requireRole(ctx context.Context, role string) (middleware.Principal, error) {
+	p := middleware.PrincipalFromContext(ctx)
+	if p.UserID == "" {
+		return p, ErrUnauthenticated
+	}
+	if !p.HasRole(role) {
+		return p, fmt.Errorf("%w: %s role required", ErrForbidden, role)
+	}
+	return p, nil
+}
+
+// sameOutcome reports whether proposal names the given outcome
+func sameOutcome(proposal *models.ResolutionProposal, winningOptionID *string, resolvedValue *float64) bool {
+	if winningOptionID != nil {
+		return proposal.WinningOptionID != nil && *proposal.WinningOptionID == *winningOptionID
+	}
+	return resolvedValue != nil && proposal.ResolvedValue != nil && *proposal.ResolvedValue == *resolvedValue
+}
@@ -0,0 +1,237 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"github.com/ec332/aegis/market/internal/middleware"
+	"github.com/ec332/aegis/market/internal/tracing"
+	"github.com/ec332/aegis/market/pkg/models"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const maxReviewCommentLength = 5000
+
+// SubmitMarket sends a draft market to reviewers. Only its creator or an admin may
+// submit it, and rejected markets may be resubmitted after changes.
+func (s *Service) SubmitMarket(ctx context.Context, marketID string) (_ *models.Market, err error) {
+	ctx, span := startSpan(ctx, "SubmitMarket", attribute.String("market.id", marketID))
+	defer func() { tracing.End(span, err) }()
+
+	p := middleware.PrincipalFromContext(ctx)
+	if p.UserID == "" {
+		return nil, ErrUnauthenticated
+	}
+	market, err := s.reviewableMarket(ctx, marketID)
+	if err != nil {
+		return nil, err
+	}
+	if p.UserID != market.CreatedBy && !p.HasRole(middleware.RoleAdmin) {
+		return nil, fmt.Errorf("%w: only the market's creator can submit it for review", ErrForbidden)
+	}
+	if market.ReviewState == models.ReviewStatePending || market.ReviewState == models.ReviewStateApproved {
+		return nil, fmt.Errorf("%w: market is already %s", ErrInvalidTransition, market.ReviewState)
+	}
+
+	if err := s.addReview(ctx, market.ID, models.ReviewActionSubmit, "", models.ReviewStatePending); err != nil {
+		return nil, err
+	}
+
+	return s.GetMarket(ctx, marketID)
+}
+
+// ReviewMarket records a comment, approval or rejection on a draft market. Approving
+// and rejecting need the reviewer role and are not open to the market's creator; the
+// market is approved once enough distinct reviewers have approved it.
+func (s *Service) ReviewMarket(ctx context.Context, marketID string, req models.CreateReviewRequest) (_ *models.Market, err error) {
+	ctx, span := startSpan(ctx, "ReviewMarket",
+		attribute.String("market.id", marketID),
+		attribute.String("review.action", string(req.Action)),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	verr := &ValidationError{}
+	switch req.Action {
+	case models.ReviewActionComment, models.ReviewActionReject:
+		validateText(verr, "comment", req.Comment, maxReviewCommentLength, true)
+	case models.ReviewActionApprove:
+		validateText(verr, "comment", req.Comment, maxReviewCommentLength, false)
+	default:
+		verr.add("action", "must be comment, approve or reject")
+	}
+	if err := verr.err(); err != nil {
+		return nil, err
+	}
+
+	p := middleware.PrincipalFromContext(ctx)
+	if p.UserID == "" {
+		return nil, ErrUnauthenticated
+	}
+	market, err := s.reviewableMarket(ctx, marketID)
+	if err != nil {
+		return nil, err
+	}
+
+	isCreator := p.UserID == market.CreatedBy
+	var state models.ReviewState
+	switch req.Action {
+	case models.ReviewActionComment:
+		if !isCreator && !p.HasRole(middleware.RoleReviewer) {
+			return nil, fmt.Errorf("%w: only reviewers and the market's creator can comment", ErrForbidden)
+		}
+	default:
+		if !p.HasRole(middleware.RoleReviewer) {
+			return nil, fmt.Errorf("%w: reviewer role required", ErrForbidden)
+		}
+		if isCreator {
+			return nil, fmt.Errorf("%w: creators cannot review their own market", ErrForbidden)
+		}
+		if market.ReviewState != models.ReviewStatePending {
+			return nil, fmt.Errorf("%w: market is %s, not pending review", ErrInvalidTransition, market.ReviewState)
+		}
+
+		state = models.ReviewStateRejected
+	}
+
+	if req.Action == models.ReviewActionApprove {
+		err = s.approve(ctx, market.ID, strings.TrimSpace(req.Comment))
+	} else {
+		err = s.addReview(ctx, market.ID, req.Action, strings.TrimSpace(req.Comment), state)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetMarket(ctx, marketID)
+}
+
+// ListMarketReviews retrieves a market's review history, oldest first
+func (s *Service) ListMarketReviews(ctx context.Context, marketID string) (_ []models.MarketReview, err error) {
+	ctx, span := startSpan(ctx, "ListMarketReviews", attribute.String("market.id", marketID))
+	defer func() { tracing.End(span, err) }()
+
+	if err := validateID("market", marketID); err != nil {
+		return nil, err
+	}
+	if _, err := s.repo.GetMarket(ctx, marketID); err != nil {
+		return nil, err
+	}
+
+	return s.repo.ListMarketReviews(ctx, marketID)
+}
+
+// ReviewQueue lists draft markets awaiting review for reviewers, longest waiting first
+func (s *Service) ReviewQueue(ctx context.Context) (_ []models.ReviewQueueItem, err error) {
+	ctx, span := startSpan(ctx, "ReviewQueue")
+	defer func() { tracing.End(span, err) }()
+
+	p := middleware.PrincipalFromContext(ctx)
+	if p.UserID == "" {
+		return nil, ErrUnauthenticated
+	}
+	if !p.HasRole(middleware.RoleReviewer) {
+		return nil, fmt.Errorf("%w: reviewer role required", ErrForbidden)
+	}
+
+	items, err := s.repo.ReviewQueue(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range items {
+		priceMarket(&items[i].Market)
+	}
+
+	return items, nil
+}
+
+// RequiredApprovals returns the number of approvals a market needs to go active
+func (s *Service) RequiredApprovals() int {
+	return s.cfg.RequiredApprovals
+}
+
+// checkReview rejects activating a market that has not been approved
+func (s *Service) checkReview(market *models.Market, to models.MarketStatus) error {
+	if to != models.MarketStatusActive || s.cfg.RequiredApprovals == 0 ||
+		market.ReviewState == models.ReviewStateApproved {
+		return nil
+	}
+	return fmt.Errorf("%w: market needs %d approvals before it can go active, review state is %s",
+		ErrInvalidTransition, s.cfg.RequiredApprovals, market.ReviewState)
+}
+
+// resetReview returns a submitted or approved draft to its creator after an edit,
+// so reviewers approve what is actually published
+func (s *Service) resetReview(ctx context.Context, market *models.Market) error {
+	if market.Status != models.MarketStatusDraft ||
+		(market.ReviewState != models.ReviewStatePending && market.ReviewState != models.ReviewStateApproved) {
+		return nil
+	}
+	comment := fmt.Sprintf("edited while %s", market.ReviewState)
+	return s.addReview(ctx, market.ID, models.ReviewActionReset, comment, models.ReviewStateUnsubmitted)
+}
+
+// reviewableMarket returns the market, or ErrNotEditable unless it is a draft
+func (s *Service) reviewableMarket(ctx context.Context, marketID string) (*models.Market, error) {
+	if err := validateID("market", marketID); err != nil {
+		return nil, err
+	}
+	market, err := s.repo.GetMarket(ctx, marketID)
+	if err != nil {
+		return nil, err
+	}
+	if market.Status != models.MarketStatusDraft {
+		return nil, fmt.Errorf("%w: only draft markets are reviewed, market is %s", ErrNotEditable, market.Status)
+	}
+	return market, nil
+}
+
+// addReview records a review history entry by the current user, moving the market
+// to state when it is set
+func (s *Service) addReview(ctx context.Context, marketID string, action models.ReviewAction, comment string, state models.ReviewState) error {
+	review := &models.MarketReview{
+		ID:         uuid.New().String(),
+		MarketID:   marketID,
+		ReviewerID: middleware.PrincipalFromContext(ctx).UserID,
+		Action:     action,
+		Comment:    comment,
+		CreatedAt:  time.Now(),
+	}
+	if err := s.repo.AddMarketReview(ctx, review, state); err != nil {
+		return fmt.Errorf("add market review: %w", err)
+	}
+
+	s.logger.InfoContext(ctx, "market review recorded",
+		"market_id", marketID,
+		"action", action,
+		"reviewer_id", review.ReviewerID,
+		"review_state", state,
+	)
+	return nil
+}
+
+// approve records the current user's approval, approving the market once it has
+// the required approvals
+func (s *Service) approve(ctx context.Context, marketID string, comment string) error {
+	review := &models.MarketReview{
+		ID:         uuid.New().String(),
+		MarketID:   marketID,
+		ReviewerID: middleware.PrincipalFromContext(ctx).UserID,
+		Action:     models.ReviewActionApprove,
+		Comment:    comment,
+		CreatedAt:  time.Now(),
+	}
+	state, err := s.repo.ApproveMarket(ctx, review, s.cfg.RequiredApprovals)
+	if err != nil {
+		return fmt.Errorf("approve market: %w", err)
+	}
+
+	s.logger.InfoContext(ctx, "market review recorded",
+		"market_id", marketID,
+		"action", review.Action,
+		"reviewer_id", review.ReviewerID,
+		"review_state", state,
+	)
+	return nil
+}
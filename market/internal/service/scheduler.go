@@ -0,0 +1,265 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+	"github.com/ec332/aegis/market/internal/health"
+	"github.com/ec332/aegis/market/internal/oracle"
+	"github.com/ec332/aegis/market/internal/repository"
+	"github.com/ec332/aegis/market/internal/tracing"
+	"github.com/ec332/aegis/market/pkg/models"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// oracleProposerPrefix marks proposals made by a resolver rather than a user
+const oracleProposerPrefix = "oracle:"
+
+// A market whose oracle fails is retried after oracleRetryMin, doubling with each
+// further failure up to oracleRetryMax
+const (
+	oracleRetryMin = time.Minute
+	oracleRetryMax = time.Hour
+)
+
+// RunScheduler drives markets through resolution every interval until ctx is done:
+// due markets move to resolving, their oracles propose outcomes and undisputed
+// proposals are finalized. Halted markets past their cooldown resume trading and
+// price ticks past the circuit breaker window are pruned.
+func (s *Service) RunScheduler(ctx context.Context, interval time.Duration, hb *health.Heartbeat) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		hb.Beat()
+		s.schedule(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// schedule runs one scheduler pass, logging failures so later steps still run
+func (s *Service) schedule(ctx context.Context) {
+	if n, err := s.ResumeDueHalts(ctx); err != nil {
+		s.logger.ErrorContext(ctx, "failed to resume halted markets", "error", err)
+	} else if n > 0 {
+		s.logger.InfoContext(ctx, "markets resumed", "count", n)
+	}
+
+	if n, err := s.OpenDueResolutions(ctx); err != nil {
+		s.logger.ErrorContext(ctx, "failed to open resolutions", "error", err)
+	} else if n > 0 {
+		s.logger.InfoContext(ctx, "markets moved to resolving", "count", n)
+	}
+
+	if n, err := s.RunOracles(ctx); err != nil {
+		s.logger.ErrorContext(ctx, "failed to run oracles", "error", err)
+	} else if n > 0 {
+		s.logger.InfoContext(ctx, "oracle resolutions proposed", "count", n)
+	}
+
+	if n, err := s.FinalizeDueResolutions(ctx); err != nil {
+		s.logger.ErrorContext(ctx, "failed to finalize resolutions", "error", err)
+	} else if n > 0 {
+		s.logger.InfoContext(ctx, "resolutions finalized", "count", n)
+	}
+
+	if n, err := s.PrunePriceHistory(ctx); err != nil {
+		s.logger.ErrorContext(ctx, "failed to prune price history", "error", err)
+	} else if n > 0 {
+		s.logger.DebugContext(ctx, "price history pruned", "count", n)
+	}
+}
+
+// OpenDueResolutions moves active and halted markets past their resolution time to resolving
+// and returns how many were moved. Markets whose status changed since they were
+// listed, e.g. voided by an admin or opened by another replica, are left alone.
+func (s *Service) OpenDueResolutions(ctx context.Context) (_ int, err error) {
+	ctx, span := startSpan(ctx, "OpenDueResolutions")
+	defer func() { tracing.End(span, err) }()
+
+	markets, err := s.repo.ListMarketsDueForResolution(ctx, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("list due markets: %w", err)
+	}
+
+	opened := 0
+	var errs []error
+	status := models.MarketStatusResolving
+	for i := range markets {
+		if err := s.validateStatusTransition(markets[i].Status, status); err != nil {
+			continue
+		}
+		update := repository.MarketUpdate{
+			UpdateMarketRequest: models.UpdateMarketRequest{Status: &status},
+			ExpectedStatus:      &markets[i].Status,
+		}
+		err := s.repo.UpdateMarket(ctx, markets[i].ID, update)
+		if errors.Is(err, ErrConflict) || errors.Is(err, ErrNotFound) {
+			s.logger.DebugContext(ctx, "market changed before resolution opened", "market_id", markets[i].ID, "error", err)
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("market %s: %w", markets[i].ID, err))
+			continue
+		}
+		opened++
+
+		s.logger.InfoContext(ctx, "market resolving", "market_id", markets[i].ID)
+		s.publishMarketPools(ctx, markets[i].ID)
+	}
+
+	return opened, errors.Join(errs...)
+}
+
+// RunOracles asks the resolver of each resolving market without a pending proposal
+// for its outcome and proposes it. Markets whose source has no outcome yet are
+// retried on the next pass; markets whose oracle failed are retried with backoff,
+// and only their first failure in a row is returned. It returns how many
+// proposals were made.
+func (s *Service) RunOracles(ctx context.Context) (_ int, err error) {
+	ctx, span := startSpan(ctx, "RunOracles")
+	defer func() { tracing.End(span, err) }()
+
+	markets, err := s.repo.ListMarketsAwaitingOracle(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("list markets awaiting oracle: %w", err)
+	}
+	s.oracleRetries.keep(markets)
+
+	proposed := 0
+	var errs []error
+	now := time.Now()
+	for i := range markets {
+		if !s.oracleRetries.due(markets[i].ID, now) {
+			continue
+		}
+		err := s.runOracle(ctx, &markets[i])
+		switch {
+		case err == nil:
+			proposed++
+			s.oracleRetries.succeeded(markets[i].ID)
+		case errors.Is(err, oracle.ErrNoOutcome):
+			s.oracleRetries.succeeded(markets[i].ID)
+			s.logger.DebugContext(ctx, "oracle has no outcome yet", "market_id", markets[i].ID, "reason", err)
+		default:
+			failures, retryAt := s.oracleRetries.failed(markets[i].ID, now)
+			if failures == 1 {
+				errs = append(errs, fmt.Errorf("market %s: %w", markets[i].ID, err))
+				continue
+			}
+			s.logger.DebugContext(ctx, "oracle still failing", "market_id", markets[i].ID,
+				"failures", failures, "retry_at", retryAt, "error", err)
+		}
+	}
+
+	return proposed, errors.Join(errs...)
+}
+
+// oracleFailure counts a market's oracle failures in a row
+type oracleFailure struct {
+	count   int
+	retryAt time.Time
+}
+
+// oracleRetries tracks the markets whose oracle is failing
+type oracleRetries struct {
+	mu       sync.Mutex
+	failures map[string]oracleFailure
+}
+
+// due reports whether a market's oracle may run at now
+func (r *oracleRetries) due(marketID string, now time.Time) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	f, ok := r.failures[marketID]
+	return !ok || !now.Before(f.retryAt)
+}
+
+// failed records a failure, returning the failures in a row and when to retry
+func (r *oracleRetries) failed(marketID string, now time.Time) (int, time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	f := r.failures[marketID]
+	f.count++
+	delay := oracleRetryMin
+	for i := 1; i < f.count && delay < oracleRetryMax; i++ {
+		delay *= 2
+	}
+	if delay > oracleRetryMax {
+		delay = oracleRetryMax
+	}
+	f.retryAt = now.Add(delay)
+	r.failures[marketID] = f
+	return f.count, f.retryAt
+}
+
+// succeeded clears a market's failures
+func (r *oracleRetries) succeeded(marketID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.failures, marketID)
+}
+
+// keep forgets the markets no longer awaiting their oracle
+func (r *oracleRetries) keep(markets []models.Market) {
+	awaiting := make(map[string]bool, len(markets))
+	for i := range markets {
+		awaiting[markets[i].ID] = true
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id := range r.failures {
+		if !awaiting[id] {
+			delete(r.failures, id)
+		}
+	}
+}
+
+// runOracle fetches and proposes the outcome of one market
+func (s *Service) runOracle(ctx context.Context, market *models.Market) (err error) {
+	spec := market.ResolutionSpec
+	ctx, span := startSpan(ctx, "runOracle",
+		attribute.String("market.id", market.ID),
+		attribute.String("oracle.resolver", spec.Resolver),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	resolver, ok := s.cfg.Resolvers.Get(spec.Resolver)
+	if !ok {
+		return fmt.Errorf("unknown resolver %q", spec.Resolver)
+	}
+	outcome, err := resolver.Resolve(ctx, market, spec.Config)
+	if err != nil {
+		return err
+	}
+	if err := s.validateOutcome(market, outcome.WinningOptionID, outcome.ResolvedValue); err != nil {
+		return fmt.Errorf("resolver %s returned an invalid outcome: %w", spec.Resolver, err)
+	}
+
+	_, err = s.createProposal(ctx, market, oracleProposerPrefix+spec.Resolver,
+		outcome.WinningOptionID, outcome.ResolvedValue, outcome.Evidence)
+	return err
+}
+
+// validateResolutionSpec checks that spec names a registered resolver whose config
+// fits market
+func (s *Service) validateResolutionSpec(verr *ValidationError, market *models.Market, spec *models.ResolutionSpec) {
+	if spec == nil {
+		return
+	}
+	resolver, ok := s.cfg.Resolvers.Get(spec.Resolver)
+	if !ok {
+		verr.add("resolution_spec.resolver", fmt.Sprintf("must be one of %v", s.cfg.Resolvers.Names()))
+		return
+	}
+	if err := resolver.Validate(market, spec.Config); err != nil {
+		verr.add("resolution_spec.config", err.Error())
+	}
+}
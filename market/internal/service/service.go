@@ -3,61 +3,185 @@ package service
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
+	"math"
+	"strings"
 	"time"
+	"unicode/utf8"
+	"github.com/ec332/aegis/market/internal/metrics"
+	"github.com/ec332/aegis/market/internal/middleware"
+	"github.com/ec332/aegis/market/internal/oracle"
+	"github.com/ec332/aegis/market/internal/pricing"
 	"github.com/ec332/aegis/market/internal/repository"
+	"github.com/ec332/aegis/market/internal/tracing"
+	"github.com/ec332/aegis/market/internal/txservice"
 	"github.com/ec332/aegis/market/pkg/models"
 	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// Config holds the market rules the service enforces
+type Config struct {
+	// RequiredApprovals is the number of reviewers other than the creator who must
+	// approve a market before it can go active; 0 disables review
+	RequiredApprovals int
+	// DisputeWindow is how long a proposed resolution can be disputed
+	DisputeWindow time.Duration
+	// DisputeBond is staked by each dispute
+	DisputeBond float64
+	// Resolvers holds the oracles markets can name in their resolution spec
+	Resolvers *oracle.Registry
+	// MaxOpenBuyValue caps what a user's resting buy orders would pay if they all
+	// filled, as they reserve no collateral; 0 disables the cap
+	MaxOpenBuyValue float64
+	// DefaultFeeRate and DefaultMinFee are the trading fees of new markets
+	DefaultFeeRate float64
+	DefaultMinFee  float64
+	// FeeSplit divides each fee between its recipients
+	FeeSplit FeeSplit
+	// Limits are the risk limits of markets without their own; 0 disables a limit
+	Limits Limits
+	// CircuitBreaker halts markets whose prices move too fast
+	CircuitBreaker CircuitBreaker
+	// Transactions records executed trades with the transaction service; nil
+	// leaves them unrecorded
+	Transactions *txservice.Client
+}
+
+// CircuitBreaker halts a market for Cooldown when an option's price moves by more
+// than PriceMove within Window; a PriceMove of 0 disables it
+type CircuitBreaker struct {
+	PriceMove float64
+	Window    time.Duration
+	Cooldown  time.Duration
+}
+
+// Limits holds default risk limits
+type Limits struct {
+	MaxPosition         float64
+	MaxTradeShares      float64
+	MaxTreasuryExposure float64
+}
+
+// FeeSplit holds the fractions of a fee paid to the market's creator and its
+// liquidity providers; the platform keeps the rest
+type FeeSplit struct {
+	Creator            float64
+	LiquidityProviders float64
+}
+
 // Service handles business logic for markets
 type Service struct {
 	repo        *repository.Repository
 	redisClient *redis.Client
+	logger      *slog.Logger
+	cfg         Config
+	// oracleRetries holds the markets whose oracle failed, retried with backoff
+	oracleRetries *oracleRetries
 }
 
 // New creates a new service instance
-func New(repo *repository.Repository, redisClient *redis.Client) *Service {
+func New(repo *repository.Repository, redisClient *redis.Client, logger *slog.Logger, cfg Config) *Service {
 	return &Service{
 		repo:        repo,
 		redisClient: redisClient,
+		logger:      logger,
+		cfg:         cfg,
+
+		oracleRetries: &oracleRetries{failures: map[string]oracleFailure{}},
 	}
 }
 
 // CreateMarket creates a new market with validation (called by API Gateway)
-func (s *Service) CreateMarket(ctx context.Context, req models.CreateMarketRequest) (*models.Market, error) {
+func (s *Service) CreateMarket(ctx context.Context, req models.CreateMarketRequest) (_ *models.Market, err error) {
+	ctx, span := startSpan(ctx, "CreateMarket")
+	defer func() { tracing.End(span, err) }()
+
 	// Validation
-	if err := s.validateCreateMarketRequest(req); err != nil {
-		return nil, fmt.Errorf("validation failed: %w", err)
+	if err := s.validateCreateMarketRequest(ctx, &req); err != nil {
+		return nil, err
+	}
+	if err := checkFeeOverride(ctx, req.FeeRate, req.MinFee); err != nil {
+		return nil, err
+	}
+	if err := checkLimitsOverride(ctx, req.Limits); err != nil {
+		return nil, err
 	}
 
 	now := time.Now()
 	marketID := uuid.New().String()
+	span.SetAttributes(attribute.String("market.id", marketID))
 
 	// Create market
 	market := &models.Market{
 		ID:                 marketID,
 		Title:              req.Title,
 		Description:        req.Description,
+		ResolutionRules:    req.ResolutionRules,
+		ResolutionSource:   req.ResolutionSource,
+		ResolutionSpec:     req.ResolutionSpec,
 		Status:             models.MarketStatusDraft,
+		Type:               req.Type,
+		LowerBound:         req.LowerBound,
+		UpperBound:         req.UpperBound,
 		ResolutionDatetime: req.ResolutionDatetime,
 		WinningOptionID:    nil,
+		ParentMarketID:     req.ParentMarketID,
+		ConditionOptionID:  req.ConditionOptionID,
+		CategoryID:         req.CategoryID,
+		CreatedBy:          middleware.PrincipalFromContext(ctx).UserID,
+		ReviewState:        models.ReviewStateUnsubmitted,
+		Tags:               req.Tags,
+		FeeRate:            s.cfg.DefaultFeeRate,
+		MinFee:             s.cfg.DefaultMinFee,
 		CreatedAt:          now,
 		UpdatedAt:          now,
 	}
+	if req.FeeRate != nil {
+		market.FeeRate = *req.FeeRate
+	}
+	if req.MinFee != nil {
+		market.MinFee = *req.MinFee
+	}
+	if req.Limits != nil {
+		market.Limits = *req.Limits
+	}
 
-	// Create options
-	options := make([]models.Option, len(req.Options))
-	for i, title := range req.Options {
+	// Create options; scalar markets get a long and a short option
+	titles := req.Options
+	var sides []models.ScalarSide
+	if req.Type == models.MarketTypeScalar {
+		if len(titles) == 0 {
+			titles = []string{"Long", "Short"}
+		}
+		sides = []models.ScalarSide{models.ScalarSideLong, models.ScalarSideShort}
+	}
+	options := make([]models.Option, len(titles))
+	for i, title := range titles {
 		options[i] = models.Option{
-			ID:        uuid.New().String(),
-			MarketID:  marketID,
-			Title:     title,
-			CreatedAt: now,
+			ID:           uuid.New().String(),
+			MarketID:     marketID,
+			Title:        title,
+			DisplayOrder: i,
+			CreatedAt:    now,
+		}
+		if sides != nil {
+			options[i].Side = sides[i]
 		}
 	}
 
+	// The resolution spec may refer to options by title
+	market.Options = options
+	verr := &ValidationError{}
+	s.validateResolutionSpec(verr, market, req.ResolutionSpec)
+	if err := verr.err(); err != nil {
+		return nil, err
+	}
+
 	// Create liquidity pools (one per option, initial value 0)
 	pools := make([]models.LiquidityPool, len(options))
 	for i, option := range options {
@@ -78,69 +202,207 @@ func (s *Service) CreateMarket(ctx context.Context, req models.CreateMarketReque
 	// Add options and pools to response
 	market.Options = options
 	market.LiquidityPools = pools
+	priceMarket(market)
 
 	// Publish market creation event to Redis
 	if err := s.publishLiquidityUpdate(ctx, marketID, pools); err != nil {
-		fmt.Printf("Warning: failed to publish market creation: %v\n", err)
+		s.logger.WarnContext(ctx, "failed to publish market creation", "market_id", marketID, "error", err)
 	}
 
 	return market, nil
 }
 
 // GetMarket retrieves a market by ID
-func (s *Service) GetMarket(ctx context.Context, marketID string) (*models.Market, error) {
+func (s *Service) GetMarket(ctx context.Context, marketID string) (_ *models.Market, err error) {
+	ctx, span := startSpan(ctx, "GetMarket", attribute.String("market.id", marketID))
+	defer func() { tracing.End(span, err) }()
+
+	if err := validateID("market", marketID); err != nil {
+		return nil, err
+	}
+
 	market, err := s.repo.GetMarket(ctx, marketID)
 	if err != nil {
 		return nil, err
 	}
+	priceMarket(market)
 
 	return market, nil
 }
 
-// ListMarkets retrieves markets
-func (s *Service) ListMarkets(ctx context.Context, status *models.MarketStatus) ([]models.Market, error) {
-	markets, err := s.repo.ListMarkets(ctx, status)
+// ListMarkets retrieves markets matching filter, featured markets first
+func (s *Service) ListMarkets(ctx context.Context, filter models.MarketFilter) (_ []models.Market, err error) {
+	ctx, span := startSpan(ctx, "ListMarkets")
+	defer func() { tracing.End(span, err) }()
+
+	if err := s.validateMarketFilter(ctx, &filter); err != nil {
+		return nil, err
+	}
+
+	markets, err := s.repo.ListMarkets(ctx, filter)
 	if err != nil {
 		return nil, err
 	}
+	for i := range markets {
+		priceMarket(&markets[i])
+	}
 
 	return markets, nil
 }
 
 // UpdateMarket updates a market's details
-func (s *Service) UpdateMarket(ctx context.Context, marketID string, req models.UpdateMarketRequest) (*models.Market, error) {
-	// Validate status transition if status is being updated
-	if req.Status != nil {
-		market, err := s.repo.GetMarket(ctx, marketID)
+func (s *Service) UpdateMarket(ctx context.Context, marketID string, req models.UpdateMarketRequest) (_ *models.Market, err error) {
+	ctx, span := startSpan(ctx, "UpdateMarket", attribute.String("market.id", marketID))
+	defer func() { tracing.End(span, err) }()
+
+	if err := validateID("market", marketID); err != nil {
+		return nil, err
+	}
+	if err := s.validateUpdateMarketRequest(ctx, &req); err != nil {
+		return nil, err
+	}
+	if err := checkFeeOverride(ctx, req.FeeRate, req.MinFee); err != nil {
+		return nil, err
+	}
+	if err := checkLimitsOverride(ctx, req.Limits); err != nil {
+		return nil, err
+	}
+	if req.Subsidy != nil && !middleware.PrincipalFromContext(ctx).HasRole(middleware.RoleAdmin) {
+		return nil, fmt.Errorf("%w: only admins can fund markets from the treasury", ErrForbidden)
+	}
+	changesFees := req.FeeRate != nil || req.MinFee != nil
+
+	// Validate edits, status transition and resolution against the current market
+	var payouts map[string]float64
+	var current *models.Market
+	var settlement *repository.ProposalSettlement
+	var funding *repository.LiquidityChange
+	if req.ChangesText() || req.ResolutionSpec != nil || req.Status != nil || changesFees {
+		current, err = s.repo.GetMarket(ctx, marketID)
 		if err != nil {
 			return nil, err
 		}
-		if err := s.validateStatusTransition(market.Status, *req.Status); err != nil {
-			return nil, fmt.Errorf("invalid status transition: %w", err)
+		if changesFees && current.Status != models.MarketStatusDraft && current.Status != models.MarketStatusHidden {
+			return nil, fmt.Errorf("%w: trading fees are locked while a market is %s", ErrNotEditable, current.Status)
+		}
+		if (req.ChangesText() || req.ResolutionSpec != nil) &&
+			current.Status != models.MarketStatusDraft && current.Status != models.MarketStatusHidden {
+			return nil, fmt.Errorf("%w: title, description and resolution texts are locked once a market is %s",
+				ErrNotEditable, current.Status)
+		}
+		verr := &ValidationError{}
+		s.validateResolutionSpec(verr, current, req.ResolutionSpec)
+		if err := verr.err(); err != nil {
+			return nil, err
+		}
+		if req.Status != nil {
+			if err := s.validateStatusTransition(current.Status, *req.Status); err != nil {
+				return nil, err
+			}
+			if *req.Status == models.MarketStatusResolved {
+				return nil, fmt.Errorf("%w: markets resolve through a resolution proposal", ErrInvalidTransition)
+			}
+			if *req.Status == models.MarketStatusHalted || (current.Status == models.MarketStatusHalted && *req.Status == models.MarketStatusActive) {
+				return nil, fmt.Errorf("%w: halt and resume trading with /halt and /resume", ErrInvalidTransition)
+			}
+			if err := s.checkReview(current, *req.Status); err != nil {
+				return nil, err
+			}
+			if err := s.checkCondition(ctx, current, *req.Status); err != nil {
+				return nil, err
+			}
+			// The subsidy is held to the limits the update leaves in force
+			limited := *current
+			if req.Limits != nil {
+				limited.Limits = *req.Limits
+			}
+			if funding, err = s.checkFunding(ctx, &limited, *req.Status, req.Subsidy); err != nil {
+				return nil, err
+			}
+		}
+		if payouts, err = s.resolutionPayouts(current, req); err != nil {
+			return nil, err
+		}
+		if req.Status != nil && *req.Status == models.MarketStatusVoided {
+			if settlement, err = s.cancelSettlement(ctx, current); err != nil {
+				return nil, err
+			}
 		}
 	}
 
 	// Update the market in the repository
-	if err := s.repo.UpdateMarket(ctx, marketID, req); err != nil {
+	update := repository.MarketUpdate{
+		UpdateMarketRequest: req,
+		Payouts:             payouts,
+		EditedBy:            middleware.PrincipalFromContext(ctx).UserID,
+		Settlement:          settlement,
+		Funding:             funding,
+	}
+	if current != nil {
+		// The checks above hold only while the market keeps the status they saw
+		update.ExpectedStatus = &current.Status
+	}
+	if err := s.repo.UpdateMarket(ctx, marketID, update); err != nil {
 		return nil, fmt.Errorf("update market: %w", err)
 	}
 
+	// Edited texts need a fresh review
+	if req.ChangesText() || req.ResolutionSpec != nil {
+		if err := s.resetReview(ctx, current); err != nil {
+			return nil, err
+		}
+	}
+
 	// Fetch the updated market
 	market, err := s.repo.GetMarket(ctx, marketID)
 	if err != nil {
 		return nil, err
 	}
+	priceMarket(market)
 
 	// Publish update to Redis
 	if err := s.publishLiquidityUpdate(ctx, marketID, market.LiquidityPools); err != nil {
-		fmt.Printf("Warning: failed to publish market update: %v\n", err)
+		s.logger.WarnContext(ctx, "failed to publish market update", "market_id", marketID, "error", err)
+	}
+	// Settlement took the remaining orders off the book; funding opened the pool
+	if payouts != nil || funding != nil {
+		s.publishOrderBook(ctx, market)
+	}
+
+	// Activate or void markets conditional on this one
+	if req.Status != nil && isFinal(*req.Status) {
+		if err := s.settleDependents(ctx, market); err != nil {
+			s.logger.ErrorContext(ctx, "failed to settle conditional markets", "market_id", marketID, "error", err)
+		}
 	}
 
 	return market, nil
 }
 
+// ListMarketRevisions retrieves the prior texts of a market, newest first
+func (s *Service) ListMarketRevisions(ctx context.Context, marketID string) (_ []models.MarketRevision, err error) {
+	ctx, span := startSpan(ctx, "ListMarketRevisions", attribute.String("market.id", marketID))
+	defer func() { tracing.End(span, err) }()
+
+	if err := validateID("market", marketID); err != nil {
+		return nil, err
+	}
+	// Distinguish an unknown market from one that was never edited
+	if _, err := s.repo.GetMarket(ctx, marketID); err != nil {
+		return nil, err
+	}
+
+	return s.repo.ListMarketRevisions(ctx, marketID)
+}
+
 // UpdateLiquidityPool updates a liquidity pool and publishes to Redis
-func (s *Service) UpdateLiquidityPool(ctx context.Context, marketID, poolID string, poolValue float64) error {
+func (s *Service) UpdateLiquidityPool(ctx context.Context, marketID, poolID string, poolValue float64) (err error) {
+	ctx, span := startSpan(ctx, "UpdateLiquidityPool",
+		attribute.String("market.id", marketID),
+		attribute.String("pool.id", poolID),
+	)
+	defer func() { tracing.End(span, err) }()
+
 	if err := s.repo.UpdateLiquidityPool(ctx, poolID, poolValue); err != nil {
 		return err
 	}
@@ -153,17 +415,18 @@ func (s *Service) UpdateLiquidityPool(ctx context.Context, marketID, poolID stri
 
 	// Publish to Redis
 	if err := s.publishLiquidityUpdate(ctx, marketID, pools); err != nil {
-		fmt.Printf("Warning: failed to publish liquidity update: %v\n", err)
+		s.logger.WarnContext(ctx, "failed to publish liquidity update", "market_id", marketID, "error", err)
 	}
 
 	return nil
 }
 
-// SubscribeToLiquidityUpdates subscribes to liquidity pool updates for a market from Redis
-func (s *Service) SubscribeToLiquidityUpdates(ctx context.Context, marketID string) (<-chan models.LiquidityUpdate, error) {
-	pubsub := s.redisClient.Subscribe(ctx, fmt.Sprintf("market:%s:liquidity", marketID))
+// SubscribeToMarketEvents subscribes to a market's liquidity pool and order book
+// updates from Redis
+func (s *Service) SubscribeToMarketEvents(ctx context.Context, marketID string) (<-chan models.StreamEvent, error) {
+	pubsub := s.redisClient.Subscribe(ctx, liquidityChannel(marketID), bookChannel(marketID), tradingChannel(marketID))
 
-	ch := make(chan models.LiquidityUpdate)
+	ch := make(chan models.StreamEvent)
 
 	go func() {
 		defer close(ch)
@@ -174,12 +437,12 @@ func (s *Service) SubscribeToLiquidityUpdates(ctx context.Context, marketID stri
 			case <-ctx.Done():
 				return
 			case msg := <-pubsub.Channel():
-				var update models.LiquidityUpdate
-				if err := json.Unmarshal([]byte(msg.Payload), &update); err != nil {
-					fmt.Printf("Error unmarshaling liquidity update: %v\n", err)
+				event, traceContext, err := decodeMarketEvent(msg.Channel, []byte(msg.Payload))
+				if err != nil {
+					s.logger.WarnContext(ctx, "failed to unmarshal market event", "market_id", marketID, "error", err)
 					continue
 				}
-				ch <- update
+				s.deliverMarketEvent(ctx, ch, marketID, event, traceContext)
 			}
 		}
 	}()
@@ -187,11 +450,66 @@ func (s *Service) SubscribeToLiquidityUpdates(ctx context.Context, marketID stri
 	return ch, nil
 }
 
-func (s *Service) publishLiquidityUpdate(ctx context.Context, marketID string, pools []models.LiquidityPool) error {
+// decodeMarketEvent decodes a message published on one of a market's channels,
+// returning the event with its trace context removed
+func decodeMarketEvent(channel string, payload []byte) (models.StreamEvent, map[string]string, error) {
+	if strings.HasSuffix(channel, ":book") {
+		var book models.OrderBook
+		if err := json.Unmarshal(payload, &book); err != nil {
+			return models.StreamEvent{}, nil, err
+		}
+		traceContext := book.TraceContext
+		book.TraceContext = nil
+		return models.StreamEvent{Event: models.StreamEventBookUpdate, Data: book}, traceContext, nil
+	}
+	if strings.HasSuffix(channel, ":trading") {
+		var update models.TradingUpdate
+		if err := json.Unmarshal(payload, &update); err != nil {
+			return models.StreamEvent{}, nil, err
+		}
+		traceContext := update.TraceContext
+		update.TraceContext = nil
+		event := models.StreamEventTradingResumed
+		if update.Halted {
+			event = models.StreamEventTradingHalted
+		}
+		return models.StreamEvent{Event: event, Data: update}, traceContext, nil
+	}
+
+	var update models.LiquidityUpdate
+	if err := json.Unmarshal(payload, &update); err != nil {
+		return models.StreamEvent{}, nil, err
+	}
+	traceContext := update.TraceContext
+	update.TraceContext = nil
+	return models.StreamEvent{Event: models.StreamEventLiquidityUpdate, Data: update}, traceContext, nil
+}
+
+// deliverMarketEvent forwards event to a subscriber inside a consumer span linked to the publisher's trace
+func (s *Service) deliverMarketEvent(ctx context.Context, ch chan<- models.StreamEvent, marketID string, event models.StreamEvent, traceContext map[string]string) {
+	producerCtx := tracing.Extract(context.Background(), traceContext)
+	_, span := tracing.Tracer().Start(ctx, "service.deliverMarketEvent",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithLinks(trace.LinkFromContext(producerCtx)),
+		trace.WithAttributes(attribute.String("market.id", marketID), attribute.String("event", event.Event)),
+	)
+	defer span.End()
+
+	select {
+	case ch <- event:
+	case <-ctx.Done():
+	}
+}
+
+func (s *Service) publishLiquidityUpdate(ctx context.Context, marketID string, pools []models.LiquidityPool) (err error) {
+	ctx, span := startSpan(ctx, "publishLiquidityUpdate", attribute.String("market.id", marketID))
+	defer func() { tracing.End(span, err) }()
+
 	update := models.LiquidityUpdate{
 		MarketID:       marketID,
 		LiquidityPools: pools,
 		Timestamp:      time.Now(),
+		TraceContext:   tracing.Inject(ctx),
 	}
 
 	data, err := json.Marshal(update)
@@ -199,8 +517,8 @@ func (s *Service) publishLiquidityUpdate(ctx context.Context, marketID string, p
 		return fmt.Errorf("marshal liquidity update: %w", err)
 	}
 
-	channel := fmt.Sprintf("market:%s:liquidity", marketID)
-	if err := s.redisClient.Publish(ctx, channel, data).Err(); err != nil {
+	if err := s.redisClient.Publish(ctx, liquidityChannel(marketID), data).Err(); err != nil {
+		metrics.RedisPublishFailures.WithLabelValues("liquidity").Inc()
 		return fmt.Errorf("publish to redis: %w", err)
 	}
 
@@ -209,32 +527,246 @@ func (s *Service) publishLiquidityUpdate(ctx context.Context, marketID string, p
 
 // Helper functions
 
-func (s *Service) validateCreateMarketRequest(req models.CreateMarketRequest) error {
-	if req.Title == "" {
-		return fmt.Errorf("title is required")
-	}
-	if req.Description == "" {
-		return fmt.Errorf("description is required")
+func liquidityChannel(marketID string) string {
+	return fmt.Sprintf("market:%s:liquidity", marketID)
+}
+
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracing.Tracer().Start(ctx, "service."+name, trace.WithAttributes(attrs...))
+}
+
+// Text length limits, matching the column sizes where the database has one
+const (
+	maxTitleLength            = 255
+	maxDescriptionLength      = 10000
+	maxResolutionRulesLength  = 20000
+	maxResolutionSourceLength = 2048
+)
+
+// validateText checks a text field's length in characters, as VARCHAR columns count them
+func validateText(verr *ValidationError, field, value string, max int, required bool) {
+	switch {
+	case required && strings.TrimSpace(value) == "":
+		verr.add(field, field+" is required")
+	case utf8.RuneCountInString(value) > max:
+		verr.add(field, fmt.Sprintf("%s must be at most %d characters", field, max))
 	}
-	if len(req.Options) < 2 {
-		return fmt.Errorf("at least 2 options are required")
+}
+
+// validateID treats malformed IDs as not found, since no record can have them
+func validateID(kind, id string) error {
+	if _, err := uuid.Parse(id); err != nil {
+		return fmt.Errorf("%s %s: %w", kind, id, ErrNotFound)
 	}
 	return nil
 }
 
+func (s *Service) validateCreateMarketRequest(ctx context.Context, req *models.CreateMarketRequest) error {
+	verr := &ValidationError{}
+	validateText(verr, "title", req.Title, maxTitleLength, true)
+	validateText(verr, "description", req.Description, maxDescriptionLength, true)
+	validateText(verr, "resolution_rules", req.ResolutionRules, maxResolutionRulesLength, false)
+	validateText(verr, "resolution_source", req.ResolutionSource, maxResolutionSourceLength, false)
+	if req.Type == "" {
+		req.Type = models.MarketTypeCategorical
+	}
+	switch req.Type {
+	case models.MarketTypeCategorical:
+		if len(req.Options) < 2 {
+			verr.add("options", "at least 2 options are required")
+		}
+		if req.LowerBound != nil || req.UpperBound != nil {
+			verr.add("type", "bounds are only allowed on scalar markets")
+		}
+	case models.MarketTypeScalar:
+		if len(req.Options) != 0 && len(req.Options) != 2 {
+			verr.add("options", "scalar markets take no options or exactly 2 (long and short titles)")
+		}
+		if req.LowerBound == nil {
+			verr.add("lower_bound", "lower_bound is required for scalar markets")
+		}
+		if req.UpperBound == nil {
+			verr.add("upper_bound", "upper_bound is required for scalar markets")
+		}
+		if req.LowerBound != nil && req.UpperBound != nil && *req.LowerBound >= *req.UpperBound {
+			verr.add("upper_bound", "upper_bound must be greater than lower_bound")
+		}
+	default:
+		verr.add("type", fmt.Sprintf("unknown market type %q", req.Type))
+	}
+	for i, title := range req.Options {
+		validateOptionTitle(verr, fmt.Sprintf("options[%d]", i), title)
+	}
+	if req.CategoryID != nil {
+		s.validateCategoryRef(ctx, verr, "category_id", *req.CategoryID)
+	}
+	req.Tags = normalizeTags(verr, req.Tags)
+	s.validateConditionRef(ctx, verr, req.ParentMarketID, req.ConditionOptionID)
+	validateFees(verr, req.FeeRate, req.MinFee)
+	validateLimits(verr, req.Limits)
+	return verr.err()
+}
+
+func (s *Service) validateUpdateMarketRequest(ctx context.Context, req *models.UpdateMarketRequest) error {
+	verr := &ValidationError{}
+	if req.Title != nil {
+		validateText(verr, "title", *req.Title, maxTitleLength, true)
+	}
+	if req.Description != nil {
+		validateText(verr, "description", *req.Description, maxDescriptionLength, true)
+	}
+	if req.ResolutionRules != nil {
+		validateText(verr, "resolution_rules", *req.ResolutionRules, maxResolutionRulesLength, false)
+	}
+	if req.ResolutionSource != nil {
+		validateText(verr, "resolution_source", *req.ResolutionSource, maxResolutionSourceLength, false)
+	}
+	if req.CategoryID != nil {
+		s.validateCategoryRef(ctx, verr, "category_id", *req.CategoryID)
+	}
+	if req.Tags != nil {
+		tags := normalizeTags(verr, *req.Tags)
+		req.Tags = &tags
+	}
+	if req.FeaturedRank != nil {
+		if *req.FeaturedRank < 0 {
+			verr.add("featured_rank", "must not be negative")
+		}
+		if req.Featured != nil && !*req.Featured {
+			verr.add("featured_rank", "cannot be set when unfeaturing a market")
+		}
+		// A rank on its own pins the market
+		featured := true
+		req.Featured = &featured
+	}
+	if req.Status != nil && !req.Status.Valid() {
+		verr.add("status", fmt.Sprintf("unknown status %q", *req.Status))
+	}
+	if req.WinningOptionID != nil {
+		verr.add("winning_option_id", "set through a resolution proposal")
+	}
+	if req.ResolvedValue != nil {
+		verr.add("resolved_value", "set through a resolution proposal")
+	}
+	validateFees(verr, req.FeeRate, req.MinFee)
+	validateLimits(verr, req.Limits)
+	if req.Subsidy != nil {
+		if req.Status == nil || *req.Status != models.MarketStatusActive {
+			verr.add("subsidy", "can only be set when activating a market")
+		}
+		if req.Subsidy.Amount < shareDust || req.Subsidy.Amount > maxOrderShares || math.IsNaN(req.Subsidy.Amount) {
+			verr.add("subsidy.amount", fmt.Sprintf("must be between %g and %g", shareDust, float64(maxOrderShares)))
+		}
+	}
+	return verr.err()
+}
+
+// resolutionPayouts validates the resolution fields of req against market and,
+// when req resolves the market, returns the per-option settlement payouts
+func (s *Service) resolutionPayouts(market *models.Market, req models.UpdateMarketRequest) (map[string]float64, error) {
+	verr := &ValidationError{}
+	resolved := *market
+
+	if req.WinningOptionID != nil {
+		if market.Type == models.MarketTypeScalar {
+			verr.add("winning_option_id", "scalar markets resolve with resolved_value")
+		} else if !hasOption(market, *req.WinningOptionID) {
+			verr.add("winning_option_id", "not an option of this market")
+		}
+		resolved.WinningOptionID = req.WinningOptionID
+	}
+	if req.ResolvedValue != nil {
+		if market.Type != models.MarketTypeScalar {
+			verr.add("resolved_value", "only scalar markets resolve with a value")
+		}
+		resolved.ResolvedValue = req.ResolvedValue
+	}
+	if err := verr.err(); err != nil {
+		return nil, err
+	}
+
+	if req.Status == nil || !isFinal(*req.Status) {
+		return nil, nil
+	}
+	resolved.Status = *req.Status
+
+	payouts, err := pricing.Payouts(&resolved)
+	if errors.Is(err, pricing.ErrUnresolved) {
+		if market.Type == models.MarketTypeScalar {
+			verr.add("resolved_value", "resolved_value is required to resolve a scalar market")
+		} else {
+			verr.add("winning_option_id", "winning_option_id is required to resolve a market")
+		}
+		return nil, verr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("compute payouts: %w", err)
+	}
+	return payouts, nil
+}
+
+func hasOption(market *models.Market, optionID string) bool {
+	for _, option := range market.Options {
+		if option.ID == optionID {
+			return true
+		}
+	}
+	return false
+}
+
+// priceMarket fills in option prices from the liquidity pools and, for scalar
+// markets, the value implied by the long price
+func priceMarket(market *models.Market) {
+	prices := pricing.OptionPrices(market.LiquidityPools)
+	for i := range market.Options {
+		market.Options[i].Price = prices[market.Options[i].ID]
+		// Settled pools are empty; options are then worth their payout
+		if market.Options[i].Payout != nil {
+			market.Options[i].Price = *market.Options[i].Payout
+		}
+		if market.Type == models.MarketTypeScalar && market.Options[i].Side == models.ScalarSideLong &&
+			market.LowerBound != nil && market.UpperBound != nil {
+			implied := pricing.ImpliedValue(*market.LowerBound, *market.UpperBound, market.Options[i].Price)
+			market.ImpliedValue = &implied
+		}
+	}
+}
+
+// validateMarketFilter checks filter values and normalizes its tags
+func (s *Service) validateMarketFilter(ctx context.Context, filter *models.MarketFilter) error {
+	verr := &ValidationError{}
+	if filter.Status != nil && !filter.Status.Valid() {
+		verr.add("status", fmt.Sprintf("unknown status %q", *filter.Status))
+	}
+	if filter.CategoryID != nil {
+		if _, err := uuid.Parse(*filter.CategoryID); err != nil {
+			verr.add("category_id", "must be a valid UUID")
+		}
+	}
+	if filter.ParentMarketID != nil {
+		if _, err := uuid.Parse(*filter.ParentMarketID); err != nil {
+			verr.add("parent_market_id", "must be a valid UUID")
+		}
+	}
+	filter.Tags = normalizeTags(verr, filter.Tags)
+	return verr.err()
+}
+
 func (s *Service) validateStatusTransition(from, to models.MarketStatus) error {
 	// Define valid transitions
 	validTransitions := map[models.MarketStatus][]models.MarketStatus{
-		models.MarketStatusDraft:     {models.MarketStatusActive, models.MarketStatusHidden},
-		models.MarketStatusActive:    {models.MarketStatusHidden, models.MarketStatusResolving},
-		models.MarketStatusHidden:    {models.MarketStatusActive, models.MarketStatusDraft},
-		models.MarketStatusResolving: {models.MarketStatusResolved},
+		models.MarketStatusDraft:     {models.MarketStatusActive, models.MarketStatusHidden, models.MarketStatusVoided},
+		models.MarketStatusActive:    {models.MarketStatusHalted, models.MarketStatusHidden, models.MarketStatusResolving, models.MarketStatusVoided},
+		models.MarketStatusHalted:    {models.MarketStatusActive, models.MarketStatusResolving, models.MarketStatusVoided},
+		models.MarketStatusHidden:    {models.MarketStatusActive, models.MarketStatusDraft, models.MarketStatusVoided},
+		models.MarketStatusResolving: {models.MarketStatusResolved, models.MarketStatusVoided},
 		models.MarketStatusResolved:  {},
+		models.MarketStatusVoided:    {},
 	}
 
 	allowed, exists := validTransitions[from]
 	if !exists {
-		return fmt.Errorf("unknown status: %s", from)
+		return fmt.Errorf("%w: unknown status %s", ErrInvalidTransition, from)
 	}
 
 	for _, allowedStatus := range allowed {
@@ -243,5 +775,5 @@ func (s *Service) validateStatusTransition(from, to models.MarketStatus) error {
 		}
 	}
 
-	return fmt.Errorf("cannot transition from %s to %s", from, to)
+	return fmt.Errorf("%w: cannot transition from %s to %s", ErrInvalidTransition, from, to)
 }
@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+	"github.com/ec332/aegis/market/internal/middleware"
+	"github.com/ec332/aegis/market/internal/repository"
+	"github.com/ec332/aegis/market/internal/tracing"
+	"github.com/ec332/aegis/market/pkg/models"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// MintCompleteSet exchanges collateral for the same number of shares of every
+// option of an active market. A complete set always pays out 1 whatever the outcome.
+func (s *Service) MintCompleteSet(ctx context.Context, marketID string, req models.CompleteSetRequest) (*models.CompleteSetResult, error) {
+	return s.exchangeCompleteSet(ctx, marketID, models.CompleteSetMint, req)
+}
+
+// RedeemCompleteSet exchanges the same number of shares of every option of a
+// market back for collateral. Sets can be redeemed at any time once the market
+// is published.
+func (s *Service) RedeemCompleteSet(ctx context.Context, marketID string, req models.CompleteSetRequest) (*models.CompleteSetResult, error) {
+	return s.exchangeCompleteSet(ctx, marketID, models.CompleteSetRedeem, req)
+}
+
+func (s *Service) exchangeCompleteSet(ctx context.Context, marketID string, action models.CompleteSetAction, req models.CompleteSetRequest) (_ *models.CompleteSetResult, err error) {
+	ctx, span := startSpan(ctx, "exchangeCompleteSet",
+		attribute.String("market.id", marketID),
+		attribute.String("set.action", string(action)),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	p := middleware.PrincipalFromContext(ctx)
+	if p.UserID == "" {
+		return nil, ErrUnauthenticated
+	}
+	if err := validateID("market", marketID); err != nil {
+		return nil, err
+	}
+	if req.Shares < shareDust || req.Shares > maxOrderShares || math.IsNaN(req.Shares) {
+		verr := &ValidationError{}
+		verr.add("shares", fmt.Sprintf("must be between %g and %g", shareDust, float64(maxOrderShares)))
+		return nil, verr
+	}
+
+	market, err := s.repo.GetMarket(ctx, marketID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkSetStatus(action, market.Status); err != nil {
+		return nil, err
+	}
+
+	set := &models.CompleteSet{
+		ID:        uuid.New().String(),
+		MarketID:  marketID,
+		UserID:    p.UserID,
+		Action:    action,
+		Shares:    req.Shares,
+		CreatedAt: time.Now(),
+	}
+	optionIDs := make([]string, len(market.Options))
+	for i, option := range market.Options {
+		optionIDs[i] = option.ID
+	}
+
+	// Each set is backed by one unit of collateral held for the market
+	amount := set.Shares
+	if action == models.CompleteSetMint {
+		amount = -amount
+	}
+	ledger := []models.LedgerEntry{
+		ledgerEntry(userAccount(p.UserID), marketID, setLedgerKind(action), amount, set.ID, set.CreatedAt),
+		ledgerEntry(collateralAccount(marketID), marketID, setLedgerKind(action), -amount, set.ID, set.CreatedAt),
+	}
+
+	// The status is checked again with the market locked, as it may change meanwhile.
+	// Minting adds shares of every option, so each must stay within the position cap.
+	limits := s.marketLimits(market)
+	check := func(state *repository.SetState) error {
+		if err := checkSetStatus(action, state.Status); err != nil {
+			return err
+		}
+		if action != models.CompleteSetMint {
+			return nil
+		}
+		received := make(map[string]float64, len(optionIDs))
+		for _, optionID := range optionIDs {
+			received[optionID] = set.Shares
+		}
+		return checkPositionLimit(limits, state.Holdings, received)
+	}
+	if err := s.repo.ExchangeCompleteSet(ctx, set, optionIDs, ledger, check); err != nil {
+		return nil, fmt.Errorf("%s complete set: %w", action, err)
+	}
+
+	s.logger.InfoContext(ctx, "complete set exchanged",
+		"market_id", marketID,
+		"set_id", set.ID,
+		"user_id", p.UserID,
+		"action", action,
+		"shares", set.Shares,
+	)
+
+	positions, err := s.repo.ListMarketPositions(ctx, marketID, p.UserID)
+	if err != nil {
+		return nil, err
+	}
+	return &models.CompleteSetResult{Set: *set, Positions: positions}, nil
+}
+
+// checkSetStatus rejects minting outside active markets and any exchange in drafts
+func checkSetStatus(action models.CompleteSetAction, status models.MarketStatus) error {
+	switch {
+	case action == models.CompleteSetMint && status != models.MarketStatusActive:
+		return fmt.Errorf("%w: market is %s", ErrMarketClosed, status)
+	case status == models.MarketStatusDraft:
+		return fmt.Errorf("%w: market is %s", ErrMarketClosed, status)
+	}
+	return nil
+}
+
+func setLedgerKind(action models.CompleteSetAction) models.LedgerKind {
+	if action == models.CompleteSetMint {
+		return models.LedgerKindMint
+	}
+	return models.LedgerKindRedeem
+}
+
+func collateralAccount(marketID string) string {
+	return models.LedgerAccountCollateralPrefix + marketID
+}
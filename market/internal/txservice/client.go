@@ -0,0 +1,354 @@
+// Package txservice is a client for the transaction service, which keeps the
+// durable record of executed trades.
+package txservice
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"github.com/ec332/aegis/market/internal/tracing"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// Transaction types
+const (
+	TypeBuy  = "BUY"
+	TypeSell = "SELL"
+)
+
+// maxAmount bounds shares and prices, which the service stores as NUMERIC(10, 4)
+const maxAmount = 1e6
+
+// timeLayout is how the service reads and writes timestamps, in UTC
+const timeLayout = "2006-01-02 15:04:05.999999"
+
+var (
+	// ErrNotFound is returned for a transaction the service does not have
+	ErrNotFound = errors.New("transaction not found")
+	// ErrRejected is returned when the service refuses a transaction as invalid;
+	// sending it again will not help
+	ErrRejected = errors.New("transaction rejected")
+	// ErrCircuitOpen is returned without calling the service while it is failing
+	ErrCircuitOpen = errors.New("transaction service circuit open")
+)
+
+// Transaction is one side of a trade: a user buying or selling shares of an option
+type Transaction struct {
+	ID       string
+	UserID   string
+	MarketID string
+	OptionID string
+	Type     string
+	Shares   float64
+	// PricePerShare is the trade's average price
+	PricePerShare float64
+	CreatedAt     time.Time
+}
+
+// Config holds the client's connection settings
+type Config struct {
+	// URL is the service's base URL, e.g. http://localhost:5555
+	URL string
+	// Timeout bounds each attempt
+	Timeout time.Duration
+	// Retries is how many times a failed call is retried, waiting RetryBackoff
+	// and then twice as long before each further retry
+	Retries      int
+	RetryBackoff time.Duration
+	// After BreakerFailures consecutive failed calls the client stops calling the
+	// service for BreakerCooldown, then lets one call through to probe it
+	BreakerFailures int
+	BreakerCooldown time.Duration
+}
+
+// Client calls the transaction service's /transactions API
+type Client struct {
+	baseURL string
+	http    *http.Client
+	retries int
+	backoff time.Duration
+	breaker *breaker
+}
+
+// New creates a client for the service at cfg.URL
+func New(cfg Config) *Client {
+	return &Client{
+		baseURL: strings.TrimRight(cfg.URL, "/"),
+		http:    &http.Client{Timeout: cfg.Timeout},
+		retries: cfg.Retries,
+		backoff: cfg.RetryBackoff,
+		breaker: newBreaker(cfg.BreakerFailures, cfg.BreakerCooldown),
+	}
+}
+
+// Create records t, which must carry its ID. Creating the same ID again is safe:
+// every attempt first checks whether t was recorded already, e.g. by an earlier
+// call that failed after it, and returns that record. An ID recorded with other
+// terms yields ErrRejected.
+func (c *Client) Create(ctx context.Context, t Transaction) (_ *Transaction, err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "txservice.Create")
+	defer func() { tracing.End(span, err) }()
+	span.SetAttributes(attribute.String("transaction.id", t.ID), attribute.String("market.id", t.MarketID))
+
+	if err := validate(t); err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(toWire(t))
+	if err != nil {
+		return nil, fmt.Errorf("marshal transaction: %w", err)
+	}
+
+	// The service answers a duplicate ID with a server error, so it is looked up first
+	var created wireTransaction
+	err = c.call(ctx, func(ctx context.Context) error {
+		if err := c.do(ctx, http.MethodGet, "/transactions/"+url.PathEscape(t.ID), nil, &created); !errors.Is(err, ErrNotFound) {
+			return err
+		}
+		return c.do(ctx, http.MethodPost, "/transactions", body, &created)
+	})
+	if err != nil {
+		return nil, err
+	}
+	recorded, err := created.transaction()
+	if err != nil {
+		return nil, err
+	}
+	if !sameTerms(recorded, t) {
+		return nil, fmt.Errorf("%w: id %s is recorded with other terms", ErrRejected, t.ID)
+	}
+	return recorded, nil
+}
+
+// Get retrieves the transaction with id
+func (c *Client) Get(ctx context.Context, id string) (_ *Transaction, err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "txservice.Get")
+	defer func() { tracing.End(span, err) }()
+	span.SetAttributes(attribute.String("transaction.id", id))
+
+	var t wireTransaction
+	err = c.call(ctx, func(ctx context.Context) error {
+		return c.do(ctx, http.MethodGet, "/transactions/"+url.PathEscape(id), nil, &t)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return t.transaction()
+}
+
+// List retrieves the transactions the service holds for a market
+func (c *Client) List(ctx context.Context, marketID string) (_ []Transaction, err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "txservice.List")
+	defer func() { tracing.End(span, err) }()
+	span.SetAttributes(attribute.String("market.id", marketID))
+
+	var list []wireTransaction
+	err = c.call(ctx, func(ctx context.Context) error {
+		return c.do(ctx, http.MethodGet, "/transactions?market_id="+url.QueryEscape(marketID), nil, &list)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	transactions := make([]Transaction, 0, len(list))
+	for _, w := range list {
+		// A service that ignores the filter answers with every market's
+		if w.MarketID != marketID {
+			continue
+		}
+		t, err := w.transaction()
+		if err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, *t)
+	}
+	return transactions, nil
+}
+
+// call runs fn through the circuit breaker, retrying failures the service may
+// recover from. Answers such as ErrNotFound and ErrRejected count as success.
+func (c *Client) call(ctx context.Context, fn func(ctx context.Context) error) error {
+	if !c.breaker.allow() {
+		return ErrCircuitOpen
+	}
+
+	var err error
+	wait := c.backoff
+	for attempt := 0; ; attempt++ {
+		err = fn(ctx)
+		if !retryable(err) || attempt >= c.retries {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			c.breaker.record(false)
+			return fmt.Errorf("%w (retry cancelled: %v)", err, ctx.Err())
+		case <-time.After(wait):
+		}
+		wait *= 2
+	}
+	c.breaker.record(!retryable(err))
+	return err
+}
+
+// do sends one request and decodes a successful response into out
+func (c *Client) do(ctx context.Context, method, path string, body []byte, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return fmt.Errorf("build request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return ErrNotFound
+	case resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusTooManyRequests:
+		// The service is slow or busy rather than refusing the request
+		return fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, errorMessage(resp))
+	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+		return fmt.Errorf("%w: %s %s: %s", ErrRejected, method, path, errorMessage(resp))
+	case resp.StatusCode >= 300:
+		return fmt.Errorf("%s %s: unexpected status %s: %s", method, path, resp.Status, errorMessage(resp))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode %s %s: %w", method, path, err)
+	}
+	return nil
+}
+
+// retryable reports whether err is a failure of the service rather than an answer
+func retryable(err error) bool {
+	return err != nil && !errors.Is(err, ErrNotFound) && !errors.Is(err, ErrRejected)
+}
+
+// errorMessage reads the service's {"error": "..."} body
+func errorMessage(resp *http.Response) string {
+	var body struct {
+		Error string `json:"error"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<16)).Decode(&body); err != nil || body.Error == "" {
+		return resp.Status
+	}
+	return body.Error
+}
+
+// sameTerms reports whether a recorded transaction is t, as the service keeps it
+func sameTerms(recorded *Transaction, t Transaction) bool {
+	return recorded.ID == t.ID &&
+		recorded.UserID == UserUUID(t.UserID) &&
+		recorded.MarketID == t.MarketID &&
+		recorded.OptionID == t.OptionID &&
+		recorded.Type == t.Type &&
+		recorded.Shares == wireAmount(t.Shares) &&
+		recorded.PricePerShare == wireAmount(t.PricePerShare)
+}
+
+func validate(t Transaction) error {
+	if _, err := uuid.Parse(t.ID); err != nil {
+		return fmt.Errorf("%w: id must be a UUID", ErrRejected)
+	}
+	if t.Type != TypeBuy && t.Type != TypeSell {
+		return fmt.Errorf("%w: unknown type %q", ErrRejected, t.Type)
+	}
+	if !(t.Shares > 0 && t.Shares < maxAmount) || !(t.PricePerShare >= 0 && t.PricePerShare < maxAmount) {
+		return fmt.Errorf("%w: shares and price must be below %g", ErrRejected, float64(maxAmount))
+	}
+	return nil
+}
+
+// UserUUID returns the UUID the service records a user under: the ID itself when
+// it is a UUID, otherwise a UUID derived from it, so every ID maps to the same one
+func UserUUID(userID string) string {
+	if id, err := uuid.Parse(userID); err == nil {
+		return id.String()
+	}
+	return uuid.NewSHA1(uuid.NameSpaceURL, []byte("aegis:user:"+userID)).String()
+}
+
+// wireTransaction is a transaction as the service encodes it, with numbers and
+// times as strings
+type wireTransaction struct {
+	ID            string `json:"id,omitempty"`
+	UserID        string `json:"user_id"`
+	MarketID      string `json:"market_id"`
+	OptionID      string `json:"option_id"`
+	Type          string `json:"transaction_type"`
+	Shares        string `json:"number_of_shares"`
+	PricePerShare string `json:"price_per_share"`
+	CreatedAt     string `json:"created_at,omitempty"`
+}
+
+func toWire(t Transaction) wireTransaction {
+	w := wireTransaction{
+		ID:            t.ID,
+		UserID:        UserUUID(t.UserID),
+		MarketID:      t.MarketID,
+		OptionID:      t.OptionID,
+		Type:          t.Type,
+		Shares:        formatAmount(t.Shares),
+		PricePerShare: formatAmount(t.PricePerShare),
+	}
+	if !t.CreatedAt.IsZero() {
+		w.CreatedAt = t.CreatedAt.UTC().Format(timeLayout)
+	}
+	return w
+}
+
+// formatAmount encodes x with the four decimals the service keeps
+func formatAmount(x float64) string {
+	return strconv.FormatFloat(x, 'f', 4, 64)
+}
+
+// wireAmount is x as the service records it
+func wireAmount(x float64) float64 {
+	v, _ := strconv.ParseFloat(formatAmount(x), 64)
+	return v
+}
+
+func (w wireTransaction) transaction() (*Transaction, error) {
+	t := &Transaction{
+		ID:       w.ID,
+		UserID:   w.UserID,
+		MarketID: w.MarketID,
+		OptionID: w.OptionID,
+		Type:     w.Type,
+	}
+	var err error
+	if t.Shares, err = strconv.ParseFloat(w.Shares, 64); err != nil || math.IsNaN(t.Shares) {
+		return nil, fmt.Errorf("transaction %s: invalid number_of_shares %q", w.ID, w.Shares)
+	}
+	if t.PricePerShare, err = strconv.ParseFloat(w.PricePerShare, 64); err != nil || math.IsNaN(t.PricePerShare) {
+		return nil, fmt.Errorf("transaction %s: invalid price_per_share %q", w.ID, w.PricePerShare)
+	}
+	if w.CreatedAt != "" {
+		if t.CreatedAt, err = time.ParseInLocation(timeLayout, w.CreatedAt, time.UTC); err != nil {
+			return nil, fmt.Errorf("transaction %s: invalid created_at %q", w.ID, w.CreatedAt)
+		}
+	}
+	return t, nil
+}
@@ -0,0 +1,295 @@
+package txservice
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+	"github.com/ec332/aegis/market/internal/txservice/txstub"
+	"github.com/go-chi/chi/v5"
+)
+
+func transaction() Transaction {
+	return Transaction{
+		ID:            "6f1c2b1e-4a8e-4d4b-9a39-0c0f3b7a1d01",
+		UserID:        "alice",
+		MarketID:      "0b7e8f6a-2c1d-4e5f-8a9b-1c2d3e4f5a6b",
+		OptionID:      "9a8b7c6d-5e4f-4a3b-8c2d-1e0f9a8b7c6d",
+		Type:          TypeBuy,
+		Shares:        12.5,
+		PricePerShare: 0.41236,
+		CreatedAt:     time.Date(2026, 3, 1, 14, 30, 5, 123456000, time.FixedZone("CET", 3600)),
+	}
+}
+
+// stub serves the in-memory transaction service behind wrap, if set
+func stub(t *testing.T, wrap func(http.Handler) http.Handler) (*txstub.Store, *httptest.Server) {
+	t.Helper()
+	store := txstub.New()
+	r := chi.NewRouter()
+	if wrap != nil {
+		r.Use(wrap)
+	}
+	store.Routes(r)
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	return store, srv
+}
+
+func client(url string, retries, breakerFailures int) *Client {
+	return New(Config{
+		URL:             url,
+		Timeout:         time.Second,
+		Retries:         retries,
+		RetryBackoff:    time.Millisecond,
+		BreakerFailures: breakerFailures,
+		BreakerCooldown: 50 * time.Millisecond,
+	})
+}
+
+// status answers every request with code
+func status(code int, calls *atomic.Int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		w.Write([]byte(`{"error": "nope"}`))
+	}))
+}
+
+func TestCreateWireEncoding(t *testing.T) {
+	var posted map[string]string
+	_, srv := stub(t, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodPost {
+				if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+					t.Errorf("Content-Type = %q, want application/json", ct)
+				}
+				body, err := io.ReadAll(r.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				r.Body = io.NopCloser(bytes.NewReader(body))
+				if err := json.Unmarshal(body, &posted); err != nil {
+					t.Fatalf("POST body is not a JSON object of strings: %v", err)
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+
+	tx := transaction()
+	got, err := client(srv.URL, 0, 0).Create(context.Background(), tx)
+	if err != nil {
+		t.Fatalf("Create error = %v", err)
+	}
+
+	want := map[string]string{
+		"id":               tx.ID,
+		"user_id":          UserUUID("alice"),
+		"market_id":        tx.MarketID,
+		"option_id":        tx.OptionID,
+		"transaction_type": "BUY",
+		"number_of_shares": "12.5000",
+		"price_per_share":  "0.4124",
+		"created_at":       "2026-03-01 13:30:05.123456",
+	}
+	for k, v := range want {
+		if posted[k] != v {
+			t.Errorf("posted %s = %q, want %q", k, posted[k], v)
+		}
+	}
+	if got.UserID != UserUUID("alice") || got.Shares != 12.5 || got.PricePerShare != 0.4124 {
+		t.Errorf("Create = %+v, want the recorded terms", got)
+	}
+	if !got.CreatedAt.Equal(tx.CreatedAt) {
+		t.Errorf("created at = %v, want %v", got.CreatedAt, tx.CreatedAt)
+	}
+}
+
+func TestWireDecodeErrors(t *testing.T) {
+	tests := []wireTransaction{
+		{ID: "a", Shares: "many", PricePerShare: "1"},
+		{ID: "b", Shares: "1", PricePerShare: "NaN"},
+		{ID: "c", Shares: "1", PricePerShare: "1", CreatedAt: "yesterday"},
+	}
+	for _, w := range tests {
+		if _, err := w.transaction(); err == nil {
+			t.Errorf("transaction(%+v) error = nil, want an error", w)
+		}
+	}
+}
+
+func TestCreateIsIdempotent(t *testing.T) {
+	store, srv := stub(t, nil)
+	c := client(srv.URL, 0, 0)
+	tx := transaction()
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.Create(context.Background(), tx); err != nil {
+			t.Fatalf("Create #%d error = %v", i+1, err)
+		}
+	}
+	if n := store.Len(); n != 1 {
+		t.Errorf("stub holds %d transactions, want 1", n)
+	}
+
+	other := tx
+	other.Shares = 3
+	if _, err := c.Create(context.Background(), other); !errors.Is(err, ErrRejected) {
+		t.Errorf("Create with other terms error = %v, want %v", err, ErrRejected)
+	}
+}
+
+func TestListFiltersByMarket(t *testing.T) {
+	_, srv := stub(t, nil)
+	c := client(srv.URL, 0, 0)
+	ctx := context.Background()
+
+	tx := transaction()
+	other := tx
+	other.ID, other.MarketID = "7c1d2e3f-4a5b-4c6d-8e7f-9a0b1c2d3e4f", "1f2e3d4c-5b6a-4978-8695-a4b3c2d1e0f9"
+	for _, x := range []Transaction{tx, other} {
+		if _, err := c.Create(ctx, x); err != nil {
+			t.Fatalf("Create error = %v", err)
+		}
+	}
+
+	list, err := c.List(ctx, tx.MarketID)
+	if err != nil {
+		t.Fatalf("List error = %v", err)
+	}
+	if len(list) != 1 || list[0].ID != tx.ID {
+		t.Errorf("List = %+v, want only %s", list, tx.ID)
+	}
+}
+
+func TestCallRetries(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		retries int
+		calls   int32
+		err     error
+	}{
+		{name: "server error", status: http.StatusServiceUnavailable, retries: 2, calls: 3},
+		{name: "request timeout", status: http.StatusRequestTimeout, retries: 2, calls: 3},
+		{name: "too many requests", status: http.StatusTooManyRequests, retries: 1, calls: 2},
+		{name: "no retries", status: http.StatusBadGateway, retries: 0, calls: 1},
+		{name: "rejected", status: http.StatusBadRequest, retries: 2, calls: 1, err: ErrRejected},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			srv := status(tt.status, &calls)
+			defer srv.Close()
+
+			_, err := client(srv.URL, tt.retries, 0).Get(context.Background(), transaction().ID)
+			if err == nil {
+				t.Fatal("Get error = nil")
+			}
+			if tt.err != nil && !errors.Is(err, tt.err) {
+				t.Errorf("Get error = %v, want %v", err, tt.err)
+			}
+			if tt.err == nil && errors.Is(err, ErrRejected) {
+				t.Errorf("Get error = %v, must not be a rejection", err)
+			}
+			if n := calls.Load(); n != tt.calls {
+				t.Errorf("service called %d times, want %d", n, tt.calls)
+			}
+		})
+	}
+}
+
+func TestCreateRecoversAfterRetry(t *testing.T) {
+	var failures atomic.Int32
+	failures.Store(2)
+	store, srv := stub(t, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodPost && failures.Add(-1) >= 0 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+
+	if _, err := client(srv.URL, 2, 0).Create(context.Background(), transaction()); err != nil {
+		t.Fatalf("Create error = %v", err)
+	}
+	if n := store.Len(); n != 1 {
+		t.Errorf("stub holds %d transactions, want 1", n)
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	var calls atomic.Int32
+	var healthy atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	c := client(srv.URL, 0, 2)
+	ctx := context.Background()
+	id := transaction().ID
+	for i := 0; i < 2; i++ {
+		if _, err := c.Get(ctx, id); err == nil || errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("Get #%d error = %v, want the service's failure", i+1, err)
+		}
+	}
+	if _, err := c.Get(ctx, id); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Get with the breaker open error = %v, want %v", err, ErrCircuitOpen)
+	}
+	if n := calls.Load(); n != 2 {
+		t.Fatalf("service called %d times, want 2", n)
+	}
+
+	// After the cooldown one failing probe reopens it
+	time.Sleep(60 * time.Millisecond)
+	if _, err := c.Get(ctx, id); err == nil || errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("probe error = %v, want the service's failure", err)
+	}
+	if _, err := c.Get(ctx, id); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Get after a failed probe error = %v, want %v", err, ErrCircuitOpen)
+	}
+
+	// A successful probe closes it; not found is an answer, not a failure
+	healthy.Store(true)
+	time.Sleep(60 * time.Millisecond)
+	for i := 0; i < 3; i++ {
+		if _, err := c.Get(ctx, id); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("Get #%d after recovery error = %v, want %v", i+1, err, ErrNotFound)
+		}
+	}
+}
+
+func TestBreakerProbesOnce(t *testing.T) {
+	b := newBreaker(1, time.Millisecond)
+	b.record(false)
+	if b.allow() {
+		t.Fatal("allow() = true while open")
+	}
+	time.Sleep(2 * time.Millisecond)
+	if !b.allow() {
+		t.Fatal("allow() = false after the cooldown, want a probe")
+	}
+	if b.allow() {
+		t.Fatal("allow() = true while a probe is in flight")
+	}
+	b.record(true)
+	if !b.allow() || !b.allow() {
+		t.Fatal("allow() = false after a successful probe")
+	}
+}
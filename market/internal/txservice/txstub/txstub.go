@@ -0,0 +1,187 @@
+// Package txstub is an in-memory stand-in for the transaction service, serving
+// its /transactions API with numbers and times encoded as strings, for running
+// and testing trade recording without it.
+package txstub
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+const timeLayout = "2006-01-02 15:04:05.999999"
+
+// transaction mirrors the service's JSON encoding
+type transaction struct {
+	ID            string `json:"id"`
+	UserID        string `json:"user_id"`
+	MarketID      string `json:"market_id"`
+	OptionID      string `json:"option_id"`
+	Type          string `json:"transaction_type"`
+	Shares        string `json:"number_of_shares"`
+	PricePerShare string `json:"price_per_share"`
+	CreatedAt     string `json:"created_at"`
+}
+
+// Store holds the transactions by ID
+type Store struct {
+	mu           sync.RWMutex
+	transactions map[string]transaction
+}
+
+// New creates an empty store
+func New() *Store {
+	return &Store{transactions: map[string]transaction{}}
+}
+
+// Routes registers the service's /transactions API on r
+func (s *Store) Routes(r chi.Router) {
+	r.Get("/transactions", s.list)
+	r.Post("/transactions", s.create)
+	r.Get("/transactions/{id}", s.get)
+	r.Put("/transactions/{id}", s.update)
+	r.Delete("/transactions/{id}", s.delete)
+}
+
+// Len returns how many transactions the store holds
+func (s *Store) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.transactions)
+}
+
+func (s *Store) list(w http.ResponseWriter, r *http.Request) {
+	marketID := r.URL.Query().Get("market_id")
+	s.mu.RLock()
+	list := make([]transaction, 0, len(s.transactions))
+	for _, t := range s.transactions {
+		if marketID == "" || t.MarketID == marketID {
+			list = append(list, t)
+		}
+	}
+	s.mu.RUnlock()
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].CreatedAt != list[j].CreatedAt {
+			return list[i].CreatedAt < list[j].CreatedAt
+		}
+		return list[i].ID < list[j].ID
+	})
+
+	writeJSON(w, http.StatusOK, list)
+}
+
+func (s *Store) get(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	t, ok := s.transactions[chi.URLParam(r, "id")]
+	s.mu.RUnlock()
+	if !ok {
+		writeJSON(w, http.StatusNotFound, struct{}{})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, t)
+}
+
+func (s *Store) create(w http.ResponseWriter, r *http.Request) {
+	var t transaction
+	if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid JSON body")
+		return
+	}
+	if t.ID == "" {
+		t.ID = uuid.New().String()
+	}
+	if t.CreatedAt == "" {
+		t.CreatedAt = time.Now().UTC().Format(timeLayout)
+	}
+	if err := validate(t); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.transactions[t.ID]; exists {
+		// The service surfaces the primary key violation as a database error
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("duplicate key value violates unique constraint: id %s", t.ID))
+		return
+	}
+	s.transactions[t.ID] = t
+	writeJSON(w, http.StatusCreated, t)
+}
+
+func (s *Store) update(w http.ResponseWriter, r *http.Request) {
+	var t transaction
+	if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid JSON body")
+		return
+	}
+	t.ID = chi.URLParam(r, "id")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	existing, ok := s.transactions[t.ID]
+	if !ok {
+		writeJSON(w, http.StatusNotFound, struct{}{})
+		return
+	}
+	if t.CreatedAt == "" {
+		t.CreatedAt = existing.CreatedAt
+	}
+	if err := validate(t); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	s.transactions[t.ID] = t
+	writeJSON(w, http.StatusOK, t)
+}
+
+func (s *Store) delete(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	_, ok := s.transactions[chi.URLParam(r, "id")]
+	delete(s.transactions, chi.URLParam(r, "id"))
+	s.mu.Unlock()
+	if !ok {
+		writeJSON(w, http.StatusNotFound, struct{}{})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// validate applies the service's column constraints
+func validate(t transaction) error {
+	for name, id := range map[string]string{"id": t.ID, "user_id": t.UserID, "market_id": t.MarketID, "option_id": t.OptionID} {
+		if _, err := uuid.Parse(id); err != nil {
+			return fmt.Errorf("The %s column must be a UUID", name)
+		}
+	}
+	if t.Type == "" || len(t.Type) > 50 {
+		return fmt.Errorf("The transaction_type column must be 1 to 50 characters")
+	}
+	for name, value := range map[string]string{"number_of_shares": t.Shares, "price_per_share": t.PricePerShare} {
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return fmt.Errorf("Type error in the %s field", name)
+		}
+	}
+	if _, err := time.Parse(timeLayout, t.CreatedAt); err != nil {
+		return fmt.Errorf("Type error in the created_at field")
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
@@ -1,40 +1,524 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"math"
+	"net/url"
 	"os"
+	"strings"
+	"time"
+	"github.com/ec332/aegis/market/pkg/models"
 	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
 )
 
+// Config is the market service configuration. Values are resolved in order:
+// built-in defaults, then the YAML config file (if any), then env variables.
 type Config struct {
-	Port        string
-	DatabaseURL string
-	RedisURL    string
+	Server       ServerConfig       `yaml:"server"`
+	Database     DatabaseConfig     `yaml:"database"`
+	Redis        RedisConfig        `yaml:"redis"`
+	CORS         CORSConfig         `yaml:"cors"`
+	SSE          SSEConfig          `yaml:"sse"`
+	Log          LogConfig          `yaml:"log"`
+	Tracing      TracingConfig      `yaml:"tracing"`
+	Health       HealthConfig       `yaml:"health"`
+	Auth         AuthConfig         `yaml:"auth"`
+	RateLimit    RateLimitConfig    `yaml:"rate_limit"`
+	Markets      MarketsConfig      `yaml:"markets"`
+	Oracle       OracleConfig       `yaml:"oracle"`
+	Transactions TransactionsConfig `yaml:"transactions"`
+	Ingest       IngestConfig       `yaml:"ingest"`
+	Features     map[string]bool    `yaml:"features"`
 }
 
-// Load loads configuration from env variables
-func Load() (*Config, error) {
+// ServerConfig holds HTTP server settings
+type ServerConfig struct {
+	Port            string        `yaml:"port"`
+	ReadTimeout     time.Duration `yaml:"read_timeout"`
+	WriteTimeout    time.Duration `yaml:"write_timeout"`
+	IdleTimeout     time.Duration `yaml:"idle_timeout"`
+	RequestTimeout  time.Duration `yaml:"request_timeout"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+	DrainDelay      time.Duration `yaml:"drain_delay"`
+}
+
+// DatabaseConfig holds PostgreSQL connection settings
+type DatabaseConfig struct {
+	URL             string        `yaml:"url"`
+	MaxOpenConns    int           `yaml:"max_open_conns"`
+	MaxIdleConns    int           `yaml:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time"`
+}
+
+// RedisConfig holds Redis connection settings
+type RedisConfig struct {
+	URL string `yaml:"url"`
+}
+
+// CORSConfig holds cross-origin settings. Allowed origins are reloadable.
+type CORSConfig struct {
+	AllowedOrigins   []string `yaml:"allowed_origins"`
+	AllowCredentials bool     `yaml:"allow_credentials"`
+	MaxAge           int      `yaml:"max_age"`
+}
+
+// SSEConfig holds streaming settings. The ping interval is reloadable.
+type SSEConfig struct {
+	PingInterval time.Duration `yaml:"ping_interval"`
+}
+
+// LogConfig holds logging settings. Both fields are reloadable.
+type LogConfig struct {
+	Level      string  `yaml:"level"`
+	SampleRate float64 `yaml:"sample_rate"`
+}
+
+// TracingConfig holds OpenTelemetry settings
+type TracingConfig struct {
+	Exporter    string `yaml:"exporter"`
+	ServiceName string `yaml:"service_name"`
+}
+
+// HealthConfig holds probe settings
+type HealthConfig struct {
+	CheckTimeout time.Duration `yaml:"check_timeout"`
+}
+
+// AuthConfig holds credentials accepted from internal callers
+type AuthConfig struct {
+	// ServiceKeys are accepted in the X-Service-Key header
+	ServiceKeys []string `yaml:"service_keys"`
+}
+
+// RateLimitConfig holds per-route request limits, enforced across replicas via Redis
+type RateLimitConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Policies by route policy name; "default" applies to routes without their own policy
+	Policies map[string]RateLimitPolicy `yaml:"policies"`
+	// MaxStreamsPerClient caps concurrent SSE connections per user or IP
+	MaxStreamsPerClient int `yaml:"max_streams_per_client"`
+}
+
+// RateLimitPolicy allows Rate requests per Period on average, with bursts of up to Burst
+type RateLimitPolicy struct {
+	Rate   int           `yaml:"rate"`
+	Period time.Duration `yaml:"period"`
+	Burst  int           `yaml:"burst"`
+}
+
+// MarketsConfig holds market lifecycle rules
+type MarketsConfig struct {
+	// RequiredApprovals is the number of reviewers other than the creator who must
+	// approve a market before it can go active
+	RequiredApprovals int `yaml:"required_approvals"`
+	// DisputeWindow is how long a proposed resolution can be disputed before it is final
+	DisputeWindow time.Duration `yaml:"dispute_window"`
+	// DisputeBond is the amount a user stakes to dispute a proposed resolution
+	DisputeBond float64 `yaml:"dispute_bond"`
+	// SchedulerInterval is how often due markets are moved to resolving, oracles are
+	// consulted and undisputed resolutions are finalized
+	SchedulerInterval time.Duration `yaml:"scheduler_interval"`
+	// MaxOpenBuyValue caps what one user's resting buy orders, across markets, would
+	// pay if they all filled; 0 disables the cap
+	MaxOpenBuyValue float64 `yaml:"max_open_buy_value"`
+	// DefaultFeeRate and DefaultMinFee are the trading fees of markets created without
+	// their own
+	DefaultFeeRate float64 `yaml:"default_fee_rate"`
+	DefaultMinFee  float64 `yaml:"default_min_fee"`
+	// FeeSplit divides collected fees between the platform, market creators and
+	// liquidity providers
+	FeeSplit FeeSplitConfig `yaml:"fee_split"`
+	// Limits are the risk limits of markets without their own
+	Limits LimitsConfig `yaml:"limits"`
+	// CircuitBreaker halts trading on markets whose prices move too fast
+	CircuitBreaker CircuitBreakerConfig `yaml:"circuit_breaker"`
+}
+
+// FeeSplitConfig holds the fractions of each fee paid to its recipients; they sum to 1
+type FeeSplitConfig struct {
+	Platform           float64 `yaml:"platform"`
+	Creator            float64 `yaml:"creator"`
+	LiquidityProviders float64 `yaml:"liquidity_providers"`
+}
+
+// LimitsConfig holds the default risk limits; 0 disables a limit
+type LimitsConfig struct {
+	// MaxPosition caps the shares of an option one user may hold, counting open buy orders
+	MaxPosition float64 `yaml:"max_position"`
+	// MaxTradeShares caps the shares of a single order
+	MaxTradeShares float64 `yaml:"max_trade_shares"`
+	// MaxTreasuryExposure caps what the treasury stands to lose on a market's outcome
+	MaxTreasuryExposure float64 `yaml:"max_treasury_exposure"`
+}
+
+// CircuitBreakerConfig halts a market for Cooldown when an option's price moves by
+// more than PriceMove (in price units, so 0.2 is 20 points) within Window; a
+// PriceMove of 0 disables it
+type CircuitBreakerConfig struct {
+	PriceMove float64       `yaml:"price_move"`
+	Window    time.Duration `yaml:"window"`
+	Cooldown  time.Duration `yaml:"cooldown"`
+}
+
+// OracleConfig holds settings for resolvers that fetch outcomes from data sources
+type OracleConfig struct {
+	// HTTPTimeout bounds each request made by the HTTP resolver
+	HTTPTimeout time.Duration `yaml:"http_timeout"`
+	// MaxResponseBytes caps the size of a source response
+	MaxResponseBytes int `yaml:"max_response_bytes"`
+	// AllowedHosts, when not empty, lists the only hosts sources may be on
+	AllowedHosts []string `yaml:"allowed_hosts"`
+	// AllowPrivate lets sources be on loopback, private and link-local addresses,
+	// e.g. a local stub feed
+	AllowPrivate bool `yaml:"allow_private"`
+}
+
+// TransactionsConfig holds settings for recording trades with the transaction service
+type TransactionsConfig struct {
+	// URL is the service's base URL; trades are not recorded when it is empty
+	URL string `yaml:"url"`
+	// Timeout bounds each request
+	Timeout time.Duration `yaml:"timeout"`
+	// Retries is how many times a failed request is retried, backing off from
+	// RetryBackoff
+	Retries      int           `yaml:"retries"`
+	RetryBackoff time.Duration `yaml:"retry_backoff"`
+	// BreakerFailures consecutive failed requests stop requests for BreakerCooldown;
+	// 0 disables the circuit breaker
+	BreakerFailures int           `yaml:"breaker_failures"`
+	BreakerCooldown time.Duration `yaml:"breaker_cooldown"`
+	// RecordInterval is how often trades awaiting recording are sent
+	RecordInterval time.Duration `yaml:"record_interval"`
+	// ReconcileInterval is how often pools and positions are compared with the
+	// recorded transactions; 0 disables the check
+	ReconcileInterval time.Duration `yaml:"reconcile_interval"`
+}
+
+// IngestConfig holds settings for consuming trade events from a Redis Stream
+type IngestConfig struct {
+	// Stream is the stream other services push trade events to; events are not
+	// consumed when it is empty
+	Stream string `yaml:"stream"`
+	// Group is the consumer group replicas share; Consumer names this replica and
+	// defaults to the hostname
+	Group    string `yaml:"group"`
+	Consumer string `yaml:"consumer"`
+	// DeadLetterStream receives events that cannot be applied; defaults to the
+	// stream's name followed by ":dead"
+	DeadLetterStream string `yaml:"dead_letter_stream"`
+	// BatchSize bounds the entries read at once; a read waits up to Block for new ones
+	BatchSize int           `yaml:"batch_size"`
+	Block     time.Duration `yaml:"block"`
+	// MaxDeliveries is how often an event is tried before it is dead-lettered
+	MaxDeliveries int `yaml:"max_deliveries"`
+	// ClaimIdle is how long an entry stays unacknowledged before another replica
+	// takes it over
+	ClaimIdle time.Duration `yaml:"claim_idle"`
+}
+
+// Default returns the built-in configuration
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Port:            "8080",
+			ReadTimeout:     15 * time.Second,
+			WriteTimeout:    15 * time.Second,
+			IdleTimeout:     60 * time.Second,
+			RequestTimeout:  10 * time.Second,
+			ShutdownTimeout: 30 * time.Second,
+			DrainDelay:      5 * time.Second,
+		},
+		Database: DatabaseConfig{
+			MaxOpenConns:    25,
+			MaxIdleConns:    25,
+			ConnMaxLifetime: 30 * time.Minute,
+			ConnMaxIdleTime: 5 * time.Minute,
+		},
+		Redis: RedisConfig{
+			URL: "redis://localhost:6379",
+		},
+		CORS: CORSConfig{
+			AllowedOrigins: []string{"*"},
+			MaxAge:         300,
+		},
+		SSE: SSEConfig{
+			PingInterval: 30 * time.Second,
+		},
+		Log: LogConfig{
+			Level:      "info",
+			SampleRate: 1,
+		},
+		Tracing: TracingConfig{
+			Exporter:    "none",
+			ServiceName: "market-service",
+		},
+		Health: HealthConfig{
+			CheckTimeout: 2 * time.Second,
+		},
+		RateLimit: RateLimitConfig{
+			Enabled: true,
+			Policies: map[string]RateLimitPolicy{
+				"default":          {Rate: 20, Period: time.Second, Burst: 40},
+				"markets.create":   {Rate: 5, Period: time.Minute, Burst: 5},
+				"markets.update":   {Rate: 30, Period: time.Minute, Burst: 30},
+				"markets.trade":    {Rate: 120, Period: time.Minute, Burst: 60},
+				"markets.stream":   {Rate: 10, Period: time.Minute, Burst: 10},
+				"categories.write": {Rate: 30, Period: time.Minute, Burst: 30},
+			},
+			MaxStreamsPerClient: 5,
+		},
+		Markets: MarketsConfig{
+			RequiredApprovals: 2,
+			DisputeWindow:     24 * time.Hour,
+			DisputeBond:       100,
+			SchedulerInterval: 30 * time.Second,
+			MaxOpenBuyValue:   10000,
+			DefaultFeeRate:    0.02,
+			DefaultMinFee:     0,
+			FeeSplit: FeeSplitConfig{
+				Platform:           0.5,
+				Creator:            0.2,
+				LiquidityProviders: 0.3,
+			},
+			CircuitBreaker: CircuitBreakerConfig{
+				PriceMove: 0.25,
+				Window:    5 * time.Minute,
+				Cooldown:  15 * time.Minute,
+			},
+		},
+		Oracle: OracleConfig{
+			HTTPTimeout:      10 * time.Second,
+			MaxResponseBytes: 1 << 20,
+		},
+		Transactions: TransactionsConfig{
+			Timeout:         5 * time.Second,
+			Retries:         2,
+			RetryBackoff:    200 * time.Millisecond,
+			BreakerFailures: 5,
+			BreakerCooldown: 30 * time.Second,
+			RecordInterval:  5 * time.Second,
+		},
+		Ingest: IngestConfig{
+			Group:         "market",
+			BatchSize:     100,
+			Block:         5 * time.Second,
+			MaxDeliveries: 5,
+			ClaimIdle:     time.Minute,
+		},
+		Features: map[string]bool{},
+	}
+}
+
+// Load loads configuration from the YAML file at path (optional) and env variables
+func Load(path string) (*Config, error) {
 	_ = godotenv.Load()
 
-	port := getEnv("PORT", "8080")
-	databaseURL := getEnv("DATABASE_URL", "")
-	if databaseURL == "" {
-		return nil, fmt.Errorf("DATABASE_URL is required")
+	cfg := Default()
+	if path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+	// Report malformed env values together with invalid settings
+	problems := cfg.applyEnv()
+	problems = append(problems, cfg.validate()...)
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("invalid configuration:\n%w", errors.Join(problems...))
 	}
 
-	redisURL := getEnv("REDIS_URL", "redis://localhost:6379")
+	return cfg, nil
+}
 
-	return &Config{
-		Port:        port,
-		DatabaseURL: databaseURL,
-		RedisURL:    redisURL,
-	}, nil
+// FeatureEnabled reports whether the named feature flag is on
+func (c *Config) FeatureEnabled(name string) bool {
+	return c.Features[name]
+}
+
+func (c *Config) loadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open config file: %w", err)
+	}
+	defer f.Close()
+
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil {
+		return fmt.Errorf("parse config file %s: %w", path, err)
+	}
+	if c.Features == nil {
+		c.Features = map[string]bool{}
+	}
+	return nil
 }
 
-// Fallback if no env variable is set
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+// validate returns an error for every invalid setting
+func (c *Config) validate() []error {
+	var errs []error
+	fail := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if c.Server.Port == "" {
+		fail("server.port (PORT) is required")
+	}
+	durations := []struct {
+		name  string
+		value time.Duration
+	}{
+		{"server.read_timeout (READ_TIMEOUT)", c.Server.ReadTimeout},
+		{"server.write_timeout (WRITE_TIMEOUT)", c.Server.WriteTimeout},
+		{"server.idle_timeout (IDLE_TIMEOUT)", c.Server.IdleTimeout},
+		{"server.request_timeout (REQUEST_TIMEOUT)", c.Server.RequestTimeout},
+		{"server.shutdown_timeout (SHUTDOWN_TIMEOUT)", c.Server.ShutdownTimeout},
+		{"sse.ping_interval (SSE_PING_INTERVAL)", c.SSE.PingInterval},
+		{"health.check_timeout (HEALTH_CHECK_TIMEOUT)", c.Health.CheckTimeout},
+	}
+	for _, d := range durations {
+		if d.value <= 0 {
+			fail("%s must be positive", d.name)
+		}
+	}
+	if c.Server.DrainDelay < 0 {
+		fail("server.drain_delay (SHUTDOWN_DRAIN_DELAY) must not be negative")
+	}
+
+	if c.Database.URL == "" {
+		fail("database.url (DATABASE_URL) is required")
+	}
+	if c.Database.MaxOpenConns < 1 {
+		fail("database.max_open_conns (DB_MAX_OPEN_CONNS) must be at least 1")
+	}
+	if c.Database.MaxIdleConns < 0 || c.Database.MaxIdleConns > c.Database.MaxOpenConns {
+		fail("database.max_idle_conns (DB_MAX_IDLE_CONNS) must be between 0 and max_open_conns")
+	}
+
+	if c.Redis.URL == "" {
+		fail("redis.url (REDIS_URL) is required")
+	}
+
+	if len(c.CORS.AllowedOrigins) == 0 {
+		fail("cors.allowed_origins (CORS_ALLOWED_ORIGINS) must list at least one origin")
+	}
+	for _, origin := range c.CORS.AllowedOrigins {
+		if origin == "*" && c.CORS.AllowCredentials {
+			fail("cors.allow_credentials cannot be combined with a wildcard origin")
+		}
+	}
+
+	switch strings.ToLower(c.Log.Level) {
+	case "debug", "info", "warn", "warning", "error":
+	default:
+		fail("log.level (LOG_LEVEL) must be one of debug, info, warn, error")
 	}
-	return defaultValue
+	if c.Log.SampleRate < 0 || c.Log.SampleRate > 1 {
+		fail("log.sample_rate (LOG_SAMPLE_RATE) must be between 0 and 1")
+	}
+
+	switch c.Tracing.Exporter {
+	case "none", "stdout", "otlp":
+	default:
+		fail("tracing.exporter (TRACING_EXPORTER) must be one of none, stdout, otlp")
+	}
+
+	for i, key := range c.Auth.ServiceKeys {
+		if len(key) < 16 {
+			fail("auth.service_keys[%d] must be at least 16 characters", i)
+		}
+	}
+
+	if _, ok := c.RateLimit.Policies["default"]; !ok {
+		fail("rate_limit.policies must include a default policy")
+	}
+	for name, p := range c.RateLimit.Policies {
+		if p.Rate < 1 || p.Period <= 0 || p.Burst < 1 {
+			fail("rate_limit.policies.%s needs rate >= 1, a positive period and burst >= 1", name)
+		}
+	}
+	if c.RateLimit.MaxStreamsPerClient < 0 {
+		fail("rate_limit.max_streams_per_client (RATE_LIMIT_MAX_STREAMS) must not be negative")
+	}
+
+	if c.Markets.RequiredApprovals < 0 {
+		fail("markets.required_approvals (REVIEW_REQUIRED_APPROVALS) must not be negative")
+	}
+	if c.Markets.DisputeWindow <= 0 {
+		fail("markets.dispute_window (DISPUTE_WINDOW) must be positive")
+	}
+	if c.Markets.DisputeBond < 0 {
+		fail("markets.dispute_bond (DISPUTE_BOND) must not be negative")
+	}
+	if c.Markets.SchedulerInterval <= 0 {
+		fail("markets.scheduler_interval (SCHEDULER_INTERVAL) must be positive")
+	}
+	if c.Markets.MaxOpenBuyValue < 0 {
+		fail("markets.max_open_buy_value (MAX_OPEN_BUY_VALUE) must not be negative")
+	}
+	if c.Markets.DefaultFeeRate < 0 || c.Markets.DefaultFeeRate > models.MaxFeeRate {
+		fail("markets.default_fee_rate (DEFAULT_FEE_RATE) must be between 0 and %g", models.MaxFeeRate)
+	}
+	if c.Markets.DefaultMinFee < 0 {
+		fail("markets.default_min_fee (DEFAULT_MIN_FEE) must not be negative")
+	}
+	split := c.Markets.FeeSplit
+	if split.Platform < 0 || split.Creator < 0 || split.LiquidityProviders < 0 ||
+		math.Abs(split.Platform+split.Creator+split.LiquidityProviders-1) > 1e-9 {
+		fail("markets.fee_split (FEE_SPLIT_*) must be non-negative fractions summing to 1")
+	}
+	limits := c.Markets.Limits
+	if limits.MaxPosition < 0 || limits.MaxTradeShares < 0 || limits.MaxTreasuryExposure < 0 {
+		fail("markets.limits (MAX_POSITION, MAX_TRADE_SHARES, MAX_TREASURY_EXPOSURE) must not be negative")
+	}
+	breaker := c.Markets.CircuitBreaker
+	if breaker.PriceMove < 0 || breaker.PriceMove >= 1 {
+		fail("markets.circuit_breaker.price_move (HALT_PRICE_MOVE) must be at least 0 and below 1")
+	}
+	if breaker.PriceMove > 0 && (breaker.Window <= 0 || breaker.Cooldown <= 0) {
+		fail("markets.circuit_breaker window and cooldown (HALT_WINDOW, HALT_COOLDOWN) must be positive")
+	}
+
+	if c.Oracle.HTTPTimeout <= 0 {
+		fail("oracle.http_timeout (ORACLE_HTTP_TIMEOUT) must be positive")
+	}
+	if c.Oracle.MaxResponseBytes < 1 {
+		fail("oracle.max_response_bytes (ORACLE_MAX_RESPONSE_BYTES) must be at least 1")
+	}
+
+	if tx := c.Transactions; tx.URL != "" {
+		if u, err := url.Parse(tx.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			fail("transactions.url (TX_SERVICE_URL) must be an absolute http or https URL")
+		}
+		if tx.Timeout <= 0 || tx.RecordInterval <= 0 {
+			fail("transactions.timeout and record_interval (TX_SERVICE_TIMEOUT, TX_RECORD_INTERVAL) must be positive")
+		}
+		if tx.Retries < 0 || tx.RetryBackoff < 0 {
+			fail("transactions.retries and retry_backoff (TX_SERVICE_RETRIES, TX_SERVICE_RETRY_BACKOFF) must not be negative")
+		}
+		if tx.BreakerFailures < 0 || (tx.BreakerFailures > 0 && tx.BreakerCooldown <= 0) {
+			fail("transactions.breaker_failures (TX_SERVICE_BREAKER_FAILURES) must not be negative and breaker_cooldown (TX_SERVICE_BREAKER_COOLDOWN) must be positive")
+		}
+		if tx.ReconcileInterval < 0 {
+			fail("transactions.reconcile_interval (TX_RECONCILE_INTERVAL) must not be negative")
+		}
+	}
+
+	if in := c.Ingest; in.Stream != "" {
+		if in.Group == "" {
+			fail("ingest.group (INGEST_GROUP) is required")
+		}
+		if in.DeadLetterStream == in.Stream {
+			fail("ingest.dead_letter_stream (INGEST_DEAD_LETTER_STREAM) must differ from the stream")
+		}
+		if in.BatchSize < 1 || in.MaxDeliveries < 1 {
+			fail("ingest.batch_size and max_deliveries (INGEST_BATCH_SIZE, INGEST_MAX_DELIVERIES) must be at least 1")
+		}
+		if in.Block <= 0 || in.ClaimIdle <= 0 {
+			fail("ingest.block and claim_idle (INGEST_BLOCK, INGEST_CLAIM_IDLE) must be positive")
+		}
+	}
+
+	return errs
 }
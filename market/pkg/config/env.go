@@ -0,0 +1,195 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// applyEnv overrides settings with any env variables that are set, returning
+// an error for each value that could not be parsed
+func (c *Config) applyEnv() []error {
+	e := &envReader{}
+
+	e.string("PORT", &c.Server.Port)
+	e.duration("READ_TIMEOUT", &c.Server.ReadTimeout)
+	e.duration("WRITE_TIMEOUT", &c.Server.WriteTimeout)
+	e.duration("IDLE_TIMEOUT", &c.Server.IdleTimeout)
+	e.duration("REQUEST_TIMEOUT", &c.Server.RequestTimeout)
+	e.duration("SHUTDOWN_TIMEOUT", &c.Server.ShutdownTimeout)
+	e.duration("SHUTDOWN_DRAIN_DELAY", &c.Server.DrainDelay)
+
+	e.string("DATABASE_URL", &c.Database.URL)
+	e.int("DB_MAX_OPEN_CONNS", &c.Database.MaxOpenConns)
+	e.int("DB_MAX_IDLE_CONNS", &c.Database.MaxIdleConns)
+	e.duration("DB_CONN_MAX_LIFETIME", &c.Database.ConnMaxLifetime)
+	e.duration("DB_CONN_MAX_IDLE_TIME", &c.Database.ConnMaxIdleTime)
+
+	e.string("REDIS_URL", &c.Redis.URL)
+
+	e.list("CORS_ALLOWED_ORIGINS", &c.CORS.AllowedOrigins)
+	e.bool("CORS_ALLOW_CREDENTIALS", &c.CORS.AllowCredentials)
+	e.int("CORS_MAX_AGE", &c.CORS.MaxAge)
+
+	e.duration("SSE_PING_INTERVAL", &c.SSE.PingInterval)
+
+	e.string("LOG_LEVEL", &c.Log.Level)
+	e.float("LOG_SAMPLE_RATE", &c.Log.SampleRate)
+
+	e.string("TRACING_EXPORTER", &c.Tracing.Exporter)
+	e.string("OTEL_SERVICE_NAME", &c.Tracing.ServiceName)
+
+	e.duration("HEALTH_CHECK_TIMEOUT", &c.Health.CheckTimeout)
+
+	e.list("SERVICE_KEYS", &c.Auth.ServiceKeys)
+
+	e.bool("RATE_LIMIT_ENABLED", &c.RateLimit.Enabled)
+	e.int("RATE_LIMIT_MAX_STREAMS", &c.RateLimit.MaxStreamsPerClient)
+
+	e.int("REVIEW_REQUIRED_APPROVALS", &c.Markets.RequiredApprovals)
+	e.duration("DISPUTE_WINDOW", &c.Markets.DisputeWindow)
+	e.float("DISPUTE_BOND", &c.Markets.DisputeBond)
+	e.duration("SCHEDULER_INTERVAL", &c.Markets.SchedulerInterval)
+	e.float("MAX_OPEN_BUY_VALUE", &c.Markets.MaxOpenBuyValue)
+	e.float("DEFAULT_FEE_RATE", &c.Markets.DefaultFeeRate)
+	e.float("DEFAULT_MIN_FEE", &c.Markets.DefaultMinFee)
+	e.float("FEE_SPLIT_PLATFORM", &c.Markets.FeeSplit.Platform)
+	e.float("FEE_SPLIT_CREATOR", &c.Markets.FeeSplit.Creator)
+	e.float("FEE_SPLIT_LP", &c.Markets.FeeSplit.LiquidityProviders)
+	e.float("MAX_POSITION", &c.Markets.Limits.MaxPosition)
+	e.float("MAX_TRADE_SHARES", &c.Markets.Limits.MaxTradeShares)
+	e.float("MAX_TREASURY_EXPOSURE", &c.Markets.Limits.MaxTreasuryExposure)
+	e.float("HALT_PRICE_MOVE", &c.Markets.CircuitBreaker.PriceMove)
+	e.duration("HALT_WINDOW", &c.Markets.CircuitBreaker.Window)
+	e.duration("HALT_COOLDOWN", &c.Markets.CircuitBreaker.Cooldown)
+
+	e.duration("ORACLE_HTTP_TIMEOUT", &c.Oracle.HTTPTimeout)
+	e.int("ORACLE_MAX_RESPONSE_BYTES", &c.Oracle.MaxResponseBytes)
+	e.list("ORACLE_ALLOWED_HOSTS", &c.Oracle.AllowedHosts)
+	e.bool("ORACLE_ALLOW_PRIVATE", &c.Oracle.AllowPrivate)
+
+	e.string("TX_SERVICE_URL", &c.Transactions.URL)
+	e.duration("TX_SERVICE_TIMEOUT", &c.Transactions.Timeout)
+	e.int("TX_SERVICE_RETRIES", &c.Transactions.Retries)
+	e.duration("TX_SERVICE_RETRY_BACKOFF", &c.Transactions.RetryBackoff)
+	e.int("TX_SERVICE_BREAKER_FAILURES", &c.Transactions.BreakerFailures)
+	e.duration("TX_SERVICE_BREAKER_COOLDOWN", &c.Transactions.BreakerCooldown)
+	e.duration("TX_RECORD_INTERVAL", &c.Transactions.RecordInterval)
+	e.duration("TX_RECONCILE_INTERVAL", &c.Transactions.ReconcileInterval)
+
+	e.string("INGEST_STREAM", &c.Ingest.Stream)
+	e.string("INGEST_GROUP", &c.Ingest.Group)
+	e.string("INGEST_CONSUMER", &c.Ingest.Consumer)
+	e.string("INGEST_DEAD_LETTER_STREAM", &c.Ingest.DeadLetterStream)
+	e.int("INGEST_BATCH_SIZE", &c.Ingest.BatchSize)
+	e.duration("INGEST_BLOCK", &c.Ingest.Block)
+	e.int("INGEST_MAX_DELIVERIES", &c.Ingest.MaxDeliveries)
+	e.duration("INGEST_CLAIM_IDLE", &c.Ingest.ClaimIdle)
+
+	e.features("FEATURES", c.Features)
+
+	return e.errs
+}
+
+// envReader parses env variables into config fields, collecting parse errors
+type envReader struct {
+	errs []error
+}
+
+func (e *envReader) lookup(key string) (string, bool) {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return "", false
+	}
+	return value, true
+}
+
+func (e *envReader) string(key string, dst *string) {
+	if value, ok := e.lookup(key); ok {
+		*dst = value
+	}
+}
+
+func (e *envReader) int(key string, dst *int) {
+	if value, ok := e.lookup(key); ok {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			e.errs = append(e.errs, fmt.Errorf("%s must be an integer", key))
+			return
+		}
+		*dst = n
+	}
+}
+
+func (e *envReader) float(key string, dst *float64) {
+	if value, ok := e.lookup(key); ok {
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			e.errs = append(e.errs, fmt.Errorf("%s must be a number", key))
+			return
+		}
+		*dst = f
+	}
+}
+
+func (e *envReader) bool(key string, dst *bool) {
+	if value, ok := e.lookup(key); ok {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			e.errs = append(e.errs, fmt.Errorf("%s must be true or false", key))
+			return
+		}
+		*dst = b
+	}
+}
+
+func (e *envReader) duration(key string, dst *time.Duration) {
+	if value, ok := e.lookup(key); ok {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			e.errs = append(e.errs, fmt.Errorf("%s must be a duration such as 5s", key))
+			return
+		}
+		*dst = d
+	}
+}
+
+// list parses a comma-separated list
+func (e *envReader) list(key string, dst *[]string) {
+	if value, ok := e.lookup(key); ok {
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		*dst = items
+	}
+}
+
+// features parses "name=true,other=false" into the feature flag map
+func (e *envReader) features(key string, dst map[string]bool) {
+	value, ok := e.lookup(key)
+	if !ok {
+		return
+	}
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, raw, found := strings.Cut(pair, "=")
+		if !found {
+			dst[name] = true
+			continue
+		}
+		enabled, err := strconv.ParseBool(raw)
+		if err != nil {
+			e.errs = append(e.errs, fmt.Errorf("%s: flag %q must be true or false", key, name))
+			continue
+		}
+		dst[name] = enabled
+	}
+}
@@ -0,0 +1,83 @@
+package config
+
+import (
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+// Manager holds the active configuration and applies reloadable settings on demand.
+// Reloadable settings are log level and sampling, CORS allowed origins, the SSE ping
+// interval and feature flags; everything else requires a restart.
+type Manager struct {
+	path    string
+	current atomic.Pointer[Config]
+
+	mu        sync.Mutex
+	listeners []func(*Config)
+}
+
+// NewManager creates a manager serving cfg, reloading from the file at path
+func NewManager(path string, cfg *Config) *Manager {
+	m := &Manager{path: path}
+	m.current.Store(cfg)
+	return m
+}
+
+// Current returns the active configuration. Callers must not modify it.
+func (m *Manager) Current() *Config {
+	return m.current.Load()
+}
+
+// OnReload registers fn to be called with the new configuration after each successful reload
+func (m *Manager) OnReload(fn func(*Config)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.listeners = append(m.listeners, fn)
+}
+
+// Reload re-reads the config file and env, applying only reloadable settings.
+// It returns the names of changed settings that were ignored because they need a restart.
+func (m *Manager) Reload() (ignored []string, err error) {
+	loaded, err := Load(m.path)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	old := m.current.Load()
+	next := *old
+	next.Log = loaded.Log
+	next.CORS.AllowedOrigins = loaded.CORS.AllowedOrigins
+	next.SSE.PingInterval = loaded.SSE.PingInterval
+	next.Features = loaded.Features
+
+	// Anything else that differs needs a restart to take effect
+	restartOnly := map[string][2]any{
+		"server":                 {old.Server, loaded.Server},
+		"database":               {old.Database, loaded.Database},
+		"redis":                  {old.Redis, loaded.Redis},
+		"cors.allow_credentials": {old.CORS.AllowCredentials, loaded.CORS.AllowCredentials},
+		"cors.max_age":           {old.CORS.MaxAge, loaded.CORS.MaxAge},
+		"tracing":                {old.Tracing, loaded.Tracing},
+		"health":                 {old.Health, loaded.Health},
+		"auth":                   {old.Auth, loaded.Auth},
+		"rate_limit":             {old.RateLimit, loaded.RateLimit},
+		"markets":                {old.Markets, loaded.Markets},
+		"oracle":                 {old.Oracle, loaded.Oracle},
+	}
+	for name, values := range restartOnly {
+		if !reflect.DeepEqual(values[0], values[1]) {
+			ignored = append(ignored, name)
+		}
+	}
+
+	m.current.Store(&next)
+	for _, fn := range m.listeners {
+		fn(&next)
+	}
+
+	return ignored, nil
+}
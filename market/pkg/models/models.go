@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 )
 
@@ -8,11 +9,41 @@ import (
 type MarketStatus string
 
 const (
-	MarketStatusDraft     MarketStatus = "draft"
-	MarketStatusActive    MarketStatus = "active"
-	MarketStatusHidden    MarketStatus = "hidden"
+	MarketStatusDraft  MarketStatus = "draft"
+	MarketStatusActive MarketStatus = "active"
+	MarketStatusHidden MarketStatus = "hidden"
+	// MarketStatusHalted pauses trading on a market that stays listed
+	MarketStatusHalted    MarketStatus = "halted"
 	MarketStatusResolving MarketStatus = "resolving"
 	MarketStatusResolved  MarketStatus = "resolved"
+	// MarketStatusVoided ends a market without a winner; every share is refunded
+	MarketStatusVoided MarketStatus = "voided"
+)
+
+// Valid reports whether s is a known market status
+func (s MarketStatus) Valid() bool {
+	switch s {
+	case MarketStatusDraft, MarketStatusActive, MarketStatusHidden, MarketStatusHalted, MarketStatusResolving,
+		MarketStatusResolved, MarketStatusVoided:
+		return true
+	}
+	return false
+}
+
+// MarketType distinguishes markets with discrete outcomes from numeric range markets
+type MarketType string
+
+const (
+	MarketTypeCategorical MarketType = "categorical"
+	MarketTypeScalar      MarketType = "scalar"
+)
+
+// ScalarSide marks the long and short options of a scalar market
+type ScalarSide string
+
+const (
+	ScalarSideLong  ScalarSide = "long"
+	ScalarSideShort ScalarSide = "short"
 )
 
 // Market
@@ -20,20 +51,49 @@ type Market struct {
 	ID                 string          `json:"id"`
 	Title              string          `json:"title"`
 	Description        string          `json:"description"`
+	ResolutionRules    string          `json:"resolution_rules"`
+	ResolutionSource   string          `json:"resolution_source"`
+	ResolutionSpec     *ResolutionSpec `json:"resolution_spec,omitempty"`
 	Status             MarketStatus    `json:"status"`
+	Type               MarketType      `json:"type"`
+	LowerBound         *float64        `json:"lower_bound,omitempty"`
+	UpperBound         *float64        `json:"upper_bound,omitempty"`
+	ResolvedValue      *float64        `json:"resolved_value,omitempty"`
+	ImpliedValue       *float64        `json:"implied_value,omitempty"`
 	ResolutionDatetime *time.Time      `json:"resolution_datetime,omitempty"`
 	WinningOptionID    *string         `json:"winning_option_id,omitempty"`
+	ParentMarketID     *string         `json:"parent_market_id,omitempty"`
+	ConditionOptionID  *string         `json:"condition_option_id,omitempty"`
+	CategoryID         *string         `json:"category_id,omitempty"`
+	CreatedBy          string          `json:"created_by,omitempty"`
+	ReviewState        ReviewState     `json:"review_state"`
+	Tags               []string        `json:"tags"`
+	Featured           bool            `json:"featured"`
+	FeaturedRank       *int            `json:"featured_rank,omitempty"`
+	FeeRate            float64         `json:"fee_rate"`
+	MinFee             float64         `json:"min_fee"`
+	Limits             MarketLimits    `json:"limits"`
 	Options            []Option        `json:"options,omitempty"`
 	LiquidityPools     []LiquidityPool `json:"liquidity_pools,omitempty"`
 	CreatedAt          time.Time       `json:"created_at"`
 	UpdatedAt          time.Time       `json:"updated_at"`
 }
 
+// MaxFeeRate caps a market's fee rate, whether overridden or the configured default
+const MaxFeeRate = 0.1
+
 // Each market will have options
 type Option struct {
-	ID        string    `json:"id"`
-	MarketID  string    `json:"market_id"`
-	Title     string    `json:"title"`
+	ID           string     `json:"id"`
+	MarketID     string     `json:"market_id"`
+	Title        string     `json:"title"`
+	ShortLabel   *string    `json:"short_label,omitempty"`
+	ImageURL     *string    `json:"image_url,omitempty"`
+	DisplayOrder int        `json:"display_order"`
+	Side         ScalarSide `json:"side,omitempty"`
+	Price        float64    `json:"price"`
+	// Payout per share, set once the market resolves
+	Payout    *float64  `json:"payout,omitempty"`
 	CreatedAt time.Time `json:"created_at"`
 }
 
@@ -46,26 +106,813 @@ type LiquidityPool struct {
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
-// CreateMarketRequest represents the payload for creating a new market
+// CreateMarketRequest represents the payload for creating a new market.
+// Scalar markets take bounds instead of options; their long and short options
+// are created automatically, titled by Options if two are given.
 type CreateMarketRequest struct {
 	Title              string     `json:"title"`
 	Description        string     `json:"description"`
+	ResolutionRules    string     `json:"resolution_rules,omitempty"`
+	ResolutionSource   string     `json:"resolution_source,omitempty"`
+	Type               MarketType `json:"type,omitempty"`
+	LowerBound         *float64   `json:"lower_bound,omitempty"`
+	UpperBound         *float64   `json:"upper_bound,omitempty"`
 	ResolutionDatetime *time.Time `json:"resolution_datetime,omitempty"`
 	Options            []string   `json:"options"`
+	CategoryID         *string    `json:"category_id,omitempty"`
+	Tags               []string   `json:"tags,omitempty"`
+	// ParentMarketID and ConditionOptionID make the market conditional: it can only
+	// go active once the parent resolves to that option and is voided otherwise
+	ParentMarketID    *string `json:"parent_market_id,omitempty"`
+	ConditionOptionID *string `json:"condition_option_id,omitempty"`
+	// ResolutionSpec lets an oracle propose the outcome once the market is resolving
+	ResolutionSpec *ResolutionSpec `json:"resolution_spec,omitempty"`
+	// FeeRate and MinFee override the default trading fees (admins only)
+	FeeRate *float64 `json:"fee_rate,omitempty"`
+	MinFee  *float64 `json:"min_fee,omitempty"`
+	// Limits override the default risk limits (admins only)
+	Limits *MarketLimits `json:"limits,omitempty"`
+}
+
+// MarketLimits caps the risk a market takes on. Unset limits fall back to the
+// platform defaults.
+type MarketLimits struct {
+	// MaxPosition caps the shares of an option one user may hold, counting open buy orders
+	MaxPosition *float64 `json:"max_position,omitempty"`
+	// MaxTradeShares caps the shares of a single order
+	MaxTradeShares *float64 `json:"max_trade_shares,omitempty"`
+	// MaxTreasuryExposure caps what the treasury stands to lose on the market's outcome
+	MaxTreasuryExposure *float64 `json:"max_treasury_exposure,omitempty"`
+}
+
+// ResolutionSpec names the resolver that fetches a market's outcome and its settings,
+// e.g. {"resolver": "http_json", "config": {"url": "...", "path": "data.price"}}
+type ResolutionSpec struct {
+	Resolver string          `json:"resolver"`
+	Config   json.RawMessage `json:"config,omitempty"`
+}
+
+// CreateOptionRequest represents the payload for adding an option to a draft market
+type CreateOptionRequest struct {
+	Title      string  `json:"title"`
+	ShortLabel *string `json:"short_label,omitempty"`
+	ImageURL   *string `json:"image_url,omitempty"`
+}
+
+// UpdateOptionRequest represents the payload for editing an option of a draft market.
+// An empty ShortLabel or ImageURL clears it.
+type UpdateOptionRequest struct {
+	Title      *string `json:"title,omitempty"`
+	ShortLabel *string `json:"short_label,omitempty"`
+	ImageURL   *string `json:"image_url,omitempty"`
 }
 
-// UpdateMarketRequest represents the payload for updating a market
+// ReorderOptionsRequest lists every option of a market in its new display order
+type ReorderOptionsRequest struct {
+	OptionIDs []string `json:"option_ids"`
+}
+
+// UpdateMarketRequest represents the payload for updating a market. Title,
+// description and resolution texts can only change while the market is draft or hidden.
 type UpdateMarketRequest struct {
-	Status             *MarketStatus `json:"status,omitempty"`
-	WinningOptionID    *string       `json:"winning_option_id,omitempty"`
-	ResolutionDatetime *time.Time    `json:"resolution_datetime,omitempty"`
+	Title              *string         `json:"title,omitempty"`
+	Description        *string         `json:"description,omitempty"`
+	ResolutionRules    *string         `json:"resolution_rules,omitempty"`
+	ResolutionSource   *string         `json:"resolution_source,omitempty"`
+	ResolutionSpec     *ResolutionSpec `json:"resolution_spec,omitempty"`
+	Status             *MarketStatus   `json:"status,omitempty"`
+	WinningOptionID    *string         `json:"winning_option_id,omitempty"`
+	ResolvedValue      *float64        `json:"resolved_value,omitempty"`
+	ResolutionDatetime *time.Time      `json:"resolution_datetime,omitempty"`
+	CategoryID         *string         `json:"category_id,omitempty"`
+	// Tags replaces all tags when present
+	Tags *[]string `json:"tags,omitempty"`
+	// Featured pins (true) or unpins (false) the market on the homepage
+	Featured     *bool `json:"featured,omitempty"`
+	FeaturedRank *int  `json:"featured_rank,omitempty"`
+	// FeeRate and MinFee change the trading fees (admins only, draft or hidden)
+	FeeRate *float64 `json:"fee_rate,omitempty"`
+	MinFee  *float64 `json:"min_fee,omitempty"`
+	// Subsidy funds the pool from the treasury as a draft goes active (admins only)
+	Subsidy *MarketSubsidy `json:"subsidy,omitempty"`
+	// Limits replaces the market's risk limits; unset limits revert to the defaults (admins only)
+	Limits *MarketLimits `json:"limits,omitempty"`
+}
+
+// MarketSubsidy is the treasury's initial liquidity for a market. Probabilities
+// sets each option's starting price, keyed by option ID; options are priced equally
+// when it is omitted.
+type MarketSubsidy struct {
+	Amount        float64            `json:"amount"`
+	Probabilities map[string]float64 `json:"probabilities,omitempty"`
+}
+
+// ChangesText reports whether the request edits the market's title, description or resolution texts
+func (r UpdateMarketRequest) ChangesText() bool {
+	return r.Title != nil || r.Description != nil || r.ResolutionRules != nil || r.ResolutionSource != nil
+}
+
+// MarketRevision holds a market's texts as they were before an edit
+type MarketRevision struct {
+	ID               string    `json:"id"`
+	MarketID         string    `json:"market_id"`
+	Revision         int       `json:"revision"`
+	Title            string    `json:"title"`
+	Description      string    `json:"description"`
+	ResolutionRules  string    `json:"resolution_rules"`
+	ResolutionSource string    `json:"resolution_source"`
+	EditedBy         string    `json:"edited_by,omitempty"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// Response for market revision listing
+type MarketRevisionListResponse struct {
+	Revisions []MarketRevision `json:"revisions"`
+	Total     int              `json:"total"`
+}
+
+// ReviewState tracks a draft market through review
+type ReviewState string
+
+const (
+	ReviewStateUnsubmitted ReviewState = "unsubmitted"
+	ReviewStatePending     ReviewState = "pending"
+	ReviewStateApproved    ReviewState = "approved"
+	ReviewStateRejected    ReviewState = "rejected"
+)
+
+// ReviewAction is an entry in a market's review history
+type ReviewAction string
+
+const (
+	ReviewActionSubmit  ReviewAction = "submit"
+	ReviewActionComment ReviewAction = "comment"
+	ReviewActionApprove ReviewAction = "approve"
+	ReviewActionReject  ReviewAction = "reject"
+	// ReviewActionReset is recorded when an edit sends a submitted market back to its creator
+	ReviewActionReset ReviewAction = "reset"
+)
+
+// MarketReview is one entry of a market's review history
+type MarketReview struct {
+	ID         string       `json:"id"`
+	MarketID   string       `json:"market_id"`
+	ReviewerID string       `json:"reviewer_id"`
+	Action     ReviewAction `json:"action"`
+	Comment    string       `json:"comment,omitempty"`
+	CreatedAt  time.Time    `json:"created_at"`
+}
+
+// Request to comment on, approve or reject a market. Rejections need a comment
+// giving the reason.
+type CreateReviewRequest struct {
+	Action  ReviewAction `json:"action"`
+	Comment string       `json:"comment"`
+}
+
+// Response for market review history
+type MarketReviewListResponse struct {
+	Reviews []MarketReview `json:"reviews"`
+	Total   int            `json:"total"`
+}
+
+// ReviewQueueItem is a market awaiting review
+type ReviewQueueItem struct {
+	Market      Market    `json:"market"`
+	Approvals   int       `json:"approvals"`
+	SubmittedAt time.Time `json:"submitted_at"`
+}
+
+// Response for the moderation queue
+type ReviewQueueResponse struct {
+	Items             []ReviewQueueItem `json:"items"`
+	Total             int               `json:"total"`
+	RequiredApprovals int               `json:"required_approvals"`
+}
+
+// ProposalStatus tracks a proposed resolution through its dispute window
+type ProposalStatus string
+
+const (
+	// ProposalStatusOpen proposals can be disputed until their deadline
+	ProposalStatusOpen     ProposalStatus = "open"
+	ProposalStatusDisputed ProposalStatus = "disputed"
+	// ProposalStatusFinalized proposals resolved their market
+	ProposalStatusFinalized ProposalStatus = "finalized"
+	// ProposalStatusOverturned proposals were replaced by an arbitrated outcome
+	ProposalStatusOverturned ProposalStatus = "overturned"
+	// ProposalStatusCancelled proposals were dropped because the market was voided
+	ProposalStatusCancelled ProposalStatus = "cancelled"
+)
+
+// DisputeStatus records what happened to a dispute and its bond
+type DisputeStatus string
+
+const (
+	DisputeStatusPending DisputeStatus = "pending"
+	// DisputeStatusUpheld disputes overturned the proposal; the bond is returned
+	DisputeStatusUpheld DisputeStatus = "upheld"
+	// DisputeStatusRejected disputes lost arbitration; the bond is forfeited
+	DisputeStatusRejected DisputeStatus = "rejected"
+	// DisputeStatusReturned disputes ended without arbitration; the bond is returned
+	DisputeStatusReturned DisputeStatus = "returned"
+)
+
+// ResolutionProposal is an outcome proposed for a resolving market
+type ResolutionProposal struct {
+	ID              string              `json:"id"`
+	MarketID        string              `json:"market_id"`
+	ProposerID      string              `json:"proposer_id"`
+	WinningOptionID *string             `json:"winning_option_id,omitempty"`
+	ResolvedValue   *float64            `json:"resolved_value,omitempty"`
+	Evidence        string              `json:"evidence"`
+	Status          ProposalStatus      `json:"status"`
+	DisputeDeadline time.Time           `json:"dispute_deadline"`
+	Disputes        []ResolutionDispute `json:"disputes"`
+	CreatedAt       time.Time           `json:"created_at"`
+	ClosedAt        *time.Time          `json:"closed_at,omitempty"`
+}
+
+// ResolutionDispute challenges a proposal, staking a bond
+type ResolutionDispute struct {
+	ID         string        `json:"id"`
+	ProposalID string        `json:"proposal_id"`
+	DisputerID string        `json:"disputer_id"`
+	Reason     string        `json:"reason"`
+	Bond       float64       `json:"bond"`
+	Status     DisputeStatus `json:"status"`
+	CreatedAt  time.Time     `json:"created_at"`
+}
+
+// Request to propose a market's outcome: winning_option_id for categorical markets,
+// resolved_value for scalar ones
+type ProposeResolutionRequest struct {
+	WinningOptionID *string  `json:"winning_option_id,omitempty"`
+	ResolvedValue   *float64 `json:"resolved_value,omitempty"`
+	Evidence        string   `json:"evidence"`
+}
+
+// Request to dispute the open proposal of a market
+type DisputeResolutionRequest struct {
+	Reason string `json:"reason"`
+}
+
+// Request to settle a disputed proposal with the arbitrated outcome
+type ArbitrateResolutionRequest struct {
+	WinningOptionID *string  `json:"winning_option_id,omitempty"`
+	ResolvedValue   *float64 `json:"resolved_value,omitempty"`
+	Reason          string   `json:"reason"`
+}
+
+// Response for a market's resolution history
+type ResolutionProposalListResponse struct {
+	Proposals []ResolutionProposal `json:"proposals"`
+	Total     int                  `json:"total"`
+}
+
+// OrderSide is whether an order buys or sells shares
+type OrderSide string
+
+const (
+	OrderSideBuy  OrderSide = "buy"
+	OrderSideSell OrderSide = "sell"
+)
+
+// OrderType distinguishes orders that rest on the book from ones that fill immediately
+type OrderType string
+
+const (
+	// OrderTypeLimit orders trade at their price or better; the unfilled rest stays on the book
+	OrderTypeLimit OrderType = "limit"
+	// OrderTypeMarket orders trade at the best available prices; the unfilled rest is cancelled
+	OrderTypeMarket OrderType = "market"
+)
+
+// OrderStatus tracks an order from placement until it is filled or cancelled
+type OrderStatus string
+
+const (
+	OrderStatusOpen            OrderStatus = "open"
+	OrderStatusPartiallyFilled OrderStatus = "partially_filled"
+	OrderStatusFilled          OrderStatus = "filled"
+	OrderStatusCancelled       OrderStatus = "cancelled"
+)
+
+// TradeVenue is where an order was filled
+type TradeVenue string
+
+const (
+	TradeVenueBook TradeVenue = "book"
+	TradeVenuePool TradeVenue = "pool"
+)
+
+// Order buys or sells shares of one option. Sell orders hold their shares until
+// they fill or are cancelled.
+type Order struct {
+	ID       string    `json:"id"`
+	MarketID string    `json:"market_id"`
+	OptionID string    `json:"option_id"`
+	UserID   string    `json:"user_id"`
+	Side     OrderSide `json:"side"`
+	Type     OrderType `json:"type"`
+	// Price is the limit price; market orders have none
+	Price        *float64    `json:"price,omitempty"`
+	Shares       float64     `json:"shares"`
+	FilledShares float64     `json:"filled_shares"`
+	Status       OrderStatus `json:"status"`
+	CreatedAt    time.Time   `json:"created_at"`
+	UpdatedAt    time.Time   `json:"updated_at"`
+}
+
+// Remaining returns the shares not filled yet
+func (o *Order) Remaining() float64 {
+	return o.Shares - o.FilledShares
+}
+
+// Trade is a fill of an incoming order against a resting order or the pool
+type Trade struct {
+	ID       string `json:"id"`
+	MarketID string `json:"market_id"`
+	OptionID string `json:"option_id"`
+	// OrderID and UserID are the incoming (taker) order and its owner
+	OrderID string     `json:"order_id"`
+	UserID  string     `json:"user_id"`
+	Side    OrderSide  `json:"side"`
+	Venue   TradeVenue `json:"venue"`
+	// MakerOrderID and MakerUserID are set on book trades
+	MakerOrderID *string `json:"maker_order_id,omitempty"`
+	MakerUserID  *string `json:"maker_user_id,omitempty"`
+	Shares       float64 `json:"shares"`
+	// Price is the average price per share
+	Price float64 `json:"price"`
+	// Fee is charged to the taker on top of (buying) or out of (selling) the trade's value
+	Fee       float64   `json:"fee"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Position is a user's holding of one option
+type Position struct {
+	UserID    string    `json:"user_id"`
+	MarketID  string    `json:"market_id"`
+	OptionID  string    `json:"option_id"`
+	Shares    float64   `json:"shares"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// LedgerKind classifies ledger entries
+type LedgerKind string
+
+const (
+	LedgerKindTrade  LedgerKind = "trade"
+	LedgerKindMint   LedgerKind = "mint"
+	LedgerKindRedeem LedgerKind = "redeem"
+	LedgerKindFee    LedgerKind = "fee"
+	// LedgerKindLiquidity moves collateral between liquidity providers and the pool
+	LedgerKindLiquidity LedgerKind = "liquidity"
+	// LedgerKindBond stakes, returns and forfeits dispute bonds
+	LedgerKindBond LedgerKind = "bond"
+	// LedgerKindReconciliation balances pools and positions repaired by reconciliation
+	LedgerKindReconciliation LedgerKind = "reconciliation"
+)
+
+// Ledger account prefixes; the market or user ID follows
+const (
+	// LedgerAccountPoolPrefix prefixes the account holding a market pool's collateral
+	LedgerAccountPoolPrefix = "pool:"
+	// LedgerAccountCollateralPrefix prefixes the account holding the collateral behind
+	// a market's minted complete sets
+	LedgerAccountCollateralPrefix = "collateral:"
+	// LedgerAccountUserPrefix prefixes user accounts
+	LedgerAccountUserPrefix = "user:"
+	// LedgerAccountLPFeesPrefix prefixes the account collecting a market's fees owed
+	// to its liquidity providers
+	LedgerAccountLPFeesPrefix = "lp_fees:"
+	// LedgerAccountBondsPrefix prefixes the account escrowing the bonds of a market's
+	// pending disputes
+	LedgerAccountBondsPrefix = "bonds:"
+)
+
+// LedgerAccountPlatform collects the platform's share of fees and forfeited bonds
+const LedgerAccountPlatform = "platform"
+
+// LedgerAccountReconciliation funds, or takes back, the value of the shares that
+// reconciliation repairs add to or remove from markets
+const LedgerAccountReconciliation = "reconciliation"
+
+// TreasuryUserID is the reserved user holding the platform treasury's liquidity and
+// positions; no request can act as it
+const TreasuryUserID = "treasury"
+
+// LedgerEntry moves collateral into (positive Amount) or out of an account. The
+// entries written for one event sum to zero.
+type LedgerEntry struct {
+	ID       string     `json:"id"`
+	Account  string     `json:"account"`
+	MarketID string     `json:"market_id"`
+	Kind     LedgerKind `json:"kind"`
+	Amount   float64    `json:"amount"`
+	// RefID is the trade or other record the entry belongs to
+	RefID     string    `json:"ref_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CompleteSetAction is whether complete sets are minted or redeemed
+type CompleteSetAction string
+
+const (
+	CompleteSetMint   CompleteSetAction = "mint"
+	CompleteSetRedeem CompleteSetAction = "redeem"
+)
+
+// CompleteSet exchanges collateral for one share of every option of a market, or
+// back. A complete set always pays out exactly 1, so Shares sets cost Shares.
+type CompleteSet struct {
+	ID        string            `json:"id"`
+	MarketID  string            `json:"market_id"`
+	UserID    string            `json:"user_id"`
+	Action    CompleteSetAction `json:"action"`
+	Shares    float64           `json:"shares"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+// CompleteSetRequest represents the payload for minting or redeeming complete sets
+type CompleteSetRequest struct {
+	Shares float64 `json:"shares"`
+}
+
+// CompleteSetResult is a mint or redeem with the user's positions in the market after it
+type CompleteSetResult struct {
+	Set       CompleteSet `json:"set"`
+	Positions []Position  `json:"positions"`
+}
+
+// LiquidityAction is what a liquidity event did
+type LiquidityAction string
+
+const (
+	LiquidityActionAdd    LiquidityAction = "add"
+	LiquidityActionRemove LiquidityAction = "remove"
+	// LiquidityActionSettle pays a provider out when the market resolves or is voided
+	LiquidityActionSettle LiquidityAction = "settle"
+)
+
+// LiquidityEvent records a provider funding a market's pool or withdrawing from it.
+// Amount is the collateral paid in (add) or out (remove, settle) and Shares the LP
+// shares minted or burned; Fees is the provider's share of fees paid out with it.
+type LiquidityEvent struct {
+	ID        string          `json:"id"`
+	MarketID  string          `json:"market_id"`
+	UserID    string          `json:"user_id"`
+	Action    LiquidityAction `json:"action"`
+	Amount    float64         `json:"amount"`
+	Shares    float64         `json:"shares"`
+	Fees      float64         `json:"fees"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// LiquidityPosition is a provider's ownership of a market's pool. Value marks its
+// part of the pool reserves to current prices; FeesOwed is what it can withdraw of
+// the fees collected for liquidity providers.
+type LiquidityPosition struct {
+	MarketID  string    `json:"market_id"`
+	UserID    string    `json:"user_id"`
+	Shares    float64   `json:"shares"`
+	PoolShare float64   `json:"pool_share"`
+	Value     float64   `json:"value"`
+	FeesOwed  float64   `json:"fees_owed"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// MarketLiquidity summarizes a market's pool. PoolValue marks the reserves to
+// current prices; TotalValueLocked is all collateral the market holds in its pool,
+// LP fee and complete-set accounts.
+type MarketLiquidity struct {
+	MarketID         string    `json:"market_id"`
+	TotalShares      float64   `json:"total_shares"`
+	Providers        int       `json:"providers"`
+	PoolValue        float64   `json:"pool_value"`
+	FeesCollected    float64   `json:"fees_collected"`
+	TotalValueLocked float64   `json:"total_value_locked"`
+	Timestamp        time.Time `json:"timestamp"`
+}
+
+// AddLiquidityRequest represents the payload for funding a market's pool
+type AddLiquidityRequest struct {
+	Amount float64 `json:"amount"`
+}
+
+// RemoveLiquidityRequest represents the payload for burning LP shares
+type RemoveLiquidityRequest struct {
+	Shares float64 `json:"shares"`
+}
+
+// LiquidityResult is a liquidity event with the provider's LP position and its
+// option positions in the market after it
+type LiquidityResult struct {
+	Event     LiquidityEvent    `json:"event"`
+	Provider  LiquidityPosition `json:"provider"`
+	Positions []Position        `json:"positions"`
+}
+
+// Response for LP position listing
+type LiquidityPositionListResponse struct {
+	Positions []LiquidityPosition `json:"positions"`
+	Total     int                 `json:"total"`
+}
+
+// HaltCause records what halted trading on a market
+type HaltCause string
+
+const (
+	HaltCauseManual HaltCause = "manual"
+	// HaltCauseCircuitBreaker halts a market whose price moved too far too fast
+	HaltCauseCircuitBreaker HaltCause = "circuit_breaker"
+)
+
+// MarketHalt is one trading halt of a market. Halts with ResumeAt resume on their
+// own once it passes; the others wait for an admin.
+type MarketHalt struct {
+	ID        string     `json:"id"`
+	MarketID  string     `json:"market_id"`
+	Cause     HaltCause  `json:"cause"`
+	Reason    string     `json:"reason"`
+	HaltedBy  string     `json:"halted_by,omitempty"`
+	HaltedAt  time.Time  `json:"halted_at"`
+	ResumeAt  *time.Time `json:"resume_at,omitempty"`
+	ResumedAt *time.Time `json:"resumed_at,omitempty"`
+	ResumedBy string     `json:"resumed_by,omitempty"`
+}
+
+// HaltMarketRequest halts trading on an active market, until ResumeAt when it is set
+type HaltMarketRequest struct {
+	Reason   string     `json:"reason"`
+	ResumeAt *time.Time `json:"resume_at,omitempty"`
+}
+
+// Response for halt history listing
+type MarketHaltListResponse struct {
+	Halts []MarketHalt `json:"halts"`
+	Total int          `json:"total"`
+}
+
+// MarketExposure is a market's risk measured against its effective limits, where
+// a nil limit is unlimited. The treasury's exposure is what it would lose if the
+// option worst for it won: what it put into the market less its LP share of that
+// option's reserve and its own shares of it.
+type MarketExposure struct {
+	MarketID         string       `json:"market_id"`
+	Title            string       `json:"title"`
+	Status           MarketStatus `json:"status"`
+	Limits           MarketLimits `json:"limits"`
+	TreasuryInvested float64      `json:"treasury_invested"`
+	TreasuryExposure float64      `json:"treasury_exposure"`
+	// LargestPositions lists the biggest user positions in the market, largest first
+	LargestPositions []Position `json:"largest_positions"`
+}
+
+// ExposureReport lists the exposure of every open market
+type ExposureReport struct {
+	Markets          []MarketExposure `json:"markets"`
+	Total            int              `json:"total"`
+	TreasuryInvested float64          `json:"treasury_invested"`
+	TreasuryExposure float64          `json:"treasury_exposure"`
+	Timestamp        time.Time        `json:"timestamp"`
+}
+
+// ReconciliationReport compares markets' pools and positions with the trades the
+// transaction service recorded for them
+type ReconciliationReport struct {
+	Markets []MarketReconciliation `json:"markets"`
+	// Discrepancies counts the pools and positions that differ from the replay
+	Discrepancies int       `json:"discrepancies"`
+	Repaired      bool      `json:"repaired"`
+	Timestamp     time.Time `json:"timestamp"`
+}
+
+// MarketReconciliation is one market's replay. Trades not yet recorded are left
+// out; transactions are matched to the market's trades by ID.
+type MarketReconciliation struct {
+	MarketID      string       `json:"market_id"`
+	Status        MarketStatus `json:"status"`
+	Transactions  int          `json:"transactions"`
+	PendingTrades int          `json:"pending_trades"`
+	// MissingTransactions were expected for recorded trades but are not there,
+	// UnexpectedTransactions match no trade of the market and MismatchedTransactions
+	// differ from the trade they record
+	MissingTransactions    []string              `json:"missing_transactions,omitempty"`
+	UnexpectedTransactions []string              `json:"unexpected_transactions,omitempty"`
+	MismatchedTransactions []string              `json:"mismatched_transactions,omitempty"`
+	Pools                  []PoolDiscrepancy     `json:"pools,omitempty"`
+	Positions              []PositionDiscrepancy `json:"positions,omitempty"`
+}
+
+// PoolDiscrepancy is an option reserve that differs from the replayed one
+type PoolDiscrepancy struct {
+	OptionID   string  `json:"option_id"`
+	PoolValue  float64 `json:"pool_value"`
+	Expected   float64 `json:"expected"`
+	Difference float64 `json:"difference"`
+	Repaired   bool    `json:"repaired"`
+}
+
+// PositionDiscrepancy is a user's position that differs from the replayed one.
+// UserID is the transaction service's UUID for users the market does not know.
+type PositionDiscrepancy struct {
+	UserID     string  `json:"user_id"`
+	OptionID   string  `json:"option_id"`
+	Shares     float64 `json:"shares"`
+	Expected   float64 `json:"expected"`
+	Difference float64 `json:"difference"`
+	Repaired   bool    `json:"repaired"`
+}
+
+// PlaceOrderRequest represents the payload for placing an order. Limit orders need
+// a price between 0 and 1; market orders must not have one.
+type PlaceOrderRequest struct {
+	OptionID string    `json:"option_id"`
+	Side     OrderSide `json:"side"`
+	Type     OrderType `json:"type"`
+	Shares   float64   `json:"shares"`
+	Price    *float64  `json:"price,omitempty"`
+}
+
+// OrderResult is an order after matching with the trades it made
+type OrderResult struct {
+	Order  Order   `json:"order"`
+	Trades []Trade `json:"trades"`
+}
+
+// Quote estimates what an order would fill at the current book and pool without
+// placing it. Total is what a buy costs or a sell returns after fees.
+type Quote struct {
+	MarketID     string    `json:"market_id"`
+	OptionID     string    `json:"option_id"`
+	Side         OrderSide `json:"side"`
+	Shares       float64   `json:"shares"`
+	FilledShares float64   `json:"filled_shares"`
+	AveragePrice float64   `json:"average_price"`
+	Amount       float64   `json:"amount"`
+	Fee          float64   `json:"fee"`
+	Total        float64   `json:"total"`
+	Timestamp    time.Time `json:"timestamp"`
+}
+
+// FeeSummary totals the fees collected on a market's trades and who received them
+type FeeSummary struct {
+	MarketID           string  `json:"market_id"`
+	FeeRate            float64 `json:"fee_rate"`
+	MinFee             float64 `json:"min_fee"`
+	Trades             int     `json:"trades"`
+	Total              float64 `json:"total"`
+	Platform           float64 `json:"platform"`
+	Creator            float64 `json:"creator"`
+	LiquidityProviders float64 `json:"liquidity_providers"`
+}
+
+// Response for order listing
+type OrderListResponse struct {
+	Orders []Order `json:"orders"`
+	Total  int     `json:"total"`
+}
+
+// Response for position listing
+type PositionListResponse struct {
+	Positions []Position `json:"positions"`
+	Total     int        `json:"total"`
+}
+
+// BookLevel aggregates the resting orders at one price
+type BookLevel struct {
+	Price  float64 `json:"price"`
+	Shares float64 `json:"shares"`
+	Orders int     `json:"orders"`
+}
+
+// OptionBook is the depth of one option's book: bids best (highest) first, asks
+// best (lowest) first. PoolPrice is the pool's marginal price when it has liquidity.
+type OptionBook struct {
+	OptionID  string      `json:"option_id"`
+	Bids      []BookLevel `json:"bids"`
+	Asks      []BookLevel `json:"asks"`
+	PoolPrice *float64    `json:"pool_price,omitempty"`
+}
+
+// OrderBook is the depth of every option of a market
+type OrderBook struct {
+	MarketID     string            `json:"market_id"`
+	Options      []OptionBook      `json:"options"`
+	Timestamp    time.Time         `json:"timestamp"`
+	TraceContext map[string]string `json:"trace_context,omitempty"`
+}
+
+// MarketFilter narrows market listings
+type MarketFilter struct {
+	Status *MarketStatus
+	// ParentMarketID matches markets conditional on the given market
+	ParentMarketID *string
+	// CategoryID matches the category and all of its descendants
+	CategoryID *string
+	// Tags matches markets carrying every listed tag
+	Tags     []string
+	Featured *bool
+}
+
+// Category groups markets; categories nest through ParentID (e.g. Crypto > Bitcoin)
+type Category struct {
+	ID        string    `json:"id"`
+	ParentID  *string   `json:"parent_id,omitempty"`
+	Name      string    `json:"name"`
+	Slug      string    `json:"slug"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// CreateCategoryRequest represents the payload for creating a category
+type CreateCategoryRequest struct {
+	Name     string  `json:"name"`
+	Slug     string  `json:"slug"`
+	ParentID *string `json:"parent_id,omitempty"`
+}
+
+// UpdateCategoryRequest represents the payload for updating a category
+type UpdateCategoryRequest struct {
+	Name *string `json:"name,omitempty"`
+	Slug *string `json:"slug,omitempty"`
+	// ParentID moves the category; an empty string makes it top-level
+	ParentID *string `json:"parent_id,omitempty"`
+}
+
+// Response for category listing
+type CategoryListResponse struct {
+	Categories []Category `json:"categories"`
+	Total      int        `json:"total"`
+}
+
+// CategoryCount is the number of markets in a category for facet navigation
+type CategoryCount struct {
+	CategoryID string  `json:"category_id"`
+	ParentID   *string `json:"parent_id,omitempty"`
+	Name       string  `json:"name"`
+	Slug       string  `json:"slug"`
+	// Count is markets filed directly under the category
+	Count int `json:"count"`
+	// Total also includes markets in descendant categories
+	Total int `json:"total"`
+}
+
+// TagCount is the number of markets carrying a tag
+type TagCount struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+// MarketFacets holds per-category and per-tag market counts for a filter
+type MarketFacets struct {
+	Categories    []CategoryCount `json:"categories"`
+	Uncategorized int             `json:"uncategorized"`
+	Tags          []TagCount      `json:"tags"`
 }
 
 // LiquidityUpdate represents a liquidity pool update published to Redis
 type LiquidityUpdate struct {
-	MarketID       string          `json:"market_id"`
-	LiquidityPools []LiquidityPool `json:"liquidity_pools"`
-	Timestamp      time.Time       `json:"timestamp"`
+	MarketID       string            `json:"market_id"`
+	LiquidityPools []LiquidityPool   `json:"liquidity_pools"`
+	Timestamp      time.Time         `json:"timestamp"`
+	TraceContext   map[string]string `json:"trace_context,omitempty"`
+}
+
+// TradeEvent sets a liquidity pool's value after a trade made by another service.
+// Services push it to the ingest Redis Stream; it is applied once per EventID, and
+// only if its Sequence is above that of the last event applied to the pool.
+type TradeEvent struct {
+	EventID   string  `json:"event_id"`
+	MarketID  string  `json:"market_id"`
+	PoolID    string  `json:"pool_id"`
+	PoolValue float64 `json:"pool_value"`
+	Sequence  int64   `json:"sequence"`
+}
+
+// TradingUpdate is published when trading on a market halts or resumes
+type TradingUpdate struct {
+	MarketID     string            `json:"market_id"`
+	Halted       bool              `json:"halted"`
+	Halt         MarketHalt        `json:"halt"`
+	Timestamp    time.Time         `json:"timestamp"`
+	TraceContext map[string]string `json:"trace_context,omitempty"`
+}
+
+// Market stream event names, sent as the SSE event field
+const (
+	StreamEventLiquidityUpdate = "liquidity-update"
+	StreamEventBookUpdate      = "book-update"
+	StreamEventTradingHalted   = "trading-halted"
+	StreamEventTradingResumed  = "trading-resumed"
+)
+
+// StreamEvent is a market event delivered to stream subscribers
+type StreamEvent struct {
+	Event string
+	Data  interface{}
 }
 
 // Response for market listing
@@ -74,8 +921,44 @@ type MarketListResponse struct {
 	Total   int      `json:"total"`
 }
 
+// MarketStats holds aggregate figures across all markets
+type MarketStats struct {
+	MarketsByStatus map[MarketStatus]int `json:"markets_by_status"`
+	TotalLiquidity  float64              `json:"total_liquidity"`
+}
+
+// Machine-readable error codes returned in ErrorResponse.Code
+const (
+	ErrorCodeInvalidRequest     = "invalid_request"
+	ErrorCodeInvalidBody        = "invalid_body"
+	ErrorCodeValidationFailed   = "validation_failed"
+	ErrorCodeNotFound           = "not_found"
+	ErrorCodeInvalidTransition  = "invalid_transition"
+	ErrorCodeNotEditable        = "not_editable"
+	ErrorCodeConflict           = "conflict"
+	ErrorCodeMarketClosed       = "market_closed"
+	ErrorCodeInsufficientShares = "insufficient_shares"
+	ErrorCodeOpenOrderLimit     = "open_order_limit_exceeded"
+	ErrorCodePositionLimit      = "position_limit_exceeded"
+	ErrorCodeTradeSizeLimit     = "trade_size_limit_exceeded"
+	ErrorCodeExposureLimit      = "exposure_limit_exceeded"
+	ErrorCodeUnauthenticated    = "unauthenticated"
+	ErrorCodeForbidden          = "forbidden"
+	ErrorCodeRateLimited        = "rate_limited"
+	ErrorCodeUnavailable        = "unavailable"
+	ErrorCodeInternal           = "internal"
+)
+
 // Error Response
 type ErrorResponse struct {
-	Error   string `json:"error"`
-	Message string `json:"message,omitempty"`
+	Error   string       `json:"error"`
+	Code    string       `json:"code"`
+	Message string       `json:"message,omitempty"`
+	Details []FieldError `json:"details,omitempty"`
+}
+
+// FieldError describes why a single request field was rejected
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
 }
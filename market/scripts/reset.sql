@@ -1,10 +1,26 @@
 -- Drop tables in reverse order of dependencies
+DROP TABLE IF EXISTS price_history CASCADE;
+DROP TABLE IF EXISTS market_halts CASCADE;
+DROP TABLE IF EXISTS liquidity_events CASCADE;
+DROP TABLE IF EXISTS liquidity_providers CASCADE;
+DROP TABLE IF EXISTS complete_sets CASCADE;
+DROP TABLE IF EXISTS ledger_entries CASCADE;
+DROP TABLE IF EXISTS positions CASCADE;
+DROP TABLE IF EXISTS trades CASCADE;
+DROP TABLE IF EXISTS orders CASCADE;
+DROP TABLE IF EXISTS resolution_disputes CASCADE;
+DROP TABLE IF EXISTS resolution_proposals CASCADE;
+DROP TABLE IF EXISTS market_reviews CASCADE;
+DROP TABLE IF EXISTS market_revisions CASCADE;
+DROP TABLE IF EXISTS market_tags CASCADE;
 DROP TABLE IF EXISTS liquidity_pool CASCADE;
 DROP TABLE IF EXISTS options CASCADE;
 DROP TABLE IF EXISTS markets CASCADE;
+DROP TABLE IF EXISTS categories CASCADE;
+DROP TABLE IF EXISTS schema_migrations CASCADE;
 
 -- Schema will be recreated by the application's InitSchema function
--- Reference schema (matches repository.InitSchema):
+-- Reference schema (matches migration 1 in repository/schema.go; later migrations are only in schema.go):
 /*
 CREATE TABLE IF NOT EXISTS markets (
     id UUID PRIMARY KEY,
@@ -58,3 +58,25 @@ INSERT INTO liquidity_pool (id, market_id, option_id, pool_value, updated_at) VA
 ('750e8400-e29b-41d4-a716-446655440005', '550e8400-e29b-41d4-a716-446655440003', '650e8400-e29b-41d4-a716-446655440005', 30000.00, NOW()),
 ('750e8400-e29b-41d4-a716-446655440006', '550e8400-e29b-41d4-a716-446655440003', '650e8400-e29b-41d4-a716-446655440006', 30000.00, NOW());
 
+
+-- Insert categories (Science and Crypto are top-level, Bitcoin sits under Crypto)
+INSERT INTO categories (id, parent_id, name, slug, created_at, updated_at) VALUES
+('850e8400-e29b-41d4-a716-446655440001', NULL, 'Science', 'science', NOW(), NOW()),
+('850e8400-e29b-41d4-a716-446655440002', NULL, 'Crypto', 'crypto', NOW(), NOW()),
+('850e8400-e29b-41d4-a716-446655440003', '850e8400-e29b-41d4-a716-446655440002', 'Bitcoin', 'bitcoin', NOW(), NOW()),
+('850e8400-e29b-41d4-a716-446655440004', '850e8400-e29b-41d4-a716-446655440001', 'AI', 'ai', NOW(), NOW());
+
+UPDATE markets SET category_id = '850e8400-e29b-41d4-a716-446655440001', featured_rank = 0 WHERE id = '550e8400-e29b-41d4-a716-446655440001';
+UPDATE markets SET category_id = '850e8400-e29b-41d4-a716-446655440003' WHERE id = '550e8400-e29b-41d4-a716-446655440002';
+UPDATE markets SET category_id = '850e8400-e29b-41d4-a716-446655440004' WHERE id = '550e8400-e29b-41d4-a716-446655440003';
+
+-- Insert tags
+INSERT INTO market_tags (market_id, tag) VALUES
+('550e8400-e29b-41d4-a716-446655440001', 'spacex'),
+('550e8400-e29b-41d4-a716-446655440001', 'mars'),
+('550e8400-e29b-41d4-a716-446655440002', 'btc'),
+('550e8400-e29b-41d4-a716-446655440002', 'price'),
+('550e8400-e29b-41d4-a716-446655440003', 'ai');
+
+-- Sample markets are already live, so they count as reviewed
+UPDATE markets SET review_state = 'approved' WHERE status <> 'draft';